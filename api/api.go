@@ -0,0 +1,325 @@
+// Package api serves a local HTTP API for managing the discussion queue and the meme stash from scripts.  Requests
+// carry the configured token.  Changes go through the same checks as chat commands, hold the same locks, and are
+// journaled, audited, published and saved the same way, by executing them through the registry.
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	gb "github.com/ericebersohl/gobottas"
+	"github.com/ericebersohl/gobottas/discord"
+	"github.com/ericebersohl/gobottas/discussion"
+	"github.com/ericebersohl/gobottas/meme"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// The name changes made through the API are credited to, in the journal, the audit log and events
+const User = "api"
+
+// Largest request body accepted
+const MaxBody = 1 << 20
+
+// Serves the API under /api/
+type Server struct {
+	token    string
+	queues   *discussion.Queues
+	stashes  *meme.Stashes
+	registry gb.Registry
+	session  gb.Session
+	log      *gb.Logger
+	guilds   func() []gb.Snowflake // the guilds the bot is in; nil takes any guild
+}
+
+type Opt func(*Server)
+
+// Serve every guild's queue and stash to requests with token.  Changes are executed through r, which saves, audits
+// and publishes them, and sends any responses, like a submitter's notice that their meme was approved, through s.
+func New(token string, qs *discussion.Queues, ss *meme.Stashes, r gb.Registry, s gb.Session, opts ...Opt) *Server {
+	srv := Server{
+		token:    token,
+		queues:   qs,
+		stashes:  ss,
+		registry: r,
+		session:  s,
+	}
+
+	for _, o := range opts {
+		o(&srv)
+	}
+
+	return &srv
+}
+
+func WithLogger(l *gb.Logger) Opt {
+	return func(s *Server) {
+		s.log = l
+	}
+}
+
+// only serve the guilds f reports the bot is in, such as those in the discord session's state
+func WithGuilds(f func() []gb.Snowflake) Opt {
+	return func(s *Server) {
+		s.guilds = f
+	}
+}
+
+// What the API says when a request fails
+type problem struct {
+	Error   string `json:"error"`   // short name, e.g. "Topic Not Found"
+	Message string `json:"message"` // what went wrong
+}
+
+// Route a request.  Every guild has its own queue and stash, under /api/guilds/{guild}.  Paths are:
+//
+//	GET                /api/guilds                                   the guilds, with how much each has queued and stashed
+//	GET                .../queue                                     the queue and the archive of finished topics
+//	POST               .../queue/done                                finish the topic at the front
+//	GET, POST          .../queue/topics                              list or add topics
+//	GET, PATCH, DELETE .../queue/topics/{name}                       a topic; PATCH renames, describes or moves it
+//	POST               .../queue/topics/{name}/sources               attach a source
+//	DELETE             .../queue/topics/{name}/sources/{n}?url=...   detach a source, named by its url
+//	GET, POST          .../memes                                     list or add memes
+//	GET, DELETE        .../memes/{n}                                 a meme; DELETE names it, ?meme=...
+//	GET                .../submissions                               memes waiting for approval
+//	POST               .../submissions/{id}/approve, .../reject
+//
+// Memes and sources are numbered by their place in a list that chat commands change too, so deleting one by number
+// also takes what the client expects to find there, and is refused with 409 Conflict if something else is.
+func (s *Server) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if !s.authorized(req) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="gobottas"`)
+		s.fail(w, http.StatusUnauthorized, "Unauthorized", "Send the API token as a bearer token.")
+		return
+	}
+
+	path, ok := segments(req.URL.EscapedPath())
+	if !ok || len(path) < 2 || path[0] != "api" || path[1] != "guilds" {
+		s.fail(w, http.StatusNotFound, "Not Found", "There is nothing at that path.")
+		return
+	}
+	path = path[2:]
+
+	if len(path) == 0 {
+		s.allow(w, req, handlers{http.MethodGet: func(w http.ResponseWriter, req *http.Request) { s.listGuilds(w) }})
+		return
+	}
+
+	guild, err := gb.ToSnowflake(path[0])
+	if err != nil {
+		s.fail(w, http.StatusBadRequest, "Invalid Guild", "Guild ids are numbers.")
+		return
+	}
+	if !s.known(guild) {
+		s.fail(w, http.StatusNotFound, "Guild Not Found", "The bot isn't in that guild.")
+		return
+	}
+	path = path[1:]
+
+	switch {
+	case len(path) == 1 && path[0] == "queue":
+		s.allow(w, req, handlers{http.MethodGet: func(w http.ResponseWriter, req *http.Request) { s.getQueue(w, guild) }})
+	case len(path) == 2 && path[0] == "queue" && path[1] == "done":
+		s.allow(w, req, handlers{http.MethodPost: func(w http.ResponseWriter, req *http.Request) { s.finishTopic(w, guild) }})
+	case len(path) == 2 && path[0] == "queue" && path[1] == "topics":
+		s.allow(w, req, handlers{
+			http.MethodGet:  func(w http.ResponseWriter, req *http.Request) { s.listTopics(w, guild) },
+			http.MethodPost: func(w http.ResponseWriter, req *http.Request) { s.addTopic(w, req, guild) },
+		})
+	case len(path) == 3 && path[0] == "queue" && path[1] == "topics":
+		name := path[2]
+		s.allow(w, req, handlers{
+			http.MethodGet:    func(w http.ResponseWriter, req *http.Request) { s.getTopic(w, name, guild) },
+			http.MethodPatch:  func(w http.ResponseWriter, req *http.Request) { s.editTopic(w, req, name, guild) },
+			http.MethodDelete: func(w http.ResponseWriter, req *http.Request) { s.removeTopic(w, name, guild) },
+		})
+	case len(path) == 4 && path[0] == "queue" && path[1] == "topics" && path[3] == "sources":
+		name := path[2]
+		s.allow(w, req, handlers{http.MethodPost: func(w http.ResponseWriter, req *http.Request) { s.attachSource(w, req, name, guild) }})
+	case len(path) == 5 && path[0] == "queue" && path[1] == "topics" && path[3] == "sources":
+		name, n := path[2], path[4]
+		s.allow(w, req, handlers{http.MethodDelete: func(w http.ResponseWriter, req *http.Request) { s.detachSource(w, req, name, n, guild) }})
+
+	case len(path) == 1 && path[0] == "memes":
+		s.allow(w, req, handlers{
+			http.MethodGet:  func(w http.ResponseWriter, req *http.Request) { s.listMemes(w, guild) },
+			http.MethodPost: func(w http.ResponseWriter, req *http.Request) { s.addMeme(w, req, guild) },
+		})
+	case len(path) == 2 && path[0] == "memes":
+		n := path[1]
+		s.allow(w, req, handlers{
+			http.MethodGet:    func(w http.ResponseWriter, req *http.Request) { s.getMeme(w, n, guild) },
+			http.MethodDelete: func(w http.ResponseWriter, req *http.Request) { s.removeMeme(w, req, n, guild) },
+		})
+
+	case len(path) == 1 && path[0] == "submissions":
+		s.allow(w, req, handlers{http.MethodGet: func(w http.ResponseWriter, req *http.Request) { s.listSubmissions(w, guild) }})
+	case len(path) == 3 && path[0] == "submissions" && (path[2] == "approve" || path[2] == "reject"):
+		id, verdict := path[1], path[2]
+		s.allow(w, req, handlers{http.MethodPost: func(w http.ResponseWriter, req *http.Request) { s.review(w, req, id, verdict, guild) }})
+
+	default:
+		s.fail(w, http.StatusNotFound, "Not Found", "There is nothing at that path.")
+	}
+}
+
+// Handlers by method
+type handlers map[string]http.HandlerFunc
+
+// call the handler for the request's method, or refuse it
+func (s *Server) allow(w http.ResponseWriter, req *http.Request, h handlers) {
+	f, ok := h[req.Method]
+	if !ok {
+		var methods []string
+		for m := range h {
+			methods = append(methods, m)
+		}
+		w.Header().Set("Allow", strings.Join(methods, ", "))
+		s.fail(w, http.StatusMethodNotAllowed, "Method Not Allowed", fmt.Sprintf("%s is not allowed here.", req.Method))
+		return
+	}
+	f(w, req)
+}
+
+// whether the request carries the token; without a token, nothing is
+func (s *Server) authorized(req *http.Request) bool {
+	auth := req.Header.Get("Authorization")
+	if s.token == "" || !strings.HasPrefix(auth, "Bearer ") {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(auth, "Bearer ")), []byte(s.token)) == 1
+}
+
+// split an escaped path into unescaped segments, so that topic names can have slashes in them
+func segments(escaped string) ([]string, bool) {
+	var out []string
+	for _, seg := range strings.Split(strings.Trim(escaped, "/"), "/") {
+		seg, err := url.PathUnescape(seg)
+		if err != nil {
+			return nil, false
+		}
+		out = append(out, seg)
+	}
+	return out, true
+}
+
+// whether the bot is in the guild, as far as the server knows
+func (s *Server) known(guild gb.Snowflake) bool {
+	if s.guilds == nil {
+		return true
+	}
+	for _, g := range s.guilds() {
+		if g == guild {
+			return true
+		}
+	}
+	return false
+}
+
+// A message for a change made through the API, as if an admin had sent a command in the guild
+func (s *Server) message(c gb.Command, guild gb.Snowflake) *gb.Message {
+	id := gb.NewCorrelationId()
+	return &gb.Message{
+		Command:  c,
+		Source:   &gb.Source{GuildId: guild, Username: User, Moderator: true, Admin: true},
+		Response: &gb.Response{},
+		Id:       id,
+		Log:      s.log.With("cid", id, "api", true),
+	}
+}
+
+// Finish a change through the registry, which saves the queue, audits and publishes the change, and sends any
+// response.  The change has been made either way; a failure here means it may not last or be heard about.
+func (s *Server) execute(w http.ResponseWriter, msg *gb.Message) bool {
+	if err := s.registry.Execute(msg, s.session); err != nil {
+		s.fail(w, http.StatusInternalServerError, "Not Saved", fmt.Sprintf("The change was made, but saving or announcing it failed: %v", err))
+		return false
+	}
+	return true
+}
+
+// save a guild's stash, which the caller has locked
+func (s *Server) saveStash(w http.ResponseWriter, st *meme.Stash) bool {
+	if err := st.Save(st.LocalPath); err != nil {
+		s.log.Error("failed to save the meme stash", "path", st.LocalPath, "err", err)
+		s.fail(w, http.StatusInternalServerError, "Meme Save Error", err.Error())
+		return false
+	}
+	return true
+}
+
+// read a JSON body into v, refusing settings it doesn't have
+func (s *Server) decode(w http.ResponseWriter, req *http.Request, v interface{}) bool {
+	d := json.NewDecoder(http.MaxBytesReader(w, req.Body, MaxBody))
+	d.DisallowUnknownFields()
+	if err := d.Decode(v); err == io.EOF {
+		s.fail(w, http.StatusBadRequest, "Invalid Body", "The request needs a JSON body.")
+		return false
+	} else if err != nil {
+		s.fail(w, http.StatusBadRequest, "Invalid Body", err.Error())
+		return false
+	}
+	return true
+}
+
+// read a query parameter the request needs
+func (s *Server) param(w http.ResponseWriter, req *http.Request, key string) (string, bool) {
+	v, ok := req.URL.Query()[key]
+	if !ok {
+		s.fail(w, http.StatusBadRequest, "Missing Parameter", fmt.Sprintf("The request needs ?%s=.", key))
+		return "", false
+	}
+	return v[0], true
+}
+
+// write v as JSON
+func (s *Server) reply(w http.ResponseWriter, status int, v interface{}) {
+	if v == nil {
+		w.WriteHeader(status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		s.log.Warn("failed to write an api response", "err", err)
+	}
+}
+
+func (s *Server) fail(w http.ResponseWriter, status int, name, message string) {
+	s.reply(w, status, problem{Error: name, Message: message})
+}
+
+// Report an error from a change.  Errors meant for users are the client's fault; anything else is the bot's.
+func (s *Server) failErr(w http.ResponseWriter, err error) {
+	e, ok := err.(discord.Error)
+	if !ok {
+		s.log.Error("api request failed", "err", err)
+		s.fail(w, http.StatusInternalServerError, "Internal Error", err.Error())
+		return
+	}
+
+	status := http.StatusBadRequest
+	switch {
+	case strings.HasSuffix(e.Name, "Not Found"):
+		status = http.StatusNotFound
+	case strings.HasPrefix(e.Name, "Duplicate") || e.Name == "Possible Duplicate" || e.Name == "Already Submitted",
+		strings.HasSuffix(e.Name, "Changed"):
+		status = http.StatusConflict
+	}
+	s.fail(w, status, e.Name, e.Desc)
+}
+
+// parse a number from the path
+func (s *Server) number(w http.ResponseWriter, v, what string) (int, bool) {
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		s.fail(w, http.StatusBadRequest, "Invalid "+what, fmt.Sprintf("%q is not a number.", v))
+		return 0, false
+	}
+	return n, true
+}
@@ -0,0 +1,459 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/bwmarrin/discordgo"
+	gb "github.com/ericebersohl/gobottas"
+	"github.com/ericebersohl/gobottas/core"
+	"github.com/ericebersohl/gobottas/discussion"
+	"github.com/ericebersohl/gobottas/event"
+	"github.com/ericebersohl/gobottas/journal"
+	"github.com/ericebersohl/gobottas/meme"
+	"github.com/ericebersohl/gobottas/mock"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const token = "0123456789abcdef"
+
+// The guild most tests work in, and the prefix of its paths
+const (
+	guild  gb.Snowflake = 5
+	prefix              = "/api/guilds/5"
+)
+
+// A server for guilds 5 and 6 over fresh queues and stashes in a temporary directory, and the registry and session
+// behind it, with a journal.  The queue and stash are guild 5's, and its stash starts empty.
+type fixture struct {
+	dir     string
+	journal *journal.Journal
+	queues  *discussion.Queues
+	stashes *meme.Stashes
+	queue   *discussion.Queue
+	stash   *meme.Stash
+	reg     *core.Registry
+	session *mock.Session
+	events  []gb.Event
+	srv     *httptest.Server
+}
+
+func newFixture(t *testing.T) *fixture {
+	dir, err := ioutil.TempDir("", "api")
+	if err != nil {
+		t.Fatalf("temp dir: %v", err)
+	}
+
+	f := fixture{dir: dir, journal: journal.New(dir), session: mock.NewSession()}
+	f.queues = discussion.NewQueues(dir, discussion.WithJournal(f.journal))
+	f.stashes = meme.NewStashes(dir, meme.WithJournal(f.journal))
+	f.journal.Register(discussion.JournalName, f.queues)
+	f.journal.Register(meme.JournalName, f.stashes)
+	f.queue, f.stash = f.queues.Get(guild), f.stashes.Get(guild)
+	f.stash.Memes = nil
+
+	b := event.New()
+	var mu sync.Mutex
+	b.Subscribe("test", func(e gb.Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		f.events = append(f.events, e)
+	})
+
+	f.reg = core.NewRegistry(core.WithLogger(nil), core.WithPath(dir), core.WithQueues(f.queues), core.WithStashes(f.stashes), core.WithJournal(f.journal), core.WithEvents(b))
+	guilds := func() []gb.Snowflake { return []gb.Snowflake{guild, 6} }
+	f.srv = httptest.NewServer(New(token, f.queues, f.stashes, f.reg, f.session, WithGuilds(guilds)))
+	return &f
+}
+
+func (f *fixture) close() {
+	f.srv.Close()
+	_ = os.RemoveAll(f.dir)
+}
+
+// make a request with the token, returning the status and the decoded body
+func (f *fixture) do(t *testing.T, method, path, body string) (int, map[string]interface{}) {
+	req, err := http.NewRequest(method, f.srv.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := f.srv.Client().Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	var out map[string]interface{}
+	data, _ := ioutil.ReadAll(resp.Body)
+	if len(data) > 0 && data[0] == '{' {
+		_ = json.Unmarshal(data, &out)
+	}
+	return resp.StatusCode, out
+}
+
+// the names of the topics in a guild's queue, in order
+func (f *fixture) names(guild gb.Snowflake) string {
+	q := f.queues.Get(guild)
+	q.Lock()
+	defer q.Unlock()
+
+	var names []string
+	for _, t := range q.List() {
+		names = append(names, t.Name)
+	}
+	return strings.Join(names, ",")
+}
+
+/*
+Test Cases:
+- requests without the token, or with the wrong one, are refused
+- unknown paths and methods are refused, as are guilds the bot isn't in
+*/
+func TestServer_Auth(t *testing.T) {
+	f := newFixture(t)
+	defer f.close()
+
+	for _, auth := range []string{"", "Bearer wrong", token} {
+		req, _ := http.NewRequest(http.MethodGet, f.srv.URL+prefix+"/queue", nil)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		resp, err := f.srv.Client().Do(req)
+		if err != nil {
+			t.Fatalf("get: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("auth %q: status %d, want 401", auth, resp.StatusCode)
+		}
+	}
+
+	if status, _ := f.do(t, http.MethodGet, "/api/nothing", ""); status != http.StatusNotFound {
+		t.Errorf("unknown path: status %d", status)
+	}
+	if status, _ := f.do(t, http.MethodPut, prefix+"/queue/topics", ""); status != http.StatusMethodNotAllowed {
+		t.Errorf("unknown method: status %d", status)
+	}
+	if status, body := f.do(t, http.MethodGet, "/api/guilds/7/queue", ""); status != http.StatusNotFound || body["error"] != "Guild Not Found" {
+		t.Errorf("other guild: status %d (%v)", status, body)
+	}
+	if status, body := f.do(t, http.MethodGet, "/api/guilds/five/queue", ""); status != http.StatusBadRequest || body["error"] != "Invalid Guild" {
+		t.Errorf("bad guild: status %d (%v)", status, body)
+	}
+}
+
+/*
+Test Cases:
+- the guilds are listed with how much each has queued, stashed and waiting
+- a guild's topics and memes aren't in another guild's queue and stash
+*/
+func TestServer_Guilds(t *testing.T) {
+	f := newFixture(t)
+	defer f.close()
+
+	if status, _ := f.do(t, "POST", prefix+"/queue/topics", `{"name": "a"}`); status != 201 {
+		t.Fatalf("add topic: status %d", status)
+	}
+	if status, _ := f.do(t, "POST", prefix+"/memes", `{"meme": "Bwoah"}`); status != 201 {
+		t.Fatalf("add meme: status %d", status)
+	}
+	if status, _ := f.do(t, "POST", "/api/guilds/6/queue/topics", `{"name": "b"}`); status != 201 {
+		t.Fatalf("add topic in 6: status %d", status)
+	}
+
+	req, _ := http.NewRequest("GET", f.srv.URL+"/api/guilds", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := f.srv.Client().Do(req)
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	var guilds []guildSummary
+	_ = json.NewDecoder(resp.Body).Decode(&guilds)
+	resp.Body.Close()
+
+	want := []guildSummary{{Id: "5", Topics: 1, Memes: 1}, {Id: "6", Topics: 1, Memes: 3}}
+	if fmt.Sprint(guilds) != fmt.Sprint(want) {
+		t.Errorf("listed %+v, want %+v", guilds, want)
+	}
+
+	if a, b := f.names(guild), f.names(6); a != "a" || b != "b" {
+		t.Errorf("queues are %q and %q, want %q and %q", a, b, "a", "b")
+	}
+
+	// each guild's queue is saved in its own directory
+	for g, want := range map[gb.Snowflake]int{guild: 1, 6: 1} {
+		saved := discussion.NewQueue()
+		if err := saved.Load(f.queues.Path(g)); err != nil || saved.Len() != want {
+			t.Errorf("guild %s: queue not saved (err = %v, topics = %d)", g, err, saved.Len())
+		}
+	}
+}
+
+/*
+Test Cases:
+- topics are added, read, edited, moved, given sources and removed, with the same checks as chat commands
+- a bad position changes nothing
+- a source is only detached if it is the one the client names
+- each change is saved, journaled in the audit log and published
+- finished topics are in the queue's archive
+*/
+func TestServer_Topics(t *testing.T) {
+	f := newFixture(t)
+	defer f.close()
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+		wantError  string
+		wantQueue  string
+	}{
+		{name: "add", method: "POST", path: prefix + "/queue/topics", body: `{"name": "a", "description": "about a"}`, wantStatus: 201, wantQueue: "a"},
+		{name: "add-slash", method: "POST", path: prefix + "/queue/topics", body: `{"name": "b/c"}`, wantStatus: 201, wantQueue: "a,b/c"},
+		{name: "add-duplicate", method: "POST", path: prefix + "/queue/topics", body: `{"name": "a"}`, wantStatus: 409, wantError: "Duplicate Topic", wantQueue: "a,b/c"},
+		{name: "add-empty", method: "POST", path: prefix + "/queue/topics", body: `{"name": ""}`, wantStatus: 400, wantError: "Empty Topic Name", wantQueue: "a,b/c"},
+		{name: "add-unknown-field", method: "POST", path: prefix + "/queue/topics", body: `{"title": "x"}`, wantStatus: 400, wantError: "Invalid Body", wantQueue: "a,b/c"},
+		{name: "get", method: "GET", path: prefix + "/queue/topics/b%2Fc", wantStatus: 200, wantQueue: "a,b/c"},
+		{name: "get-missing", method: "GET", path: prefix + "/queue/topics/x", wantStatus: 404, wantError: "Topic Not Found", wantQueue: "a,b/c"},
+		{name: "edit-bad-position", method: "PATCH", path: prefix + "/queue/topics/a", body: `{"name": "z", "position": 2}`, wantStatus: 400, wantError: "Index Out of Range", wantQueue: "a,b/c"},
+		{name: "edit", method: "PATCH", path: prefix + "/queue/topics/a", body: `{"name": "z", "position": 1}`, wantStatus: 200, wantQueue: "b/c,z"},
+		{name: "attach-bad-url", method: "POST", path: prefix + "/queue/topics/z/sources", body: `{"url": "example.com"}`, wantStatus: 400, wantError: "Invalid Source", wantQueue: "b/c,z"},
+		{name: "attach", method: "POST", path: prefix + "/queue/topics/z/sources", body: `{"url": "https://example.com"}`, wantStatus: 201, wantQueue: "b/c,z"},
+		{name: "detach-out-of-range", method: "DELETE", path: prefix + "/queue/topics/z/sources/3?url=https%3A%2F%2Fexample.com", wantStatus: 400, wantError: "Index Out of Range", wantQueue: "b/c,z"},
+		{name: "detach-unnamed", method: "DELETE", path: prefix + "/queue/topics/z/sources/0", wantStatus: 400, wantError: "Missing Parameter", wantQueue: "b/c,z"},
+		{name: "detach-changed", method: "DELETE", path: prefix + "/queue/topics/z/sources/0?url=https%3A%2F%2Fexample.org", wantStatus: 409, wantError: "Source Changed", wantQueue: "b/c,z"},
+		{name: "detach", method: "DELETE", path: prefix + "/queue/topics/z/sources/0?url=https%3A%2F%2Fexample.com", wantStatus: 204, wantQueue: "b/c,z"},
+		{name: "done", method: "POST", path: prefix + "/queue/done", wantStatus: 200, wantQueue: "z"},
+		{name: "remove", method: "DELETE", path: prefix + "/queue/topics/z", wantStatus: 204, wantQueue: ""},
+		{name: "remove-missing", method: "DELETE", path: prefix + "/queue/topics/z", wantStatus: 404, wantError: "Topic Not Found", wantQueue: ""},
+		{name: "done-empty", method: "POST", path: prefix + "/queue/done", wantStatus: 400, wantError: "Empty Queue", wantQueue: ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			status, body := f.do(t, test.method, test.path, test.body)
+			if status != test.wantStatus {
+				t.Errorf("status %d, want %d (%v)", status, test.wantStatus, body)
+			}
+			if test.wantError != "" && body["error"] != test.wantError {
+				t.Errorf("error %v, want %q", body["error"], test.wantError)
+			}
+			if got := f.names(guild); got != test.wantQueue {
+				t.Errorf("queue %q, want %q", got, test.wantQueue)
+			}
+		})
+	}
+
+	// the finished topic is in the archive
+	status, body := f.do(t, "GET", prefix+"/queue", "")
+	archive, _ := body["archive"].([]interface{})
+	if status != 200 || len(archive) != 1 {
+		t.Fatalf("queue: status %d, archive %v", status, body["archive"])
+	}
+	if fin, _ := archive[0].(map[string]interface{}); fin["by"] != User || fin["topic"].(map[string]interface{})["name"] != "b/c" {
+		t.Errorf("archived %v", archive[0])
+	}
+
+	// the last successful change was saved
+	saved := discussion.NewQueue()
+	if err := saved.Load(f.queues.Path(guild)); err != nil || saved.Len() != 0 {
+		t.Errorf("queue not saved (err = %v, topics = %d)", err, saved.Len())
+	}
+
+	var kinds []string
+	for _, e := range f.events {
+		kinds = append(kinds, e.Kind())
+	}
+	if want := "topic.added,topic.added,topic.completed,topic.removed"; strings.Join(kinds, ",") != want {
+		t.Errorf("published %v, want %s", kinds, want)
+	}
+	if e, ok := f.events[3].(*event.TopicRemoved); !ok || e.Username != User || e.GuildId != guild {
+		t.Errorf("removal credited to %+v", f.events[3])
+	}
+}
+
+/*
+Test Cases:
+- memes are added, listed, read and removed, with the same duplicate check as chat commands
+- a meme is only removed if it is the one the client names
+- a guild's submissions are listed, approved with a notice to the submitter, and rejected
+*/
+func TestServer_Memes(t *testing.T) {
+	f := newFixture(t)
+	defer f.close()
+
+	if status, _ := f.do(t, "POST", prefix+"/memes", `{"meme": "Is his career over!?"}`); status != 201 {
+		t.Fatalf("add: status %d", status)
+	}
+	if status, body := f.do(t, "POST", prefix+"/memes", `{"meme": "is his career over"}`); status != 409 || body["error"] != "Possible Duplicate" {
+		t.Errorf("duplicate: status %d (%v)", status, body)
+	}
+	if status, _ := f.do(t, "POST", prefix+"/memes", `{"meme": "is his career over", "force": true}`); status != 201 {
+		t.Errorf("forced: status %d", status)
+	}
+	if status, body := f.do(t, "GET", prefix+"/memes/1", ""); status != 200 || body["meme"] != "is his career over" || body["added-by"] != User {
+		t.Errorf("get: status %d (%v)", status, body)
+	}
+	if status, _ := f.do(t, "GET", prefix+"/memes/7", ""); status != 404 {
+		t.Errorf("get missing: status %d", status)
+	}
+	if status, body := f.do(t, "DELETE", prefix+"/memes/0", ""); status != 400 || body["error"] != "Missing Parameter" {
+		t.Errorf("remove without naming the meme: status %d (%v)", status, body)
+	}
+	if status, body := f.do(t, "DELETE", prefix+"/memes/0?meme=is+his+career+over", ""); status != 409 || body["error"] != "Meme Changed" {
+		t.Errorf("remove the wrong meme: status %d (%v)", status, body)
+	}
+	if status, _ := f.do(t, "DELETE", prefix+"/memes/0?meme="+url.QueryEscape("Is his career over!?"), ""); status != 204 {
+		t.Errorf("remove: status %d", status)
+	}
+
+	saved := meme.Stash{}
+	if err := saved.Load(f.stash.LocalPath); err != nil || len(saved.Memes) != 1 || saved.Memes[0].Meme != "is his career over" {
+		t.Errorf("stash not saved (err = %v): %+v", err, saved.Memes)
+	}
+
+	f.stash.Lock()
+	for _, text := range []string{"first", "second"} {
+		if _, err := f.stash.Submit(meme.NewMeme(text, "user"), &gb.Source{AuthorId: 1, ChannelId: 2, GuildId: 5}); err != nil {
+			t.Fatalf("submit: %v", err)
+		}
+	}
+	f.stash.Unlock()
+
+	req, _ := http.NewRequest("GET", f.srv.URL+prefix+"/submissions", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := f.srv.Client().Do(req)
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	var subs []meme.Submission
+	_ = json.NewDecoder(resp.Body).Decode(&subs)
+	resp.Body.Close()
+	if len(subs) != 2 {
+		t.Errorf("listed %d submissions, want 2", len(subs))
+	}
+
+	if status, _ := f.do(t, "POST", "/api/guilds/6/submissions/0/approve", ""); status != 404 {
+		t.Errorf("other guild's submission: status %d", status)
+	}
+	if status, _ := f.do(t, "POST", prefix+"/submissions/0/approve", ""); status != 200 {
+		t.Errorf("approve: status %d", status)
+	}
+	if status, _ := f.do(t, "POST", prefix+"/submissions/1/reject", `{"reason": "not funny"}`); status != 200 {
+		t.Errorf("reject: status %d", status)
+	}
+
+	sent := f.session.Sent()
+	if len(sent) != 2 || sent[0].ChannelId != "2" || !strings.Contains(sent[0].Text, "approved by api") || !strings.Contains(sent[1].Text, "rejected (not funny)") {
+		t.Errorf("submitter not told: %+v", sent)
+	}
+	if len(f.stash.Memes) != 2 || len(f.stash.Pending) != 0 {
+		t.Errorf("stash has %d memes and %d pending, want 2 and 0", len(f.stash.Memes), len(f.stash.Pending))
+	}
+}
+
+/*
+Test Cases:
+- topics added through the API and through chat commands at the same time all make it into the queue
+*/
+func TestServer_Concurrent(t *testing.T) {
+	f := newFixture(t)
+	defer f.close()
+
+	i := discussion.Interceptor(f.queues)
+	var wg sync.WaitGroup
+	for n := 0; n < 20; n++ {
+		wg.Add(2)
+		go func(n int) {
+			defer wg.Done()
+			if status, _ := f.do(t, "POST", prefix+"/queue/topics", fmt.Sprintf(`{"name": "api %d"}`, n)); status != 201 {
+				t.Errorf("api %d: status %d", n, status)
+			}
+		}(n)
+		go func(n int) {
+			defer wg.Done()
+			msg := mock.NewMessage(gb.Queue, mock.WithArgs("add", fmt.Sprintf("chat %d", n)), mock.WithGuild(guild))
+			if err := i(msg); err != nil {
+				t.Errorf("chat %d: %v", n, err)
+			}
+			if err := f.reg.Execute(msg, f.session); err != nil {
+				t.Errorf("chat %d: %v", n, err)
+			}
+		}(n)
+	}
+	wg.Wait()
+
+	saved := discussion.NewQueue()
+	if err := saved.Load(f.queues.Path(guild)); err != nil || saved.Len() != 40 || f.queue.Len() != 40 {
+		t.Errorf("saved %d topics, have %d, want 40 (err = %v)", saved.Len(), f.queue.Len(), err)
+	}
+}
+
+/*
+Test Cases:
+- a change made through the API is journaled in its guild, and a moderator there can undo it from chat
+- another guild can't undo it, and undoing it leaves the other guild's queue and stash alone
+- the undone queue is saved
+*/
+func TestServer_UndoFromChat(t *testing.T) {
+	f := newFixture(t)
+	defer f.close()
+
+	for _, c := range []struct{ path, body string }{
+		{"/api/guilds/6/queue/topics", `{"name": "other"}`},
+		{prefix + "/queue/topics", `{"name": "a"}`},
+		{prefix + "/memes", `{"meme": "Bwoah"}`},
+	} {
+		if status, body := f.do(t, "POST", c.path, c.body); status != 201 {
+			t.Fatalf("%s: status %d (%v)", c.path, status, body)
+		}
+	}
+
+	// a moderator's command in the guild, as the bot would receive it
+	chat := func(guild gb.Snowflake, content string) *gb.Message {
+		msg, err := f.reg.Parse(&discordgo.Message{GuildID: guild.String(), ChannelID: "2", Author: &discordgo.User{ID: "3"}, Content: content})
+		if err != nil {
+			t.Fatalf("parse: %v", err)
+		}
+		msg.Source.Moderator = true
+		if err := f.reg.Intercept(msg); err != nil {
+			t.Fatalf("intercept: %v", err)
+		}
+		if err := f.reg.Execute(msg, f.session); err != nil {
+			t.Fatalf("execute: %v", err)
+		}
+		return msg
+	}
+
+	if msg := chat(6, "&undo 2"); msg.Response.Embed == nil || msg.Response.Embed.Title != "Change Not Found" || f.names(guild) != "a" {
+		t.Errorf("undone from another guild (response = %+v, queue = %q)", msg.Response, f.names(guild))
+	}
+
+	if msg := chat(guild, "&undo 2"); !strings.HasPrefix(msg.Response.Text, "Undid change 2") {
+		t.Errorf("not undone: %+v", msg.Response)
+	}
+	if a, b := f.names(guild), f.names(6); a != "" || b != "other" {
+		t.Errorf("queues are %q and %q after undo, want %q and %q", a, b, "", "other")
+	}
+	saved := discussion.NewQueue()
+	if err := saved.Load(f.queues.Path(guild)); err != nil || saved.Len() != 0 {
+		t.Errorf("undo not saved (err = %v, topics = %d)", err, saved.Len())
+	}
+
+	if msg := chat(guild, "&undo 3"); !strings.HasPrefix(msg.Response.Text, "Undid change 3") {
+		t.Errorf("not undone: %+v", msg.Response)
+	}
+	if n, other := len(f.stash.Memes), len(f.stashes.Get(6).Memes); n != 0 || other != 3 {
+		t.Errorf("stashes have %d and %d memes after undo, want 0 and 3", n, other)
+	}
+}
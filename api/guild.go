@@ -0,0 +1,59 @@
+package api
+
+import (
+	gb "github.com/ericebersohl/gobottas"
+	"net/http"
+	"sort"
+)
+
+// A guild as the API lists it.  The id is a string, since JavaScript can't hold snowflakes as numbers.
+type guildSummary struct {
+	Id      string `json:"id"`
+	Topics  int    `json:"topics"`  // topics in the queue
+	Memes   int    `json:"memes"`   // memes in the stash
+	Pending int    `json:"pending"` // submissions waiting for approval
+}
+
+// the guilds the bot is in, and any others with a queue or stash, in order
+func (s *Server) guildIds() []gb.Snowflake {
+	seen := make(map[gb.Snowflake]bool)
+	var ids []gb.Snowflake
+	add := func(guilds []gb.Snowflake) {
+		for _, g := range guilds {
+			if !seen[g] && s.known(g) {
+				seen[g] = true
+				ids = append(ids, g)
+			}
+		}
+	}
+
+	if s.guilds != nil {
+		add(s.guilds())
+	}
+	add(s.queues.Guilds())
+	add(s.stashes.Guilds())
+
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+func (s *Server) listGuilds(w http.ResponseWriter) {
+	out := make([]guildSummary, 0)
+	for _, g := range s.guildIds() {
+		sum := guildSummary{Id: g.String()}
+
+		q := s.queues.Get(g)
+		q.Lock()
+		sum.Topics = q.Len()
+		q.Unlock()
+
+		st := s.stashes.Get(g)
+		st.Lock()
+		sum.Memes, sum.Pending = len(st.Memes), len(st.Pending)
+		st.Unlock()
+
+		out = append(out, sum)
+	}
+
+	s.reply(w, http.StatusOK, out)
+}
@@ -0,0 +1,187 @@
+package api
+
+import (
+	"fmt"
+	gb "github.com/ericebersohl/gobottas"
+	"github.com/ericebersohl/gobottas/discord"
+	"github.com/ericebersohl/gobottas/meme"
+	"net/http"
+)
+
+// A meme as the API shows it, with the index chat commands know it by
+type indexedMeme struct {
+	Index int `json:"index"`
+	meme.Meme
+}
+
+// A new meme
+type newMeme struct {
+	Meme  string `json:"meme"`
+	Force bool   `json:"force"` // add it even if it looks like one already in the stash
+}
+
+// Why a submission was rejected, if anyone says
+type verdict struct {
+	Reason string `json:"reason"`
+}
+
+func (s *Server) listMemes(w http.ResponseWriter, guild gb.Snowflake) {
+	st := s.stashes.Get(guild)
+	st.Lock()
+	out := make([]indexedMeme, 0, len(st.Memes))
+	for i, m := range st.Memes {
+		out = append(out, indexedMeme{Index: i, Meme: *m})
+	}
+	st.Unlock()
+
+	s.reply(w, http.StatusOK, out)
+}
+
+// the meme at index n in st, which is locked
+func (s *Server) meme(st *meme.Stash, n int) (*meme.Meme, error) {
+	if n < 0 || n >= len(st.Memes) {
+		return nil, discord.NewError("Meme Not Found", "The provided index does not correspond to a meme.")
+	}
+	return st.Memes[n], nil
+}
+
+func (s *Server) getMeme(w http.ResponseWriter, n string, guild gb.Snowflake) {
+	i, ok := s.number(w, n, "Index")
+	if !ok {
+		return
+	}
+
+	st := s.stashes.Get(guild)
+	st.Lock()
+	m, err := s.meme(st, i)
+	var out indexedMeme
+	if err == nil {
+		out = indexedMeme{Index: i, Meme: *m}
+	}
+	st.Unlock()
+
+	if err != nil {
+		s.failErr(w, err)
+		return
+	}
+	s.reply(w, http.StatusOK, out)
+}
+
+func (s *Server) addMeme(w http.ResponseWriter, req *http.Request, guild gb.Snowflake) {
+	var in newMeme
+	if !s.decode(w, req, &in) {
+		return
+	}
+
+	// hash an image before locking the stash, since it's downloaded
+	m := meme.NewMeme(in.Meme, User)
+	m.Fingerprint(s.log)
+
+	msg := s.message(gb.Meme, guild)
+	st := s.stashes.Get(guild)
+	st.Lock()
+	err := st.AddMeme(msg, m, in.Force)
+	var out indexedMeme
+	saved := false
+	if err == nil {
+		out = indexedMeme{Index: len(st.Memes) - 1, Meme: *m}
+		saved = s.saveStash(w, st)
+	}
+	st.Unlock()
+
+	if err != nil {
+		s.failErr(w, err)
+		return
+	}
+	if saved && s.execute(w, msg) {
+		s.reply(w, http.StatusCreated, out)
+	}
+}
+
+func (s *Server) removeMeme(w http.ResponseWriter, req *http.Request, n string, guild gb.Snowflake) {
+	i, ok := s.number(w, n, "Index")
+	if !ok {
+		return
+	}
+
+	old, ok := s.param(w, req, "meme")
+	if !ok {
+		return
+	}
+
+	msg := s.message(gb.Meme, guild)
+	st := s.stashes.Get(guild)
+	st.Lock()
+	m, err := s.meme(st, i)
+	if err == nil && m.Meme != old {
+		// chat changed the stash since the client listed it
+		err = discord.NewError("Meme Changed", fmt.Sprintf("Meme %d is now %q; list the memes again.", i, m.Meme))
+	}
+	if err == nil {
+		err = st.RemoveMeme(msg, m)
+	}
+	saved := err == nil && s.saveStash(w, st)
+	st.Unlock()
+
+	if err != nil {
+		s.failErr(w, err)
+		return
+	}
+	if saved && s.execute(w, msg) {
+		s.reply(w, http.StatusNoContent, nil)
+	}
+}
+
+func (s *Server) listSubmissions(w http.ResponseWriter, guild gb.Snowflake) {
+	st := s.stashes.Get(guild)
+	st.Lock()
+	out := make([]meme.Submission, 0)
+	for _, p := range st.Pending {
+		if p.GuildId == guild {
+			out = append(out, *p)
+		}
+	}
+	st.Unlock()
+
+	s.reply(w, http.StatusOK, out)
+}
+
+// Approve or reject a guild's submission.  The submitter is told, as they are when a moderator reviews it in chat.
+func (s *Server) review(w http.ResponseWriter, req *http.Request, id, outcome string, guild gb.Snowflake) {
+	n, ok := s.number(w, id, "Submission")
+	if !ok {
+		return
+	}
+
+	// a rejection may give a reason
+	var in verdict
+	if outcome == "reject" && req.ContentLength != 0 && !s.decode(w, req, &in) {
+		return
+	}
+
+	msg := s.message(gb.Meme, guild)
+	st := s.stashes.Get(guild)
+	st.Lock()
+	var sub *meme.Submission
+	var err error
+	if outcome == "approve" {
+		sub, err = st.ApproveSubmission(msg, n)
+	} else {
+		sub, err = st.RejectSubmission(msg, n, in.Reason)
+	}
+	var out meme.Submission
+	saved := false
+	if err == nil {
+		out = *sub
+		saved = s.saveStash(w, st)
+	}
+	st.Unlock()
+
+	if err != nil {
+		s.failErr(w, err)
+		return
+	}
+	if saved && s.execute(w, msg) {
+		s.reply(w, http.StatusOK, out)
+	}
+}
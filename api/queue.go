@@ -0,0 +1,272 @@
+package api
+
+import (
+	"fmt"
+	gb "github.com/ericebersohl/gobottas"
+	"github.com/ericebersohl/gobottas/discord"
+	"github.com/ericebersohl/gobottas/discussion"
+	"net/http"
+	"time"
+)
+
+// The queue as the API shows it
+type queue struct {
+	Modified time.Time             `json:"modified"`
+	Topics   []discussion.Topic    `json:"topics"`
+	Archive  []discussion.Finished `json:"archive"` // newest first
+}
+
+// A new topic
+type newTopic struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// Changes to a topic; fields left out aren't changed
+type topicEdit struct {
+	Name        *string `json:"name"`
+	Description *string `json:"description"`
+	Position    *int    `json:"position"` // counting from 0 at the front
+}
+
+// A source to attach, or the one a client expects to detach
+type source struct {
+	URL string `json:"url"`
+}
+
+// copy a topic, so it can be written out after the queue is unlocked
+func topic(t *discussion.Topic) discussion.Topic {
+	c := *t
+	c.Sources = append([]string(nil), t.Sources...)
+	return c
+}
+
+func topics(q *discussion.Queue) []discussion.Topic {
+	out := make([]discussion.Topic, 0, q.Len())
+	for _, t := range q.List() {
+		out = append(out, topic(t))
+	}
+	return out
+}
+
+// the archive, newest first
+func archive(q *discussion.Queue) []discussion.Finished {
+	out := make([]discussion.Finished, 0, len(q.Archive))
+	for i := len(q.Archive) - 1; i >= 0; i-- {
+		f := *q.Archive[i]
+		t := topic(f.Topic)
+		f.Topic = &t
+		out = append(out, f)
+	}
+	return out
+}
+
+func (s *Server) getQueue(w http.ResponseWriter, guild gb.Snowflake) {
+	q := s.queues.Get(guild)
+	q.Lock()
+	out := queue{Modified: q.Modified, Topics: topics(q), Archive: archive(q)}
+	q.Unlock()
+
+	s.reply(w, http.StatusOK, out)
+}
+
+func (s *Server) listTopics(w http.ResponseWriter, guild gb.Snowflake) {
+	q := s.queues.Get(guild)
+	q.Lock()
+	out := topics(q)
+	q.Unlock()
+
+	s.reply(w, http.StatusOK, out)
+}
+
+func (s *Server) getTopic(w http.ResponseWriter, name string, guild gb.Snowflake) {
+	q := s.queues.Get(guild)
+	q.Lock()
+	t, err := q.Find(name)
+	var out discussion.Topic
+	if err == nil {
+		out = topic(t)
+	}
+	q.Unlock()
+
+	if err != nil {
+		s.failErr(w, err)
+		return
+	}
+	s.reply(w, http.StatusOK, out)
+}
+
+func (s *Server) addTopic(w http.ResponseWriter, req *http.Request, guild gb.Snowflake) {
+	var in newTopic
+	if !s.decode(w, req, &in) {
+		return
+	}
+
+	msg := s.message(gb.Queue, guild)
+	q := s.queues.Get(guild)
+	q.Lock()
+	t, err := q.AddTopic(msg, in.Name, in.Description)
+	var out discussion.Topic
+	if err == nil {
+		out = topic(t)
+	}
+	q.Unlock()
+
+	if err != nil {
+		s.failErr(w, err)
+		return
+	}
+	if s.execute(w, msg) {
+		s.reply(w, http.StatusCreated, out)
+	}
+}
+
+func (s *Server) editTopic(w http.ResponseWriter, req *http.Request, name string, guild gb.Snowflake) {
+	var in topicEdit
+	if !s.decode(w, req, &in) {
+		return
+	}
+
+	msg := s.message(gb.Queue, guild)
+	q := s.queues.Get(guild)
+	q.Lock()
+	out, err := s.edit(q, msg, name, in)
+	q.Unlock()
+
+	if err != nil {
+		s.failErr(w, err)
+		return
+	}
+	if s.execute(w, msg) {
+		s.reply(w, http.StatusOK, out)
+	}
+}
+
+// Rename, describe and move a topic in q, in that order, for the author of msg; the queue is locked.  A position that
+// is out of range is refused before anything changes.
+func (s *Server) edit(q *discussion.Queue, msg *gb.Message, name string, in topicEdit) (discussion.Topic, error) {
+	t, err := q.Find(name)
+	if err != nil {
+		return discussion.Topic{}, err
+	}
+
+	if in.Position != nil && (*in.Position < 0 || *in.Position >= q.Len()) {
+		return discussion.Topic{}, discord.NewError("Index Out of Range", "You specified a position that is out of the range of topics.")
+	}
+
+	if in.Name != nil || in.Description != nil {
+		newName, description := t.Name, t.Description
+		if in.Name != nil {
+			newName = *in.Name
+		}
+		if in.Description != nil {
+			description = *in.Description
+		}
+
+		if err := q.EditTopic(msg, name, newName, description); err != nil {
+			return discussion.Topic{}, err
+		}
+		name = newName
+	}
+
+	if in.Position != nil {
+		if err := q.MoveTopic(msg, name, *in.Position); err != nil {
+			return discussion.Topic{}, err
+		}
+	}
+
+	return topic(t), nil
+}
+
+func (s *Server) removeTopic(w http.ResponseWriter, name string, guild gb.Snowflake) {
+	msg := s.message(gb.Queue, guild)
+	q := s.queues.Get(guild)
+	q.Lock()
+	err := q.RemoveTopic(msg, name)
+	q.Unlock()
+
+	if err != nil {
+		s.failErr(w, err)
+		return
+	}
+	if s.execute(w, msg) {
+		s.reply(w, http.StatusNoContent, nil)
+	}
+}
+
+func (s *Server) finishTopic(w http.ResponseWriter, guild gb.Snowflake) {
+	msg := s.message(gb.Queue, guild)
+	q := s.queues.Get(guild)
+	q.Lock()
+	t, err := q.FinishTopic(msg)
+	var out discussion.Topic
+	if err == nil {
+		out = topic(t)
+	}
+	q.Unlock()
+
+	if err != nil {
+		s.failErr(w, err)
+		return
+	}
+	if s.execute(w, msg) {
+		s.reply(w, http.StatusOK, out)
+	}
+}
+
+func (s *Server) attachSource(w http.ResponseWriter, req *http.Request, name string, guild gb.Snowflake) {
+	var in source
+	if !s.decode(w, req, &in) {
+		return
+	}
+
+	msg := s.message(gb.Queue, guild)
+	q := s.queues.Get(guild)
+	q.Lock()
+	err := q.AttachSource(msg, name, in.URL)
+	var out discussion.Topic
+	if t, ferr := q.Find(name); err == nil && ferr == nil {
+		out = topic(t)
+	}
+	q.Unlock()
+
+	if err != nil {
+		s.failErr(w, err)
+		return
+	}
+	if s.execute(w, msg) {
+		s.reply(w, http.StatusCreated, out)
+	}
+}
+
+func (s *Server) detachSource(w http.ResponseWriter, req *http.Request, name, n string, guild gb.Snowflake) {
+	i, ok := s.number(w, n, "Source")
+	if !ok {
+		return
+	}
+
+	url, ok := s.param(w, req, "url")
+	if !ok {
+		return
+	}
+
+	msg := s.message(gb.Queue, guild)
+	q := s.queues.Get(guild)
+	q.Lock()
+	var err error
+	if t, ferr := q.Find(name); ferr == nil && i >= 0 && i < len(t.Sources) && t.Sources[i] != url {
+		// chat changed the topic's sources since the client read them
+		err = discord.NewError("Source Changed", fmt.Sprintf("Source %d of %q is now %s; read the topic again.", i, name, t.Sources[i]))
+	} else {
+		err = q.DetachSource(msg, name, i)
+	}
+	q.Unlock()
+
+	if err != nil {
+		s.failErr(w, err)
+		return
+	}
+	if s.execute(w, msg) {
+		s.reply(w, http.StatusNoContent, nil)
+	}
+}
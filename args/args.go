@@ -0,0 +1,320 @@
+// Package args splits commands into arguments with Tokenize, and parses the arguments of a subcommand into a struct,
+// so that interceptors declare what they expect instead of checking len(msg.Args) and converting strings by hand.
+//
+// Each exported field with an `arg` tag is an argument, filled in field order:
+//
+//	type attachArgs struct {
+//		Name string   `arg:"name"`
+//		URL  *url.URL `arg:"url"`
+//	}
+//
+// Tag options follow the name, separated by commas:
+//   - optional: the argument may be left out; it and everything after it keeps its zero value
+//   - min=N: an int or duration must be at least N (seconds for durations)
+//   - flag: a bool field set by --name or -n anywhere in the arguments
+//
+// A slice field must come last and takes every remaining argument; it needs at least one unless it is optional.
+// Supported types are string, int, bool (flags), time.Duration, *url.URL, gb.Snowflake (a user or channel mention,
+// or an id) and slices of those.
+package args
+
+import (
+	"fmt"
+	gb "github.com/ericebersohl/gobottas"
+	"github.com/ericebersohl/gobottas/discord"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// An argument declared by a struct field
+type field struct {
+	index    int
+	name     string
+	optional bool
+	flag     bool
+	variadic bool
+	min      *int
+	typ      reflect.Type // type of one value; the element type for variadic fields
+}
+
+var (
+	durationType  = reflect.TypeOf(time.Duration(0))
+	urlType       = reflect.TypeOf(&url.URL{})
+	snowflakeType = reflect.TypeOf(gb.Snowflake(0))
+)
+
+// Parse args into the struct that dst points to.  Missing, extra and malformed arguments return a discord.Error
+// that includes the usage line for cmd, e.g. "&dq add".  A badly declared struct returns a plain error.
+func Parse(cmd string, args []string, dst interface{}) error {
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("args: cannot parse into %T, need a pointer to a struct", dst)
+	}
+	v = v.Elem()
+
+	fields, err := fieldsOf(v.Type())
+	if err != nil {
+		return err
+	}
+	usage := usageOf(cmd, fields)
+
+	// flags may appear anywhere, so pull them out first
+	var positional []field
+	for _, f := range fields {
+		if !f.flag {
+			positional = append(positional, f)
+			continue
+		}
+
+		var rest []string
+		for _, a := range args {
+			if a == "--"+f.name || a == "-"+f.name[:1] {
+				v.Field(f.index).SetBool(true)
+			} else {
+				rest = append(rest, a)
+			}
+		}
+		args = rest
+	}
+
+	for _, f := range positional {
+		if f.variadic {
+			if len(args) == 0 && !f.optional {
+				return discord.NewError("Too Few Args", fmt.Sprintf("Missing %s:\n%s", f.name, usage))
+			}
+
+			if len(args) == 0 {
+				break
+			}
+
+			s := reflect.MakeSlice(v.Field(f.index).Type(), 0, len(args))
+			for _, a := range args {
+				e, err := convert(f, a, usage)
+				if err != nil {
+					return err
+				}
+				s = reflect.Append(s, e)
+			}
+			v.Field(f.index).Set(s)
+			args = nil
+			break
+		}
+
+		if len(args) == 0 {
+			if f.optional {
+				break
+			}
+			return discord.NewError("Too Few Args", fmt.Sprintf("Missing %s:\n%s", f.name, usage))
+		}
+
+		e, err := convert(f, args[0], usage)
+		if err != nil {
+			return err
+		}
+		v.Field(f.index).Set(e)
+		args = args[1:]
+	}
+
+	if len(args) > 0 {
+		return discord.NewError("Too Many Args", fmt.Sprintf("Unexpected %q; put arguments with spaces in quotes:\n%s", args[0], usage))
+	}
+
+	return nil
+}
+
+// Build the usage line for cmd from the struct that dst points to, e.g. "`&dq add [name] [description?]`"
+func Usage(cmd string, dst interface{}) string {
+	t := reflect.TypeOf(dst)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	fields, err := fieldsOf(t)
+	if err != nil {
+		return fmt.Sprintf("`%s`", cmd)
+	}
+	return usageOf(cmd, fields)
+}
+
+func usageOf(cmd string, fields []field) string {
+	var b strings.Builder
+	b.WriteString("`")
+	b.WriteString(cmd)
+
+	for _, f := range fields {
+		b.WriteString(" [")
+		if f.flag {
+			b.WriteString("--")
+		}
+		b.WriteString(f.name)
+		if f.variadic {
+			b.WriteString("...")
+		}
+		if f.optional && !f.flag {
+			b.WriteString("?")
+		}
+		b.WriteString("]")
+	}
+
+	b.WriteString("`")
+	return b.String()
+}
+
+// Read the argument declarations from a struct type
+func fieldsOf(t reflect.Type) ([]field, error) {
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("args: %s is not a struct", t)
+	}
+
+	var fields []field
+	optional := false
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag, ok := sf.Tag.Lookup("arg")
+		if !ok {
+			continue
+		}
+
+		opts := strings.Split(tag, ",")
+		f := field{index: i, name: opts[0], typ: sf.Type}
+		if f.name == "" {
+			f.name = strings.ToLower(sf.Name)
+		}
+
+		for _, o := range opts[1:] {
+			switch {
+			case o == "optional":
+				f.optional = true
+			case o == "flag":
+				f.flag = true
+			case strings.HasPrefix(o, "min="):
+				n, err := strconv.Atoi(strings.TrimPrefix(o, "min="))
+				if err != nil {
+					return nil, fmt.Errorf("args: bad minimum on %s.%s: %v", t, sf.Name, err)
+				}
+				f.min = &n
+			default:
+				return nil, fmt.Errorf("args: unknown option %q on %s.%s", o, t, sf.Name)
+			}
+		}
+
+		if f.flag {
+			if sf.Type.Kind() != reflect.Bool {
+				return nil, fmt.Errorf("args: flag %s.%s must be a bool", t, sf.Name)
+			}
+			f.optional = true
+			fields = append(fields, f)
+			continue
+		}
+
+		if sf.Type.Kind() == reflect.Slice {
+			f.variadic = true
+			f.typ = sf.Type.Elem()
+		}
+
+		if !supported(f.typ) {
+			return nil, fmt.Errorf("args: unsupported type %s for %s.%s", sf.Type, t, sf.Name)
+		}
+
+		// once an argument may be left out, the ones after it can't be required
+		if optional && !f.optional {
+			return nil, fmt.Errorf("args: required %s.%s follows an optional argument", t, sf.Name)
+		}
+		optional = optional || f.optional
+
+		fields = append(fields, f)
+	}
+
+	// a variadic argument takes everything, so it has to be the last positional one
+	for i, f := range fields {
+		if !f.variadic {
+			continue
+		}
+		for _, g := range fields[i+1:] {
+			if !g.flag {
+				return nil, fmt.Errorf("args: %s must be the last argument of %s", f.name, t)
+			}
+		}
+	}
+
+	return fields, nil
+}
+
+func supported(t reflect.Type) bool {
+	switch t {
+	case durationType, urlType, snowflakeType:
+		return true
+	}
+	return t.Kind() == reflect.String || t.Kind() == reflect.Int
+}
+
+// Convert one argument to the field's type
+func convert(f field, s, usage string) (reflect.Value, error) {
+	invalid := func(want string) error {
+		return discord.NewError("Invalid Argument", fmt.Sprintf("%s must be %s, not %q:\n%s", f.name, want, s, usage))
+	}
+
+	switch f.typ {
+	case durationType:
+		// a bare number is seconds
+		var d time.Duration
+		if n, err := strconv.Atoi(s); err == nil {
+			d = time.Duration(n) * time.Second
+		} else if d, err = time.ParseDuration(s); err != nil {
+			return reflect.Value{}, invalid("a duration like 30s or 5m")
+		}
+
+		if f.min != nil && d < time.Duration(*f.min)*time.Second {
+			return reflect.Value{}, invalid(fmt.Sprintf("at least %s", time.Duration(*f.min)*time.Second))
+		}
+		return reflect.ValueOf(d), nil
+
+	case urlType:
+		u, ok := ParseURL(s)
+		if !ok {
+			return reflect.Value{}, invalid("a link starting with http:// or https://")
+		}
+		return reflect.ValueOf(u), nil
+
+	case snowflakeType:
+		// user mentions look like <@id>, or <@!id> for users with a nickname, and channel mentions like <#id>
+		id := s
+		if strings.HasPrefix(id, "<@") && strings.HasSuffix(id, ">") {
+			id = strings.TrimPrefix(id[2:len(id)-1], "!")
+		} else if strings.HasPrefix(id, "<#") && strings.HasSuffix(id, ">") {
+			id = id[2 : len(id)-1]
+		}
+
+		n, err := strconv.ParseUint(id, 10, 64)
+		if err != nil {
+			return reflect.Value{}, invalid("a mention")
+		}
+		return reflect.ValueOf(gb.Snowflake(n)), nil
+	}
+
+	if f.typ.Kind() == reflect.Int {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return reflect.Value{}, invalid("a whole number")
+		}
+
+		if f.min != nil && n < *f.min {
+			return reflect.Value{}, invalid(fmt.Sprintf("at least %d", *f.min))
+		}
+		return reflect.ValueOf(n).Convert(f.typ), nil
+	}
+
+	return reflect.ValueOf(s).Convert(f.typ), nil
+}
+
+// Parse a link the way url arguments are parsed, for links that come from somewhere other than a command
+func ParseURL(s string) (*url.URL, bool) {
+	u, err := url.ParseRequestURI(s)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, false
+	}
+	return u, true
+}
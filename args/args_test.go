@@ -0,0 +1,160 @@
+package args
+
+import (
+	gb "github.com/ericebersohl/gobottas"
+	"github.com/ericebersohl/gobottas/discord"
+	"github.com/google/go-cmp/cmp"
+	"net/url"
+	"testing"
+	"time"
+)
+
+type topicArgs struct {
+	Name        string `arg:"name"`
+	Description string `arg:"description,optional"`
+}
+
+type typedArgs struct {
+	Count int           `arg:"count,min=0"`
+	Wait  time.Duration `arg:"wait"`
+	Link  *url.URL      `arg:"link"`
+	User  gb.Snowflake  `arg:"user"`
+}
+
+type restArgs struct {
+	Id     int      `arg:"id"`
+	Force  bool     `arg:"force,flag"`
+	Reason []string `arg:"reason,optional"`
+}
+
+type requiredRestArgs struct {
+	Ids []int `arg:"ids"`
+}
+
+/*
+Test Cases:
+- positional: required only, required and optional, missing, too many
+- types: all valid, nickname mention, channel mention, bare number duration, bad int, below min, bad duration, bad url, relative url, bad mention
+- variadic and flags: none, several, flag anywhere, short flag, bad element, required variadic missing
+*/
+func TestParse(t *testing.T) {
+	link, _ := url.Parse("https://example.com/a")
+
+	tests := []struct {
+		name    string
+		in      []string
+		dst     interface{}
+		want    interface{}
+		wantErr string // Name of the discord.Error, or empty for none
+	}{
+		{name: "required", in: []string{"t"}, dst: &topicArgs{}, want: &topicArgs{Name: "t"}},
+		{name: "optional", in: []string{"t", "d"}, dst: &topicArgs{}, want: &topicArgs{Name: "t", Description: "d"}},
+		{name: "missing", in: nil, dst: &topicArgs{}, wantErr: "Too Few Args"},
+		{name: "too-many", in: []string{"t", "d", "x"}, dst: &topicArgs{}, wantErr: "Too Many Args"},
+
+		{name: "typed", in: []string{"3", "1m", "https://example.com/a", "<@42>"}, dst: &typedArgs{},
+			want: &typedArgs{Count: 3, Wait: time.Minute, Link: link, User: 42}},
+		{name: "nickname", in: []string{"0", "5s", "https://example.com/a", "<@!42>"}, dst: &typedArgs{},
+			want: &typedArgs{Wait: 5 * time.Second, Link: link, User: 42}},
+		{name: "channel", in: []string{"0", "5s", "https://example.com/a", "<#42>"}, dst: &typedArgs{},
+			want: &typedArgs{Wait: 5 * time.Second, Link: link, User: 42}},
+		{name: "seconds", in: []string{"0", "90", "https://example.com/a", "42"}, dst: &typedArgs{},
+			want: &typedArgs{Wait: 90 * time.Second, Link: link, User: 42}},
+		{name: "bad-int", in: []string{"three", "1m", "https://example.com/a", "<@42>"}, dst: &typedArgs{}, wantErr: "Invalid Argument"},
+		{name: "below-min", in: []string{"-1", "1m", "https://example.com/a", "<@42>"}, dst: &typedArgs{}, wantErr: "Invalid Argument"},
+		{name: "bad-duration", in: []string{"1", "soon", "https://example.com/a", "<@42>"}, dst: &typedArgs{}, wantErr: "Invalid Argument"},
+		{name: "bad-url", in: []string{"1", "1m", "ftp://example.com", "<@42>"}, dst: &typedArgs{}, wantErr: "Invalid Argument"},
+		{name: "relative-url", in: []string{"1", "1m", "example.com", "<@42>"}, dst: &typedArgs{}, wantErr: "Invalid Argument"},
+		{name: "bad-mention", in: []string{"1", "1m", "https://example.com/a", "<@&42>"}, dst: &typedArgs{}, wantErr: "Invalid Argument"},
+
+		{name: "rest-none", in: []string{"1"}, dst: &restArgs{}, want: &restArgs{Id: 1}},
+		{name: "rest-several", in: []string{"1", "not", "funny"}, dst: &restArgs{}, want: &restArgs{Id: 1, Reason: []string{"not", "funny"}}},
+		{name: "flag-anywhere", in: []string{"--force", "1", "x"}, dst: &restArgs{}, want: &restArgs{Id: 1, Force: true, Reason: []string{"x"}}},
+		{name: "short-flag", in: []string{"1", "-f"}, dst: &restArgs{}, want: &restArgs{Id: 1, Force: true}},
+		{name: "rest-ints", in: []string{"1", "2"}, dst: &requiredRestArgs{}, want: &requiredRestArgs{Ids: []int{1, 2}}},
+		{name: "rest-bad-int", in: []string{"1", "two"}, dst: &requiredRestArgs{}, wantErr: "Invalid Argument"},
+		{name: "rest-missing", in: nil, dst: &requiredRestArgs{}, wantErr: "Too Few Args"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := Parse("&cmd", test.in, test.dst)
+			if err != nil {
+				e, ok := err.(discord.Error)
+				if !ok {
+					t.Fatalf("not a discord error: %v", err)
+				}
+
+				if e.Name != test.wantErr {
+					t.Errorf("error %q, want %q (%s)", e.Name, test.wantErr, e.Desc)
+				}
+				return
+			}
+
+			if test.wantErr != "" {
+				t.Fatalf("no error, want %q", test.wantErr)
+			}
+
+			if !cmp.Equal(test.dst, test.want) {
+				t.Errorf("got != want (%s)", cmp.Diff(test.want, test.dst))
+			}
+		})
+	}
+}
+
+/*
+Test Cases:
+- not a pointer, unsupported type, required after optional, variadic not last, flag not a bool, unknown option
+*/
+func TestParse_BadSpec(t *testing.T) {
+	tests := []struct {
+		name string
+		dst  interface{}
+	}{
+		{name: "not-pointer", dst: topicArgs{}},
+		{name: "unsupported", dst: &struct {
+			F float64 `arg:"f"`
+		}{}},
+		{name: "required-after-optional", dst: &struct {
+			A string `arg:"a,optional"`
+			B string `arg:"b"`
+		}{}},
+		{name: "variadic-not-last", dst: &struct {
+			A []string `arg:"a"`
+			B string   `arg:"b,optional"`
+		}{}},
+		{name: "flag-not-bool", dst: &struct {
+			A string `arg:"a,flag"`
+		}{}},
+		{name: "unknown-option", dst: &struct {
+			A string `arg:"a,sometimes"`
+		}{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := Parse("&cmd", []string{"x"}, test.dst)
+			if err == nil {
+				t.Fatalf("no error")
+			}
+
+			if _, ok := err.(discord.Error); ok {
+				t.Errorf("spec error shown to the user: %v", err)
+			}
+		})
+	}
+}
+
+/*
+Test Cases:
+- required and optional, flags and variadic
+*/
+func TestUsage(t *testing.T) {
+	if got, want := Usage("&dq add", &topicArgs{}), "`&dq add [name] [description?]`"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+
+	if got, want := Usage("&meme reject", restArgs{}), "`&meme reject [id] [--force] [reason...?]`"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
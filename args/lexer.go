@@ -0,0 +1,230 @@
+package args
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Error returned by Tokenize for malformed input
+type SyntaxError struct {
+	Pos int    // offset of the problem in the input, in characters
+	Msg string // what went wrong
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%s at character %d", e.Msg, e.Pos+1)
+}
+
+// Show the line of the input the error is on, with a caret under the problem
+func (e *SyntaxError) Context(in string) string {
+	runes := []rune(in)
+	if e.Pos > len(runes) {
+		return ""
+	}
+
+	// find the line containing the error
+	start, end := e.Pos, e.Pos
+	for start > 0 && runes[start-1] != '\n' {
+		start--
+	}
+	for end < len(runes) && runes[end] != '\n' {
+		end++
+	}
+
+	return string(runes[start:end]) + "\n" + strings.Repeat(" ", e.Pos-start) + "^"
+}
+
+// Split message content into arguments the way a shell would.
+//   - Whitespace separates arguments.
+//   - Double quotes group an argument; inside them a backslash escapes a quote or another backslash.
+//   - Single quotes at the start of an argument group it literally.  A single quote only closes when followed by
+//     whitespace or the end of the message, so apostrophes ('it's') don't need escaping.
+//   - Outside quotes a backslash escapes a following quote, backslash or whitespace character.  Anywhere else a
+//     backslash is kept, so regular expressions like \d survive.
+//   - Curly quotes from mobile keyboards (“ ” ‘ ’) work like their straight equivalents.
+//   - `inline code` and ```fenced code``` are kept literally, and a fence's language tag is dropped.
+//
+// Malformed input, like an unterminated quote, returns a *SyntaxError.
+func Tokenize(s string) (tok []string, err error) {
+	l := lexer{in: []rune(s)}
+
+	for {
+		// skip whitespace between arguments
+		for l.pos < len(l.in) && unicode.IsSpace(l.in[l.pos]) {
+			l.pos++
+		}
+
+		if l.pos >= len(l.in) {
+			return tok, nil
+		}
+
+		t, err := l.token()
+		if err != nil {
+			return nil, err
+		}
+		tok = append(tok, t)
+	}
+}
+
+// state of Tokenize as it works through the input
+type lexer struct {
+	in  []rune
+	pos int
+	buf strings.Builder // the argument being read
+}
+
+func isDoubleQuote(r rune) bool {
+	return r == '"' || r == '“' || r == '”'
+}
+
+func isSingleQuote(r rune) bool {
+	return r == '\'' || r == '‘' || r == '’'
+}
+
+// characters that a backslash escapes
+func escapable(r rune) bool {
+	return r == '\\' || isDoubleQuote(r) || isSingleQuote(r) || unicode.IsSpace(r)
+}
+
+// read one argument, starting at a non-space character
+func (l *lexer) token() (string, error) {
+	l.buf.Reset()
+	start := l.pos
+
+	for l.pos < len(l.in) {
+		r := l.in[l.pos]
+
+		var err error
+		switch {
+		case unicode.IsSpace(r):
+			return l.buf.String(), nil
+
+		case r == '\\':
+			if l.pos+1 < len(l.in) && escapable(l.in[l.pos+1]) {
+				l.pos++
+			}
+			l.buf.WriteRune(l.in[l.pos])
+			l.pos++
+
+		case isDoubleQuote(r):
+			err = l.double()
+
+		case isSingleQuote(r) && l.pos == start:
+			err = l.single()
+
+		case r == '`':
+			err = l.code()
+
+		default:
+			l.buf.WriteRune(r)
+			l.pos++
+		}
+
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return l.buf.String(), nil
+}
+
+// read a double quoted section, starting at the opening quote
+func (l *lexer) double() error {
+	open := l.pos
+	l.pos++
+
+	for l.pos < len(l.in) {
+		r := l.in[l.pos]
+
+		switch {
+		case isDoubleQuote(r):
+			l.pos++
+			return nil
+
+		case r == '\\' && l.pos+1 < len(l.in) && (l.in[l.pos+1] == '\\' || isDoubleQuote(l.in[l.pos+1])):
+			l.buf.WriteRune(l.in[l.pos+1])
+			l.pos += 2
+
+		default:
+			l.buf.WriteRune(r)
+			l.pos++
+		}
+	}
+
+	return &SyntaxError{Pos: open, Msg: "unterminated double quote"}
+}
+
+// read a single quoted section, starting at the opening quote
+func (l *lexer) single() error {
+	open := l.pos
+
+	for end := open + 1; end < len(l.in); end++ {
+		if isSingleQuote(l.in[end]) && (end+1 == len(l.in) || unicode.IsSpace(l.in[end+1])) {
+			l.buf.WriteString(string(l.in[open+1 : end]))
+			l.pos = end + 1
+			return nil
+		}
+	}
+
+	return &SyntaxError{Pos: open, Msg: "unterminated single quote"}
+}
+
+// read inline code or a code fence, starting at the first backtick
+func (l *lexer) code() error {
+	open := l.pos
+
+	// count the backticks that open the code; three or more make a fence
+	n := 0
+	for l.pos < len(l.in) && l.in[l.pos] == '`' {
+		n++
+		l.pos++
+	}
+
+	if n >= 3 {
+		n = 3
+		l.pos = open + 3
+	}
+
+	// find a run of exactly as many backticks
+	for end := l.pos; end < len(l.in); end++ {
+		if l.in[end] != '`' {
+			continue
+		}
+
+		run := 0
+		for end+run < len(l.in) && l.in[end+run] == '`' {
+			run++
+		}
+
+		if run == n || n == 3 && run > 3 {
+			body := string(l.in[l.pos:end])
+			if n == 3 {
+				body = fenceBody(body)
+			}
+
+			l.buf.WriteString(body)
+			l.pos = end + n
+			return nil
+		}
+
+		end += run - 1
+	}
+
+	if n == 3 {
+		return &SyntaxError{Pos: open, Msg: "unterminated code block"}
+	}
+	return &SyntaxError{Pos: open, Msg: "unterminated inline code"}
+}
+
+// Strip the language tag and surrounding newlines from the inside of a code fence
+func fenceBody(s string) string {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		tag := s[:i]
+		if !strings.ContainsAny(tag, " \t`") {
+			s = s[i+1:]
+		}
+	}
+
+	return strings.TrimSuffix(s, "\n")
+}
@@ -0,0 +1,167 @@
+package args
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+/*
+Test Cases:
+- nil string
+- start quotes, end quotes
+- single quote with whitespace
+- single quotes, apostrophes, unterminated single quote
+- escaped quotes, escaped space, backslashes kept, trailing backslash
+- curly quotes, mixed curly and straight
+- adjacent quoted sections, empty quotes
+- inline code, double backtick code, code fence with and without language, unterminated code
+*/
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		out     []string
+		wantErr bool
+		wantPos int
+	}{
+		{name: "nil", in: "", out: nil, wantErr: false},
+		{name: "start-quotation", in: `"start quotes" and other args`, out: []string{"start quotes", "and", "other", "args"}, wantErr: false},
+		{name: "end-quotation", in: `other args "end quotes"`, out: []string{"other", "args", "end quotes"}, wantErr: false},
+		{name: "single-quote", in: `a " b`, out: nil, wantErr: true, wantPos: 2},
+
+		{name: "single-quotes", in: `&dq add 'my topic' desc`, out: []string{"&dq", "add", "my topic", "desc"}},
+		{name: "apostrophes", in: `&meme add 'it's fine' don't`, out: []string{"&meme", "add", "it's fine", "don't"}},
+		{name: "unterminated-single", in: `&dq add 'topic`, wantErr: true, wantPos: 8},
+
+		{name: "escaped-quotes", in: `"say \"hi\"" \"bare\"`, out: []string{`say "hi"`, `"bare"`}},
+		{name: "escaped-space", in: `two\ words`, out: []string{"two words"}},
+		{name: "backslash-kept", in: `&trigger add "/\d+\s/" '\w' C:\path`, out: []string{"&trigger", "add", `/\d+\s/`, `\w`, `C:\path`}},
+		{name: "escaped-backslash", in: `"a\\" b\\`, out: []string{`a\`, `b\`}},
+		{name: "trailing-backslash", in: `end\`, out: []string{`end\`}},
+
+		{name: "curly-quotes", in: `&dq add “phone topic” ‘single one’`, out: []string{"&dq", "add", "phone topic", "single one"}},
+		{name: "mixed-quotes", in: `“opened curly" ”both closing”`, out: []string{"opened curly", "both closing"}},
+		{name: "unterminated-curly", in: `&dq add “topic`, wantErr: true, wantPos: 8},
+
+		{name: "adjacent", in: `pre"fix and"post`, out: []string{"prefix andpost"}},
+		{name: "empty-quotes", in: `"" x`, out: []string{"", "x"}},
+
+		{name: "inline-code", in: "run `a \"b\" c` now", out: []string{"run", `a "b" c`, "now"}},
+		{name: "double-backtick", in: "``a`b``", out: []string{"a`b"}},
+		{name: "fence", in: "&meme add ```\nline one\nline two\n```", out: []string{"&meme", "add", "line one\nline two"}},
+		{name: "fence-lang", in: "```go\nfmt.Println(\"x\")\n``` after", out: []string{"fmt.Println(\"x\")", "after"}},
+		{name: "fence-one-line", in: "```a b```", out: []string{"a b"}},
+		{name: "unterminated-fence", in: "x ```\ncode", wantErr: true, wantPos: 2},
+		{name: "unterminated-inline", in: "x `code", wantErr: true, wantPos: 2},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := Tokenize(test.in)
+			if (err != nil) != test.wantErr {
+				t.Errorf("err != wantErr (err = %v, wantErr = %v)", err, test.wantErr)
+			}
+
+			if err != nil {
+				e, ok := err.(*SyntaxError)
+				if !ok {
+					t.Fatalf("error is %T, want *SyntaxError", err)
+				}
+
+				if e.Pos != test.wantPos {
+					t.Errorf("pos = %d, want %d", e.Pos, test.wantPos)
+				}
+			}
+
+			if !cmp.Equal(test.out, got) {
+				t.Errorf("out != got (%s)", cmp.Diff(test.out, got))
+			}
+		})
+	}
+}
+
+/*
+Test Cases:
+- error on the only line, error on a later line
+*/
+func TestSyntaxError_Context(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{name: "one-line", in: `&dq add "topic`, want: "&dq add \"topic\n        ^"},
+		{name: "later-line", in: "&meme add\n  `oops", want: "  `oops\n  ^"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Tokenize(test.in)
+			e, ok := err.(*SyntaxError)
+			if !ok {
+				t.Fatalf("no syntax error (err = %v)", err)
+			}
+
+			if got := e.Context(test.in); got != test.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, test.want)
+			}
+		})
+	}
+}
+
+// quote an argument so that Tokenize reads it back unchanged
+func quote(s string) string {
+	var b strings.Builder
+	b.WriteRune('"')
+	for _, r := range s {
+		if r == '\\' || isDoubleQuote(r) {
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+	b.WriteRune('"')
+	return b.String()
+}
+
+// Tokenize must never panic, errors must point into the input, and quoting the tokens must give them back
+func FuzzTokenize(f *testing.F) {
+	for _, s := range []string{
+		"", `&dq add "topic" desc`, `'it's' don't`, `a\ b \" \\`, "“curly” ‘quotes’", "`code` ```go\nx\n```", `"open`, "\\",
+	} {
+		f.Add(s)
+	}
+
+	f.Fuzz(func(t *testing.T, in string) {
+		if !utf8.ValidString(in) {
+			t.Skip()
+		}
+
+		tok, err := Tokenize(in)
+		if err != nil {
+			e, ok := err.(*SyntaxError)
+			if !ok {
+				t.Fatalf("error is %T, want *SyntaxError", err)
+			}
+			if e.Pos < 0 || e.Pos >= utf8.RuneCountInString(in) {
+				t.Fatalf("error position %d outside input of length %d", e.Pos, utf8.RuneCountInString(in))
+			}
+			_ = e.Context(in)
+			return
+		}
+
+		var quoted []string
+		for _, s := range tok {
+			quoted = append(quoted, quote(s))
+		}
+
+		again, err := Tokenize(strings.Join(quoted, " "))
+		if err != nil {
+			t.Fatalf("quoted tokens don't tokenize: %v", err)
+		}
+		if !cmp.Equal(tok, again) {
+			t.Fatalf("round trip changed tokens: %s", cmp.Diff(tok, again))
+		}
+	})
+}
@@ -0,0 +1,203 @@
+// Package audit keeps a record of who changed what.  Every change a command makes is appended to a local file, which
+// moderators can search, and posted in the guild's audit channel if it has one.
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"github.com/bwmarrin/discordgo"
+	gb "github.com/ericebersohl/gobottas"
+	"github.com/ericebersohl/gobottas/discord"
+	"github.com/ericebersohl/gobottas/metrics"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// The file the log is appended to, in the log's directory
+const FileName = "audit.jsonl"
+
+// One change, by one user
+type Entry struct {
+	Time      time.Time    `json:"time"`
+	GuildId   gb.Snowflake `json:"guild"`
+	ChannelId gb.Snowflake `json:"channel"`
+	ActorId   gb.Snowflake `json:"actor"`
+	Actor     string       `json:"actor_name"`
+	Command   string       `json:"command"`
+	Action    string       `json:"action"`
+	Target    string       `json:"target"`
+	Before    string       `json:"before,omitempty"`
+	After     string       `json:"after,omitempty"`
+}
+
+// The changes a message made, as entries
+func Entries(msg *gb.Message, now time.Time) []Entry {
+	var entries []Entry
+	for _, c := range msg.Changes {
+		e := Entry{
+			Time:    now,
+			Command: msg.Command.String(),
+			Action:  c.Action,
+			Target:  c.Target,
+			Before:  c.Before,
+			After:   c.After,
+		}
+		if msg.Source != nil {
+			e.GuildId = msg.Source.GuildId
+			e.ChannelId = msg.Source.ChannelId
+			e.ActorId = msg.Source.AuthorId
+			e.Actor = msg.Source.Username
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+// Build the embed posted in the audit channel
+func (e Entry) Embed() *discordgo.MessageEmbed {
+	em := discord.NewEmbed().
+		EmbedColor(gb.ConfigCol).
+		EmbedTitle(e.Action).
+		EmbedFooter(e.Command, "", "").
+		EmbedTimestamp(e.Time).
+		AddField("Actor", field(fmt.Sprintf("<@%s> (%s)", e.ActorId, e.Actor)), true).
+		AddField("Target", field(e.Target), true)
+
+	if e.ChannelId != 0 {
+		em = em.AddField("Channel", fmt.Sprintf("<#%s>", e.ChannelId), true)
+	}
+
+	return em.
+		AddField("Before", field(e.Before), false).
+		AddField("After", field(e.After), false).
+		MessageEmbed
+}
+
+// discord doesn't show fields without a value
+func orNone(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// discord rejects the whole embed if a field's value is too long, and a topic's description or a meme can run past it
+func field(s string) string {
+	return truncate(orNone(s), discord.FieldValueLimit)
+}
+
+// Cut s to at most n bytes on a rune boundary, marking that it was cut
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+
+	cut := n - len("…")
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut] + "…"
+}
+
+// The audit file, which entries are only ever appended to
+type Log struct {
+	mu        sync.Mutex
+	LocalPath string // directory the file is in
+}
+
+func New(localPath string) *Log {
+	return &Log{LocalPath: localPath}
+}
+
+// Append entries to the file
+func (l *Log) Append(entries ...Entry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	f, err := os.OpenFile(l.path(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		metrics.PersistenceErrors.Inc("audit", "save")
+		return err
+	}
+	defer f.Close()
+
+	enc := json.NewEncoder(f)
+	for _, e := range entries {
+		if err := enc.Encode(e); err != nil {
+			metrics.PersistenceErrors.Inc("audit", "save")
+			return err
+		}
+	}
+
+	return nil
+}
+
+// The last n entries in a guild that match the query, newest first.  A user mention or id matches what the user did;
+// anything else matches the names of actors and targets, ignoring case.
+func (l *Log) Search(guild gb.Snowflake, query string, n int) ([]Entry, error) {
+	match := matcher(query)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	f, err := os.Open(l.path())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		metrics.PersistenceErrors.Inc("audit", "load")
+		return nil, err
+	}
+	defer f.Close()
+
+	var found []Entry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			// a line cut short by a crash shouldn't hide the rest
+			continue
+		}
+		if e.GuildId == guild && match(e) {
+			found = append(found, e)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		metrics.PersistenceErrors.Inc("audit", "load")
+		return nil, err
+	}
+
+	// newest first
+	var recent []Entry
+	for i := len(found) - 1; i >= 0 && len(recent) < n; i-- {
+		recent = append(recent, found[i])
+	}
+	return recent, nil
+}
+
+// what a query matches
+func matcher(query string) func(Entry) bool {
+	query = strings.TrimSpace(query)
+
+	// mentions look like <@123> or, for users with a nickname, <@!123>
+	id := strings.TrimSuffix(strings.TrimPrefix(strings.TrimPrefix(query, "<@"), "!"), ">")
+	if sf, err := gb.ToSnowflake(id); err == nil {
+		return func(e Entry) bool {
+			return e.ActorId == sf
+		}
+	}
+
+	query = strings.ToLower(query)
+	return func(e Entry) bool {
+		return strings.Contains(strings.ToLower(e.Actor), query) || strings.Contains(strings.ToLower(e.Target), query)
+	}
+}
+
+func (l *Log) path() string {
+	return fmt.Sprintf("%s/%s", l.LocalPath, FileName)
+}
@@ -0,0 +1,214 @@
+package audit
+
+import (
+	gb "github.com/ericebersohl/gobottas"
+	"github.com/ericebersohl/gobottas/discord"
+	"github.com/ericebersohl/gobottas/mock"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+/*
+Test Cases:
+- a message's changes become entries with the actor, channel and command
+- messages without changes have no entries
+*/
+func TestEntries(t *testing.T) {
+	now := time.Date(2020, 1, 2, 15, 4, 5, 0, time.UTC)
+
+	msg := mock.NewMessage(gb.Queue, mock.WithSource(1, 2, "user", ""), mock.WithGuild(3))
+	msg.AddChange("bump topic", "x", "position 3", "position 1")
+	msg.AddChange("add topic", "y", "", "about y")
+
+	got := Entries(msg, now)
+	if len(got) != 2 {
+		t.Fatalf("got %d entries, want 2", len(got))
+	}
+
+	want := Entry{Time: now, GuildId: 3, ChannelId: 2, ActorId: 1, Actor: "user", Command: "Queue", Action: "bump topic", Target: "x", Before: "position 3", After: "position 1"}
+	if got[0] != want {
+		t.Errorf("got %+v, want %+v", got[0], want)
+	}
+
+	if got := Entries(mock.NewMessage(gb.Queue), now); len(got) != 0 {
+		t.Errorf("got %d entries for no changes", len(got))
+	}
+}
+
+/*
+Test Cases:
+- empty values are shown as "-"
+- short values are unchanged
+- values over discord's field limit are cut on a rune boundary and marked
+*/
+func TestEntry_Embed(t *testing.T) {
+	tests := []struct {
+		name   string
+		before string
+		want   string
+	}{
+		{"empty", "", "-"},
+		{"short", "about x", "about x"},
+		{"ascii", strings.Repeat("a", 2000), strings.Repeat("a", discord.FieldValueLimit-len("…")) + "…"},
+		{"multibyte", strings.Repeat("é", 1000), strings.Repeat("é", (discord.FieldValueLimit-len("…"))/2) + "…"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			e := Entry{Action: "edit topic", Target: test.before, Before: test.before, After: test.before}.Embed()
+
+			for _, f := range e.Fields {
+				if len(f.Value) > discord.FieldValueLimit {
+					t.Errorf("%s is %d bytes", f.Name, len(f.Value))
+				}
+				if !utf8.ValidString(f.Value) {
+					t.Errorf("%s isn't valid utf-8", f.Name)
+				}
+			}
+
+			if got := e.Fields[len(e.Fields)-1].Value; got != test.want {
+				t.Errorf("got after %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+/*
+Test Cases:
+- search by mention, nickname mention and id matches the actor
+- search by text matches actors and targets, ignoring case
+- only the guild's entries are found, newest first, at most n
+- a log that hasn't been written finds nothing
+- a broken line doesn't hide the rest
+*/
+func TestLog_Search(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit_search")
+	if err != nil {
+		t.Fatalf("temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	l := New(dir)
+	if got, err := l.Search(1, "anything", 10); err != nil || len(got) != 0 {
+		t.Fatalf("empty log found %v (err = %v)", got, err)
+	}
+
+	err = l.Append(
+		Entry{GuildId: 1, ActorId: 10, Actor: "alice", Action: "add topic", Target: "Racing Lines"},
+		Entry{GuildId: 1, ActorId: 11, Actor: "bob", Action: "bump topic", Target: "racing lines"},
+		Entry{GuildId: 2, ActorId: 10, Actor: "alice", Action: "add topic", Target: "Racing Lines"},
+	)
+	if err != nil {
+		t.Fatalf("append: %v", err)
+	}
+
+	// a crash mid-write leaves half a line
+	f, err := os.OpenFile(dir+"/"+FileName, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	_, _ = f.WriteString("{\"guild\": 1, \"act\n")
+	_ = f.Close()
+
+	if err := l.Append(Entry{GuildId: 1, ActorId: 10, Actor: "alice", Action: "remove topic", Target: "Tyres"}); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		n     int
+		want  []string // actions
+	}{
+		{name: "mention", query: "<@10>", n: 10, want: []string{"remove topic", "add topic"}},
+		{name: "nickname-mention", query: "<@!11>", n: 10, want: []string{"bump topic"}},
+		{name: "id", query: "10", n: 10, want: []string{"remove topic", "add topic"}},
+		{name: "target", query: "RACING", n: 10, want: []string{"bump topic", "add topic"}},
+		{name: "actor", query: "bo", n: 10, want: []string{"bump topic"}},
+		{name: "limit", query: "alice", n: 1, want: []string{"remove topic"}},
+		{name: "no-match", query: "pit stop", n: 10, want: nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := l.Search(1, test.query, test.n)
+			if err != nil {
+				t.Fatalf("search: %v", err)
+			}
+
+			var actions []string
+			for _, e := range got {
+				actions = append(actions, e.Action)
+			}
+			if strings.Join(actions, ",") != strings.Join(test.want, ",") {
+				t.Errorf("got %v, want %v", actions, test.want)
+			}
+		})
+	}
+}
+
+/*
+Test Cases:
+- only moderators can search
+- a search needs a query
+- matches are listed with their before and after values
+- other commands are ignored
+*/
+func TestInterceptor(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit_interceptor")
+	if err != nil {
+		t.Fatalf("temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	l := New(dir)
+	if err := l.Append(Entry{GuildId: 1, ActorId: 10, Actor: "alice", Action: "bump topic", Target: "x", Before: "position 3", After: "position 1"}); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+
+	i := Interceptor(l)
+	tests := []struct {
+		name      string
+		in        *gb.Message
+		wantTitle string
+		wantField string
+	}{
+		{name: "other-command", in: mock.NewMessage(gb.Queue, mock.WithGuild(1), mock.WithModerator(), mock.WithArgs("x"))},
+		{name: "not-moderator", in: mock.NewMessage(gb.Audit, mock.WithGuild(1), mock.WithArgs("x")), wantTitle: "Not Allowed"},
+		{name: "no-query", in: mock.NewMessage(gb.Audit, mock.WithGuild(1), mock.WithModerator()), wantTitle: "Too Few Args"},
+		{name: "found", in: mock.NewMessage(gb.Audit, mock.WithGuild(1), mock.WithModerator(), mock.WithArgs("<@10>")), wantTitle: "Audit: <@10>", wantField: "<@10> changed x\nposition 3 → position 1"},
+		{name: "other-guild", in: mock.NewMessage(gb.Audit, mock.WithGuild(2), mock.WithModerator(), mock.WithArgs("x")), wantTitle: "Audit: x"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := i(test.in); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			e := test.in.Response.Embed
+			if test.wantTitle == "" {
+				if e != nil {
+					t.Errorf("unexpected embed %q", e.Title)
+				}
+				return
+			}
+
+			if e == nil || e.Title != test.wantTitle {
+				t.Fatalf("embed = %+v, want %q", e, test.wantTitle)
+			}
+
+			var field string
+			if len(e.Fields) > 0 {
+				field = e.Fields[0].Value
+			}
+			if field != test.wantField {
+				t.Errorf("field = %q, want %q", field, test.wantField)
+			}
+		})
+	}
+}
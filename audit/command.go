@@ -0,0 +1,75 @@
+package audit
+
+import (
+	"errors"
+	"fmt"
+	gb "github.com/ericebersohl/gobottas"
+	"github.com/ericebersohl/gobottas/args"
+	"github.com/ericebersohl/gobottas/discord"
+	"time"
+)
+
+// Entries shown by a search
+const listed = 10
+
+// Returns the interceptor for the audit command, which lets moderators search the guild's changes
+func Interceptor(l *Log) gb.Interceptor {
+	return func(msg *gb.Message) error {
+		if msg.Command != gb.Audit {
+			return nil
+		}
+
+		if l == nil {
+			return errors.New("cannot intercept without an audit log")
+		}
+
+		msg.Response.ChannelId = msg.Source.ChannelId
+
+		if !msg.Source.Moderator {
+			msg.Response.Embed = discord.NewError("Not Allowed", "Only moderators can search the audit log.").Embed()
+			return nil
+		}
+
+		var a struct {
+			Query string `arg:"user|topic"`
+		}
+		if err := args.Parse("&audit", msg.Args, &a); err != nil {
+			return embedError(msg, err)
+		}
+
+		entries, err := l.Search(msg.Source.GuildId, a.Query, listed)
+		if err != nil {
+			return err
+		}
+
+		e := discord.NewEmbed().
+			EmbedColor(gb.ConfigCol).
+			EmbedTitle(fmt.Sprintf("Audit: %s", a.Query)).
+			EmbedTimestamp(time.Now())
+
+		for _, entry := range entries {
+			name := fmt.Sprintf("%s: %s", entry.Time.Format("Jan 2 15:04"), entry.Action)
+			value := fmt.Sprintf("<@%s> changed %s", entry.ActorId, orNone(entry.Target))
+			if entry.Before != "" || entry.After != "" {
+				value += fmt.Sprintf("\n%s → %s", orNone(entry.Before), orNone(entry.After))
+			}
+			e = e.AddField(name, truncate(value, discord.FieldValueLimit), false)
+		}
+
+		if len(entries) == 0 {
+			e = e.EmbedDescription("No changes match.")
+		}
+
+		msg.Response.Embed = e.MessageEmbed
+		return nil
+	}
+}
+
+// show discord errors to the user, pass anything else up
+func embedError(msg *gb.Message, err error) error {
+	if e, ok := err.(discord.Error); ok {
+		msg.Response.SetError(e.Embed())
+		return nil
+	}
+	return err
+}
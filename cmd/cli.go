@@ -0,0 +1,121 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	gb "github.com/ericebersohl/gobottas"
+	"github.com/ericebersohl/gobottas/config"
+	"github.com/ericebersohl/gobottas/meme"
+	"io"
+	"os"
+	"os/user"
+)
+
+// Run an operator subcommand against the data directory instead of starting the bot
+func runCLI(cfg *config.Config, args []string) error {
+	switch args[0] {
+	case "meme":
+		if len(args) < 2 {
+			return errors.New("usage: meme [export|import] -guild id ...")
+		}
+		return runMeme(cfg.Dir, args[1], args[2:])
+	default:
+		return fmt.Errorf("unknown subcommand %q", args[0])
+	}
+}
+
+// meme export -guild id [-format json|csv] [-o file]
+// meme import -guild id [-format json|csv] file
+func runMeme(dirPath, cmd string, args []string) error {
+	fs := flag.NewFlagSet("meme "+cmd, flag.ContinueOnError)
+	guild := fs.String("guild", "", "Id of the guild whose stash to use")
+	format := fs.String("format", "", "File format, json or csv (default: from the file extension, json for stdout)")
+	out := fs.String("o", "", "File to export to (default: stdout)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	// every guild has its own stash
+	id, err := gb.ToSnowflake(*guild)
+	if err != nil {
+		return errors.New("-guild must be the id of the guild whose stash to use")
+	}
+	s := meme.NewStashes(dirPath).Get(id)
+
+	switch cmd {
+	case "export":
+		f, err := fileFormat(*format, *out)
+		if err != nil {
+			return err
+		}
+
+		var w io.Writer = os.Stdout
+		if *out != "" {
+			file, err := os.Create(*out)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			w = file
+		}
+
+		return s.Export(w, f)
+
+	case "import":
+		if fs.NArg() < 1 {
+			return errors.New("usage: meme import -guild id [-format json|csv] file")
+		}
+
+		f, err := fileFormat(*format, fs.Arg(0))
+		if err != nil {
+			return err
+		}
+
+		file, err := os.Open(fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		// credit memes without an author to whoever ran the import
+		name := "Import"
+		if u, err := user.Current(); err == nil {
+			name = u.Username
+		}
+
+		memes, err := meme.Decode(file, f)
+		if err != nil {
+			return err
+		}
+		meme.FingerprintImages(memes, gb.NewLogger(os.Stderr))
+
+		added, skipped := s.Import(memes, name)
+
+		for _, m := range added {
+			fmt.Printf("added: %s\n", m.Meme)
+		}
+		fmt.Printf("%d added, %d duplicates skipped\n", len(added), skipped)
+
+		if len(added) == 0 {
+			return nil
+		}
+		return s.Save(s.LocalPath)
+
+	default:
+		return fmt.Errorf("unknown meme subcommand %q", cmd)
+	}
+}
+
+// use the format flag if set, otherwise go by the file name
+func fileFormat(format, name string) (string, error) {
+	if format != "" {
+		return format, nil
+	}
+
+	if name == "" {
+		return meme.FormatJSON, nil
+	}
+
+	return meme.FormatFromName(name)
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"flag"
+	"github.com/ericebersohl/gobottas/config"
+	"github.com/joho/godotenv"
+	"os"
+)
+
+// Command line flags; they override the config file and environment when they are given
+var (
+	configPath      string
+	printConfig     bool
+	channelBuffer   int
+	dirPath         string
+	prefix          string
+	discussionQueue bool
+	memeStash       bool
+	triggers        bool
+	logLevel        string
+	logFormat       string
+	metricsAddr     string
+	deadLetter      string
+)
+
+func init() {
+	flag.StringVar(&configPath, "config", "", "Load settings from a .yaml, .yml or .toml file (default: $"+config.EnvConfig+")")
+	flag.BoolVar(&printConfig, "print-config", false, "Print the effective config and exit")
+	flag.IntVar(&channelBuffer, "buf", config.DefaultChannelBuffer, "Set the buffer size for the message channel (must be greater than 0, 1 is an unbuffered channel)")
+	flag.StringVar(&dirPath, "dir", config.DefaultDirPath, "Set the location on the local machine for gobottas to store files")
+	flag.StringVar(&prefix, "prefix", "", "Set the command prefix for guilds that haven't chosen their own (default: &)")
+	flag.BoolVar(&memeStash, "m", false, "Enable the MemeStash feature in every guild")
+	flag.BoolVar(&discussionQueue, "q", false, "Enable the Discussion Queue feature in every guild")
+	flag.BoolVar(&triggers, "t", false, "Enable the auto-reply Trigger feature in every guild")
+	flag.StringVar(&logLevel, "log-level", config.DefaultLogLevel, "Log entries at this level and above: debug, info, warn or error")
+	flag.StringVar(&logFormat, "log-format", config.DefaultLogFormat, "Write logs as text or json")
+	flag.StringVar(&deadLetter, "dead-letter", "", "Append responses that could not be sent to this file (default: off)")
+	flag.StringVar(&metricsAddr, "metrics-addr", "", "Serve metrics and health checks on this address, e.g. :9090 (default: off)")
+}
+
+// Build the config from the file, the environment and the flags, in that order; flag.Parse must have been called
+func loadConfig() (*config.Config, error) {
+	// .env is a convenience for development; the environment may already have everything
+	if err := godotenv.Load(); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	c := config.Default()
+
+	if path := configFile(); path != "" {
+		if err := c.LoadFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := c.LoadEnv(os.Getenv); err != nil {
+		return nil, err
+	}
+
+	// only flags that were given override what came before
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "buf":
+			c.Buffer = channelBuffer
+		case "dir":
+			c.Dir = dirPath
+		case "prefix":
+			c.Prefix = prefix
+		case "m":
+			if memeStash {
+				c.Enable("meme")
+			}
+		case "q":
+			if discussionQueue {
+				c.Enable("queue")
+			}
+		case "t":
+			if triggers {
+				c.Enable("trigger")
+			}
+		case "log-level":
+			c.LogLevel = logLevel
+		case "log-format":
+			c.LogFormat = logFormat
+		case "metrics-addr":
+			c.MetricsAddr = metricsAddr
+		case "dead-letter":
+			c.DeadLetter = deadLetter
+		}
+	})
+
+	// the bot token is only needed to connect
+	if err := c.Validate(!printConfig && flag.NArg() == 0); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// The config file given with --config or in the environment, or "" if there isn't one
+func configFile() string {
+	if configPath != "" {
+		return configPath
+	}
+	return os.Getenv(config.EnvConfig)
+}
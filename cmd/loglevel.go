@@ -0,0 +1,27 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package main
+
+import (
+	gb "github.com/ericebersohl/gobottas"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// Turn on debug logging with SIGUSR1 and go back to the configured level with SIGUSR2
+func watchLogLevel(l *gb.Logger, configured gb.Level) {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGUSR1, syscall.SIGUSR2)
+
+	for sig := range c {
+		level := configured
+		if sig == syscall.SIGUSR1 {
+			level = gb.LevelDebug
+		}
+
+		l.SetLevel(level)
+		l.Warn("log level changed", "level", level, "signal", sig)
+	}
+}
@@ -0,0 +1,9 @@
+//go:build windows || plan9
+// +build windows plan9
+
+package main
+
+import gb "github.com/ericebersohl/gobottas"
+
+// There are no user signals here, so the level stays as configured
+func watchLogLevel(l *gb.Logger, configured gb.Level) {}
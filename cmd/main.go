@@ -2,36 +2,30 @@ package main
 
 import (
 	"flag"
-	"fmt"
 	"github.com/bwmarrin/discordgo"
 	gb "github.com/ericebersohl/gobottas"
+	"github.com/ericebersohl/gobottas/api"
+	"github.com/ericebersohl/gobottas/audit"
+	"github.com/ericebersohl/gobottas/config"
+	"github.com/ericebersohl/gobottas/confirm"
 	"github.com/ericebersohl/gobottas/core"
+	"github.com/ericebersohl/gobottas/dashboard"
+	"github.com/ericebersohl/gobottas/discord"
 	"github.com/ericebersohl/gobottas/discussion"
+	"github.com/ericebersohl/gobottas/dispatch"
+	"github.com/ericebersohl/gobottas/event"
+	"github.com/ericebersohl/gobottas/guild"
+	"github.com/ericebersohl/gobottas/journal"
 	"github.com/ericebersohl/gobottas/meme"
-	"github.com/joho/godotenv"
+	"github.com/ericebersohl/gobottas/metrics"
+	"github.com/ericebersohl/gobottas/trigger"
+	"github.com/ericebersohl/gobottas/webhook"
 	"log"
+	"net/http"
 	"os"
+	_ "time/tzdata" // meme of the day schedules need zone data, which slim images lack
 )
 
-const (
-	DefaultChannelBuffer = 15
-	DefaultDirPath       = "/store"
-)
-
-var (
-	channelBuffer   int
-	dirPath         string
-	discussionQueue bool
-	memeStash       bool
-)
-
-func init() {
-	flag.IntVar(&channelBuffer, "buf", DefaultChannelBuffer, "Set the buffer size for the message channel [Default: 15] (must be greater than 0, 1 is an unbuffered channel)")
-	flag.StringVar(&dirPath, "dir", DefaultDirPath, "Set the location on the local machine for gobottas to store files [Default: /store]")
-	flag.BoolVar(&memeStash, "m", false, "Whether to include the MemeStash feature (default = false)")
-	flag.BoolVar(&discussionQueue, "q", false, "Whether to include the Discussion Queue feature (default = false)")
-}
-
 // Returns a message handler for discord messages, a function is needed since we want the handler to have access to the channel
 func messageHandler(c chan *gb.Message, r gb.Registry) func(s *discordgo.Session, m *discordgo.MessageCreate) {
 	return func(s *discordgo.Session, m *discordgo.MessageCreate) {
@@ -44,7 +38,12 @@ func messageHandler(c chan *gb.Message, r gb.Registry) func(s *discordgo.Session
 		// Parse the message and send all messages go through the bot
 		msg, err := r.Parse(m.Message)
 		if err != nil {
-			log.Printf("ignoring message (id = %s) due to error: %v", m.ID, err)
+			msg.Log.Warn("failed to parse message", "id", m.ID, "err", err)
+		}
+
+		// permissions come from the session state rather than the message
+		if msg.Source != nil {
+			member(s, msg.Source, m.Author.ID, m.ChannelID, m.GuildID)
 		}
 
 		// send the parsed message through the channel
@@ -52,92 +51,384 @@ func messageHandler(c chan *gb.Message, r gb.Registry) func(s *discordgo.Session
 	}
 }
 
-// function to be run in goroutine that handles parsed Messages coming out of the channel
-func handleCommands(c chan *gb.Message, r gb.Registry, s *discordgo.Session) {
+// Returns a handler for reactions, which go through the bot like messages do, along with the message reacted to
+func reactionHandler(c chan *gb.Message, r gb.Registry) func(s *discordgo.Session, m *discordgo.MessageReactionAdd) {
+	return func(s *discordgo.Session, m *discordgo.MessageReactionAdd) {
+		// ignore the bot's own reactions, like those it adds to its messages for others to click
+		if s.State.User != nil && m.UserID == s.State.User.ID {
+			return
+		}
 
-	// wait for messages come through, block until they do
-	for msg := range c {
-		err := r.Intercept(msg)
+		// the message is usually in the state; older ones have to be fetched
+		target, err := s.State.Message(m.ChannelID, m.MessageID)
+		if err != nil {
+			target, err = s.ChannelMessage(m.ChannelID, m.MessageID)
+		}
+
+		msg, perr := r.ParseReaction(m.MessageReaction, target)
+		if perr != nil {
+			msg.Log.Warn("failed to parse reaction", "message", m.MessageID, "err", perr)
+		}
 		if err != nil {
-			log.Printf("CommandHandler: %v", err)
+			msg.Log.Warn("failed to get the message reacted to", "message", m.MessageID, "err", err)
 		}
 
-		if msg.Response.Embed != nil {
-			fmt.Printf("HANDLER: %v\n", msg.Response.Embed.Fields)
+		if msg.Source != nil {
+			member(s, msg.Source, m.UserID, m.ChannelID, m.GuildID)
 		}
 
-		err = r.Execute(msg, s)
+		c <- msg
+	}
+}
+
+// Returns a lookup of the guild a channel is in, from the session state or else from discord
+func channelGuild(s *discordgo.Session) func(gb.Snowflake) (gb.Snowflake, error) {
+	return func(channel gb.Snowflake) (gb.Snowflake, error) {
+		ch, err := s.State.Channel(channel.String())
 		if err != nil {
-			log.Printf("CommandHandler: %v", err)
+			if ch, err = s.Channel(channel.String()); err != nil {
+				return 0, err
+			}
 		}
+
+		// direct message channels have no guild
+		return gb.ToSnowflake(ch.GuildID)
 	}
 }
 
-func getRegistryOpts() (opts []core.RegistryOpt) {
-	// set the dir path
-	opts = append(opts, core.WithPath(dirPath))
+// Returns a list of the guilds the bot is in, from the session state
+func sessionGuilds(s *discordgo.Session) func() []gb.Snowflake {
+	return func() []gb.Snowflake {
+		s.State.RLock()
+		defer s.State.RUnlock()
 
-	// set discussion queue opts if applicable
-	if discussionQueue {
-		q := discussion.NewQueue()
-		opts = append(opts, core.WithQueue(q))
-		opts = append(opts, core.WithInterceptor(gb.Queue, discussion.Interceptor(q)))
+		var guilds []gb.Snowflake
+		for _, g := range s.State.Guilds {
+			if id, err := gb.ToSnowflake(g.ID); err == nil {
+				guilds = append(guilds, id)
+			}
+		}
+		return guilds
+	}
+}
+
+// Fill in what the source's author may do and which roles they have, from the session state or else from discord
+func member(s *discordgo.Session, src *gb.Source, userId, channelId, guildId string) {
+	if p, err := s.State.UserChannelPermissions(userId, channelId); err == nil {
+		// moderators can manage messages in the channel, admins can manage the guild
+		src.Moderator = p&discordgo.PermissionManageMessages != 0
+		src.Admin = p&(discordgo.PermissionAdministrator|discordgo.PermissionManageServer) != 0
 	}
 
-	// set the memeStash option
-	if memeStash {
-		s := meme.DefaultStash(dirPath)
-		opts = append(opts, core.WithStash(s))
-		opts = append(opts, core.WithInterceptor(gb.Meme, meme.Interceptor(&s)))
+	if guildId == "" {
+		return
 	}
 
+	m, err := s.State.Member(guildId, userId)
+	if err != nil {
+		if m, err = s.GuildMember(guildId, userId); err != nil {
+			return
+		}
+		_ = s.State.MemberAdd(m)
+	}
+
+	// reactions don't say who reacted beyond their id
+	if src.Username == "" && m.User != nil {
+		src.Username = m.User.Username
+	}
+
+	for _, r := range m.Roles {
+		if id, err := gb.ToSnowflake(r); err == nil {
+			src.Roles = append(src.Roles, id)
+		}
+	}
+}
+
+// function to be run in goroutine that handles parsed Messages coming out of the channel
+func handleCommands(c chan *gb.Message, r gb.Registry, s gb.Session) {
+
+	// wait for messages come through, block until they do; the registry logs failures with the message's correlation id
+	for msg := range c {
+		_ = r.Intercept(msg)
+		_ = r.Execute(msg, s)
+	}
+}
+
+func getRegistryOpts(cfg *config.Config, botId gb.Snowflake, logger *gb.Logger) (opts []core.RegistryOpt) {
+	dirPath := cfg.Dir
+
+	// log first, so that problems loading files are logged too
+	opts = append(opts, core.WithLogger(logger))
+
+	// set the dir path
+	opts = append(opts, core.WithPath(dirPath))
+	if cfg.Prefix != "" {
+		opts = append(opts, core.WithPrefix(cfg.Prefix))
+	}
+
+	// guild settings are always available
+	g := guild.NewStore(dirPath)
+	opts = append(opts, core.WithBotId(botId))
+	opts = append(opts, core.WithGuilds(g))
+
+	// commands that come too fast are dropped
+	opts = append(opts, core.WithCooldowns(cfg.Limiter(gb.SystemClock{})))
+
+	// removals wait for the author to confirm them
+	opts = append(opts, core.WithConfirmations(confirm.New(gb.SystemClock{})))
+
+	// every module is set up, and the registry only lets messages through to the ones enabled where they were sent
+	opts = append(opts, core.WithModules(cfg.EnabledModules(), cfg.GuildModules()))
+
+	// changes to the queues and the stashes can be undone
+	j := journal.New(dirPath)
+
+	// every guild has its own queue and stash
+	q := discussion.NewQueues(dirPath, discussion.WithJournal(j), discussion.WithLogger(logger))
+	opts = append(opts, core.WithQueues(q))
+	opts = append(opts, core.WithInterceptor(gb.Queue, discussion.Interceptor(q)))
+
+	stashes := meme.NewStashes(dirPath, meme.WithJournal(j), meme.WithLogger(logger))
+	opts = append(opts, core.WithStashes(stashes))
+	opts = append(opts, core.WithInterceptor(gb.Meme, meme.Interceptor(stashes)))
+	g.AddOption(moderationOption(stashes))
+
+	j.Register(discussion.JournalName, q)
+	j.Register(meme.JournalName, stashes)
+	opts = append(opts, core.WithJournal(j))
+
+	// modules hear about each other's events through the bus, and so do the services with webhooks
+	bus := event.New(event.WithLogger(logger))
+	webhook.New(cfg.Hooks(), webhook.WithLogger(logger)).Subscribe(bus)
+	opts = append(opts, core.WithEvents(bus))
+
+	// every change is kept in the audit file
+	opts = append(opts, core.WithAudit(audit.New(dirPath)))
+
+	t := trigger.NewSet(dirPath)
+	opts = append(opts, core.WithTriggers(t))
+	opts = append(opts, core.WithInterceptor(gb.Trigger, trigger.Interceptor(t, stashes)))
+
 	return opts
 }
 
+// Let guild admins turn meme approval on and off with `&config set meme.moderation`
+func moderationOption(stashes *meme.Stashes) *guild.Option {
+	o := guild.Option{
+		Name:  "meme.moderation",
+		Usage: "on or off; whether new memes need a moderator's approval",
+		Get: func(id gb.Snowflake) string {
+			stash := stashes.Get(id)
+			stash.Lock()
+			defer stash.Unlock()
+
+			if stash.IsModerated(id) {
+				return "on"
+			}
+			return "off"
+		},
+		Set: func(id gb.Snowflake, value string) error {
+			if value != "on" && value != "off" {
+				return discord.NewError("Invalid Value", "meme.moderation must be on or off.")
+			}
+
+			stash := stashes.Get(id)
+			stash.Lock()
+			defer stash.Unlock()
+
+			stash.SetModerated(id, value == "on")
+			return stash.Save(stash.LocalPath)
+		},
+	}
+	return &o
+}
+
 func main() {
 	// parse flags
 	flag.Parse()
 
+	// combine the config file, environment and flags
+	cfg, err := loadConfig()
+	if err != nil {
+		log.Fatalf("Failed to load config.\n%v", err)
+	}
+
+	// a dry run shows what the bot would run with
+	if printConfig {
+		if err := cfg.Write(os.Stdout); err != nil {
+			log.Fatalf("Failed to print config: %v", err)
+		}
+		return
+	}
+
+	// everything logs through one structured logger, including packages that use the standard log package
+	logger := cfg.Logger(os.Stderr)
+	log.SetFlags(0)
+	log.SetOutput(logger.Writer(gb.LevelWarn))
+	if path := configFile(); path != "" {
+		logger.Info("loaded config", "file", path)
+	}
+
+	// the level can be changed without a restart
+	level, _ := gb.ParseLevel(cfg.LogLevel)
+	go watchLogLevel(logger, level)
+
 	// create local file directory
-	err := os.MkdirAll(dirPath, 0755)
+	err = os.MkdirAll(cfg.Dir, 0755)
+	if err != nil {
+		fatal(logger, "failed to create the local directory", "dir", cfg.Dir, "err", err)
+	}
+
+	// operator subcommands work on the data directory and exit
+	if flag.NArg() > 0 {
+		if err := runCLI(cfg, flag.Args()); err != nil {
+			fatal(logger, "subcommand failed", "subcommand", flag.Arg(0), "err", err)
+		}
+		return
+	}
+
+	// Get Connection to Server
+	discord, err := discordgo.New("Bot " + cfg.Auth)
 	if err != nil {
-		log.Fatalf("Failed to create local directory at %s: %v", dirPath, err)
+		fatal(logger, "failed to create the discord client", "err", err)
 	}
 
-	// Load Environment Variables
-	err = godotenv.Load()
+	// keep recent messages, so that reactions to them don't need another request
+	discord.State.MaxMessageCount = 100
+
+	// the bot's own id is needed to recognize mentions as a prefix
+	me, err := discord.User("@me")
 	if err != nil {
-		log.Fatalf("Failed to load variables from .env.\n%v", err)
+		fatal(logger, "failed to get the bot user", "err", err)
+	}
+	botId, err := gb.ToSnowflake(me.ID)
+	if err != nil {
+		fatal(logger, "failed to parse the bot user id", "id", me.ID, "err", err)
 	}
 
 	// build a registry
-	registry := core.NewRegistry(getRegistryOpts()...)
+	opts := append(getRegistryOpts(cfg, botId, logger), core.WithChannels(channelGuild(discord)))
+	registry := core.NewRegistry(opts...)
 
 	// make a channel through which commands are sent and executed
-	cmdChannel := make(chan *gb.Message, channelBuffer)
+	cmdChannel := make(chan *gb.Message, cfg.Buffer)
 	defer close(cmdChannel)
 
-	// Get Connection to Server
-	discord, err := discordgo.New("Bot " + os.Getenv("AUTH"))
-	if err != nil {
-		log.Fatalf("Failed to create discord client.\n%v", err)
-	}
-
 	// add a new message handler
 	discord.AddHandler(messageHandler(cmdChannel, registry))
+	discord.AddHandler(reactionHandler(cmdChannel, registry))
+
+	// follow the session for health checks; discordgo reconnects on its own
+	health := metrics.NewHealth(gb.SystemClock{}, metrics.DefaultGrace)
+	discord.AddHandler(func(_ *discordgo.Session, _ *discordgo.Ready) {
+		health.Connected()
+		logger.Info("discord session ready")
+	})
+	discord.AddHandler(func(_ *discordgo.Session, _ *discordgo.Resumed) {
+		health.Connected()
+		logger.Info("discord session resumed")
+	})
+	discord.AddHandler(func(_ *discordgo.Session, _ *discordgo.Disconnect) {
+		health.Disconnected()
+		logger.Warn("discord session disconnected")
+	})
+
+	// serve metrics and health checks while connecting, so that probes see the bot start
+	if cfg.MetricsAddr != "" {
+		serveMetrics(cfg.MetricsAddr, cmdChannel, health, logger)
+	}
 
 	// Open the connection
 	if err := discord.Open(); err != nil {
-		log.Fatalf("Failed to open connection.\n%v", err)
+		fatal(logger, "failed to open the connection", "err", err)
 	}
 	defer discord.Close()
 
+	// every response goes out through the dispatcher, which keeps under discord's rate limits and retries
+	out, closeOut, err := dispatcher(cfg, discord, logger)
+	if err != nil {
+		fatal(logger, "failed to open the dead-letter file", "file", cfg.DeadLetter, "err", err)
+	}
+	defer closeOut()
+
 	// spin up a goroutine to handle any commands that come through the channel
-	go handleCommands(cmdChannel, registry, discord)
+	go handleCommands(cmdChannel, registry, out)
+
+	// scripts and the dashboard manage guilds' queues and stashes through the admin API, alongside chat commands
+	if cfg.APIAddr != "" {
+		srv := api.New(cfg.APIToken, registry.DiscussionQueues, registry.MemeStashes, registry, out,
+			api.WithLogger(logger), api.WithGuilds(sessionGuilds(discord)))
+		serveAPI(cfg.APIAddr, dashboard.Handler(srv), logger)
+	}
+
+	// post memes of the day as their schedules come due
+	if registry.MemeStashes != nil {
+		sched := meme.NewScheduler(registry.MemeStashes, gb.SystemClock{}, meme.WithEnabled(registry.Enabled))
+		go sched.Run(nil, func(msg *gb.Message) {
+			// the registry logs failures
+			_ = registry.Execute(msg, out)
+		})
+	}
 
 	// log that gobottas is running
-	log.Printf("Gobottas initialized with %d interceptors.", len(registry.Interceptors))
+	logger.Info("gobottas initialized", "interceptors", len(registry.Interceptors), "level", logger.Level())
 
 	// keep main open indefinitely
 	<-make(chan interface{})
 }
+
+// Log an error and exit
+func fatal(l *gb.Logger, msg string, kv ...interface{}) {
+	l.Error(msg, kv...)
+	os.Exit(1)
+}
+
+// Serve metrics and health checks on addr in the background
+func serveMetrics(addr string, c chan *gb.Message, health *metrics.Health, logger *gb.Logger) {
+	metrics.Default.NewGaugeFunc("gobottas_command_buffer_length", "Messages waiting in the command channel.", func() float64 {
+		return float64(len(c))
+	})
+	metrics.Default.NewGaugeFunc("gobottas_command_buffer_capacity", "Size of the command channel.", func() float64 {
+		return float64(cap(c))
+	})
+	metrics.Default.NewGaugeFunc("gobottas_discord_connected", "1 while the discord session is connected.", func() float64 {
+		if health.Ready() {
+			return 1
+		}
+		return 0
+	})
+
+	go func() {
+		logger.Info("serving metrics", "addr", addr)
+		if err := http.ListenAndServe(addr, metrics.Handler(metrics.Default, health)); err != nil {
+			logger.Error("metrics listener stopped", "addr", addr, "err", err)
+		}
+	}()
+}
+
+// Serve the admin API on addr in the background
+func serveAPI(addr string, h http.Handler, logger *gb.Logger) {
+	go func() {
+		logger.Info("serving the admin api and dashboard", "addr", addr)
+		if err := http.ListenAndServe(addr, h); err != nil {
+			logger.Error("admin api listener stopped", "addr", addr, "err", err)
+		}
+	}()
+}
+
+// Build the dispatcher in front of the session, with a dead-letter file if the config has one.  done closes the file.
+func dispatcher(cfg *config.Config, s gb.Session, logger *gb.Logger) (d *dispatch.Dispatcher, done func(), err error) {
+	opts := []dispatch.Opt{dispatch.WithLogger(logger)}
+	done = func() {}
+
+	if cfg.DeadLetter != "" {
+		f, err := os.OpenFile(cfg.DeadLetter, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return nil, nil, err
+		}
+		opts = append(opts, dispatch.WithDeadLetter(f))
+		done = func() { _ = f.Close() }
+	}
+
+	return dispatch.New(s, opts...), done, nil
+}
@@ -0,0 +1,334 @@
+// Package config builds the bot's startup configuration from, in increasing order of precedence, built-in defaults,
+// a YAML or TOML file, environment variables and command line flags.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	gb "github.com/ericebersohl/gobottas"
+	"github.com/ericebersohl/gobottas/guild"
+	"gopkg.in/yaml.v2"
+	"io"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Defaults for settings that aren't configured anywhere
+const (
+	DefaultDirPath       = "/store"
+	DefaultChannelBuffer = 15
+	DefaultLogLevel      = "info"
+	DefaultLogFormat     = "text"
+)
+
+// Environment variables that override the file
+const (
+	EnvAuth       = "AUTH"
+	EnvConfig     = "GOBOTTAS_CONFIG"
+	EnvDir        = "GOBOTTAS_DIR"
+	EnvBuffer     = "GOBOTTAS_BUFFER"
+	EnvPrefix     = "GOBOTTAS_PREFIX"
+	EnvModules    = "GOBOTTAS_MODULES" // comma separated, e.g. "queue,meme"
+	EnvLogLevel   = "GOBOTTAS_LOG_LEVEL"
+	EnvLogFormat  = "GOBOTTAS_LOG_FORMAT"
+	EnvMetrics    = "GOBOTTAS_METRICS_ADDR"
+	EnvDeadLetter = "GOBOTTAS_DEAD_LETTER"
+	EnvAPIAddr    = "GOBOTTAS_API_ADDR"
+	EnvAPIToken   = "GOBOTTAS_API_TOKEN"
+)
+
+// Shortest API token accepted, so that it can't be guessed
+const MinAPIToken = 16
+
+type Config struct {
+	Auth    string            `yaml:"auth,omitempty" toml:"auth"`     // bot token, usually set with AUTH
+	Dir     string            `yaml:"dir" toml:"dir"`                 // where gobottas stores files
+	Buffer  int               `yaml:"buffer" toml:"buffer"`           // size of the message channel; 1 is unbuffered
+	Prefix  string            `yaml:"prefix,omitempty" toml:"prefix"` // command prefix; empty for the built-in one
+	Modules []string          `yaml:"modules" toml:"modules"`         // features enabled in every guild
+	Guilds  map[string]*Guild `yaml:"guilds,omitempty" toml:"guilds"` // per-guild settings, by guild id
+
+	LogLevel  string `yaml:"log_level" toml:"log_level"`   // debug, info, warn or error
+	LogFormat string `yaml:"log_format" toml:"log_format"` // text, or json for log shipping
+
+	MetricsAddr string `yaml:"metrics_addr,omitempty" toml:"metrics_addr"` // e.g. ":9090" for metrics and health checks; empty for none
+	DeadLetter  string `yaml:"dead_letter,omitempty" toml:"dead_letter"`   // file that responses discord refused are appended to; empty for none
+
+	APIAddr  string `yaml:"api_addr,omitempty" toml:"api_addr"`   // e.g. "127.0.0.1:8080" for the admin API and dashboard; empty for none
+	APIToken string `yaml:"api_token,omitempty" toml:"api_token"` // bearer token the admin API requires, usually set with GOBOTTAS_API_TOKEN
+
+	Cooldowns *Cooldowns `yaml:"cooldowns,omitempty" toml:"cooldowns"` // how often commands can be used
+
+	Webhooks []*Webhook `yaml:"webhooks,omitempty" toml:"webhooks"` // services posted queue and meme events
+}
+
+// Settings for one guild
+type Guild struct {
+	Modules []string `yaml:"modules" toml:"modules"` // features enabled in the guild, replacing the global list
+}
+
+func Default() *Config {
+	c := Config{
+		Dir:    DefaultDirPath,
+		Buffer: DefaultChannelBuffer,
+
+		LogLevel:  DefaultLogLevel,
+		LogFormat: DefaultLogFormat,
+	}
+	return &c
+}
+
+// Read a YAML (.yaml, .yml) or TOML (.toml) file over the config.  Settings the file leaves out keep their values.
+func (c *Config) LoadFile(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.UnmarshalStrict(data, c)
+	case ".toml":
+		var md toml.MetaData
+		md, err = toml.Decode(string(data), c)
+		if err == nil && len(md.Undecoded()) > 0 {
+			err = fmt.Errorf("unknown setting %q", md.Undecoded()[0].String())
+		}
+	default:
+		return fmt.Errorf("config file %s must end in .yaml, .yml or .toml", path)
+	}
+
+	if err != nil {
+		return fmt.Errorf("config file %s: %v", path, err)
+	}
+	return nil
+}
+
+// Override the config with any environment variables that are set; getenv is usually os.Getenv
+func (c *Config) LoadEnv(getenv func(string) string) error {
+	if v := getenv(EnvAuth); v != "" {
+		c.Auth = v
+	}
+
+	if v := getenv(EnvDir); v != "" {
+		c.Dir = v
+	}
+
+	if v := getenv(EnvBuffer); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("%s: %v", EnvBuffer, err)
+		}
+		c.Buffer = n
+	}
+
+	if v := getenv(EnvPrefix); v != "" {
+		c.Prefix = v
+	}
+
+	if v := getenv(EnvModules); v != "" {
+		c.Modules = nil
+		for _, m := range strings.Split(v, ",") {
+			if m = strings.TrimSpace(m); m != "" {
+				c.Modules = append(c.Modules, m)
+			}
+		}
+	}
+
+	if v := getenv(EnvLogLevel); v != "" {
+		c.LogLevel = v
+	}
+
+	if v := getenv(EnvLogFormat); v != "" {
+		c.LogFormat = v
+	}
+
+	if v := getenv(EnvMetrics); v != "" {
+		c.MetricsAddr = v
+	}
+
+	if v := getenv(EnvDeadLetter); v != "" {
+		c.DeadLetter = v
+	}
+
+	if v := getenv(EnvAPIAddr); v != "" {
+		c.APIAddr = v
+	}
+
+	if v := getenv(EnvAPIToken); v != "" {
+		c.APIToken = v
+	}
+
+	return nil
+}
+
+// Turn a module on everywhere, e.g. from a command line flag
+func (c *Config) Enable(module string) {
+	for _, m := range c.Modules {
+		if m == module {
+			return
+		}
+	}
+	c.Modules = append(c.Modules, module)
+}
+
+// Check the config for mistakes, reporting all of them at once.  needAuth is false for runs that don't connect to
+// discord, like --print-config and the operator subcommands.
+func (c *Config) Validate(needAuth bool) error {
+	var problems []string
+
+	if needAuth && c.Auth == "" {
+		problems = append(problems, fmt.Sprintf("no bot token; set %s in the environment or a .env file", EnvAuth))
+	}
+
+	if c.Dir == "" {
+		problems = append(problems, "dir must not be empty")
+	}
+
+	if c.Buffer < 1 {
+		problems = append(problems, fmt.Sprintf("buffer must be greater than 0, not %d", c.Buffer))
+	}
+
+	if err := guild.ValidatePrefix(c.Prefix); err != nil {
+		problems = append(problems, fmt.Sprintf("prefix: %v", err))
+	}
+
+	if err := checkModules(c.Modules); err != nil {
+		problems = append(problems, fmt.Sprintf("modules: %v", err))
+	}
+
+	if _, err := gb.ParseLevel(c.LogLevel); err != nil {
+		problems = append(problems, fmt.Sprintf("log_level: %v", err))
+	}
+
+	if c.LogFormat != "text" && c.LogFormat != "json" {
+		problems = append(problems, fmt.Sprintf("log_format must be text or json, not %q", c.LogFormat))
+	}
+
+	if c.MetricsAddr != "" {
+		if _, _, err := net.SplitHostPort(c.MetricsAddr); err != nil {
+			problems = append(problems, fmt.Sprintf("metrics_addr: %v", err))
+		}
+	}
+
+	if c.APIAddr != "" {
+		if _, _, err := net.SplitHostPort(c.APIAddr); err != nil {
+			problems = append(problems, fmt.Sprintf("api_addr: %v", err))
+		}
+
+		if len(c.APIToken) < MinAPIToken {
+			problems = append(problems, fmt.Sprintf("api_token must be at least %d characters to serve the api; set %s", MinAPIToken, EnvAPIToken))
+		}
+	}
+
+	problems = append(problems, c.Cooldowns.problems()...)
+	problems = append(problems, webhookProblems(c.Webhooks)...)
+
+	for id, g := range c.Guilds {
+		if _, err := strconv.ParseUint(id, 10, 64); err != nil {
+			problems = append(problems, fmt.Sprintf("guild %q: ids are numbers", id))
+		}
+
+		if g == nil {
+			continue
+		}
+
+		if err := checkModules(g.Modules); err != nil {
+			problems = append(problems, fmt.Sprintf("guild %s modules: %v", id, err))
+		}
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+
+	sort.Strings(problems)
+	return errors.New("invalid config:\n\t" + strings.Join(problems, "\n\t"))
+}
+
+// Check that every module name is one gobottas has
+func checkModules(modules []string) error {
+	for _, m := range modules {
+		if _, ok := gb.Modules[m]; !ok {
+			var names []string
+			for n := range gb.Modules {
+				names = append(names, n)
+			}
+			sort.Strings(names)
+			return fmt.Errorf("unknown module %q; choose from %s", m, strings.Join(names, ", "))
+		}
+	}
+	return nil
+}
+
+// The commands of the modules enabled in every guild.  The config must be valid.
+func (c *Config) EnabledModules() []gb.Command {
+	return commands(c.Modules)
+}
+
+// The commands of the modules enabled in guilds that have their own list.  The config must be valid.
+func (c *Config) GuildModules() map[gb.Snowflake][]gb.Command {
+	guilds := make(map[gb.Snowflake][]gb.Command)
+	for id, g := range c.Guilds {
+		if g == nil {
+			continue
+		}
+
+		sf, _ := gb.ToSnowflake(id)
+		guilds[sf] = commands(g.Modules)
+	}
+	return guilds
+}
+
+func commands(modules []string) []gb.Command {
+	cmds := make([]gb.Command, 0, len(modules))
+	for _, m := range modules {
+		cmds = append(cmds, gb.Modules[m])
+	}
+	return cmds
+}
+
+// Build the logger the config asks for, writing to w.  The config must be valid.
+func (c *Config) Logger(w io.Writer) *gb.Logger {
+	level, _ := gb.ParseLevel(c.LogLevel)
+
+	opts := []gb.LoggerOpt{gb.LogLevel(level)}
+	if c.LogFormat == "json" {
+		opts = append(opts, gb.LogJSON())
+	}
+	return gb.NewLogger(w, opts...)
+}
+
+// Write the config as YAML, with the bot token, API token and webhook secrets hidden
+func (c *Config) Write(w io.Writer) error {
+	out := *c
+	if out.Auth != "" {
+		out.Auth = "<redacted>"
+	}
+	if out.APIToken != "" {
+		out.APIToken = "<redacted>"
+	}
+
+	out.Webhooks = nil
+	for _, h := range c.Webhooks {
+		if h != nil && h.Secret != "" {
+			hidden := *h
+			hidden.Secret = "<redacted>"
+			h = &hidden
+		}
+		out.Webhooks = append(out.Webhooks, h)
+	}
+
+	data, err := yaml.Marshal(&out)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(data)
+	return err
+}
@@ -0,0 +1,266 @@
+package config
+
+import (
+	"bytes"
+	gb "github.com/ericebersohl/gobottas"
+	"github.com/ericebersohl/gobottas/mock"
+	"github.com/ericebersohl/gobottas/webhook"
+	"github.com/google/go-cmp/cmp"
+	"strings"
+	"testing"
+	"time"
+)
+
+/*
+Test Cases:
+- yaml, toml: same config
+- unknown setting in yaml, in toml
+- missing file, unsupported extension
+*/
+func TestConfig_LoadFile(t *testing.T) {
+	want := &Config{
+		Dir:     "/data",
+		Buffer:  DefaultChannelBuffer,
+		Prefix:  "!",
+		Modules: []string{"queue", "meme"},
+		Guilds:  map[string]*Guild{"123": {Modules: []string{"trigger"}}},
+
+		LogLevel:  "debug",
+		LogFormat: DefaultLogFormat,
+
+		Cooldowns: &Cooldowns{
+			Feedback:    true,
+			ExemptRoles: []string{"42"},
+			Commands:    map[string]*Cooldown{"meme": {User: "10s", UserBurst: 2}},
+		},
+
+		Webhooks: []*Webhook{{Name: "wiki", URL: "https://wiki.example.com/hooks/gobottas", Secret: "shh", Events: []string{"topic.added", "topic.completed"}}},
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		wantErr bool
+	}{
+		{name: "yaml", path: "testdata/gobottas.yaml"},
+		{name: "toml", path: "testdata/gobottas.toml"},
+		{name: "unknown-yaml", path: "testdata/unknown.yaml", wantErr: true},
+		{name: "unknown-toml", path: "testdata/unknown.toml", wantErr: true},
+		{name: "missing", path: "testdata/missing.yaml", wantErr: true},
+		{name: "extension", path: "config_test.go", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := Default()
+			err := c.LoadFile(test.path)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("err != wantErr (err = %v, wantErr = %v)", err, test.wantErr)
+			}
+
+			if err == nil && !cmp.Equal(c, want) {
+				t.Errorf("got != want (%s)", cmp.Diff(want, c))
+			}
+		})
+	}
+}
+
+/*
+Test Cases:
+- unset variables keep the file's values
+- every variable set
+- bad buffer
+*/
+func TestConfig_LoadEnv(t *testing.T) {
+	env := func(vars map[string]string) func(string) string {
+		return func(k string) string { return vars[k] }
+	}
+
+	c := Default()
+	c.Modules = []string{"queue"}
+	if err := c.LoadEnv(env(nil)); err != nil || c.Dir != DefaultDirPath || len(c.Modules) != 1 {
+		t.Errorf("unset variables changed the config (err = %v): %+v", err, c)
+	}
+
+	err := c.LoadEnv(env(map[string]string{
+		EnvAuth:       "token",
+		EnvDir:        "/env",
+		EnvBuffer:     "3",
+		EnvPrefix:     "?",
+		EnvModules:    "meme, trigger,",
+		EnvLogLevel:   "warn",
+		EnvLogFormat:  "json",
+		EnvMetrics:    ":9090",
+		EnvDeadLetter: "/env/dead.jsonl",
+		EnvAPIAddr:    "127.0.0.1:8080",
+		EnvAPIToken:   "api-token",
+	}))
+	want := &Config{Auth: "token", Dir: "/env", Buffer: 3, Prefix: "?", Modules: []string{"meme", "trigger"}, LogLevel: "warn", LogFormat: "json", MetricsAddr: ":9090", DeadLetter: "/env/dead.jsonl", APIAddr: "127.0.0.1:8080", APIToken: "api-token"}
+	if err != nil || !cmp.Equal(c, want) {
+		t.Errorf("got != want (err = %v): %s", err, cmp.Diff(want, c))
+	}
+
+	if err := c.LoadEnv(env(map[string]string{EnvBuffer: "many"})); err == nil {
+		t.Errorf("no error for a bad buffer")
+	}
+}
+
+/*
+Test Cases:
+- default, with and without auth
+- every problem reported together
+*/
+func TestConfig_Validate(t *testing.T) {
+	if err := Default().Validate(false); err != nil {
+		t.Errorf("default config invalid: %v", err)
+	}
+
+	if err := Default().Validate(true); err == nil {
+		t.Errorf("missing auth not reported")
+	}
+
+	c := &Config{
+		Buffer:  0,
+		Prefix:  "a b",
+		Modules: []string{"queue", "music"},
+		Guilds:  map[string]*Guild{"main": {Modules: []string{"memes"}}},
+
+		LogLevel:  "loud",
+		LogFormat: "xml",
+
+		MetricsAddr: "9090",
+		APIAddr:     "8080",
+		APIToken:    "short",
+
+		Cooldowns: &Cooldowns{
+			ExemptRoles: []string{"mods"},
+			Default:     &Cooldown{User: "soon"},
+			Commands:    map[string]*Cooldown{"memes": {}, "dq": {Guild: "5s", GuildBurst: -1}},
+		},
+
+		Webhooks: []*Webhook{
+			{Name: "wiki", URL: "wiki.example.com", Secret: "shh"},
+			{Name: "wiki", URL: "https://example.com", Secret: "shh", Events: []string{"topic.renamed"}},
+			{URL: "https://example.com"},
+		},
+	}
+
+	err := c.Validate(true)
+	if err == nil {
+		t.Fatalf("no error")
+	}
+
+	for _, want := range []string{"AUTH", "dir", "buffer", "prefix", `"music"`, `"main"`, `"memes"`, "log_level", "log_format", "metrics_addr", "api_addr", "api_token", `"mods"`, `"soon"`, `"memes"`, "guild_burst", `"wiki.example.com"`, "names must be unique", `"topic.renamed"`, "name must not be empty", "secret must not be empty"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("problem with %s not reported:\n%v", want, err)
+		}
+	}
+}
+
+/*
+Test Cases:
+- Enable: new module, already enabled
+- module commands globally and per guild
+*/
+func TestConfig_Modules(t *testing.T) {
+	c := Default()
+	c.Enable("queue")
+	c.Enable("queue")
+	c.Guilds = map[string]*Guild{"123": {Modules: []string{"meme", "trigger"}}, "456": nil}
+
+	if got, want := c.EnabledModules(), []gb.Command{gb.Queue}; !cmp.Equal(got, want) {
+		t.Errorf("global modules: %s", cmp.Diff(want, got))
+	}
+
+	want := map[gb.Snowflake][]gb.Command{123: {gb.Meme, gb.Trigger}}
+	if got := c.GuildModules(); !cmp.Equal(got, want) {
+		t.Errorf("guild modules: %s", cmp.Diff(want, got))
+	}
+}
+
+/*
+Test Cases:
+- the token and webhook secrets are hidden, and the config reads back
+*/
+func TestConfig_Write(t *testing.T) {
+	c := Default()
+	c.Auth = "secret-token"
+	c.APIToken = "secret-api-token"
+	c.Modules = []string{"meme"}
+	c.Webhooks = []*Webhook{{Name: "wiki", URL: "https://example.com", Secret: "secret-key"}}
+
+	var buf bytes.Buffer
+	if err := c.Write(&buf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if strings.Contains(buf.String(), "secret-token") || strings.Contains(buf.String(), "secret-key") || strings.Contains(buf.String(), "secret-api-token") {
+		t.Errorf("secret printed:\n%s", buf.String())
+	}
+
+	if c.Auth != "secret-token" || c.Webhooks[0].Secret != "secret-key" {
+		t.Errorf("writing changed the config")
+	}
+
+	if !strings.Contains(buf.String(), "- meme") {
+		t.Errorf("modules missing:\n%s", buf.String())
+	}
+}
+
+/*
+Test Cases:
+- no cooldowns: no limiter
+- limits per command, default, exempt roles and feedback carried over
+*/
+func TestConfig_Limiter(t *testing.T) {
+	clock := mock.NewClock(time.Date(2020, 1, 2, 15, 4, 5, 0, time.UTC))
+	if l := Default().Limiter(clock); l != nil {
+		t.Errorf("limiter without cooldowns")
+	}
+
+	c := Default()
+	c.Cooldowns = &Cooldowns{
+		Feedback:    true,
+		ExemptRoles: []string{"42"},
+		Default:     &Cooldown{User: "1m"},
+		Commands:    map[string]*Cooldown{"dq": {Channel: "10s", ChannelBurst: 2}},
+	}
+	if err := c.Validate(false); err != nil {
+		t.Fatalf("invalid: %v", err)
+	}
+
+	l := c.Limiter(clock)
+	if !l.Feedback {
+		t.Errorf("feedback not set")
+	}
+
+	src := &gb.Source{AuthorId: 1, ChannelId: 2}
+	for i, want := range []bool{true, true, false} {
+		if ok, _, _ := l.Allow(gb.Queue, src); ok != want {
+			t.Errorf("dq use %d allowed = %t, want %t", i+1, ok, want)
+		}
+	}
+
+	l.Allow(gb.Meme, src)
+	if ok, wait, _ := l.Allow(gb.Meme, src); ok || wait != time.Minute {
+		t.Errorf("default not applied (ok = %t, wait = %s)", ok, wait)
+	}
+
+	if ok, _, _ := l.Allow(gb.Meme, &gb.Source{AuthorId: 1, Roles: []gb.Snowflake{42}}); !ok {
+		t.Errorf("exempt role limited")
+	}
+}
+
+/*
+Test Cases:
+- webhooks become hooks, with their events as kinds
+*/
+func TestConfig_Hooks(t *testing.T) {
+	c := Default()
+	c.Webhooks = []*Webhook{{Name: "wiki", URL: "https://example.com", Secret: "shh", Events: []string{"topic.added"}}}
+
+	want := []webhook.Hook{{Name: "wiki", URL: "https://example.com", Secret: "shh", Kinds: []string{"topic.added"}}}
+	if got := c.Hooks(); !cmp.Equal(got, want) {
+		t.Errorf("got != want (%s)", cmp.Diff(want, got))
+	}
+}
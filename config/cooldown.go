@@ -0,0 +1,129 @@
+package config
+
+import (
+	"fmt"
+	gb "github.com/ericebersohl/gobottas"
+	"github.com/ericebersohl/gobottas/cooldown"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Limits on how often commands can be used; without them there are no limits
+type Cooldowns struct {
+	Feedback    bool                 `yaml:"feedback" toml:"feedback"`                   // reply "try again in 5s" the first time a user is refused
+	ExemptRoles []string             `yaml:"exempt_roles,omitempty" toml:"exempt_roles"` // ids of roles that are never limited
+	Default     *Cooldown            `yaml:"default,omitempty" toml:"default"`           // for commands without their own limits
+	Commands    map[string]*Cooldown `yaml:"commands,omitempty" toml:"commands"`         // by command, e.g. meme or dq
+}
+
+// Limits on one command.  Each scope allows a burst of uses, then one per duration, such as 10s or 1m; a scope without
+// a duration isn't limited.
+type Cooldown struct {
+	User         string `yaml:"user,omitempty" toml:"user"`
+	UserBurst    int    `yaml:"user_burst,omitempty" toml:"user_burst"`
+	Channel      string `yaml:"channel,omitempty" toml:"channel"`
+	ChannelBurst int    `yaml:"channel_burst,omitempty" toml:"channel_burst"`
+	Guild        string `yaml:"guild,omitempty" toml:"guild"`
+	GuildBurst   int    `yaml:"guild_burst,omitempty" toml:"guild_burst"`
+}
+
+// problems with the cooldowns, for Validate
+func (c *Cooldowns) problems() []string {
+	if c == nil {
+		return nil
+	}
+
+	var problems []string
+
+	for _, r := range c.ExemptRoles {
+		if _, err := strconv.ParseUint(r, 10, 64); err != nil {
+			problems = append(problems, fmt.Sprintf("cooldowns: exempt role %q: ids are numbers", r))
+		}
+	}
+
+	if _, err := c.Default.rule(); err != nil {
+		problems = append(problems, fmt.Sprintf("cooldowns default: %v", err))
+	}
+
+	var names []string
+	for name := range c.Commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		switch gb.StrToCommand(name) {
+		case gb.Unrecognized, gb.None, gb.Error:
+			problems = append(problems, fmt.Sprintf("cooldowns: unknown command %q", name))
+			continue
+		}
+
+		if _, err := c.Commands[name].rule(); err != nil {
+			problems = append(problems, fmt.Sprintf("cooldowns %s: %v", name, err))
+		}
+	}
+
+	return problems
+}
+
+// convert the limits of one command
+func (c *Cooldown) rule() (cooldown.Rule, error) {
+	var r cooldown.Rule
+	if c == nil {
+		return r, nil
+	}
+
+	scopes := []struct {
+		name  string
+		every string
+		burst int
+		dst   *cooldown.Limit
+	}{
+		{"user", c.User, c.UserBurst, &r.User},
+		{"channel", c.Channel, c.ChannelBurst, &r.Channel},
+		{"guild", c.Guild, c.GuildBurst, &r.Guild},
+	}
+
+	for _, s := range scopes {
+		if s.burst < 0 {
+			return r, fmt.Errorf("%s_burst must not be negative", s.name)
+		}
+
+		if s.every == "" {
+			continue
+		}
+
+		d, err := time.ParseDuration(s.every)
+		if err != nil || d <= 0 {
+			return r, fmt.Errorf("%s must be a duration like 10s, not %q", s.name, s.every)
+		}
+		*s.dst = cooldown.Limit{Every: d, Burst: s.burst}
+	}
+
+	return r, nil
+}
+
+// Build the limiter the cooldowns describe, or nil if there are none.  The config must be valid.
+func (c *Config) Limiter(clock gb.Clock) *cooldown.Limiter {
+	if c.Cooldowns == nil {
+		return nil
+	}
+
+	opts := []cooldown.Opt{cooldown.WithFeedback(c.Cooldowns.Feedback)}
+
+	for _, r := range c.Cooldowns.ExemptRoles {
+		id, _ := gb.ToSnowflake(r)
+		opts = append(opts, cooldown.WithExemptRoles(id))
+	}
+
+	rule, _ := c.Cooldowns.Default.rule()
+	opts = append(opts, cooldown.WithDefault(rule))
+
+	for name, cd := range c.Cooldowns.Commands {
+		rule, _ := cd.rule()
+		opts = append(opts, cooldown.WithRule(gb.StrToCommand(name), rule))
+	}
+
+	return cooldown.New(clock, opts...)
+}
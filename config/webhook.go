@@ -0,0 +1,71 @@
+package config
+
+import (
+	"fmt"
+	"github.com/ericebersohl/gobottas/webhook"
+	"net/url"
+)
+
+// A service that is posted queue and meme events as they happen
+type Webhook struct {
+	Name   string   `yaml:"name" toml:"name"`               // for logs and metrics
+	URL    string   `yaml:"url" toml:"url"`                 // http or https
+	Secret string   `yaml:"secret,omitempty" toml:"secret"` // key the payloads are signed with
+	Events []string `yaml:"events,omitempty" toml:"events"` // e.g. topic.added; empty for every event
+}
+
+// problems with the webhooks, for Validate
+func webhookProblems(hooks []*Webhook) []string {
+	var problems []string
+	names := make(map[string]bool)
+
+	for i, h := range hooks {
+		if h == nil {
+			problems = append(problems, fmt.Sprintf("webhook %d is empty", i+1))
+			continue
+		}
+
+		name := h.Name
+		if name == "" {
+			problems = append(problems, fmt.Sprintf("webhook %d: name must not be empty", i+1))
+			name = fmt.Sprint(i + 1)
+		} else if names[name] {
+			problems = append(problems, fmt.Sprintf("webhook %q: names must be unique", name))
+		}
+		names[name] = true
+
+		if u, err := url.Parse(h.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			problems = append(problems, fmt.Sprintf("webhook %q: url must be an http or https url, not %q", name, h.URL))
+		}
+
+		if h.Secret == "" {
+			problems = append(problems, fmt.Sprintf("webhook %q: secret must not be empty", name))
+		}
+
+		for _, e := range h.Events {
+			if !knownKind(e) {
+				problems = append(problems, fmt.Sprintf("webhook %q: unknown event %q; choose from %v", name, e, webhook.Kinds))
+			}
+		}
+	}
+
+	return problems
+}
+
+func knownKind(kind string) bool {
+	for _, k := range webhook.Kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// The hooks to post events to.  The config must be valid.
+func (c *Config) Hooks() []webhook.Hook {
+	hooks := make([]webhook.Hook, 0, len(c.Webhooks))
+	for _, h := range c.Webhooks {
+		hooks = append(hooks, webhook.Hook{Name: h.Name, URL: h.URL, Secret: h.Secret, Kinds: h.Events})
+	}
+	return hooks
+}
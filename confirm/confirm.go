@@ -0,0 +1,174 @@
+// Package confirm keeps actions that wait for their author to confirm them.  The author answers by replying yes or
+// no in the channel, or by reacting to the prompt with ✅ or ❌, before the prompt expires.
+package confirm
+
+import (
+	"fmt"
+	gb "github.com/ericebersohl/gobottas"
+	"strings"
+	"sync"
+	"time"
+)
+
+// How long an author has to answer, unless the store is given another timeout
+const DefaultTimeout = 30 * time.Second
+
+// Reactions that answer a prompt
+const (
+	Yes = "✅"
+	No  = "❌"
+)
+
+// Expired prompts are kept this long, so that a late answer can be told it was too late
+const forgetAfter = 10 * time.Minute
+
+// How a message answers a prompt
+type Answer int
+
+const (
+	NotAnswer Answer = iota
+	Confirmed
+	Cancelled
+	Expired
+)
+
+func (a Answer) String() string {
+	return [...]string{"NotAnswer", "Confirmed", "Cancelled", "Expired"}[a]
+}
+
+// An action waiting for its author's answer
+type Pending struct {
+	Command gb.Command              // the command being confirmed
+	Prompt  string                  // the text sent to ask, which reactions are matched against
+	Action  func(*gb.Message) error // carries out the command
+	Expires time.Time
+}
+
+// Actions waiting for an answer, at most one per author in each channel
+type Store struct {
+	mu      sync.Mutex
+	clock   gb.Clock
+	timeout time.Duration
+	pending map[key]*Pending
+}
+
+type key struct {
+	channel gb.Snowflake
+	user    gb.Snowflake
+}
+
+type Opt func(*Store)
+
+func New(clock gb.Clock, opts ...Opt) *Store {
+	s := Store{
+		clock:   clock,
+		timeout: DefaultTimeout,
+		pending: make(map[key]*Pending),
+	}
+
+	for _, o := range opts {
+		o(&s)
+	}
+
+	return &s
+}
+
+func WithTimeout(d time.Duration) Opt {
+	return func(s *Store) {
+		s.timeout = d
+	}
+}
+
+// Keep c until the author of src answers it or it expires, replacing anything else they have waiting in the channel,
+// and return the prompt to send them.
+func (s *Store) Ask(cmd gb.Command, src *gb.Source, c *gb.Confirmation) string {
+	prompt := fmt.Sprintf("%s React %s or reply `yes` within %s.", c.Prompt, Yes, s.timeout)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.clock.Now()
+	s.prune(now)
+
+	s.pending[key{src.ChannelId, src.AuthorId}] = &Pending{
+		Command: cmd,
+		Prompt:  prompt,
+		Action:  c.Action,
+		Expires: now.Add(s.timeout),
+	}
+	return prompt
+}
+
+// Find the action that msg answers, if any: one its author has waiting in the channel, answered with yes or no, or a
+// reaction to the prompt.  An answered action is forgotten, whether it was confirmed, cancelled or too late.
+func (s *Store) Answer(msg *gb.Message) (*Pending, Answer) {
+	if msg.Source == nil {
+		return nil, NotAnswer
+	}
+
+	var yes bool
+	switch msg.Command {
+	case gb.None:
+		switch strings.ToLower(strings.TrimSpace(msg.Source.Content)) {
+		case "yes", "y", Yes:
+			yes = true
+		case "no", "n", No:
+		default:
+			return nil, NotAnswer
+		}
+
+	case gb.Reaction:
+		if msg.Reaction == nil || !msg.Reaction.FromBot {
+			return nil, NotAnswer
+		}
+		switch msg.Reaction.Emoji {
+		case Yes:
+			yes = true
+		case No:
+		default:
+			return nil, NotAnswer
+		}
+
+	default:
+		return nil, NotAnswer
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := key{msg.Source.ChannelId, msg.Source.AuthorId}
+	p, ok := s.pending[k]
+	if !ok {
+		return nil, NotAnswer
+	}
+
+	// reactions to the bot's other messages aren't answers
+	if msg.Command == gb.Reaction && msg.Reaction.Content != p.Prompt {
+		return nil, NotAnswer
+	}
+
+	delete(s.pending, k)
+	switch {
+	case s.clock.Now().After(p.Expires):
+		return p, Expired
+	case yes:
+		return p, Confirmed
+	}
+	return p, Cancelled
+}
+
+// The number of actions waiting, including expired ones not yet forgotten
+func (s *Store) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.pending)
+}
+
+// forget actions that expired a while ago; the caller holds the lock
+func (s *Store) prune(now time.Time) {
+	for k, p := range s.pending {
+		if now.Sub(p.Expires) > forgetAfter {
+			delete(s.pending, k)
+		}
+	}
+}
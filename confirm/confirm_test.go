@@ -0,0 +1,88 @@
+package confirm
+
+import (
+	gb "github.com/ericebersohl/gobottas"
+	"github.com/ericebersohl/gobottas/mock"
+	"testing"
+	"time"
+)
+
+/*
+Test Cases:
+- other users, other channels and other chat don't answer
+- yes from the author confirms
+- no cancels
+- reactions only answer on the prompt itself, from the bot
+- an answer after the timeout is too late
+- a new prompt replaces the old one
+- expired prompts are forgotten after a while
+*/
+func TestStore_Answer(t *testing.T) {
+	clock := mock.NewClock(time.Date(2020, 1, 2, 15, 4, 5, 0, time.UTC))
+	s := New(clock, WithTimeout(30*time.Second))
+
+	ask := func(channel, user gb.Snowflake, prompt string) string {
+		return s.Ask(gb.Queue, &gb.Source{ChannelId: channel, AuthorId: user}, &gb.Confirmation{Prompt: prompt})
+	}
+	chat := func(channel, user gb.Snowflake, content string) *gb.Message {
+		return mock.NewMessage(gb.None, mock.WithSource(user, channel, "user", content))
+	}
+	react := func(channel, user gb.Snowflake, emoji, content string, fromBot bool) *gb.Message {
+		msg := mock.NewMessage(gb.None, mock.WithSource(user, channel, "user", ""), mock.WithReaction(emoji, 7, fromBot))
+		msg.Reaction.Content = content
+		return msg
+	}
+
+	prompt := ask(1, 2, "Remove topic \"x\"?")
+	if want := "Remove topic \"x\"? React ✅ or reply `yes` within 30s."; prompt != want {
+		t.Fatalf("prompt = %q, want %q", prompt, want)
+	}
+
+	tests := []struct {
+		name    string
+		before  func()
+		msg     func() *gb.Message
+		advance time.Duration
+		want    Answer
+	}{
+		{name: "other-user", msg: func() *gb.Message { return chat(1, 3, "yes") }, want: NotAnswer},
+		{name: "other-channel", msg: func() *gb.Message { return chat(4, 2, "yes") }, want: NotAnswer},
+		{name: "chat", msg: func() *gb.Message { return chat(1, 2, "yes please") }, want: NotAnswer},
+		{name: "command", msg: func() *gb.Message { return mock.NewMessage(gb.Queue, mock.WithSource(2, 1, "user", "yes")) }, want: NotAnswer},
+		{name: "confirmed", msg: func() *gb.Message { return chat(1, 2, " YES ") }, want: Confirmed},
+		{name: "answered", msg: func() *gb.Message { return chat(1, 2, "yes") }, want: NotAnswer},
+
+		{name: "cancelled", before: func() { ask(1, 2, "Remove?") }, msg: func() *gb.Message { return chat(1, 2, "n") }, want: Cancelled},
+
+		{name: "other-emoji", before: func() { prompt = ask(1, 2, "Remove?") }, msg: func() *gb.Message { return react(1, 2, "👍", prompt, true) }, want: NotAnswer},
+		{name: "not-bot", msg: func() *gb.Message { return react(1, 2, Yes, prompt, false) }, want: NotAnswer},
+		{name: "other-message", msg: func() *gb.Message { return react(1, 2, Yes, "Topics", true) }, want: NotAnswer},
+		{name: "reaction-cancel", msg: func() *gb.Message { return react(1, 2, No, prompt, true) }, want: Cancelled},
+		{name: "reaction-yes", before: func() { prompt = ask(1, 2, "Remove?") }, msg: func() *gb.Message { return react(1, 2, Yes, prompt, true) }, want: Confirmed},
+
+		{name: "expired", before: func() { ask(1, 2, "Remove?") }, advance: 31 * time.Second, msg: func() *gb.Message { return chat(1, 2, "yes") }, want: Expired},
+
+		{name: "replaced", before: func() { ask(1, 2, "Remove a?"); prompt = ask(1, 2, "Remove b?") }, msg: func() *gb.Message { return react(1, 2, Yes, prompt, true) }, want: Confirmed},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.before != nil {
+				test.before()
+			}
+			clock.Advance(test.advance)
+
+			if _, got := s.Answer(test.msg()); got != test.want {
+				t.Errorf("answer = %s, want %s", got, test.want)
+			}
+		})
+	}
+
+	// an unanswered prompt is forgotten once it has been expired for a while
+	ask(1, 2, "Remove?")
+	clock.Advance(time.Minute + forgetAfter)
+	ask(5, 6, "Remove?")
+	if s.Len() != 1 {
+		t.Errorf("%d prompts kept, want 1", s.Len())
+	}
+}
@@ -0,0 +1,218 @@
+// Package cooldown keeps users from flooding the bot with commands.  Each command can be limited per user, per channel
+// and per guild; a limit allows a burst of uses and then one use per period.
+package cooldown
+
+import (
+	gb "github.com/ericebersohl/gobottas"
+	"sync"
+	"time"
+)
+
+// Burst uses, then one more every Every.  A zero Every is no limit.
+type Limit struct {
+	Every time.Duration
+	Burst int // at least 1
+}
+
+// The limits on one command
+type Rule struct {
+	User    Limit // for each user, across channels
+	Channel Limit // for everyone in a channel
+	Guild   Limit // for everyone in a guild
+}
+
+// Checks commands against the rules, keeping track of recent uses
+type Limiter struct {
+	mu       sync.Mutex
+	clock    gb.Clock
+	rules    map[gb.Command]Rule
+	fallback Rule // for commands without their own rule
+	exempt   map[gb.Snowflake]bool
+	buckets  map[key]*bucket
+	calls    int // since buckets were last pruned
+
+	// tell users when they are on cooldown, once per cooldown
+	Feedback bool
+}
+
+// scopes of a limit
+const (
+	scopeUser = iota
+	scopeChannel
+	scopeGuild
+)
+
+type key struct {
+	cmd   gb.Command
+	scope int
+	id    gb.Snowflake
+}
+
+// uses left, refilled one per limit period
+type bucket struct {
+	tokens float64
+	last   time.Time
+	full   time.Time // when every use has come back, after which the bucket can be forgotten
+	warned bool      // feedback was given since the last allowed use
+}
+
+// Allow calls between pruning buckets that have filled up
+const pruneEvery = 1024
+
+type Opt func(*Limiter)
+
+func New(clock gb.Clock, opts ...Opt) *Limiter {
+	l := Limiter{
+		clock:   clock,
+		rules:   make(map[gb.Command]Rule),
+		exempt:  make(map[gb.Snowflake]bool),
+		buckets: make(map[key]*bucket),
+	}
+
+	for _, o := range opts {
+		o(&l)
+	}
+
+	return &l
+}
+
+// limit a command; replaces the default rule for it
+func WithRule(c gb.Command, r Rule) Opt {
+	return func(l *Limiter) {
+		l.rules[c] = r
+	}
+}
+
+// limit commands that don't have their own rule
+func WithDefault(r Rule) Opt {
+	return func(l *Limiter) {
+		l.fallback = r
+	}
+}
+
+// never limit members with any of the roles
+func WithExemptRoles(roles ...gb.Snowflake) Opt {
+	return func(l *Limiter) {
+		for _, r := range roles {
+			l.exempt[r] = true
+		}
+	}
+}
+
+func WithFeedback(on bool) Opt {
+	return func(l *Limiter) {
+		l.Feedback = on
+	}
+}
+
+// Check whether the author of src may use c now, and record the use if so.  When they may not, wait is how long
+// until they can, and warn is true the first time they are refused in a row, so feedback doesn't become spam itself.
+func (l *Limiter) Allow(c gb.Command, src *gb.Source) (ok bool, wait time.Duration, warn bool) {
+	if src == nil {
+		return true, 0, false
+	}
+
+	for _, r := range src.Roles {
+		if l.exempt[r] {
+			return true, 0, false
+		}
+	}
+
+	rule, found := l.rules[c]
+	if !found {
+		rule = l.fallback
+	}
+
+	type check struct {
+		k     key
+		limit Limit
+	}
+	checks := []check{
+		{key{c, scopeUser, src.AuthorId}, rule.User},
+		{key{c, scopeChannel, src.ChannelId}, rule.Channel},
+	}
+	// direct messages have no guild
+	if src.GuildId != 0 {
+		checks = append(checks, check{key{c, scopeGuild, src.GuildId}, rule.Guild})
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.clock.Now()
+	l.prune(now)
+
+	// every scope must allow the use before any of them counts it
+	var used []check
+	var buckets []*bucket
+	for _, ch := range checks {
+		if ch.limit.Every <= 0 {
+			continue
+		}
+
+		b := l.refill(ch.k, ch.limit, now)
+		if b.tokens < 1 {
+			w := time.Duration((1 - b.tokens) * float64(ch.limit.Every))
+			if w > wait {
+				wait = w
+			}
+			if !b.warned {
+				warn = true
+				b.warned = true
+			}
+		}
+		used = append(used, ch)
+		buckets = append(buckets, b)
+	}
+
+	if wait > 0 {
+		return false, wait, warn
+	}
+
+	for i, b := range buckets {
+		b.tokens--
+		b.warned = false
+		b.full = now.Add(time.Duration((burst(used[i].limit) - b.tokens) * float64(used[i].limit.Every)))
+	}
+	return true, 0, false
+}
+
+// forget buckets that are full again, which are the same as new ones; the caller holds the lock
+func (l *Limiter) prune(now time.Time) {
+	l.calls++
+	if l.calls < pruneEvery {
+		return
+	}
+	l.calls = 0
+
+	for k, b := range l.buckets {
+		if !now.Before(b.full) {
+			delete(l.buckets, k)
+		}
+	}
+}
+
+// get the bucket for k with the uses that have come back since it was last used; the caller holds the lock
+func (l *Limiter) refill(k key, limit Limit, now time.Time) *bucket {
+	b, ok := l.buckets[k]
+	if !ok {
+		b = &bucket{tokens: burst(limit), last: now}
+		l.buckets[k] = b
+		return b
+	}
+
+	b.tokens += float64(now.Sub(b.last)) / float64(limit.Every)
+	if b.tokens > burst(limit) {
+		b.tokens = burst(limit)
+	}
+	b.last = now
+	return b
+}
+
+// uses allowed back to back; a limit always allows at least one
+func burst(limit Limit) float64 {
+	if limit.Burst < 1 {
+		return 1
+	}
+	return float64(limit.Burst)
+}
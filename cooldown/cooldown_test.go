@@ -0,0 +1,94 @@
+package cooldown
+
+import (
+	gb "github.com/ericebersohl/gobottas"
+	"github.com/ericebersohl/gobottas/mock"
+	"testing"
+	"time"
+)
+
+/*
+Test Cases:
+- burst allowed, then refused with the wait and a single warning
+- allowed again once a use comes back
+- other users aren't limited by the user limit, but are by the channel limit
+- guild limit across channels, none in direct messages
+- commands without a rule use the default; exempt roles are never limited
+*/
+func TestLimiter_Allow(t *testing.T) {
+	clock := mock.NewClock(time.Date(2020, 1, 2, 15, 4, 5, 0, time.UTC))
+	l := New(clock,
+		WithRule(gb.Meme, Rule{
+			User:    Limit{Every: 10 * time.Second, Burst: 2},
+			Channel: Limit{Every: time.Second, Burst: 3},
+		}),
+		WithRule(gb.Queue, Rule{Guild: Limit{Every: 5 * time.Second}}),
+		WithDefault(Rule{User: Limit{Every: time.Minute}}),
+		WithExemptRoles(99),
+	)
+
+	src := func(user, channel, guild gb.Snowflake, roles ...gb.Snowflake) *gb.Source {
+		return &gb.Source{AuthorId: user, ChannelId: channel, GuildId: guild, Roles: roles}
+	}
+
+	tests := []struct {
+		name     string
+		advance  time.Duration
+		cmd      gb.Command
+		src      *gb.Source
+		wantOk   bool
+		wantWait time.Duration
+		wantWarn bool
+	}{
+		{name: "burst-1", cmd: gb.Meme, src: src(1, 1, 1), wantOk: true},
+		{name: "burst-2", cmd: gb.Meme, src: src(1, 1, 1), wantOk: true},
+		{name: "user-limit", cmd: gb.Meme, src: src(1, 1, 1), wantWait: 10 * time.Second, wantWarn: true},
+		{name: "no-repeat-warning", advance: 4 * time.Second, cmd: gb.Meme, src: src(1, 1, 1), wantWait: 6 * time.Second},
+		{name: "other-user", cmd: gb.Meme, src: src(2, 1, 1), wantOk: true},
+		{name: "channel-limit", cmd: gb.Meme, src: src(3, 1, 1), wantOk: true},
+		{name: "channel-limit-2", cmd: gb.Meme, src: src(5, 1, 1), wantOk: true},
+		{name: "channel-full", cmd: gb.Meme, src: src(4, 1, 1), wantWait: time.Second, wantWarn: true},
+		{name: "other-channel", cmd: gb.Meme, src: src(4, 2, 1), wantOk: true},
+		{name: "refilled", advance: 6 * time.Second, cmd: gb.Meme, src: src(1, 1, 1), wantOk: true},
+		{name: "warn-again", cmd: gb.Meme, src: src(1, 1, 1), wantWait: 10 * time.Second, wantWarn: true},
+
+		{name: "guild", cmd: gb.Queue, src: src(1, 1, 1), wantOk: true},
+		{name: "guild-limit", cmd: gb.Queue, src: src(2, 2, 1), wantWait: 5 * time.Second, wantWarn: true},
+		{name: "direct-message", cmd: gb.Queue, src: src(2, 3, 0), wantOk: true},
+
+		{name: "default", cmd: gb.Trigger, src: src(1, 1, 1), wantOk: true},
+		{name: "default-limit", cmd: gb.Trigger, src: src(1, 1, 1), wantWait: time.Minute, wantWarn: true},
+		{name: "exempt", cmd: gb.Trigger, src: src(1, 1, 1, 5, 99), wantOk: true},
+		{name: "nil-source", cmd: gb.Trigger, wantOk: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			clock.Advance(test.advance)
+
+			ok, wait, warn := l.Allow(test.cmd, test.src)
+			if ok != test.wantOk || wait != test.wantWait || warn != test.wantWarn {
+				t.Errorf("got (%t, %s, %t), want (%t, %s, %t)", ok, wait, warn, test.wantOk, test.wantWait, test.wantWarn)
+			}
+		})
+	}
+}
+
+/*
+Test Cases:
+- buckets that have filled up are forgotten
+*/
+func TestLimiter_prune(t *testing.T) {
+	clock := mock.NewClock(time.Date(2020, 1, 2, 15, 4, 5, 0, time.UTC))
+	l := New(clock, WithDefault(Rule{User: Limit{Every: time.Second}}))
+
+	for i := 0; i < pruneEvery-1; i++ {
+		l.Allow(gb.Meme, &gb.Source{AuthorId: gb.Snowflake(i)})
+	}
+
+	clock.Advance(time.Second)
+	l.Allow(gb.Meme, &gb.Source{AuthorId: 0})
+	if len(l.buckets) != 1 {
+		t.Errorf("%d buckets kept, want 1", len(l.buckets))
+	}
+}
@@ -5,35 +5,76 @@ import (
 	"fmt"
 	"github.com/bwmarrin/discordgo"
 	gb "github.com/ericebersohl/gobottas"
+	"github.com/ericebersohl/gobottas/args"
+	"github.com/ericebersohl/gobottas/audit"
+	"github.com/ericebersohl/gobottas/config"
+	"github.com/ericebersohl/gobottas/confirm"
+	"github.com/ericebersohl/gobottas/cooldown"
+	"github.com/ericebersohl/gobottas/discord"
 	"github.com/ericebersohl/gobottas/discussion"
+	"github.com/ericebersohl/gobottas/event"
+	"github.com/ericebersohl/gobottas/guild"
+	"github.com/ericebersohl/gobottas/journal"
 	"github.com/ericebersohl/gobottas/meme"
-	"log"
+	"github.com/ericebersohl/gobottas/metrics"
+	"github.com/ericebersohl/gobottas/trigger"
+	"math"
 	"os"
-	"regexp"
-	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
 )
 
-const (
-	DefaultCommandPrefix = '&'
-	DefaultDirPath       = "/store"
-)
+const DefaultCommandPrefix = "&"
 
 // Contains Gobottas functions and data
 type Registry struct {
-	Interceptors    map[gb.Command]gb.Interceptor // all built-in interceptors
-	DirPath         string                        // path to local data
-	CommandPrefix   uint8                         // character that precedes all Gobottas commands
-	DiscussionQueue *discussion.Queue             // the data structure that holds discussion queue data
-	MemeStash       meme.Stash                    // The list of memes to be returned at random from the meme command
+	Interceptors     map[gb.Command]gb.Interceptor // all built-in interceptors
+	DirPath          string                        // path to local data
+	CommandPrefix    string                        // precedes Gobottas commands in guilds that haven't set their own
+	BotId            gb.Snowflake                  // the bot's user id; mentioning the bot works as a prefix
+	Guilds           *guild.Store                  // per-guild settings such as the prefix
+	DiscussionQueues *discussion.Queues            // every guild's discussion queue
+	MemeStashes      *meme.Stashes                 // every guild's list of memes to be returned at random from the meme command
+	Triggers         *trigger.Set                  // patterns that Gobottas auto-replies to in normal chat
+	Log              *gb.Logger                    // every message gets a child logger with its correlation id
+	Cooldowns        *cooldown.Limiter             // keeps users from flooding commands; nil for no limits
+	Confirmations    *confirm.Store                // actions waiting for their author to confirm them; nil runs them right away
+	Journal          *journal.Journal              // changes to the stores, which can be undone
+	Audit            *audit.Log                    // who changed what; nil keeps no record
+	Events           *event.Bus                    // where the events that messages publish go; nil drops them
+
+	// looks up the guild a channel is in, from discord; nil knows no channels
+	Channels func(channel gb.Snowflake) (gb.Snowflake, error)
+
+	// modules enabled everywhere, and in guilds with their own list; nil enables everything
+	Modules      map[gb.Command]bool
+	GuildModules map[gb.Snowflake]map[gb.Command]bool
+
+	mentions []string // the ways a message can start by mentioning the bot
+
+	// subcommands seen per command, to keep typos out of the metrics
+	subMu       sync.Mutex
+	subcommands map[gb.Command]map[string]bool
+
+	// messages that outputs with an Update key edit in place
+	updMu   sync.Mutex
+	updates map[update]string
 }
 
+// Subcommands counted per command before the rest are counted as "other"
+const maxSubcommands = 16
+
 type RegistryOpt func(*Registry)
 
 func NewRegistry(opts ...RegistryOpt) *Registry {
 	r := Registry{
 		Interceptors:  make(map[gb.Command]gb.Interceptor),
-		DirPath:       DefaultDirPath,
+		DirPath:       config.DefaultDirPath,
 		CommandPrefix: DefaultCommandPrefix,
+		Log:           gb.NewLogger(os.Stderr),
 	}
 
 	for _, o := range opts {
@@ -44,22 +85,40 @@ func NewRegistry(opts ...RegistryOpt) *Registry {
 }
 
 // Opt Functions
-func WithQueue(q *discussion.Queue) RegistryOpt {
+
+// load the saved queues into qs, which should be the queues the discussion interceptor uses
+func WithQueues(qs *discussion.Queues) RegistryOpt {
 	return func(r *Registry) {
-		// check if json file exists
-		if _, err := os.Stat(fmt.Sprintf("%s/queue.json", r.DirPath)); !os.IsNotExist(err) {
-			err = q.Load(r.DirPath)
-			if err != nil {
-				log.Printf("Failed to load from JSON, using new Queue.")
-				q = discussion.NewQueue()
-			}
+		if err := qs.Load(); err != nil {
+			r.Log.Warn("failed to load the discussion queues", "err", err)
 		}
 
-		r.DiscussionQueue = q
+		r.DiscussionQueues = qs
 	}
 }
 
-func WithPrefix(p uint8) RegistryOpt {
+// log with l instead of text on stderr; it should come before options that load files
+func WithLogger(l *gb.Logger) RegistryOpt {
+	return func(r *Registry) {
+		r.Log = l
+	}
+}
+
+// limit how often commands can be used
+func WithCooldowns(l *cooldown.Limiter) RegistryOpt {
+	return func(r *Registry) {
+		r.Cooldowns = l
+	}
+}
+
+// ask authors to confirm actions that can't be taken back
+func WithConfirmations(c *confirm.Store) RegistryOpt {
+	return func(r *Registry) {
+		r.Confirmations = c
+	}
+}
+
+func WithPrefix(p string) RegistryOpt {
 	return func(r *Registry) {
 		r.CommandPrefix = p
 	}
@@ -78,19 +137,114 @@ func WithPath(s string) RegistryOpt {
 	}
 }
 
-func WithStash(s meme.Stash) RegistryOpt {
+// let messages that start by mentioning the bot be commands, e.g. "@Gobottas dq list"
+func WithBotId(id gb.Snowflake) RegistryOpt {
+	return func(r *Registry) {
+		r.BotId = id
+		// users with a nickname are mentioned with an exclamation mark
+		r.mentions = []string{fmt.Sprintf("<@%s>", id), fmt.Sprintf("<@!%s>", id)}
+	}
+}
+
+// enable modules in every guild, and per guild; a guild's list replaces the global one
+func WithModules(global []gb.Command, guilds map[gb.Snowflake][]gb.Command) RegistryOpt {
+	return func(r *Registry) {
+		r.Modules = make(map[gb.Command]bool)
+		for _, c := range global {
+			r.Modules[c] = true
+		}
+
+		r.GuildModules = make(map[gb.Snowflake]map[gb.Command]bool)
+		for id, cmds := range guilds {
+			r.GuildModules[id] = make(map[gb.Command]bool)
+			for _, c := range cmds {
+				r.GuildModules[id][c] = true
+			}
+		}
+	}
+}
+
+// use g for per-guild settings, and handle the config and alias commands that change them
+func WithGuilds(g *guild.Store) RegistryOpt {
 	return func(r *Registry) {
-		// check for saved stash
-		if _, err := os.Stat(fmt.Sprintf("%s/meme.json", r.DirPath)); !os.IsNotExist(err) {
-			err = s.Load(r.DirPath)
+		// check for saved settings
+		if _, err := os.Stat(fmt.Sprintf("%s/guild.json", r.DirPath)); !os.IsNotExist(err) {
+			err = g.Load(r.DirPath)
 			if err != nil {
-				log.Printf("Failed to load from JSON file; using new Stash")
-				s = meme.DefaultStash(r.DirPath)
+				r.Log.Warn("failed to load guild settings; using new ones", "err", err)
+				*g = *guild.NewStore(r.DirPath)
 			}
 		}
 
-		// set the stash
-		r.MemeStash = s
+		r.Guilds = g
+		r.Interceptors[gb.Config] = guild.Interceptor(g, r)
+		r.Interceptors[gb.Alias] = guild.AliasInterceptor(g)
+	}
+}
+
+// load the saved stashes into ss, which should be the stashes the meme interceptor uses
+func WithStashes(ss *meme.Stashes) RegistryOpt {
+	return func(r *Registry) {
+		if err := ss.Load(); err != nil {
+			r.Log.Warn("failed to load the meme stashes", "err", err)
+		}
+
+		r.MemeStashes = ss
+	}
+}
+
+// load the saved journal into j, and handle the undo, redo and journal commands with it.  The stores journaled
+// should be registered with j.
+func WithJournal(j *journal.Journal) RegistryOpt {
+	return func(r *Registry) {
+		if _, err := os.Stat(fmt.Sprintf("%s/journal.json", r.DirPath)); !os.IsNotExist(err) {
+			err = j.Load(r.DirPath)
+			if err != nil {
+				r.Log.Warn("failed to load the journal; starting a new one", "err", err)
+				j.Entries, j.NextId = nil, 1
+			}
+		}
+
+		r.Journal = j
+		// every interceptor sees every message, so the one that handles all three commands is only added once
+		r.Interceptors[gb.Journal] = journal.Interceptor(j)
+	}
+}
+
+// look up the guilds channels are in with f, so that settings can only name a guild's own channels
+func WithChannels(f func(channel gb.Snowflake) (gb.Snowflake, error)) RegistryOpt {
+	return func(r *Registry) {
+		r.Channels = f
+	}
+}
+
+// publish the events that interceptors put on messages, and failed commands, to b
+func WithEvents(b *event.Bus) RegistryOpt {
+	return func(r *Registry) {
+		r.Events = b
+	}
+}
+
+// record every change commands make in l, post them in guilds' audit channels, and let moderators search them
+func WithAudit(l *audit.Log) RegistryOpt {
+	return func(r *Registry) {
+		r.Audit = l
+		r.Interceptors[gb.Audit] = audit.Interceptor(l)
+	}
+}
+
+func WithTriggers(t *trigger.Set) RegistryOpt {
+	return func(r *Registry) {
+		// check for saved triggers
+		if _, err := os.Stat(fmt.Sprintf("%s/trigger.json", r.DirPath)); !os.IsNotExist(err) {
+			err = t.Load(r.DirPath)
+			if err != nil {
+				r.Log.Warn("failed to load triggers; using a new set", "err", err)
+				*t = *trigger.NewSet(r.DirPath)
+			}
+		}
+
+		r.Triggers = t
 	}
 }
 
@@ -100,7 +254,10 @@ func (r *Registry) Parse(dMsg *discordgo.Message) (cmd *gb.Message, err error) {
 	cmd = &gb.Message{
 		Command:  gb.None,
 		Response: &gb.Response{},
+		Id:       gb.NewCorrelationId(),
 	}
+	cmd.Log = r.Log.With("cid", cmd.Id)
+	defer func() { r.count(cmd, err) }()
 
 	// check for nil in msg
 	if dMsg == nil {
@@ -122,119 +279,506 @@ func (r *Registry) Parse(dMsg *discordgo.Message) (cmd *gb.Message, err error) {
 	// Convert AuthorId and ChannelId
 	src.AuthorId, err = gb.ToSnowflake(dMsg.Author.ID)
 	if err != nil {
-		log.Printf("Failed to parse author from discord: %v", err)
+		cmd.Log.Error("failed to parse author id", "id", dMsg.Author.ID, "err", err)
 		return cmd, err
 	}
 
+	// messages made up by the bot, like scheduled ones, have no id
+	if dMsg.ID != "" {
+		src.MessageId, err = gb.ToSnowflake(dMsg.ID)
+		if err != nil {
+			cmd.Log.Error("failed to parse message id", "id", dMsg.ID, "err", err)
+			return cmd, err
+		}
+	}
+
 	src.ChannelId, err = gb.ToSnowflake(dMsg.ChannelID)
 	if err != nil {
-		log.Printf("Failed to parse channel from discord: %v", err)
+		cmd.Log.Error("failed to parse channel id", "id", dMsg.ChannelID, "err", err)
 		return cmd, err
 	}
 
+	// guild id is empty for direct messages
+	if dMsg.GuildID != "" {
+		src.GuildId, err = gb.ToSnowflake(dMsg.GuildID)
+		if err != nil {
+			cmd.Log.Error("failed to parse guild id", "id", dMsg.GuildID, "err", err)
+			return cmd, err
+		}
+	}
+
 	// get username
 	src.Username = dMsg.Author.Username
 
+	// keep links to any uploaded files
+	for _, a := range dMsg.Attachments {
+		src.Attachments = append(src.Attachments, a.URL)
+	}
+
 	// attach src to msg
 	cmd.Source = &src
+	cmd.Log = cmd.Log.With("guild", src.GuildId, "channel", src.ChannelId, "author", src.AuthorId)
+
+	// check for a prefix; anything else is normal chat and doesn't need tokenizing
+	body, offset, ok := r.trimPrefix(src.GuildId, src.Content)
+	if !ok {
+		return cmd, nil
+	}
 
 	// get the command
-	args, err := Tokenize(cmd.Source.Content)
+	words, err := args.Tokenize(body)
 	if err != nil {
-		log.Printf("Failed to tokenize content: %v", err)
+		// tell the user what is wrong with their command
+		if e, ok := err.(*args.SyntaxError); ok {
+			e.Pos += offset
+			cmd.Command = gb.Error
+			cmd.Response.ChannelId = src.ChannelId
+			cmd.Response.Embed = discord.NewError("Malformed Command",
+				fmt.Sprintf("Gobottas could not read your command: %v\n```\n%s\n```", e, e.Context(src.Content))).Embed()
+			return cmd, nil
+		}
+
+		cmd.Log.Error("failed to tokenize content", "err", err)
 		return cmd, err
 	}
 
-	// check for prefix
-	if args[0][0] != r.CommandPrefix {
-		return cmd, nil
+	// expand the guild's custom shortcuts
+	words, err = r.expand(src.GuildId, words)
+	if err != nil {
+		if e, ok := err.(discord.Error); ok {
+			cmd.Command = gb.Error
+			cmd.Response.ChannelId = src.ChannelId
+			cmd.Response.Embed = e.Embed()
+			return cmd, nil
+		}
+		return cmd, err
 	}
 
 	// set the command
-	cmd.Command = gb.StrToCommand(args[0][1:])
+	cmd.Command = gb.Unrecognized
+	if len(words) > 0 {
+		cmd.Command = gb.StrToCommand(words[0])
+	}
 	if cmd.Command == gb.Unrecognized {
 		return cmd, nil
 	}
 
-	cmd.Args = args[1:]
+	cmd.Args = words[1:]
+	cmd.Log.Debug("parsed command", "command", cmd.Command, "args", len(cmd.Args))
+	return cmd, nil
+}
+
+// Parse a reaction added to target, the message reacted to, so that it goes through the interceptors like a message
+// does.  target may be nil when it couldn't be fetched, leaving only what the reaction itself says about it.
+func (r *Registry) ParseReaction(react *discordgo.MessageReaction, target *discordgo.Message) (cmd *gb.Message, err error) {
+	cmd = &gb.Message{
+		Command:  gb.Reaction,
+		Response: &gb.Response{},
+		Id:       gb.NewCorrelationId(),
+	}
+	cmd.Log = r.Log.With("cid", cmd.Id)
+	defer func() { r.count(cmd, err) }()
+
+	if react == nil {
+		cmd.Command = gb.Error
+		return cmd, errors.New("discord reaction is nil")
+	}
+
+	// the user who reacted is the source, in the channel of the message they reacted to
+	var src gb.Source
+	ids := []struct {
+		name  string
+		value string
+		id    *gb.Snowflake
+	}{
+		{"user", react.UserID, &src.AuthorId},
+		{"channel", react.ChannelID, &src.ChannelId},
+		{"message", react.MessageID, &src.MessageId},
+		{"guild", react.GuildID, &src.GuildId},
+	}
+	for _, i := range ids {
+		// guild id is empty for direct messages
+		if i.value == "" && i.name == "guild" {
+			continue
+		}
+
+		if *i.id, err = gb.ToSnowflake(i.value); err != nil {
+			cmd.Command = gb.Error
+			cmd.Log.Error("failed to parse "+i.name+" id", "id", i.value, "err", err)
+			return cmd, err
+		}
+	}
+
+	rx := gb.ReactionSource{
+		Emoji:     react.Emoji.APIName(),
+		MessageId: src.MessageId,
+	}
+	if target != nil {
+		rx.Content = target.Content
+		rx.Embeds = target.Embeds
+		if target.Author != nil {
+			// a bad author id only loses the metadata
+			if id, err := gb.ToSnowflake(target.Author.ID); err == nil {
+				rx.AuthorId = id
+				rx.FromBot = r.BotId != 0 && id == r.BotId
+			}
+		}
+	}
+
+	cmd.Source = &src
+	cmd.Reaction = &rx
+	cmd.Log = cmd.Log.With("guild", src.GuildId, "channel", src.ChannelId, "author", src.AuthorId)
+	cmd.Log.Debug("parsed reaction", "emoji", rx.Emoji, "message", rx.MessageId)
 	return cmd, nil
 }
 
+// Find the command in content: what follows a mention of the bot, or the guild's prefix.  offset is where the command
+// starts in content, in characters.  ok is false for normal chat.
+func (r *Registry) trimPrefix(guild gb.Snowflake, content string) (body string, offset int, ok bool) {
+	for _, m := range r.mentions {
+		if strings.HasPrefix(content, m) {
+			body = strings.TrimLeftFunc(content[len(m):], unicode.IsSpace)
+
+			// just mentioning the bot is normal chat
+			return body, utf8.RuneCountInString(content[:len(content)-len(body)]), body != ""
+		}
+	}
+
+	prefix := r.CommandPrefix
+	if r.Guilds != nil && guild != 0 {
+		if p := r.Guilds.Prefix(guild); p != "" {
+			prefix = p
+		}
+	}
+
+	if prefix == "" || !strings.HasPrefix(content, prefix) {
+		return "", 0, false
+	}
+
+	// "& so on" is chat, not a command
+	body = content[len(prefix):]
+	if r, _ := utf8.DecodeRuneInString(body); unicode.IsSpace(r) {
+		return "", 0, false
+	}
+
+	return body, utf8.RuneCountInString(prefix), true
+}
+
+// Replace an alias at the start of words with the command it stands for, along with any arguments after it.  Aliases
+// may expand into other aliases, up to guild.MaxAliasDepth deep.
+func (r *Registry) expand(guildId gb.Snowflake, words []string) ([]string, error) {
+	if r.Guilds == nil || guildId == 0 {
+		return words, nil
+	}
+
+	seen := make(map[string]bool)
+	for len(words) > 0 && gb.StrToCommand(words[0]) == gb.Unrecognized {
+		exp, ok := r.Guilds.Alias(guildId, words[0])
+		if !ok {
+			return words, nil
+		}
+
+		// aliases are checked for loops when they are added, but the ones they use may have changed since
+		name := strings.ToLower(words[0])
+		if seen[name] || len(seen) >= guild.MaxAliasDepth {
+			return nil, discord.NewError("Alias Loop", fmt.Sprintf("`%s` expands into itself; ask an admin to fix it.", name))
+		}
+		seen[name] = true
+
+		tok, err := args.Tokenize(exp)
+		if err != nil {
+			return nil, discord.NewError("Broken Alias", fmt.Sprintf("`%s` could not be read: %v", name, err))
+		}
+
+		words = append(tok, words[1:]...)
+	}
+
+	return words, nil
+}
+
+// The prefix of guilds that haven't set their own
+func (r *Registry) DefaultPrefix() string {
+	return r.CommandPrefix
+}
+
+// The guild a channel is in
+func (r *Registry) ChannelGuild(channel gb.Snowflake) (gb.Snowflake, error) {
+	if r.Channels == nil {
+		return 0, errors.New("cannot look up channels")
+	}
+	return r.Channels(channel)
+}
+
+// Report whether a module's command is available where a message was sent.  Commands outside of modules always are.
+// A guild's own settings come first, then the guild's list in the config, then the global list.
+func (r *Registry) Enabled(c gb.Command, src *gb.Source) bool {
+	if !gb.IsModule(c) {
+		return true
+	}
+
+	if r.Guilds != nil && src != nil {
+		if on, ok := r.Guilds.ModuleEnabled(src.GuildId, src.ChannelId, c); ok {
+			return on
+		}
+	}
+
+	if r.Modules == nil {
+		return true
+	}
+
+	if src != nil {
+		if m, ok := r.GuildModules[src.GuildId]; ok {
+			return m[c]
+		}
+	}
+
+	return r.Modules[c]
+}
+
 // Function to call all Interceptors on a message
 func (r *Registry) Intercept(msg *gb.Message) error {
-	for _, i := range r.Interceptors {
+	// tell the user when they use a feature that is turned off here
+	if msg.Source != nil && !r.Enabled(msg.Command, msg.Source) {
+		msg.Response.ChannelId = msg.Source.ChannelId
+		msg.Response.Embed = discord.NewError("Not Enabled", fmt.Sprintf("The %s feature is not enabled here.", strings.ToLower(msg.Command.String()))).Embed()
+		return nil
+	}
+
+	// answers to confirmation prompts carry out, or cancel, what they confirm instead of going to the interceptors
+	if ok, err := r.answer(msg); ok {
+		return err
+	}
+
+	// commands that come too fast are dropped, with a reply the first time if the limiter gives feedback
+	if r.throttled(msg) {
+		return nil
+	}
+
+	for c, i := range r.Interceptors {
+		// disabled modules don't see any messages, including normal chat
+		if !r.Enabled(c, msg.Source) {
+			continue
+		}
+
+		start := time.Now()
 		err := i(msg)
+		metrics.InterceptorSeconds.Observe(time.Since(start).Seconds(), c.String())
 		if err != nil {
-			log.Printf("Registry.Intercept: %v", err)
+			r.logger(msg).Error("interceptor failed", "interceptor", c, "command", msg.Command, "err", err)
+			r.failed(msg, err)
 			return err
 		}
 	}
+
+	r.redirect(msg)
+
+	// actions that need confirming wait for the author's answer
+	if msg.Confirm != nil {
+		return r.ask(msg)
+	}
 	return nil
 }
 
-// Calls the Executor to which the Registry points for the Message CommandType
-func (r *Registry) Execute(msg *gb.Message, s gb.Session) error {
-	// persist changes to the discussion queue if it has changed
-	if msg.Command == gb.Queue {
-		err := r.DiscussionQueue.Save(r.DirPath)
-		if err != nil {
-			log.Printf("Error on DQ save: %v", err)
-			return err
-		}
+// Ask the author to confirm the message's action, or carry it out without asking if there are no confirmations
+func (r *Registry) ask(msg *gb.Message) error {
+	c := msg.Confirm
+	msg.Confirm = nil
+
+	if r.Confirmations == nil {
+		return c.Action(msg)
+	}
+
+	// the prompt goes where the command was used, where the author will answer it
+	msg.Response.ChannelId = msg.Source.ChannelId
+	msg.Response.Text = r.Confirmations.Ask(msg.Command, msg.Source, c)
+	r.logger(msg).Debug("asked for confirmation", "command", msg.Command)
+	return nil
+}
+
+// Carry out or cancel the action the message answers, reporting whether it answered one
+func (r *Registry) answer(msg *gb.Message) (bool, error) {
+	if r.Confirmations == nil {
+		return false, nil
 	}
 
-	// prefer embeds, then messages, then not found
-	if msg.Response.Embed != nil {
-		fmt.Printf("%v\n", msg.Response.Embed)
-		for _, f := range msg.Response.Embed.Fields {
-			fmt.Printf("%v\n", f)
+	p, a := r.Confirmations.Answer(msg)
+	if a == confirm.NotAnswer {
+		return false, nil
+	}
+	r.logger(msg).Debug("answered confirmation", "command", p.Command, "answer", a)
+
+	msg.Response.ChannelId = msg.Source.ChannelId
+	switch a {
+	case confirm.Expired:
+		msg.Response.Embed = discord.NewError("Too Late", "That confirmation expired; use the command again.").Embed()
+
+	case confirm.Cancelled:
+		msg.Response.Text = "Cancelled."
+
+	case confirm.Confirmed:
+		// the answer carries out the command, so it is saved and sent on like the command would have been
+		msg.Command = p.Command
+		if err := p.Action(msg); err != nil {
+			r.logger(msg).Error("confirmed action failed", "command", msg.Command, "err", err)
+			return true, err
 		}
+		r.redirect(msg)
+	}
+	return true, nil
+}
 
-		_, err := s.ChannelMessageSendEmbed(msg.Response.ChannelId.String(), msg.Response.Embed)
-		if err != nil {
-			log.Printf("Error on Execute: %v", err)
-			return err
+// record a parsed message in the metrics
+func (r *Registry) count(msg *gb.Message, err error) {
+	switch {
+	case err != nil || msg.Command == gb.Error:
+		metrics.MessagesParsed.Inc("error")
+	case msg.Command == gb.None:
+		metrics.MessagesParsed.Inc("chat")
+	case msg.Command == gb.Reaction:
+		metrics.MessagesParsed.Inc("reaction")
+	default:
+		metrics.MessagesParsed.Inc("command")
+		metrics.Commands.Inc(msg.Command.String(), r.subcommand(msg))
+	}
+}
+
+// The subcommand label for a message, e.g. "add" for "&dq add".  Users can type anything, so only the first few
+// distinct subcommands of each command get their own label.
+func (r *Registry) subcommand(msg *gb.Message) string {
+	if len(msg.Args) == 0 {
+		return "none"
+	}
+	sub := strings.ToLower(msg.Args[0])
+
+	r.subMu.Lock()
+	defer r.subMu.Unlock()
+
+	if r.subcommands == nil {
+		r.subcommands = make(map[gb.Command]map[string]bool)
+	}
+	seen, ok := r.subcommands[msg.Command]
+	if !ok {
+		seen = make(map[string]bool)
+		r.subcommands[msg.Command] = seen
+	}
+
+	if !seen[sub] {
+		if len(seen) >= maxSubcommands {
+			return "other"
 		}
+		seen[sub] = true
+	}
+	return sub
+}
 
-		// exit successfully
-		return nil
+// the message's own logger, or the registry's for messages that weren't parsed, like scheduled posts
+func (r *Registry) logger(msg *gb.Message) *gb.Logger {
+	if msg.Log != nil {
+		return msg.Log
+	}
+	return r.Log
+}
+
+// Check a command against the cooldowns, setting the response if the user should be told to wait
+func (r *Registry) throttled(msg *gb.Message) bool {
+	switch msg.Command {
+	case gb.None, gb.Error, gb.Unrecognized, gb.Reaction:
+		return false
 	}
 
-	// only executes if Embed is nil
-	if msg.Response.Text != "" {
-		_, err := s.ChannelMessageSend(msg.Response.ChannelId.String(), msg.Response.Text)
-		if err != nil {
-			log.Printf("Error on Execute: %v", err)
+	if r.Cooldowns == nil {
+		return false
+	}
+
+	ok, wait, warn := r.Cooldowns.Allow(msg.Command, msg.Source)
+	if ok {
+		return false
+	}
+
+	metrics.Throttled.Inc(msg.Command.String())
+	r.logger(msg).Debug("command on cooldown", "command", msg.Command, "wait", wait)
+
+	if warn && r.Cooldowns.Feedback {
+		wait = time.Duration(math.Ceil(wait.Seconds())) * time.Second
+		msg.Response.ChannelId = msg.Source.ChannelId
+		msg.Response.Embed = discord.NewError("Slow Down", fmt.Sprintf("Try again in %s.", wait)).Embed()
+	}
+	return true
+}
+
+// Send a module's reply to the channel the guild chose for it.  Errors stay with the user who caused them.
+func (r *Registry) redirect(msg *gb.Message) {
+	if r.Guilds == nil || msg.Source == nil || msg.Response.ChannelId != msg.Source.ChannelId {
+		return
+	}
+
+	if msg.Response.Embed != nil && msg.Response.Embed.Color == gb.ErrorCol {
+		return
+	}
+
+	if ch, ok := r.Guilds.Output(msg.Source.GuildId, msg.Command); ok {
+		msg.Response.ChannelId = ch
+	}
+}
+
+// Calls the Executor to which the Registry points for the Message CommandType
+func (r *Registry) Execute(msg *gb.Message, s gb.Session) error {
+	// the changes have been made whatever happens to the response
+	r.audit(msg, s)
+	for _, e := range msg.Events {
+		r.Events.Publish(e)
+	}
+
+	// persist changes to the guild's discussion queue if it has changed; undoing and redoing may change it too
+	if r.DiscussionQueues != nil && msg.Source != nil && (msg.Command == gb.Queue || msg.Command == gb.Undo || msg.Command == gb.Redo) {
+		if err := r.DiscussionQueues.Save(msg.Source.GuildId); err != nil {
+			r.logger(msg).Error("failed to save the discussion queue", "err", err)
+			r.failed(msg, err)
 			return err
 		}
+	}
 
-		// exit sucessfully
-		return nil
+	for _, o := range msg.Response.List() {
+		if err := r.send(msg, s, o); err != nil {
+			metrics.SendFailures.Inc(o.Kind.String())
+			r.logger(msg).Error("failed to send the response", "channel", msg.Response.ChannelId, "kind", o.Kind, "err", err)
+			r.failed(msg, err)
+			return err
+		}
 	}
 
 	// Following unix norm that no response indicates success
 	return nil
 }
 
-// Split the message content by spaces while leaving segments in quotes intact
-// Uses regex
-func Tokenize(s string) (tok []string, err error) {
+// tell subscribers that a message couldn't be handled
+func (r *Registry) failed(msg *gb.Message, err error) {
+	r.Events.Publish(&event.CommandFailed{Base: event.From(msg), Command: msg.Command.String(), Err: err.Error()})
+}
+
+// Record the changes a message made in the audit log, and post them in the guild's audit channel if it has one
+func (r *Registry) audit(msg *gb.Message, s gb.Session) {
+	if r.Audit == nil || len(msg.Changes) == 0 {
+		return
+	}
 
-	// parse the string using the dark art of regular expressions
-	tok = regexp.MustCompile(`[^\s"']+|"([^"]*)"|'([^']*)`).FindAllString(s, -1)
+	entries := audit.Entries(msg, time.Now())
+	if err := r.Audit.Append(entries...); err != nil {
+		r.logger(msg).Error("failed to write the audit log", "err", err)
+	}
 
-	// remove quotes
-	for i := range tok {
+	if r.Guilds == nil || msg.Source == nil {
+		return
+	}
+	ch, ok := r.Guilds.AuditChannel(msg.Source.GuildId)
+	if !ok {
+		return
+	}
 
-		// only call on strings with quotes
-		if string(tok[i][0]) == "\"" {
-			tok[i], err = strconv.Unquote(tok[i])
-			if err != nil {
-				log.Printf("Tokenize failed to Unquote string: %v", err)
-				return nil, err
-			}
+	for _, e := range entries {
+		if _, err := s.ChannelMessageSendEmbed(ch.String(), e.Embed()); err != nil {
+			metrics.SendFailures.Inc("audit")
+			r.logger(msg).Error("failed to post in the audit channel", "channel", ch, "err", err)
 		}
 	}
-
-	return tok, nil
 }
@@ -1,10 +1,26 @@
 package core
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
 	"github.com/bwmarrin/discordgo"
 	gb "github.com/ericebersohl/gobottas"
+	"github.com/ericebersohl/gobottas/audit"
+	"github.com/ericebersohl/gobottas/confirm"
+	"github.com/ericebersohl/gobottas/cooldown"
+	"github.com/ericebersohl/gobottas/discussion"
+	"github.com/ericebersohl/gobottas/event"
+	"github.com/ericebersohl/gobottas/guild"
+	"github.com/ericebersohl/gobottas/metrics"
+	"github.com/ericebersohl/gobottas/mock"
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"io/ioutil"
+	"os"
+	"strings"
 	"testing"
+	"time"
 )
 
 /*
@@ -12,6 +28,7 @@ Test Cases:
 - nil dgo msg
 - nil author
 - bad authorid, bad channelid
+- empty content, normal chat, command, quoted args, malformed command
 */
 func TestRegistry_Parse(t *testing.T) {
 	r := NewRegistry()
@@ -21,11 +38,17 @@ func TestRegistry_Parse(t *testing.T) {
 		in       *discordgo.Message
 		wantErr  bool
 		wantType gb.Command
+		wantArgs []string
 	}{
 		{name: "nil dgo msg", in: nil, wantErr: true, wantType: gb.Error},
 		{name: "nil auth", in: &discordgo.Message{Author: nil}, wantErr: true, wantType: gb.Error},
 		{name: "bad authid", in: &discordgo.Message{Author: &discordgo.User{ID: "id"}}, wantErr: true, wantType: gb.Error},
 		{name: "bad chanid", in: &discordgo.Message{Author: &discordgo.User{ID: "0"}, ChannelID: "id"}, wantErr: true, wantType: gb.Error},
+		{name: "empty", in: &discordgo.Message{Author: &discordgo.User{ID: "0"}, ChannelID: "0"}, wantErr: false, wantType: gb.None},
+		{name: "chat", in: &discordgo.Message{Author: &discordgo.User{ID: "0"}, ChannelID: "0", Content: `it's "fine`}, wantErr: false, wantType: gb.None},
+		{name: "command", in: &discordgo.Message{Author: &discordgo.User{ID: "0"}, ChannelID: "0", Content: "&dq list"}, wantErr: false, wantType: gb.Queue, wantArgs: []string{"list"}},
+		{name: "quoted", in: &discordgo.Message{Author: &discordgo.User{ID: "0"}, ChannelID: "0", Content: "&dq add 'it's' “a topic”"}, wantErr: false, wantType: gb.Queue, wantArgs: []string{"add", "it's", "a topic"}},
+		{name: "malformed", in: &discordgo.Message{Author: &discordgo.User{ID: "0"}, ChannelID: "0", Content: `&dq add "topic`}, wantErr: false, wantType: gb.Error},
 	}
 
 	for _, test := range tests {
@@ -39,6 +62,140 @@ func TestRegistry_Parse(t *testing.T) {
 				if out.Command != test.wantType {
 					t.Errorf("out != want (out = %s, want = %s)", out.Command.String(), test.wantType.String())
 				}
+
+				if !cmp.Equal(out.Args, test.wantArgs) {
+					t.Errorf("args != wantArgs (%s)", cmp.Diff(test.wantArgs, out.Args))
+				}
+
+				if out.Command == gb.Error && out.Response.Embed == nil {
+					t.Errorf("malformed command has no error embed")
+				}
+
+				if out.Id == "" || out.Log == nil {
+					t.Errorf("message has no correlation id or logger")
+				}
+			}
+		})
+	}
+}
+
+/*
+Test Cases:
+- default prefix in a guild without one, in a direct message
+- guild prefix: multi-character, unicode, default no longer works, other guilds keep the default
+- mention: plain, nickname, no space, mention alone, someone else
+- prefix followed by a space
+- malformed command after a long prefix
+*/
+func TestRegistry_ParsePrefix(t *testing.T) {
+	g := guild.NewStore("")
+	_ = g.SetPrefix(1, "gb!")
+	_ = g.SetPrefix(2, "→")
+	r := NewRegistry(WithGuilds(g), WithBotId(99))
+
+	msg := func(guild, content string) *discordgo.Message {
+		return &discordgo.Message{Author: &discordgo.User{ID: "0"}, ChannelID: "0", GuildID: guild, Content: content}
+	}
+
+	tests := []struct {
+		name     string
+		in       *discordgo.Message
+		wantType gb.Command
+		wantArgs []string
+	}{
+		{name: "default", in: msg("3", "&dq list"), wantType: gb.Queue, wantArgs: []string{"list"}},
+		{name: "direct", in: msg("", "&meme"), wantType: gb.Meme},
+		{name: "multi", in: msg("1", "gb!dq list"), wantType: gb.Queue, wantArgs: []string{"list"}},
+		{name: "unicode", in: msg("2", "→meme add 'x'"), wantType: gb.Meme, wantArgs: []string{"add", "x"}},
+		{name: "replaced-default", in: msg("1", "&dq list"), wantType: gb.None},
+		{name: "mention", in: msg("1", "<@99> dq list"), wantType: gb.Queue, wantArgs: []string{"list"}},
+		{name: "mention-nick", in: msg("3", "<@!99>  meme"), wantType: gb.Meme},
+		{name: "mention-no-space", in: msg("3", "<@99>meme"), wantType: gb.Meme},
+		{name: "mention-alone", in: msg("3", "<@99>"), wantType: gb.None},
+		{name: "mention-other", in: msg("3", "<@98> dq list"), wantType: gb.None},
+		{name: "prefix-space", in: msg("3", "& so on"), wantType: gb.None},
+		{name: "malformed", in: msg("1", `gb!dq add "x`), wantType: gb.Error},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			out, err := r.Parse(test.in)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if out.Command != test.wantType {
+				t.Errorf("out != want (out = %s, want = %s)", out.Command.String(), test.wantType.String())
+			}
+
+			if !cmp.Equal(out.Args, test.wantArgs, cmpopts.EquateEmpty()) {
+				t.Errorf("args != wantArgs (%s)", cmp.Diff(test.wantArgs, out.Args))
+			}
+
+			// the caret points at the quote, past the prefix
+			if out.Command == gb.Error && !strings.Contains(out.Response.Embed.Description, "character 11") {
+				t.Errorf("error position doesn't count the prefix: %s", out.Response.Embed.Description)
+			}
+		})
+	}
+}
+
+/*
+Test Cases:
+- built-in alias, upper case command
+- custom alias, with extra args, upper case, alias of an alias, quoted expansion
+- loop added behind the store's back, unreadable expansion
+- aliases of other guilds and direct messages don't apply
+*/
+func TestRegistry_ParseAlias(t *testing.T) {
+	g := guild.NewStore("")
+	_ = g.SetAlias(1, "topics", "dq list")
+	_ = g.SetAlias(1, "t", "topics")
+	_ = g.SetAlias(1, "pitch", `dq add "my topic"`)
+	g.Guilds[1].Aliases["loop"] = "loop"
+	g.Guilds[1].Aliases["broken"] = `dq add "x`
+	r := NewRegistry(WithGuilds(g))
+
+	msg := func(guild, content string) *discordgo.Message {
+		return &discordgo.Message{Author: &discordgo.User{ID: "0"}, ChannelID: "0", GuildID: guild, Content: content}
+	}
+
+	tests := []struct {
+		name     string
+		in       *discordgo.Message
+		wantType gb.Command
+		wantArgs []string
+	}{
+		{name: "builtin", in: msg("2", "&q next"), wantType: gb.Queue, wantArgs: []string{"next"}},
+		{name: "upper", in: msg("2", "&DQ List"), wantType: gb.Queue, wantArgs: []string{"List"}},
+		{name: "custom", in: msg("1", "&topics"), wantType: gb.Queue, wantArgs: []string{"list"}},
+		{name: "extra-args", in: msg("1", "&topics now"), wantType: gb.Queue, wantArgs: []string{"list", "now"}},
+		{name: "custom-upper", in: msg("1", "&Topics"), wantType: gb.Queue, wantArgs: []string{"list"}},
+		{name: "chained", in: msg("1", "&t"), wantType: gb.Queue, wantArgs: []string{"list"}},
+		{name: "quoted", in: msg("1", "&pitch 'about it'"), wantType: gb.Queue, wantArgs: []string{"add", "my topic", "about it"}},
+		{name: "loop", in: msg("1", "&loop"), wantType: gb.Error},
+		{name: "broken", in: msg("1", "&broken"), wantType: gb.Error},
+		{name: "other-guild", in: msg("2", "&topics"), wantType: gb.Unrecognized},
+		{name: "direct", in: msg("", "&topics"), wantType: gb.Unrecognized},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			out, err := r.Parse(test.in)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if out.Command != test.wantType {
+				t.Errorf("out != want (out = %s, want = %s)", out.Command.String(), test.wantType.String())
+			}
+
+			if out.Command == gb.Error && out.Response.Embed == nil {
+				t.Errorf("no error embed")
+			}
+
+			if out.Command == gb.Queue && !cmp.Equal(out.Args, test.wantArgs) {
+				t.Errorf("args != wantArgs (%s)", cmp.Diff(test.wantArgs, out.Args))
 			}
 		})
 	}
@@ -46,32 +203,607 @@ func TestRegistry_Parse(t *testing.T) {
 
 /*
 Test Cases:
-- nil string
-- start quotes, end quotes
-- single quote with whitespace
+- no modules configured: everything enabled
+- global module, disabled module, guild list replaces the global one, commands outside modules
+- disabled command gets an error, disabled module's interceptor doesn't see chat
+- runtime settings: channel beats guild beats config, output channel for replies but not errors
 */
-func TestTokenize(t *testing.T) {
+func TestRegistry_Enabled(t *testing.T) {
+	all := NewRegistry()
+	if !all.Enabled(gb.Meme, &gb.Source{}) {
+		t.Errorf("module disabled without any configured")
+	}
+
+	r := NewRegistry(WithModules([]gb.Command{gb.Queue}, map[gb.Snowflake][]gb.Command{1: {gb.Meme}}))
+
+	tests := []struct {
+		name  string
+		cmd   gb.Command
+		guild gb.Snowflake
+		want  bool
+	}{
+		{name: "global", cmd: gb.Queue, guild: 2, want: true},
+		{name: "disabled", cmd: gb.Meme, guild: 2, want: false},
+		{name: "guild-enabled", cmd: gb.Meme, guild: 1, want: true},
+		{name: "guild-replaces", cmd: gb.Queue, guild: 1, want: false},
+		{name: "not-module", cmd: gb.Config, guild: 1, want: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := r.Enabled(test.cmd, &gb.Source{GuildId: test.guild}); got != test.want {
+				t.Errorf("enabled = %t, want %t", got, test.want)
+			}
+		})
+	}
+
+	// the trigger interceptor would reply to chat if it were called
+	called := false
+	r.Interceptors[gb.Trigger] = func(msg *gb.Message) error {
+		called = true
+		return nil
+	}
+
+	chat := &gb.Message{Command: gb.None, Source: &gb.Source{GuildId: 2}, Response: &gb.Response{}}
+	if err := r.Intercept(chat); err != nil || called {
+		t.Errorf("disabled module saw chat (err = %v)", err)
+	}
+
+	cmd := &gb.Message{Command: gb.Meme, Source: &gb.Source{GuildId: 2}, Response: &gb.Response{}}
+	if err := r.Intercept(cmd); err != nil || cmd.Response.Embed == nil {
+		t.Errorf("disabled command not reported (err = %v)", err)
+	}
+
+	// settings changed with the config command win over the config file
+	g := guild.NewStore("")
+	r.Guilds = g
+	off, on := false, true
+	_ = g.SetModule(2, 0, "queue", &off)
+	_ = g.SetModule(2, 5, "queue", &on)
+	_ = g.SetOutput(2, "queue", 9)
+
+	if r.Enabled(gb.Queue, &gb.Source{GuildId: 2, ChannelId: 6}) {
+		t.Errorf("module enabled after the guild turned it off")
+	}
+	if !r.Enabled(gb.Queue, &gb.Source{GuildId: 2, ChannelId: 5}) {
+		t.Errorf("module disabled in a channel that turned it on")
+	}
+
+	r.Interceptors[gb.Queue] = func(msg *gb.Message) error {
+		msg.Response.ChannelId = msg.Source.ChannelId
+		msg.Response.Text = "ok"
+		return nil
+	}
+	reply := &gb.Message{Command: gb.Queue, Source: &gb.Source{GuildId: 2, ChannelId: 5}, Response: &gb.Response{}}
+	if err := r.Intercept(reply); err != nil || reply.Response.ChannelId != 9 {
+		t.Errorf("reply not sent to the output channel (err = %v, channel = %s)", err, reply.Response.ChannelId)
+	}
+
+	denied := &gb.Message{Command: gb.Queue, Source: &gb.Source{GuildId: 2, ChannelId: 6}, Response: &gb.Response{}}
+	if err := r.Intercept(denied); err != nil || denied.Response.ChannelId != 6 {
+		t.Errorf("error not sent where the command was used (err = %v, channel = %s)", err, denied.Response.ChannelId)
+	}
+}
+
+// a response of just the output
+func output(o gb.Output) func(*gb.Response) {
+	return func(r *gb.Response) {
+		r.Outputs = append(r.Outputs, o)
+	}
+}
+
+/*
+Test Cases:
+- text and an embed are both sent, text first
+- text too long for one message is split
+- a file goes with its text as the message
+- outputs after the shorthand fields, in order: reactions on the source message, ephemeral text in a direct message
+- a reaction without a message to react to fails
+*/
+func TestRegistry_Execute(t *testing.T) {
+	embed := &discordgo.MessageEmbed{Title: "Queue"}
+	long := strings.Repeat(strings.Repeat("a", 99)+"\n", 30)
+
 	tests := []struct {
 		name    string
-		in      string
-		out     []string
+		src     *gb.Source
+		resp    func(*gb.Response)
+		want    []mock.Sent
 		wantErr bool
 	}{
-		{name: "nil", in: "", out: nil, wantErr: false},
-		{name: "start-quotation", in: `"start quotes" and other args`, out: []string{"start quotes", "and", "other", "args"}, wantErr: false},
-		{name: "end-quotation", in: `other args "end quotes"`, out: []string{"other", "args", "end quotes"}, wantErr: false},
-		{name: "single-quote", in: `a " b`, out: []string{"a", "b"}, wantErr: false},
+		{
+			name: "text-and-embed",
+			resp: func(r *gb.Response) {
+				r.Text = "added"
+				r.Embed = embed
+			},
+			want: []mock.Sent{{ChannelId: "2", MessageId: "1", Text: "added"}, {ChannelId: "2", MessageId: "2", Embed: embed}},
+		},
+		{
+			name: "split",
+			resp: func(r *gb.Response) { r.Text = long },
+			want: []mock.Sent{{ChannelId: "2", MessageId: "1", Text: long[:1999]}, {ChannelId: "2", MessageId: "2", Text: long[2000:]}},
+		},
+		{
+			name: "file",
+			resp: func(r *gb.Response) {
+				r.Text = "Exported 1 memes."
+				r.File = &discordgo.File{Name: "memes.json", Reader: strings.NewReader("[]")}
+			},
+			want: []mock.Sent{{ChannelId: "2", MessageId: "1", Text: "Exported 1 memes.", File: "memes.json", Data: []byte("[]")}},
+		},
+		{
+			name: "outputs",
+			resp: func(r *gb.Response) {
+				r.Text = "removed"
+				r.Outputs = append(r.Outputs, gb.Output{Kind: gb.ReactionOutput, Text: "✅"}, gb.Output{Kind: gb.TextOutput, Text: "only you can see this", Ephemeral: true})
+				r.AddEmbed(embed)
+			},
+			want: []mock.Sent{
+				{ChannelId: "2", MessageId: "1", Text: "removed"},
+				{ChannelId: "1", MessageId: "3", Reaction: "✅"},
+				{ChannelId: "dm-4", MessageId: "2", Text: "only you can see this"},
+				{ChannelId: "2", MessageId: "3", Embed: embed},
+			},
+		},
+		{
+			name:    "no-message",
+			src:     &gb.Source{ChannelId: 1, AuthorId: 4},
+			resp:    output(gb.Output{Kind: gb.ReactionOutput, Text: "✅"}),
+			wantErr: true,
+		},
 	}
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			got, err := Tokenize(test.in)
+			r := NewRegistry(WithLogger(nil))
+			s := mock.NewSession()
+
+			msg := &gb.Message{
+				Command:  gb.None,
+				Source:   &gb.Source{MessageId: 3, ChannelId: 1, AuthorId: 4},
+				Response: &gb.Response{ChannelId: 2},
+			}
+			if test.src != nil {
+				msg.Source = test.src
+			}
+			test.resp(msg.Response)
+
+			err := r.Execute(msg, s)
 			if (err != nil) != test.wantErr {
-				t.Errorf("err != wantErr (err = %v, wantErr = %v)", err, test.wantErr)
+				t.Fatalf("err != wantErr (err = %v, wantErr = %v)", err, test.wantErr)
+			}
+
+			if diff := cmp.Diff(test.want, s.Sent(), cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("sent (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+/*
+Test Cases:
+- a reply mentions the author
+- an update is sent the first time, then edits that message
+- an update in another channel is a separate message
+- an update whose message was deleted sends a new one
+- edits of a known message; an edit of a missing one, or one in another channel, fails
+- deleting a message by id, and the source message
+*/
+func TestRegistry_ExecuteEdits(t *testing.T) {
+	r := NewRegistry(WithLogger(nil))
+	s := mock.NewSession()
+	list := &discordgo.MessageEmbed{Title: "Topics"}
+
+	tests := []struct {
+		name    string
+		channel gb.Snowflake
+		src     *gb.Source
+		resp    func(*gb.Response)
+		want    mock.Sent
+		wantErr bool
+	}{
+		{name: "reply", channel: 2, resp: output(gb.Output{Kind: gb.TextOutput, Text: "done", Reply: true}), want: mock.Sent{ChannelId: "2", MessageId: "1", Text: "<@4> done"}},
+		{name: "update-sent", channel: 2, resp: func(r *gb.Response) { r.AddEmbedUpdate("list", list) }, want: mock.Sent{ChannelId: "2", MessageId: "2", Embed: list}},
+		{name: "update-edited", channel: 2, resp: func(r *gb.Response) { r.AddEmbedUpdate("list", list) }, want: mock.Sent{ChannelId: "2", MessageId: "2", Embed: list, Edited: true}},
+		{name: "update-other-channel", channel: 5, resp: func(r *gb.Response) { r.AddEmbedUpdate("list", list) }, want: mock.Sent{ChannelId: "5", MessageId: "3", Embed: list}},
+		{name: "delete", channel: 2, resp: output(gb.Output{Kind: gb.DeleteOutput, MessageId: 2}), want: mock.Sent{ChannelId: "2", MessageId: "2", Deleted: true}},
+		{name: "update-after-delete", channel: 2, resp: func(r *gb.Response) { r.AddEmbedUpdate("list", list) }, want: mock.Sent{ChannelId: "2", MessageId: "4", Embed: list}},
+		{name: "edit", channel: 2, resp: output(gb.Output{Kind: gb.TextOutput, Text: "undone", MessageId: 1}), want: mock.Sent{ChannelId: "2", MessageId: "1", Text: "undone", Edited: true}},
+		{name: "edit-missing", channel: 2, resp: output(gb.Output{Kind: gb.TextOutput, Text: "gone", MessageId: 2}), wantErr: true},
+		{name: "edit-other-channel", channel: 5, resp: output(gb.Output{Kind: gb.TextOutput, Text: "wrong channel", MessageId: 1}), wantErr: true},
+		{name: "delete-source", src: &gb.Source{MessageId: 1, ChannelId: 2}, resp: output(gb.Output{Kind: gb.DeleteOutput, MessageId: 0}), want: mock.Sent{ChannelId: "2", MessageId: "1", Deleted: true}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			before := len(s.Sent())
+			msg := &gb.Message{
+				Source:   &gb.Source{MessageId: 3, ChannelId: 1, AuthorId: 4},
+				Response: &gb.Response{ChannelId: test.channel},
+			}
+			if test.src != nil {
+				msg.Source = test.src
+			}
+			test.resp(msg.Response)
+
+			err := r.Execute(msg, s)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("err != wantErr (err = %v, wantErr = %v)", err, test.wantErr)
+			}
+			if test.wantErr {
+				return
+			}
+
+			sent := s.Sent()
+			if len(sent) != before+1 {
+				t.Fatalf("sent %d, want 1", len(sent)-before)
 			}
+			if diff := cmp.Diff(test.want, sent[before], cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("sent (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+/*
+Test Cases:
+- nil reaction
+- bad user id
+- reaction to the bot's message, with the message's metadata
+- reaction in a direct message to a message that couldn't be fetched
+*/
+func TestRegistry_ParseReaction(t *testing.T) {
+	r := NewRegistry(WithLogger(nil), WithBotId(9))
+	embed := &discordgo.MessageEmbed{Title: "Topics"}
+
+	tests := []struct {
+		name    string
+		react   *discordgo.MessageReaction
+		target  *discordgo.Message
+		want    *gb.Message
+		wantErr bool
+	}{
+		{name: "nil", wantErr: true},
+		{name: "bad-user", react: &discordgo.MessageReaction{UserID: "me", ChannelID: "2", MessageID: "3"}, wantErr: true},
+		{
+			name:   "bot-message",
+			react:  &discordgo.MessageReaction{UserID: "1", ChannelID: "2", MessageID: "3", GuildID: "4", Emoji: discordgo.Emoji{Name: "✅"}},
+			target: &discordgo.Message{ID: "3", Author: &discordgo.User{ID: "9"}, Content: "remove it?", Embeds: []*discordgo.MessageEmbed{embed}},
+			want: &gb.Message{
+				Command:  gb.Reaction,
+				Source:   &gb.Source{AuthorId: 1, ChannelId: 2, MessageId: 3, GuildId: 4},
+				Reaction: &gb.ReactionSource{Emoji: "✅", MessageId: 3, AuthorId: 9, FromBot: true, Content: "remove it?", Embeds: []*discordgo.MessageEmbed{embed}},
+				Response: &gb.Response{},
+			},
+		},
+		{
+			name:  "direct-message",
+			react: &discordgo.MessageReaction{UserID: "1", ChannelID: "2", MessageID: "3", Emoji: discordgo.Emoji{Name: "vote", ID: "7"}},
+			want: &gb.Message{
+				Command:  gb.Reaction,
+				Source:   &gb.Source{AuthorId: 1, ChannelId: 2, MessageId: 3},
+				Reaction: &gb.ReactionSource{Emoji: "vote:7", MessageId: 3},
+				Response: &gb.Response{},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := r.ParseReaction(test.react, test.target)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("err != wantErr (err = %v, wantErr = %v)", err, test.wantErr)
+			}
+			if test.wantErr {
+				if got.Command != gb.Error {
+					t.Errorf("command = %s, want Error", got.Command)
+				}
+				return
+			}
+
+			if diff := cmp.Diff(test.want, got, cmpopts.IgnoreFields(gb.Message{}, "Id", "Log")); diff != "" {
+				t.Errorf("message (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+/*
+Test Cases:
+- reactions reach interceptors, aren't throttled, and are counted
+*/
+func TestRegistry_InterceptReaction(t *testing.T) {
+	var seen *gb.ReactionSource
+	r := NewRegistry(
+		WithLogger(nil),
+		WithCooldowns(cooldown.New(gb.SystemClock{}, cooldown.WithDefault(cooldown.Rule{User: cooldown.Limit{Every: time.Hour}}))),
+		WithInterceptor(gb.Meme, func(msg *gb.Message) error {
+			if msg.Command == gb.Reaction {
+				seen = msg.Reaction
+			}
+			return nil
+		}),
+	)
+
+	before := metrics.MessagesParsed.Value("reaction")
+	for i := 0; i < 2; i++ {
+		seen = nil
+		msg, err := r.ParseReaction(&discordgo.MessageReaction{UserID: "1", ChannelID: "2", MessageID: "3", Emoji: discordgo.Emoji{Name: "👍"}}, nil)
+		if err != nil {
+			t.Fatalf("parse: %v", err)
+		}
+		if err := r.Intercept(msg); err != nil {
+			t.Fatalf("intercept: %v", err)
+		}
+		if seen == nil || seen.Emoji != "👍" {
+			t.Errorf("reaction %d didn't reach the interceptor", i)
+		}
+	}
+
+	if got := metrics.MessagesParsed.Value("reaction") - before; got != 2 {
+		t.Errorf("counted %v reactions, want 2", got)
+	}
+}
+
+/*
+Test Cases:
+- a removal asks first, where the command was used
+- another user's yes is normal chat
+- the author's yes removes the topic, as a queue command
+- with confirmations off, the removal happens right away
+*/
+func TestRegistry_Confirm(t *testing.T) {
+	qs := discussion.NewQueues("registry_test")
+	defer os.RemoveAll("registry_test")
+	q := qs.Get(0)
+	for _, name := range []string{"a", "b"} {
+		if err := q.Add(&discussion.Topic{Name: name}); err != nil {
+			t.Fatalf("add: %v", err)
+		}
+	}
+
+	clock := mock.NewClock(time.Date(2020, 1, 2, 15, 4, 5, 0, time.UTC))
+	r := NewRegistry(WithLogger(nil), WithConfirmations(confirm.New(clock)), WithInterceptor(gb.Queue, discussion.Interceptor(qs)))
+	run := func(user, channel gb.Snowflake, content string) *gb.Message {
+		msg, err := r.Parse(&discordgo.Message{Author: &discordgo.User{ID: user.String()}, ChannelID: channel.String(), Content: content})
+		if err != nil {
+			t.Fatalf("parse: %v", err)
+		}
+		if err := r.Intercept(msg); err != nil {
+			t.Fatalf("intercept: %v", err)
+		}
+		return msg
+	}
+
+	asked := run(1, 2, "&dq remove a")
+	if !strings.HasPrefix(asked.Response.Text, `Remove topic "a"?`) || asked.Response.ChannelId != 2 || q.Len() != 2 {
+		t.Fatalf("didn't ask first (response = %+v, topics = %d)", asked.Response, q.Len())
+	}
+
+	if other := run(3, 2, "yes"); other.Command != gb.None || other.Response.Text != "" || q.Len() != 2 {
+		t.Errorf("another user confirmed (response = %+v, topics = %d)", other.Response, q.Len())
+	}
+
+	yes := run(1, 2, "yes")
+	if yes.Command != gb.Queue || yes.Response.Text != `Removed topic "a".` || q.Len() != 1 {
+		t.Errorf("not removed (command = %s, response = %+v, topics = %d)", yes.Command, yes.Response, q.Len())
+	}
+
+	r.Confirmations = nil
+	if now := run(1, 2, "&dq remove b"); now.Response.Text != `Removed topic "b".` || q.Len() != 0 {
+		t.Errorf("not removed right away (response = %+v, topics = %d)", now.Response, q.Len())
+	}
+}
+
+/*
+Test Cases:
+- a change is written to the audit file and posted in the guild's audit channel, along with the response
+- without an audit channel, changes are only written to the file
+- responses that change nothing aren't audited
+*/
+func TestRegistry_Audit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "registry_audit")
+	if err != nil {
+		t.Fatalf("temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	g := guild.NewStore(dir)
+	g.SetAuditChannel(1, 9)
+	l := audit.New(dir)
+	r := NewRegistry(WithLogger(nil), WithPath(dir), WithGuilds(g), WithAudit(l))
+
+	change := func(guild gb.Snowflake) *gb.Message {
+		msg := mock.NewMessage(gb.Config, mock.WithSource(4, 2, "admin", ""), mock.WithGuild(guild))
+		msg.Response.ChannelId = 2
+		msg.Response.Text = "done"
+		msg.AddChange("set prefix", "prefix", "&", "!")
+		return msg
+	}
+
+	s := mock.NewSession()
+	if err := r.Execute(change(1), s); err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+
+	sent := s.Sent()
+	if len(sent) != 2 || sent[0].ChannelId != "9" || sent[0].Embed == nil || sent[0].Embed.Title != "set prefix" || sent[1].Text != "done" {
+		t.Errorf("audit not posted: %+v", sent)
+	}
 
-			if !cmp.Equal(test.out, got) {
-				t.Errorf("out != got (%s)", cmp.Diff(test.out, got))
+	s = mock.NewSession()
+	if err := r.Execute(change(5), s); err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	if sent := s.Sent(); len(sent) != 1 || sent[0].Text != "done" {
+		t.Errorf("posted without an audit channel: %+v", sent)
+	}
+
+	plain := mock.NewMessage(gb.Config, mock.WithGuild(1))
+	plain.Response.Text = "nothing changed"
+	if err := r.Execute(plain, mock.NewSession()); err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+
+	for guild, want := range map[gb.Snowflake]int{1: 1, 5: 1} {
+		if got, err := l.Search(guild, "admin", 10); err != nil || len(got) != want {
+			t.Errorf("guild %d has %d entries, want %d (err = %v)", guild, len(got), want, err)
+		}
+	}
+}
+
+/*
+Test Cases:
+- events a message publishes reach subscribers when it's executed
+- a failing interceptor publishes a failed command
+- a response that can't be sent publishes a failed command
+*/
+func TestRegistry_Events(t *testing.T) {
+	b := event.New()
+	var got []gb.Event
+	b.Subscribe("test", func(e gb.Event) { got = append(got, e) })
+
+	r := NewRegistry(WithLogger(nil), WithEvents(b), WithInterceptor(gb.Meme, func(msg *gb.Message) error {
+		return errors.New("broken")
+	}))
+
+	msg := mock.NewMessage(gb.Help, mock.WithSource(1, 2, "user", ""))
+	msg.Publish(&event.TopicAdded{Base: event.From(msg), Topic: "x"})
+	if err := r.Execute(msg, mock.NewSession()); err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	if len(got) != 1 || got[0].Kind() != event.KindTopicAdded {
+		t.Fatalf("got %v, want the added topic", got)
+	}
+
+	if err := r.Intercept(mock.NewMessage(gb.Meme, mock.WithSource(1, 2, "user", ""))); err == nil {
+		t.Fatalf("no error from a failing interceptor")
+	}
+	if e, ok := got[len(got)-1].(*event.CommandFailed); !ok || e.Command != "Meme" || e.Err != "broken" || e.UserId != 1 {
+		t.Errorf("got %+v, want the failed command", got[len(got)-1])
+	}
+
+	msg = mock.NewMessage(gb.Help)
+	msg.Response.ChannelId = 2
+	msg.Response.Text = "ok"
+	s := mock.NewSession()
+	s.Fail(errors.New("offline"))
+	if err := r.Execute(msg, s); err == nil {
+		t.Fatalf("no error from a failing session")
+	}
+	if e, ok := got[len(got)-1].(*event.CommandFailed); !ok || e.Err != "offline" {
+		t.Errorf("got %+v, want the failed send", got[len(got)-1])
+	}
+}
+
+/*
+Test Cases:
+- entries from parsing and sending a message share its correlation id
+*/
+func TestRegistry_Log(t *testing.T) {
+	var b bytes.Buffer
+	r := NewRegistry(WithLogger(gb.NewLogger(&b, gb.LogLevel(gb.LevelDebug))))
+
+	msg, err := r.Parse(&discordgo.Message{Author: &discordgo.User{ID: "1"}, ChannelID: "2", Content: "&dq list"})
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+
+	msg.Response.ChannelId = 2
+	msg.Response.Text = "ok"
+	s := mock.NewSession()
+	s.Fail(errors.New("offline"))
+	if err := r.Execute(msg, s); err == nil {
+		t.Fatalf("no error from a failing session")
+	}
+
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d entries, want 2:\n%s", len(lines), b.String())
+	}
+	for _, l := range lines {
+		if !strings.Contains(l, "cid="+msg.Id) {
+			t.Errorf("entry without the correlation id: %s", l)
+		}
+	}
+}
+
+/*
+Test Cases:
+- chat, commands and errors counted by result
+- subcommands past the limit counted as other
+*/
+func TestRegistry_Metrics(t *testing.T) {
+	r := NewRegistry(WithLogger(nil))
+	parse := func(content string) {
+		_, _ = r.Parse(&discordgo.Message{Author: &discordgo.User{ID: "1"}, ChannelID: "2", Content: content})
+	}
+
+	chat, commands, errs := metrics.MessagesParsed.Value("chat"), metrics.MessagesParsed.Value("command"), metrics.MessagesParsed.Value("error")
+	parse("hello")
+	parse("&dq list")
+	parse(`&dq add "topic`)
+	if metrics.MessagesParsed.Value("chat") != chat+1 || metrics.MessagesParsed.Value("command") != commands+1 || metrics.MessagesParsed.Value("error") != errs+1 {
+		t.Errorf("messages not counted by result")
+	}
+
+	for i := 0; i < maxSubcommands; i++ {
+		parse(fmt.Sprintf("&trigger typo%d", i))
+	}
+
+	other := metrics.Commands.Value("Trigger", "other")
+	parse("&trigger list")
+	if metrics.Commands.Value("Trigger", "other") != other+1 {
+		t.Errorf("subcommand past the limit not counted as other")
+	}
+}
+
+/*
+Test Cases:
+- allowed command reaches its interceptor
+- first refusal tells the user to wait, later ones are silent
+- chat is never limited
+*/
+func TestRegistry_Cooldowns(t *testing.T) {
+	clock := mock.NewClock(time.Date(2020, 1, 2, 15, 4, 5, 0, time.UTC))
+	limits := cooldown.New(clock, cooldown.WithDefault(cooldown.Rule{User: cooldown.Limit{Every: 4500 * time.Millisecond}}), cooldown.WithFeedback(true))
+
+	calls := 0
+	r := NewRegistry(WithLogger(nil), WithCooldowns(limits), WithInterceptor(gb.Meme, func(msg *gb.Message) error {
+		calls++
+		return nil
+	}))
+
+	tests := []struct {
+		name      string
+		cmd       gb.Command
+		wantCalls int
+		wantText  string
+	}{
+		{name: "allowed", cmd: gb.Meme, wantCalls: 1},
+		{name: "refused", cmd: gb.Meme, wantCalls: 1, wantText: "Try again in 5s."},
+		{name: "silent", cmd: gb.Meme, wantCalls: 1},
+		{name: "chat", cmd: gb.None, wantCalls: 2},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			msg := mock.NewMessage(test.cmd, mock.WithSource(1, 2, "user", ""))
+			if err := r.Intercept(msg); err != nil {
+				t.Fatalf("intercept: %v", err)
+			}
+
+			if calls != test.wantCalls {
+				t.Errorf("interceptor called %d times, want %d", calls, test.wantCalls)
+			}
+
+			var got string
+			if msg.Response.Embed != nil {
+				got = msg.Response.Embed.Description
+			}
+			if got != test.wantText {
+				t.Errorf("feedback = %q, want %q", got, test.wantText)
 			}
 		})
 	}
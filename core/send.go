@@ -0,0 +1,160 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"github.com/bwmarrin/discordgo"
+	gb "github.com/ericebersohl/gobottas"
+	"github.com/ericebersohl/gobottas/discord"
+	"net/http"
+	"unicode/utf8"
+)
+
+// A message that is updated in place, by channel and key
+type update struct {
+	channel string
+	key     string
+}
+
+// send one output of the message's response
+func (r *Registry) send(msg *gb.Message, s gb.Session, o gb.Output) error {
+	channel := msg.Response.ChannelId.String()
+
+	// ephemeral outputs go to the author in a direct message
+	if o.Ephemeral {
+		dm, err := s.UserChannelCreate(msg.Source.AuthorId.String())
+		if err != nil {
+			return err
+		}
+		channel = dm.ID
+	}
+
+	if o.Reply && msg.Source.AuthorId != 0 {
+		o.Text = fmt.Sprintf("<@%s> %s", msg.Source.AuthorId, o.Text)
+	}
+
+	log := r.logger(msg).With("command", msg.Command, "channel", channel, "kind", o.Kind)
+
+	switch o.Kind {
+	case gb.TextOutput, gb.EmbedOutput:
+		var id string
+		switch {
+		case o.MessageId != 0:
+			id = o.MessageId.String()
+		case o.Update != "":
+			id = r.updated(channel, o.Update)
+		}
+
+		if id != "" {
+			err := edit(s, channel, id, o)
+			switch {
+			case err == nil:
+				log.Debug("edited response", "message", id)
+				return nil
+			case o.Update == "" || !notFound(err):
+				return err
+			}
+			// the message was deleted since it was sent; send a new one
+		}
+
+		if o.Kind == gb.EmbedOutput {
+			m, err := s.ChannelMessageSendEmbed(channel, o.Embed)
+			if err != nil {
+				return err
+			}
+			r.setUpdated(channel, o.Update, m)
+			log.Debug("sent response", "embed", o.Embed.Title)
+			return nil
+		}
+
+		for _, text := range discord.SplitMessage(o.Text, discord.MessageLimit) {
+			m, err := s.ChannelMessageSend(channel, text)
+			if err != nil {
+				return err
+			}
+			r.setUpdated(channel, o.Update, m)
+		}
+		log.Debug("sent response")
+
+	case gb.FileOutput:
+		// text too long for one message goes with the file first and the rest after it
+		texts := discord.SplitMessage(o.Text, discord.MessageLimit)
+		if _, err := s.ChannelFileSendWithMessage(channel, texts[0], o.File.Name, o.File.Reader); err != nil {
+			return err
+		}
+		for _, text := range texts[1:] {
+			if _, err := s.ChannelMessageSend(channel, text); err != nil {
+				return err
+			}
+		}
+		log.Debug("sent response", "file", o.File.Name)
+
+	case gb.ReactionOutput:
+		// reactions go on the message being answered, wherever the reply goes
+		if msg.Source.MessageId == 0 {
+			return fmt.Errorf("no message to react to with %s", o.Text)
+		}
+		if err := s.MessageReactionAdd(msg.Source.ChannelId.String(), msg.Source.MessageId.String(), o.Text); err != nil {
+			return err
+		}
+		log.Debug("sent response", "reaction", o.Text)
+
+	case gb.DeleteOutput:
+		// without an id, the message being answered is deleted
+		channel, id := channel, o.MessageId
+		if id == 0 {
+			channel, id = msg.Source.ChannelId.String(), msg.Source.MessageId
+		}
+		if id == 0 {
+			return errors.New("no message to delete")
+		}
+		if err := s.ChannelMessageDelete(channel, id.String()); err != nil {
+			return err
+		}
+		log.Debug("deleted message", "message", id)
+	}
+
+	return nil
+}
+
+// change a message the bot sent to the output's text or embed
+func edit(s gb.Session, channel, id string, o gb.Output) error {
+	if o.Kind == gb.EmbedOutput {
+		_, err := s.ChannelMessageEditEmbed(channel, id, o.Embed)
+		return err
+	}
+
+	if utf8.RuneCountInString(o.Text) > discord.MessageLimit {
+		return fmt.Errorf("edit of message %s is longer than %d characters", id, discord.MessageLimit)
+	}
+	_, err := s.ChannelMessageEdit(channel, id, o.Text)
+	return err
+}
+
+// the message last sent in the channel with the key, if any
+func (r *Registry) updated(channel, key string) string {
+	r.updMu.Lock()
+	defer r.updMu.Unlock()
+	return r.updates[update{channel, key}]
+}
+
+// remember m as the message to edit next time the key is used in the channel
+func (r *Registry) setUpdated(channel, key string, m *discordgo.Message) {
+	if key == "" || m == nil {
+		return
+	}
+
+	r.updMu.Lock()
+	defer r.updMu.Unlock()
+
+	if r.updates == nil {
+		r.updates = make(map[update]string)
+	}
+	r.updates[update{channel, key}] = m.ID
+}
+
+// whether discord says the message doesn't exist (any more)
+func notFound(err error) bool {
+	e, ok := err.(*discordgo.RESTError)
+	return ok && e.Response != nil && e.Response.StatusCode == http.StatusNotFound
+}
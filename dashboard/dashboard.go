@@ -0,0 +1,42 @@
+// Package dashboard serves a small web page for curating the discussion queue and the meme stash outside discord:
+// reordering topics by dragging them, editing them and their sources, looking back over the archive of finished topics
+// and pruning the stash.  The page is built into the binary.  It reads and changes the queue and the stash through the
+// admin API, with the API token whoever opens it enters, so every change is checked, journaled, audited and saved as
+// it would be from chat.
+package dashboard
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+//go:embed static
+var static embed.FS
+
+// Serve the page at / and api, the admin API, under /api/
+func Handler(api http.Handler) http.Handler {
+	assets, err := fs.Sub(static, "static")
+	if err != nil {
+		panic(err) // the directory is embedded above
+	}
+	files := http.FileServer(http.FS(assets))
+
+	mux := http.NewServeMux()
+	mux.Handle("/api/", api)
+	mux.HandleFunc("/", func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet && req.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		// the page only loads its own scripts and styles, and talks to the API
+		h := w.Header()
+		h.Set("Content-Security-Policy", "default-src 'self'; img-src 'self' https: data:; frame-ancestors 'none'")
+		h.Set("X-Content-Type-Options", "nosniff")
+		h.Set("Referrer-Policy", "no-referrer")
+		files.ServeHTTP(w, req)
+	})
+	return mux
+}
@@ -0,0 +1,74 @@
+package dashboard
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+/*
+Test Cases:
+- the page and its assets are served, with the security headers
+- missing assets are not found
+- requests under /api/ go to the API
+- the page can't be changed
+*/
+func TestHandler(t *testing.T) {
+	var apiPaths []string
+	api := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		apiPaths = append(apiPaths, req.URL.Path)
+		w.WriteHeader(http.StatusTeapot)
+	})
+	srv := httptest.NewServer(Handler(api))
+	defer srv.Close()
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		wantStatus int
+		wantType   string // prefix of the content type
+		wantBody   string // something the body contains
+	}{
+		{name: "index", method: "GET", path: "/", wantStatus: 200, wantType: "text/html", wantBody: `<script src="app.js"`},
+		{name: "script", method: "GET", path: "/app.js", wantStatus: 200, wantType: "text/javascript", wantBody: "/api/"},
+		{name: "style", method: "GET", path: "/style.css", wantStatus: 200, wantType: "text/css"},
+		{name: "missing", method: "GET", path: "/nope.js", wantStatus: 404},
+		{name: "api", method: "GET", path: "/api/guilds/1/queue", wantStatus: http.StatusTeapot},
+		{name: "post", method: "POST", path: "/", wantStatus: 405},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req, err := http.NewRequest(test.method, srv.URL+test.path, nil)
+			if err != nil {
+				t.Fatalf("request: %v", err)
+			}
+			resp, err := srv.Client().Do(req)
+			if err != nil {
+				t.Fatalf("%s %s: %v", test.method, test.path, err)
+			}
+			defer resp.Body.Close()
+			body, _ := ioutil.ReadAll(resp.Body)
+
+			if resp.StatusCode != test.wantStatus {
+				t.Errorf("status %d, want %d", resp.StatusCode, test.wantStatus)
+			}
+			if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, test.wantType) {
+				t.Errorf("content type %q, want %q", ct, test.wantType)
+			}
+			if !strings.Contains(string(body), test.wantBody) {
+				t.Errorf("body doesn't contain %q", test.wantBody)
+			}
+			if test.wantStatus == 200 && resp.Header.Get("Content-Security-Policy") == "" {
+				t.Errorf("no content security policy")
+			}
+		})
+	}
+
+	if len(apiPaths) != 1 || apiPaths[0] != "/api/guilds/1/queue" {
+		t.Errorf("api got %v, want [/api/guilds/1/queue]", apiPaths)
+	}
+}
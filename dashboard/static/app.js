@@ -0,0 +1,314 @@
+'use strict';
+
+// The dashboard talks to the admin API with the token kept for this tab.  Everything the API returns is shown with
+// textContent, never as markup, since topics and memes come from chat.  Every guild has its own queue and stash; the
+// one shown is picked from the guilds the API lists, and kept in the page's URL as #{guild id}.
+
+const tokenKey = 'gobottas-token';
+
+// the id of the guild shown, as a string, since JavaScript can't hold snowflakes as numbers
+let guild = decodeURIComponent(location.hash.slice(1));
+
+const $ = (id) => document.getElementById(id);
+
+function token() {
+	return sessionStorage.getItem(tokenKey);
+}
+
+function show(message) {
+	const status = $('status');
+	status.textContent = message || '';
+	status.hidden = !message;
+}
+
+function signIn() {
+	$('dashboard').hidden = true;
+	$('guilds').hidden = true;
+	$('logout').hidden = true;
+	$('login').hidden = false;
+}
+
+// The API path of something in the guild shown
+function inGuild(path) {
+	return 'guilds/' + encodeURIComponent(guild) + '/' + path;
+}
+
+// Call the API, returning the decoded body, or null when there isn't one
+async function call(method, path, body) {
+	const headers = {Authorization: 'Bearer ' + token()};
+	if (body !== undefined) {
+		headers['Content-Type'] = 'application/json';
+	}
+
+	const res = await fetch('/api/' + path, {
+		method,
+		headers,
+		body: body === undefined ? undefined : JSON.stringify(body),
+	});
+
+	if (res.status === 401) {
+		sessionStorage.removeItem(tokenKey);
+		signIn();
+		throw new Error('The token was not accepted.');
+	}
+	if (!res.ok) {
+		const problem = await res.json().catch(() => ({}));
+		throw new Error(problem.message || res.statusText);
+	}
+	return res.status === 204 ? null : res.json();
+}
+
+// Run a change, then show the queue and the stash as they are now
+async function change(f) {
+	try {
+		await f();
+		show('');
+	} catch (e) {
+		show(e.message);
+	}
+	await refresh();
+}
+
+function topicPath(name) {
+	return inGuild('queue/topics/' + encodeURIComponent(name));
+}
+
+function when(t) {
+	return new Date(t).toLocaleString();
+}
+
+// A link, if s is one, otherwise text
+function linkOrText(s) {
+	try {
+		const u = new URL(s);
+		if (u.protocol === 'http:' || u.protocol === 'https:') {
+			const a = document.createElement('a');
+			a.href = u.href;
+			a.rel = 'noopener noreferrer';
+			a.target = '_blank';
+			a.textContent = s;
+			return a;
+		}
+	} catch (e) {
+		// not a link
+	}
+	return document.createTextNode(s);
+}
+
+function button(label, onclick) {
+	const b = document.createElement('button');
+	b.type = 'button';
+	b.textContent = label;
+	b.addEventListener('click', onclick);
+	return b;
+}
+
+function sources(topic, editable) {
+	const ul = document.createElement('ul');
+	ul.className = 'sources';
+	(topic.sources || []).forEach((s, i) => {
+		const li = document.createElement('li');
+		li.append(linkOrText(s));
+		if (editable) {
+			// the API refuses the detach if chat has changed the sources, rather than detaching the wrong one
+			li.append(button('Detach', () => change(() => call('DELETE', topicPath(topic.name) + '/sources/' + i + '?url=' + encodeURIComponent(s)))));
+		}
+		ul.append(li);
+	});
+	return ul;
+}
+
+// the topic being dragged
+let dragged = null;
+
+function renderTopic(topic, index) {
+	const li = $('topic').content.firstElementChild.cloneNode(true);
+	const view = li.querySelector('.view');
+	const editor = li.querySelector('.editor');
+
+	li.querySelector('.name').textContent = topic.name;
+	li.querySelector('.description').textContent = topic.description;
+	li.querySelector('.meta').textContent = 'Added by ' + topic.created_by + ' on ' + when(topic.created);
+	li.querySelector('.sources').replaceWith(sources(topic, true));
+
+	li.querySelector('.attach').addEventListener('submit', (e) => {
+		e.preventDefault();
+		const url = e.target.elements.url.value;
+		change(() => call('POST', topicPath(topic.name) + '/sources', {url}));
+	});
+
+	li.querySelector('.edit').addEventListener('click', () => {
+		editor.elements.name.value = topic.name;
+		editor.elements.description.value = topic.description;
+		view.hidden = true;
+		editor.hidden = false;
+		li.draggable = false;
+	});
+	editor.querySelector('.cancel').addEventListener('click', () => refresh());
+	editor.addEventListener('submit', (e) => {
+		e.preventDefault();
+		const edit = {name: editor.elements.name.value, description: editor.elements.description.value};
+		change(() => call('PATCH', topicPath(topic.name), edit));
+	});
+
+	li.querySelector('.remove').addEventListener('click', () => {
+		if (confirm('Remove "' + topic.name + '" from the queue?')) {
+			change(() => call('DELETE', topicPath(topic.name)));
+		}
+	});
+
+	// dropping a topic on another moves it to that one's position
+	li.addEventListener('dragstart', (e) => {
+		dragged = topic.name;
+		li.classList.add('dragging');
+		e.dataTransfer.effectAllowed = 'move';
+		e.dataTransfer.setData('text/plain', topic.name);
+	});
+	li.addEventListener('dragend', () => {
+		dragged = null;
+		li.classList.remove('dragging');
+	});
+	li.addEventListener('dragover', (e) => {
+		if (dragged !== null && dragged !== topic.name) {
+			e.preventDefault();
+			li.classList.add('over');
+		}
+	});
+	li.addEventListener('dragleave', () => li.classList.remove('over'));
+	li.addEventListener('drop', (e) => {
+		e.preventDefault();
+		li.classList.remove('over');
+		const name = dragged;
+		if (name !== null && name !== topic.name) {
+			change(() => call('PATCH', topicPath(name), {position: index}));
+		}
+	});
+
+	return li;
+}
+
+function renderFinished(f) {
+	const li = document.createElement('li');
+	const name = document.createElement('strong');
+	name.textContent = f.topic.name;
+	const description = document.createElement('p');
+	description.className = 'description';
+	description.textContent = f.topic.description;
+	const meta = document.createElement('p');
+	meta.className = 'meta';
+	meta.textContent = 'Finished by ' + f.by + ' on ' + when(f.finished);
+	li.append(name, description, meta, sources(f.topic, false));
+	return li;
+}
+
+function renderMeme(m) {
+	const li = document.createElement('li');
+	li.value = m.index;
+	const meta = document.createElement('span');
+	meta.className = 'meta';
+	meta.textContent = ' added by ' + m['added-by'];
+	li.append(linkOrText(m.meme), meta, button('Remove', () => {
+		if (confirm('Remove meme ' + m.index + ' from the stash?')) {
+			// the API refuses the removal if chat has changed the stash, rather than removing the wrong meme
+			change(() => call('DELETE', inGuild('memes/' + m.index) + '?meme=' + encodeURIComponent(m.meme)));
+		}
+	}));
+	return li;
+}
+
+function renderGuild(g) {
+	const option = document.createElement('option');
+	option.value = g.id;
+	option.textContent = g.id + ' (' + g.topics + ' topics, ' + g.memes + ' memes, ' + g.pending + ' pending)';
+	return option;
+}
+
+// List the guilds in the picker, and show the first if the one in the URL isn't among them
+async function pickGuild() {
+	const guilds = await call('GET', 'guilds');
+	$('guild').replaceChildren(...guilds.map(renderGuild));
+	$('guilds').hidden = false;
+
+	if (guilds.length === 0) {
+		throw new Error('The bot isn\'t in any guilds yet.');
+	}
+	if (!guilds.some((g) => g.id === guild)) {
+		guild = guilds[0].id;
+		history.replaceState(null, '', '#' + encodeURIComponent(guild));
+	}
+	$('guild').value = guild;
+}
+
+async function refresh() {
+	if (!token()) {
+		signIn();
+		return;
+	}
+
+	let queue, memes;
+	try {
+		await pickGuild();
+		[queue, memes] = await Promise.all([call('GET', inGuild('queue')), call('GET', inGuild('memes'))]);
+	} catch (e) {
+		$('dashboard').hidden = true;
+		show(e.message);
+		return;
+	}
+
+	$('topics').replaceChildren(...queue.topics.map(renderTopic));
+	$('done').disabled = queue.topics.length === 0;
+	$('finished').replaceChildren(...queue.archive.map(renderFinished));
+	$('memes').replaceChildren(...memes.map(renderMeme));
+
+	$('login').hidden = true;
+	$('logout').hidden = false;
+	$('dashboard').hidden = false;
+}
+
+$('login').addEventListener('submit', (e) => {
+	e.preventDefault();
+	sessionStorage.setItem(tokenKey, e.target.elements.token.value);
+	e.target.reset();
+	show('');
+	refresh();
+});
+
+$('logout').addEventListener('click', () => {
+	sessionStorage.removeItem(tokenKey);
+	signIn();
+});
+
+$('guild').addEventListener('change', (e) => {
+	location.hash = encodeURIComponent(e.target.value);
+});
+
+// the guild in the URL is the one shown, so links to a guild, and back and forward, work
+window.addEventListener('hashchange', () => {
+	guild = decodeURIComponent(location.hash.slice(1));
+	show('');
+	refresh();
+});
+
+$('done').addEventListener('click', () => change(() => call('POST', inGuild('queue/done'))));
+
+$('add-topic').addEventListener('submit', (e) => {
+	e.preventDefault();
+	const form = e.target;
+	const topic = {name: form.elements.name.value, description: form.elements.description.value};
+	change(async () => {
+		await call('POST', inGuild('queue/topics'), topic);
+		form.reset();
+	});
+});
+
+$('add-meme').addEventListener('submit', (e) => {
+	e.preventDefault();
+	const form = e.target;
+	const meme = {meme: form.elements.meme.value};
+	change(async () => {
+		await call('POST', inGuild('memes'), meme);
+		form.reset();
+	});
+});
+
+refresh();
@@ -0,0 +1,172 @@
+package discord
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Most characters discord allows in one message
+const MessageLimit = 2000
+
+// closes a code block that continues in the next message
+const closeFence = "\n```"
+
+// Longest language a reopened code block is labelled with
+const maxLang = 16
+
+// Split text into messages of at most limit characters, breaking between lines where it can.  A code block that is
+// split is closed at the end of one message and reopened, with its language, at the start of the next.
+func SplitMessage(text string, limit int) []string {
+	if utf8.RuneCountInString(text) <= limit {
+		return []string{text}
+	}
+
+	var out []string
+	pieces := split(text, limit)
+	for _, p := range pieces {
+		// discord won't send a blank message, such as the empty line before a long one
+		if s := p.String(); strings.TrimSpace(s) != "" {
+			out = append(out, s)
+		}
+	}
+	if len(out) == 0 {
+		out = append(out, pieces[len(pieces)-1].String())
+	}
+	return out
+}
+
+// Part of a split text that becomes one message
+type piece struct {
+	cont  bool     // the first line carries on the last line of the piece before
+	fence string   // code block reopened in front of the lines, if any
+	lines []string // lines of text
+	close bool     // the code block is still open at the end
+}
+
+func (p piece) String() string {
+	s := strings.Join(p.lines, "\n")
+	if p.fence != "" {
+		s = p.fence + "\n" + s
+	}
+	if p.close {
+		s += closeFence
+	}
+	return s
+}
+
+// Break text into pieces that are at most limit characters once their code blocks are closed and reopened.
+func split(text string, limit int) []piece {
+	var (
+		out   []piece
+		cur   piece
+		size  int    // characters in cur, without its closing fence
+		fresh = true // cur holds nothing worth sending on its own
+		bare  bool   // the last line of cur, whole, opened a code block
+		fence string // marker of the code block open before the current line, if any
+	)
+
+	// code blocks are only kept whole when a reopened one leaves room for some text
+	keep := limit >= len("```"+closeFence)+2
+
+	sep := func() int {
+		if len(cur.lines) > 0 || cur.fence != "" {
+			return 1
+		}
+		return 0
+	}
+
+	add := func(s string) {
+		size += sep() + utf8.RuneCountInString(s)
+		cur.lines = append(cur.lines, s)
+	}
+
+	// end cur where open is the code block still open, if any, and start the next piece
+	flush := func(open string, cont bool) {
+		cur.close = open != ""
+		out = append(out, cur)
+		cur, size = piece{cont: cont, fence: open}, utf8.RuneCountInString(open)
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		// whether a code block is open after this line
+		next := fence
+		if m, ok := marker(line); ok && keep {
+			switch {
+			case fence != "":
+				next = ""
+			case utf8.RuneCountInString(m+closeFence)+2 > limit:
+				next = "```"
+			default:
+				next = m
+			}
+		}
+
+		// leave room to close a code block that is still open
+		reserve := 0
+		if next != "" {
+			reserve = len(closeFence)
+		}
+
+		if size+sep()+utf8.RuneCountInString(line)+reserve > limit && !fresh {
+			if bare {
+				// a code block that only just opened moves to the next message whole
+				opener := cur.lines[len(cur.lines)-1]
+				cur.lines = cur.lines[:len(cur.lines)-1]
+				flush("", false)
+				add(opener)
+			} else {
+				flush(fence, false)
+			}
+		}
+
+		// a line longer than a whole message is broken wherever it has to be
+		rest := line
+		for {
+			room := limit - size - sep() - reserve
+			if utf8.RuneCountInString(rest) <= room {
+				add(rest)
+				break
+			}
+			if room < 1 {
+				flush(fence, false)
+				continue
+			}
+
+			cut := offset(rest, room)
+			add(rest[:cut])
+			rest = rest[cut:]
+			flush(next, true)
+		}
+
+		// the end of a broken line already went out with the start of its block
+		bare = fence == "" && next != "" && !(cur.cont && len(cur.lines) == 1)
+		fresh = len(cur.lines) == 0 || bare && len(cur.lines) == 1
+		fence = next
+	}
+
+	return append(out, cur)
+}
+
+// The marker, "```" and its language, of a line that opens or closes a code block
+func marker(line string) (string, bool) {
+	s := strings.TrimSpace(line)
+	if !strings.HasPrefix(s, "```") {
+		return "", false
+	}
+
+	if f := strings.Fields(s[3:]); len(f) > 0 && utf8.RuneCountInString(f[0]) <= maxLang {
+		return "```" + f[0], true
+	}
+	return "```", true
+}
+
+// Byte offset of the nth character of s
+func offset(s string, n int) int {
+	for i := range s {
+		if n == 0 {
+			return i
+		}
+		n--
+	}
+	return len(s)
+}
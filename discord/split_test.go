@@ -0,0 +1,145 @@
+package discord
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+/*
+Test Cases:
+- short text is left alone
+- split between lines
+- lines are never broken when they fit
+- a code block is closed and reopened with its language
+- a code block that would only open at the end of a message starts the next one
+- a closed code block isn't reopened
+- a line longer than a message is broken
+- limit counts characters, not bytes
+- blank lines before or after a split don't become empty messages
+- a long line opening a code block is broken with room to close it
+- a language too long to repeat isn't reopened
+*/
+func TestSplitMessage(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		limit int
+		want  []string
+	}{
+		{name: "short", text: "hello\nworld", limit: 20, want: []string{"hello\nworld"}},
+		{name: "lines", text: "aaaa\nbbbb\ncccc", limit: 10, want: []string{"aaaa\nbbbb", "cccc"}},
+		{name: "whole-lines", text: "aa\nbbbbbbbb\ncc", limit: 10, want: []string{"aa", "bbbbbbbb", "cc"}},
+		{
+			name:  "code-block",
+			text:  "look:\n```go\nline1\nline2\nline3\n```\ndone",
+			limit: 21,
+			want:  []string{"look:\n```go\nline1\n```", "```go\nline2\nline3\n```", "done"},
+		},
+		{
+			name:  "code-block-whole",
+			text:  "intro text\n```py\nprint(1)\n```",
+			limit: 20,
+			want:  []string{"intro text", "```py\nprint(1)\n```"},
+		},
+		{
+			name:  "closed-block",
+			text:  "```\nab\n```\nsome more text",
+			limit: 16,
+			want:  []string{"```\nab\n```", "some more text"},
+		},
+		{name: "long-line", text: "abcdefghijklmnopqrstuvwxy", limit: 10, want: []string{"abcdefghij", "klmnopqrst", "uvwxy"}},
+		{name: "leading-newline", text: "\nabcdefghijklmno", limit: 10, want: []string{"abcdefghij", "klmno"}},
+		{name: "trailing-newline", text: "abcdefghijklmnopqrst\n", limit: 10, want: []string{"abcdefghij", "klmnopqrst"}},
+		{name: "runes", text: "ééééé\nééééé", limit: 11, want: []string{"ééééé\nééééé"}},
+		{
+			name:  "long-opener",
+			text:  "a\n```yyyyyyyyyyyyyy\nmore",
+			limit: 16,
+			want:  []string{"a", "```yyyyyyyyy\n```", "```\nyyyyy\n```", "```\nmore"},
+		},
+		{
+			name:  "long-lang",
+			text:  "```goxxxxxxxxxxxxxxx",
+			limit: 12,
+			want:  []string{"```goxxx\n```", "```\nxxxx\n```", "```\nxxxx\n```", "```\nxxxx"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := SplitMessage(test.text, test.limit)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+
+			for _, m := range got {
+				if utf8.RuneCountInString(m) > test.limit {
+					t.Errorf("%q is longer than %d", m, test.limit)
+				}
+			}
+		})
+	}
+}
+
+/*
+Test Cases:
+- a long code listing splits into messages that are each a complete code block, within discord's limit
+*/
+func TestSplitMessage_Listing(t *testing.T) {
+	var b strings.Builder
+	b.WriteString("```go\n")
+	for i := 0; i < 300; i++ {
+		b.WriteString("fmt.Println(\"hello, world\")\n")
+	}
+	b.WriteString("```")
+
+	got := SplitMessage(b.String(), MessageLimit)
+	if len(got) < 2 {
+		t.Fatalf("got %d messages, want several", len(got))
+	}
+
+	for i, m := range got {
+		if utf8.RuneCountInString(m) > MessageLimit {
+			t.Errorf("message %d is %d characters", i, utf8.RuneCountInString(m))
+		}
+		if !strings.HasPrefix(m, "```go\n") || !strings.HasSuffix(m, "```") {
+			t.Errorf("message %d isn't a complete code block: %q...", i, m[:20])
+		}
+	}
+}
+
+/*
+Test Cases:
+- every message is within the limit
+- the pieces, without the fences added to them, join back into the text
+*/
+func FuzzSplitMessage(f *testing.F) {
+	f.Add("hello\nworld", uint16(5))
+	f.Add("look:\n```go\nline1\nline2\nline3\n```\ndone", uint16(21))
+	f.Add("a\n```"+strings.Repeat("y", 1993)+"\nmore", uint16(MessageLimit-1))
+	f.Add("```go"+strings.Repeat("x", 1990)+"\n```", uint16(MessageLimit-1))
+	f.Add("```\n\n```\n\n\n```py\nééé\n```", uint16(3))
+
+	f.Fuzz(func(t *testing.T, text string, limit uint16) {
+		n := int(limit)%MessageLimit + 1
+
+		for i, m := range SplitMessage(text, n) {
+			if c := utf8.RuneCountInString(m); c > n {
+				t.Errorf("message %d is %d characters, limit %d", i, c, n)
+			}
+		}
+
+		var b strings.Builder
+		for i, p := range split(text, n) {
+			if i > 0 && !p.cont {
+				b.WriteString("\n")
+			}
+			b.WriteString(strings.Join(p.lines, "\n"))
+		}
+		if b.String() != text {
+			t.Errorf("pieces join into %q, want %q", b.String(), text)
+		}
+	})
+}
@@ -5,8 +5,10 @@ import (
 	"fmt"
 	"github.com/bwmarrin/discordgo"
 	gb "github.com/ericebersohl/gobottas"
+	"github.com/ericebersohl/gobottas/args"
 	"github.com/ericebersohl/gobottas/discord"
-	"strconv"
+	"net/url"
+	"strings"
 	"time"
 )
 
@@ -23,18 +25,25 @@ const (
 	QAttach
 	QDetach
 	QList
+	QDone
 )
 
 func (qc Command) String() string {
-	return [...]string{"Error", "Add", "Remove", "Next", "Bump", "Skip", "Attach", "Detach", "List"}[qc]
+	return [...]string{"Error", "Add", "Remove", "Next", "Bump", "Skip", "Attach", "Detach", "List", "Done"}[qc]
 }
 
+// Key of the list message, which is edited in place each time the queue is listed in a channel
+const ListKey = "dq list"
+
+// Topics on each page of the list
+const PageSize = 10
+
 // parse a string arg into a QueueCommand
 func ArgToCommand(arg string) Command {
-	switch arg {
+	switch strings.ToLower(arg) {
 	case "add":
 		return QAdd
-	case "remove":
+	case "remove", "rm":
 		return QRemove
 	case "next":
 		return QNext
@@ -48,6 +57,8 @@ func ArgToCommand(arg string) Command {
 		return QDetach
 	case "list":
 		return QList
+	case "done":
+		return QDone
 	default:
 		return QError
 	}
@@ -61,39 +72,50 @@ type Topic struct {
 	Modified    time.Time `json:"modified"`
 	Created     time.Time `json:"created"`
 	CreatedBy   string    `json:"created_by"` // original author username of the topic
+
+	Votes []gb.Snowflake `json:"votes,omitempty"` // users who voted for the topic, each once
 }
 
-// Built in Embed function for Topics, primarily used for queue.Next()
+// Built in Embed function for Topics, primarily used for queue.Next().  People vote for the topic by reacting to it.
 func (t *Topic) Embed() *discordgo.MessageEmbed {
 	msg := discord.NewEmbed().
 		EmbedColor(gb.DiscCol).
 		EmbedTitle(t.Name).
-		EmbedFooter(fmt.Sprintf("Proposed by %s", t.CreatedBy), "", "").
+		EmbedFooter(fmt.Sprintf("%s%s · %s · React %s to vote", proposed, t.CreatedBy, votes(len(t.Votes)), Vote), "", "").
 		EmbedTimestamp(t.Created).
 		EmbedDescription(t.Description)
 	return msg.MessageEmbed
 }
 
-// Returns an interceptor.  Have to nest functions so that the Interceptor can access the Queue
-func Interceptor(q *Queue) gb.Interceptor {
+// Returns an interceptor.  Have to nest functions so that the Interceptor can access the Queues.  Commands change the
+// queue of the guild they're sent in, and reactions to the bot's topics and lists vote and turn pages.
+func Interceptor(qs *Queues) gb.Interceptor {
 	return func(msg *gb.Message) error {
 
 		// skip if not Queue message
-		if msg.Command != gb.Queue {
+		if msg.Command != gb.Queue && msg.Command != gb.Reaction {
 			return nil
 		}
 
 		// error if registry doesn't have a queue
-		if q == nil {
+		if qs == nil {
 			return errors.New("cannot intercept with nil queue")
 		}
 
+		if msg.Command == gb.Reaction {
+			return react(qs, msg)
+		}
+
+		q := qs.Get(msg.Source.GuildId)
+		q.Lock()
+		defer q.Unlock()
+
 		// Queue commands are sent back on the channel in which they are received
 		msg.Response.ChannelId = msg.Source.ChannelId
 
 		// error if Queue msg without at least one arg
 		if len(msg.Args) < 1 {
-			msg.Response.Embed = discord.NewError("Too Few Args", "You must supply a queue command (add, remove, next, bump, skip, attach, detach, or list)").Embed()
+			msg.Response.Embed = discord.NewError("Too Few Args", "You must supply a queue command (add, remove, next, done, bump, skip, attach, detach, or list)").Embed()
 			return nil
 		}
 
@@ -101,172 +123,127 @@ func Interceptor(q *Queue) gb.Interceptor {
 		cmd := ArgToCommand(msg.Args[0])
 		switch cmd {
 		case QAdd:
-			// check for required arg
-			if len(msg.Args) < 2 {
-				msg.Response.Embed = discord.NewError("Too Few Args", "`add` requires at least one argument:\n`&dq add [name] [description?]`").Embed()
-				return nil
+			var a struct {
+				Name        string `arg:"name"`
+				Description string `arg:"description,optional"`
 			}
-
-			t := Topic{
-				Sources:   nil,
-				Modified:  time.Now(),
-				Created:   time.Now(),
-				CreatedBy: msg.Source.Username,
-			}
-
-			t.Name = msg.Args[1]
-
-			// add description if exists
-			if len(msg.Args) > 2 {
-				t.Description = msg.Args[2]
+			if err := args.Parse("&dq add", msg.Args[1:], &a); err != nil {
+				return embedError(msg, err)
 			}
 
 			// add to queue
-			if err := q.Add(&t); err != nil {
-				if e, ok := err.(discord.Error); ok {
-					msg.Response.Embed = e.Embed()
-					return nil
-				} else {
-					return err
-				}
+			if _, err := q.AddTopic(msg, a.Name, a.Description); err != nil {
+				return embedError(msg, err)
 			}
-
 			return nil
 
 		case QRemove:
-			// check for name arg
-			if len(msg.Args) < 2 {
-				msg.Response.Embed = discord.NewError("Too Few Arguments", "Remove requires a name argument:\n`&dq remove [name]`").Embed()
-				return nil
+			var a nameArgs
+			if err := args.Parse("&dq remove", msg.Args[1:], &a); err != nil {
+				return embedError(msg, err)
 			}
 
-			// call remove
-			if err := q.Remove(msg.Args[1]); err != nil {
-				if e, ok := err.(discord.Error); ok {
-					msg.Response.Embed = e.Embed()
-					return nil
-				} else {
-					return err
-				}
+			if _, err := q.Find(a.Name); err != nil {
+				return embedError(msg, err)
 			}
 
+			msg.Confirm = &gb.Confirmation{
+				Prompt: fmt.Sprintf("Remove topic %q?", a.Name),
+				Action: func(reply *gb.Message) error {
+					q.Lock()
+					defer q.Unlock()
+
+					if err := q.RemoveTopic(reply, a.Name); err != nil {
+						return embedError(reply, err)
+					}
+
+					reply.Response.Text = fmt.Sprintf("Removed topic %q.", a.Name)
+					return nil
+				},
+			}
 			return nil
 
 		case QNext:
 			// call next; get topic
 			t, err := q.Next()
 			if err != nil {
-				if e, ok := err.(discord.Error); ok {
-					msg.Response.Embed = e.Embed()
-					return nil
-				} else {
-					return err
-				}
+				return embedError(msg, err)
 			}
 
 			msg.Response.Embed = t.Embed()
 			return nil
 
-		case QBump:
-			// check args
-			if len(msg.Args) < 2 {
-				msg.Response.Embed = discord.NewError("Too Few Args", "Bump requires a name argument:\n`&dq bump [name]`").Embed()
-				return nil
+		case QDone:
+			if err := args.Parse("&dq done", msg.Args[1:], &struct{}{}); err != nil {
+				return embedError(msg, err)
 			}
 
-			// call bump
-			if err := q.Bump(msg.Args[1]); err != nil {
-				if e, ok := err.(discord.Error); ok {
-					msg.Response.Embed = e.Embed()
-					return nil
-				} else {
-					return err
-				}
+			// the topic at the front is the one &dq next shows
+			t, err := q.FinishTopic(msg)
+			if err != nil {
+				return embedError(msg, err)
 			}
 
+			msg.Response.Text = fmt.Sprintf("Finished topic %q.", t.Name)
+			if next, err := q.Next(); err == nil {
+				msg.Response.Text += fmt.Sprintf(" Next up: %q.", next.Name)
+			}
 			return nil
 
-		case QSkip:
-			// check args
-			if len(msg.Args) < 2 {
-				msg.Response.Embed = discord.NewError("Too Few Args", "Skip requries a name argument:\n`&dq skip [name]`").Embed()
-				return nil
+		case QBump:
+			var a nameArgs
+			if err := args.Parse("&dq bump", msg.Args[1:], &a); err != nil {
+				return embedError(msg, err)
 			}
 
-			// call skip
-			if err := q.Skip(msg.Args[1]); err != nil {
-				if e, ok := err.(discord.Error); ok {
-					msg.Response.Embed = e.Embed()
-					return nil
-				} else {
-					return err
-				}
+			if err := q.BumpTopic(msg, a.Name); err != nil {
+				return embedError(msg, err)
+			}
+			return nil
+
+		case QSkip:
+			var a nameArgs
+			if err := args.Parse("&dq skip", msg.Args[1:], &a); err != nil {
+				return embedError(msg, err)
 			}
 
+			if err := q.SkipTopic(msg, a.Name); err != nil {
+				return embedError(msg, err)
+			}
 			return nil
 
 		case QAttach:
-			// check args
-			if len(msg.Args) < 3 {
-				msg.Response.Embed = discord.NewError("Too Few Args", "Attach requires two additional arguments:\n`&dq attach [name] [url]`").Embed()
-				return nil
+			var a struct {
+				Name string   `arg:"name"`
+				URL  *url.URL `arg:"url"`
 			}
-
-			// call attach
-			if err := q.Attach(msg.Args[1], msg.Args[2]); err != nil {
-				if e, ok := err.(discord.Error); ok {
-					msg.Response.Embed = e.Embed()
-					return nil
-				} else {
-					return err
-				}
+			if err := args.Parse("&dq attach", msg.Args[1:], &a); err != nil {
+				return embedError(msg, err)
 			}
 
+			if err := q.AttachSource(msg, a.Name, a.URL.String()); err != nil {
+				return embedError(msg, err)
+			}
 			return nil
 
 		case QDetach:
-			// check args
-			if len(msg.Args) < 3 {
-				msg.Response.Embed = discord.NewError("Too Few Args", "Detach requires two arguments:\n`&dq detach [name] [number]`"+
-					"\nWhere name is the topic name, and number is the index of the source url to remove.").Embed()
-				return nil
+			// number is the index of the source url to remove
+			var a struct {
+				Name   string `arg:"name"`
+				Number int    `arg:"number,min=0"`
 			}
-
-			// convert the number arg to int
-			num, err := strconv.Atoi(msg.Args[2])
-			if err != nil {
-				msg.Response.Embed = discord.NewError("String to Integer Conversion Error", err.Error()).Embed()
-				return nil
+			if err := args.Parse("&dq detach", msg.Args[1:], &a); err != nil {
+				return embedError(msg, err)
 			}
 
-			// call detach
-			if err := q.Detach(msg.Args[1], num); err != nil {
-				if e, ok := err.(discord.Error); ok {
-					msg.Response.Embed = e.Embed()
-					return nil
-				} else {
-					return err
-				}
+			if err := q.DetachSource(msg, a.Name, a.Number); err != nil {
+				return embedError(msg, err)
 			}
-
 			return nil
 
 		case QList:
-			// call list
-			l := q.List()
-
-			// create the return embed
-			e := discord.NewEmbed().
-				EmbedColor(4289797).
-				EmbedTitle("Topics").
-				EmbedTimestamp(q.Modified)
-
-			// add items
-			for _, top := range l {
-				e = e.AddField(top.Name, top.Description, false)
-			}
-
-			msg.Response.Embed = e.MessageEmbed
+			// listing again updates the last list in the channel instead of posting another
+			msg.Response.AddEmbedUpdate(ListKey, listEmbed(q, 1))
 			return nil
 
 		case QError:
@@ -282,3 +259,63 @@ func Interceptor(q *Queue) gb.Interceptor {
 		return errors.New("reached end of function without returning from switch")
 	}
 }
+
+// Build a page of the list of topics, counting from 1.  Lists with more than one page say how to turn it.
+func listEmbed(q *Queue, page int) *discordgo.MessageEmbed {
+	e := discord.NewEmbed().
+		EmbedColor(gb.DiscCol).
+		EmbedTitle(listTitle).
+		EmbedTimestamp(q.Modified)
+
+	l := q.List()
+	start, end := (page-1)*PageSize, page*PageSize
+	if end > len(l) {
+		end = len(l)
+	}
+	if start > end {
+		start = end
+	}
+	for _, top := range l[start:end] {
+		name := top.Name
+		if len(top.Votes) > 0 {
+			name = fmt.Sprintf("%s (%s)", name, votes(len(top.Votes)))
+		}
+		e = e.AddField(name, top.Description, false)
+	}
+
+	if n := pages(len(l)); n > 1 {
+		e = e.EmbedFooter(fmt.Sprintf(pageFooter+" · React %s or %s to turn the page", page, n, Previous, Next), "", "")
+	}
+	return e.MessageEmbed
+}
+
+// the number of pages the list of n topics takes
+func pages(n int) int {
+	return (n + PageSize - 1) / PageSize
+}
+
+func votes(n int) string {
+	if n == 1 {
+		return "1 vote"
+	}
+	return fmt.Sprintf("%d votes", n)
+}
+
+// a place in the queue as people count, for the audit log
+func position(i int) string {
+	return fmt.Sprintf("position %d", i+1)
+}
+
+// Arguments of the subcommands that only take a topic name
+type nameArgs struct {
+	Name string `arg:"name"`
+}
+
+// show discord errors to the user, pass anything else up
+func embedError(msg *gb.Message, err error) error {
+	if e, ok := err.(discord.Error); ok {
+		msg.Response.SetError(e.Embed())
+		return nil
+	}
+	return err
+}
@@ -3,6 +3,7 @@ package discussion
 import (
 	gb "github.com/ericebersohl/gobottas"
 	"github.com/ericebersohl/gobottas/discord"
+	"github.com/ericebersohl/gobottas/event"
 	"github.com/ericebersohl/gobottas/mock"
 	"testing"
 )
@@ -12,71 +13,78 @@ Test Cases:
 - Not a Queue Message
 - Nil Queue
 - Bad Command
-- Add: too few args, name only, name and description, duplicate
-- Remove: too few args, not found (dErr)
-- Next: empty queue (dErr), normal
+- Add: too few args, too many args, name only, name and description, duplicate
+- Remove: too few args, not found (dErr), rm alias
+- Next: empty queue in another guild (dErr), normal
+- Done: too many args, empty queue in another guild (dErr), normal
 - Bump: too few args, not found (dErr), normal
 - Skip: too few args, not found (dErr), normal
-- Attach: too few args, not found (dErr), normal
+- Attach: too few args, bad url, not found (dErr), normal
 - Detach: too few args, bad Atoi, Index Oob (dErr), normal
 */
 func TestInterceptor(t *testing.T) {
-	q := NewQueue()
-	eq := NewQueue()
+	qs := NewQueues("discussion_test")
 
 	tests := []struct {
 		name        string
-		queue       *Queue
+		queues      *Queues
 		in          *gb.Message
 		wantErr     bool
 		wantDiscErr bool
 		wantEmbed   bool
 	}{
 		// Error cases
-		{name: "not-queue-command", queue: q, in: mock.NewMessage(gb.None), wantErr: false, wantDiscErr: false, wantEmbed: false},
-		{name: "nil-queue", queue: nil, in: mock.NewMessage(gb.Queue), wantErr: true, wantDiscErr: false, wantEmbed: false},
-		{name: "bad-command", queue: q, in: mock.NewMessage(gb.Queue, mock.WithArgs("not", "valid", "args")), wantErr: false, wantDiscErr: false, wantEmbed: true},
+		{name: "not-queue-command", queues: qs, in: mock.NewMessage(gb.None), wantErr: false, wantDiscErr: false, wantEmbed: false},
+		{name: "nil-queue", queues: nil, in: mock.NewMessage(gb.Queue), wantErr: true, wantDiscErr: false, wantEmbed: false},
+		{name: "bad-command", queues: qs, in: mock.NewMessage(gb.Queue, mock.WithArgs("not", "valid", "args")), wantErr: false, wantDiscErr: false, wantEmbed: true},
 
 		// Add
-		{name: "add-too-few", queue: q, in: mock.NewMessage(gb.Queue, mock.WithArgs("add")), wantErr: true, wantDiscErr: true, wantEmbed: true},
-		{name: "add-name-only", queue: q, in: mock.NewMessage(gb.Queue, mock.WithArgs("add", "testName")), wantErr: false, wantDiscErr: false, wantEmbed: false},
-		{name: "add-both", queue: q, in: mock.NewMessage(gb.Queue, mock.WithArgs("add", "testName2", "testDesc")), wantErr: false, wantDiscErr: false, wantEmbed: false},
-		{name: "add-dup", queue: q, in: mock.NewMessage(gb.Queue, mock.WithArgs("add", "testName2")), wantErr: true, wantDiscErr: true, wantEmbed: true},
+		{name: "add-too-few", queues: qs, in: mock.NewMessage(gb.Queue, mock.WithArgs("add")), wantErr: true, wantDiscErr: true, wantEmbed: true},
+		{name: "add-too-many", queues: qs, in: mock.NewMessage(gb.Queue, mock.WithArgs("add", "unquoted", "topic", "name")), wantErr: false, wantDiscErr: false, wantEmbed: true},
+		{name: "add-name-only", queues: qs, in: mock.NewMessage(gb.Queue, mock.WithArgs("add", "testName")), wantErr: false, wantDiscErr: false, wantEmbed: false},
+		{name: "add-both", queues: qs, in: mock.NewMessage(gb.Queue, mock.WithArgs("add", "testName2", "testDesc")), wantErr: false, wantDiscErr: false, wantEmbed: false},
+		{name: "add-dup", queues: qs, in: mock.NewMessage(gb.Queue, mock.WithArgs("add", "testName2")), wantErr: true, wantDiscErr: true, wantEmbed: true},
 
 		// Remove
-		{name: "rem-too-few", queue: q, in: mock.NewMessage(gb.Queue, mock.WithArgs("remove")), wantErr: true, wantDiscErr: true, wantEmbed: true},
-		{name: "rem-not-found", queue: q, in: mock.NewMessage(gb.Queue, mock.WithArgs("remove", "not-topic")), wantErr: true, wantDiscErr: true, wantEmbed: true},
-		{name: "rem-normal", queue: q, in: mock.NewMessage(gb.Queue, mock.WithArgs("remove", "testName")), wantErr: false, wantDiscErr: false, wantEmbed: false},
+		{name: "rem-too-few", queues: qs, in: mock.NewMessage(gb.Queue, mock.WithArgs("remove")), wantErr: true, wantDiscErr: true, wantEmbed: true},
+		{name: "rem-not-found", queues: qs, in: mock.NewMessage(gb.Queue, mock.WithArgs("remove", "not-topic")), wantErr: true, wantDiscErr: true, wantEmbed: true},
+		{name: "rem-alias-not-found", queues: qs, in: mock.NewMessage(gb.Queue, mock.WithArgs("RM", "not-topic")), wantErr: false, wantDiscErr: false, wantEmbed: true},
+		{name: "rem-normal", queues: qs, in: mock.NewMessage(gb.Queue, mock.WithArgs("remove", "testName")), wantErr: false, wantDiscErr: false, wantEmbed: false},
 
 		// Next
-		{name: "next-normal", queue: q, in: mock.NewMessage(gb.Queue, mock.WithArgs("next")), wantErr: false, wantDiscErr: false, wantEmbed: true},
-		{name: "next-empty", queue: eq, in: mock.NewMessage(gb.Queue, mock.WithArgs("next")), wantErr: true, wantDiscErr: true, wantEmbed: true},
+		{name: "next-normal", queues: qs, in: mock.NewMessage(gb.Queue, mock.WithArgs("next")), wantErr: false, wantDiscErr: false, wantEmbed: true},
+		{name: "next-empty", queues: qs, in: mock.NewMessage(gb.Queue, mock.WithGuild(1), mock.WithArgs("next")), wantErr: true, wantDiscErr: true, wantEmbed: true},
+
+		// Done
+		{name: "done-too-many", queues: qs, in: mock.NewMessage(gb.Queue, mock.WithArgs("done", "extra")), wantErr: false, wantDiscErr: false, wantEmbed: true},
+		{name: "done-empty", queues: qs, in: mock.NewMessage(gb.Queue, mock.WithGuild(1), mock.WithArgs("done")), wantErr: true, wantDiscErr: true, wantEmbed: true},
 
 		// Bump
-		{name: "bump-too-few", queue: q, in: mock.NewMessage(gb.Queue, mock.WithArgs("bump")), wantErr: true, wantDiscErr: true, wantEmbed: true},
-		{name: "bump-not-found", queue: q, in: mock.NewMessage(gb.Queue, mock.WithArgs("bump", "not-found")), wantErr: true, wantDiscErr: true, wantEmbed: true},
-		{name: "bump-normal", queue: q, in: mock.NewMessage(gb.Queue, mock.WithArgs("bump", "testName2")), wantErr: false, wantDiscErr: false, wantEmbed: false},
+		{name: "bump-too-few", queues: qs, in: mock.NewMessage(gb.Queue, mock.WithArgs("bump")), wantErr: true, wantDiscErr: true, wantEmbed: true},
+		{name: "bump-not-found", queues: qs, in: mock.NewMessage(gb.Queue, mock.WithArgs("bump", "not-found")), wantErr: true, wantDiscErr: true, wantEmbed: true},
+		{name: "bump-normal", queues: qs, in: mock.NewMessage(gb.Queue, mock.WithArgs("bump", "testName2")), wantErr: false, wantDiscErr: false, wantEmbed: false},
 
 		// Skip
-		{name: "skip-too-few", queue: q, in: mock.NewMessage(gb.Queue, mock.WithArgs("skip")), wantErr: true, wantDiscErr: true, wantEmbed: true},
-		{name: "skip-not-found", queue: q, in: mock.NewMessage(gb.Queue, mock.WithArgs("skip", "not-found")), wantErr: true, wantDiscErr: true, wantEmbed: true},
-		{name: "skip-normal", queue: q, in: mock.NewMessage(gb.Queue, mock.WithArgs("skip", "testName2")), wantErr: false, wantDiscErr: false, wantEmbed: false},
+		{name: "skip-too-few", queues: qs, in: mock.NewMessage(gb.Queue, mock.WithArgs("skip")), wantErr: true, wantDiscErr: true, wantEmbed: true},
+		{name: "skip-not-found", queues: qs, in: mock.NewMessage(gb.Queue, mock.WithArgs("skip", "not-found")), wantErr: true, wantDiscErr: true, wantEmbed: true},
+		{name: "skip-normal", queues: qs, in: mock.NewMessage(gb.Queue, mock.WithArgs("skip", "testName2")), wantErr: false, wantDiscErr: false, wantEmbed: false},
 
 		// Attach
-		{name: "attach-too-few", queue: q, in: mock.NewMessage(gb.Queue, mock.WithArgs("attach")), wantErr: true, wantDiscErr: true, wantEmbed: true},
-		{name: "attach-not-found", queue: q, in: mock.NewMessage(gb.Queue, mock.WithArgs("attach", "not-found", "https://google.com")), wantErr: true, wantDiscErr: true, wantEmbed: true},
-		{name: "attach-normal", queue: q, in: mock.NewMessage(gb.Queue, mock.WithArgs("attach", "testName2", "https://google.com")), wantErr: true, wantDiscErr: true, wantEmbed: true},
+		{name: "attach-too-few", queues: qs, in: mock.NewMessage(gb.Queue, mock.WithArgs("attach")), wantErr: true, wantDiscErr: true, wantEmbed: true},
+		{name: "attach-bad-url", queues: qs, in: mock.NewMessage(gb.Queue, mock.WithArgs("attach", "testName2", "google.com")), wantErr: false, wantDiscErr: false, wantEmbed: true},
+		{name: "attach-not-found", queues: qs, in: mock.NewMessage(gb.Queue, mock.WithArgs("attach", "not-found", "https://google.com")), wantErr: true, wantDiscErr: true, wantEmbed: true},
+		{name: "attach-normal", queues: qs, in: mock.NewMessage(gb.Queue, mock.WithArgs("attach", "testName2", "https://google.com")), wantErr: true, wantDiscErr: true, wantEmbed: true},
 
 		// Detach
-		{name: "det-too-few", queue: q, in: mock.NewMessage(gb.Queue, mock.WithArgs("detach")), wantErr: true, wantDiscErr: true, wantEmbed: true},
-		{name: "det-bad-atoi", queue: q, in: mock.NewMessage(gb.Queue, mock.WithArgs("detach", "testName2", "zer0")), wantErr: true, wantDiscErr: true, wantEmbed: true},
-		{name: "det-oob", queue: q, in: mock.NewMessage(gb.Queue, mock.WithArgs("detach", "testName2", "5")), wantErr: true, wantDiscErr: true, wantEmbed: true},
-		{name: "det-norm", queue: q, in: mock.NewMessage(gb.Queue, mock.WithArgs("detach", "testName2", "0")), wantErr: false, wantDiscErr: false, wantEmbed: false},
+		{name: "det-too-few", queues: qs, in: mock.NewMessage(gb.Queue, mock.WithArgs("detach")), wantErr: true, wantDiscErr: true, wantEmbed: true},
+		{name: "det-bad-atoi", queues: qs, in: mock.NewMessage(gb.Queue, mock.WithArgs("detach", "testName2", "zer0")), wantErr: true, wantDiscErr: true, wantEmbed: true},
+		{name: "det-oob", queues: qs, in: mock.NewMessage(gb.Queue, mock.WithArgs("detach", "testName2", "5")), wantErr: true, wantDiscErr: true, wantEmbed: true},
+		{name: "det-norm", queues: qs, in: mock.NewMessage(gb.Queue, mock.WithArgs("detach", "testName2", "0")), wantErr: false, wantDiscErr: false, wantEmbed: false},
 	}
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			i := Interceptor(test.queue)
+			i := Interceptor(test.queues)
 			err := i(test.in)
 			if err != nil {
 				if !test.wantErr {
@@ -99,3 +107,83 @@ func TestInterceptor(t *testing.T) {
 		})
 	}
 }
+
+/*
+Test Cases:
+- the list updates the last list in the channel
+*/
+func TestInterceptor_List(t *testing.T) {
+	qs := NewQueues("discussion_test")
+	q := qs.Get(0)
+	if err := q.Add(&Topic{Name: "topic"}); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+
+	msg := mock.NewMessage(gb.Queue, mock.WithArgs("list"))
+	if err := Interceptor(qs)(msg); err != nil {
+		t.Fatalf("list: %v", err)
+	}
+
+	out := msg.Response.Outputs
+	if len(out) != 1 || out[0].Update != ListKey || out[0].Embed == nil || len(out[0].Embed.Fields) != 1 {
+		t.Errorf("list not sent as an update: %+v", out)
+	}
+}
+
+/*
+Test Cases:
+- remove asks before removing, and removes once confirmed
+*/
+func TestInterceptor_Remove(t *testing.T) {
+	qs := NewQueues("discussion_test")
+	q := qs.Get(0)
+	if err := q.Add(&Topic{Name: "topic"}); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+
+	msg := mock.NewMessage(gb.Queue, mock.WithArgs("remove", "topic"))
+	if err := Interceptor(qs)(msg); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	if msg.Confirm == nil || q.Len() != 1 {
+		t.Fatalf("didn't ask first (confirm = %+v, topics = %d)", msg.Confirm, q.Len())
+	}
+
+	reply := mock.NewMessage(gb.None)
+	if err := msg.Confirm.Action(reply); err != nil || reply.Response.Text != `Removed topic "topic".` || q.Len() != 0 {
+		t.Errorf("not removed (err = %v, response = %+v, topics = %d)", err, reply.Response, q.Len())
+	}
+}
+
+/*
+Test Cases:
+- done takes the front topic out, names the next one and publishes the completed topic
+- the last topic has nothing after it
+*/
+func TestInterceptor_Done(t *testing.T) {
+	qs := NewQueues("discussion_test")
+	q := qs.Get(0)
+	_ = q.Add(&Topic{Name: "first", Description: "about first", Sources: []string{"https://x.com"}})
+	_ = q.Add(&Topic{Name: "second"})
+
+	msg := mock.NewMessage(gb.Queue, mock.WithSource(1, 2, "user", ""), mock.WithArgs("done"))
+	if err := Interceptor(qs)(msg); err != nil {
+		t.Fatalf("done: %v", err)
+	}
+	if msg.Response.Text != `Finished topic "first". Next up: "second".` || q.Len() != 1 {
+		t.Errorf("response = %q, topics = %d", msg.Response.Text, q.Len())
+	}
+
+	if len(msg.Events) != 1 {
+		t.Fatalf("published %d events, want 1", len(msg.Events))
+	}
+	e, ok := msg.Events[0].(*event.TopicCompleted)
+	if !ok || e.Topic != "first" || e.Description != "about first" || len(e.Sources) != 1 || e.UserId != 1 || e.ChannelId != 2 {
+		t.Errorf("event = %+v", msg.Events[0])
+	}
+
+	msg = mock.NewMessage(gb.Queue, mock.WithArgs("done"))
+	if err := Interceptor(qs)(msg); err != nil || msg.Response.Text != `Finished topic "second".` || q.Len() != 0 {
+		t.Errorf("err = %v, response = %q, topics = %d", err, msg.Response.Text, q.Len())
+	}
+}
@@ -0,0 +1,178 @@
+package discussion
+
+import (
+	"encoding/json"
+	"fmt"
+	gb "github.com/ericebersohl/gobottas"
+	"github.com/ericebersohl/gobottas/discord"
+	"github.com/ericebersohl/gobottas/journal"
+	"time"
+)
+
+// Name the queue is registered with in the journal
+const JournalName = "queue"
+
+// What the queue needs to undo and redo a change
+type change struct {
+	Topic       *Topic `json:"topic,omitempty"`       // the topic added or removed, or as it was before an edit
+	Name        string `json:"name,omitempty"`        // the topic changed
+	Index       int    `json:"index"`                 // where the topic or source was before the change
+	To          int    `json:"to,omitempty"`          // where a moved topic went
+	Description string `json:"description,omitempty"` // an edited topic's new description
+	URL         string `json:"url,omitempty"`         // the source attached or detached
+
+	Finished *Finished `json:"finished,omitempty"` // how a finished topic was archived
+}
+
+// journal a change made by the author of msg; the change stands even if the journal can't be saved
+func (q *Queue) record(msg *gb.Message, op, summary string, c change) {
+	if err := q.Journal.Record(msg.Source, JournalName, op, summary, c); err != nil {
+		msg.Log.Warn("failed to journal the change", "op", op, "err", err)
+	}
+}
+
+// Undo a journaled change to the queue; the registry saves it
+func (q *Queue) Undo(e *journal.Entry) error {
+	var c change
+	if err := json.Unmarshal(e.Data, &c); err != nil {
+		return err
+	}
+
+	q.Lock()
+	defer q.Unlock()
+
+	switch e.Op {
+	case "add":
+		return q.Remove(c.Topic.Name)
+	case "remove":
+		return q.insert(c.Topic, c.Index)
+	case "done":
+		if err := q.insert(c.Topic, c.Index); err != nil {
+			return err
+		}
+		q.unarchive(c.Topic.Name)
+		return nil
+	case "bump", "skip", "move":
+		return q.move(c.Name, c.Index)
+	case "edit":
+		return q.Edit(c.Name, c.Topic.Name, c.Topic.Description)
+	case "attach":
+		t, err := q.Find(c.Name)
+		if err != nil {
+			return err
+		}
+		for i := len(t.Sources) - 1; i >= 0; i-- {
+			if t.Sources[i] == c.URL {
+				return q.Detach(c.Name, i)
+			}
+		}
+		return discord.NewError("Source Not Found", "That source is no longer attached to the topic.")
+	case "detach":
+		t, err := q.Find(c.Name)
+		if err != nil {
+			return err
+		}
+		i := clamp(c.Index, len(t.Sources))
+		t.Sources = append(t.Sources[:i], append([]string{c.URL}, t.Sources[i:]...)...)
+		t.Modified = time.Now()
+		q.Modified = time.Now()
+		return nil
+	}
+
+	return fmt.Errorf("queue: cannot undo %q", e.Op)
+}
+
+// Redo a journaled change to the queue that was undone; the registry saves it
+func (q *Queue) Redo(e *journal.Entry) error {
+	var c change
+	if err := json.Unmarshal(e.Data, &c); err != nil {
+		return err
+	}
+
+	q.Lock()
+	defer q.Unlock()
+
+	switch e.Op {
+	case "add":
+		return q.Add(c.Topic)
+	case "remove":
+		return q.Remove(c.Topic.Name)
+	case "done":
+		if err := q.Remove(c.Topic.Name); err != nil {
+			return err
+		}
+		if c.Finished != nil {
+			q.archive(c.Finished)
+		}
+		return nil
+	case "bump":
+		return q.Bump(c.Name)
+	case "skip":
+		return q.Skip(c.Name)
+	case "move":
+		return q.move(c.Name, c.To)
+	case "edit":
+		return q.Edit(c.Topic.Name, c.Name, c.Description)
+	case "attach":
+		return q.Attach(c.Name, c.URL)
+	case "detach":
+		t, err := q.Find(c.Name)
+		if err != nil {
+			return err
+		}
+		for i, s := range t.Sources {
+			if s == c.URL {
+				return q.Detach(c.Name, i)
+			}
+		}
+		return discord.NewError("Source Not Found", "That source is no longer attached to the topic.")
+	}
+
+	return fmt.Errorf("queue: cannot redo %q", e.Op)
+}
+
+// put a topic back at index i, or at the end if the queue has shrunk since
+func (q *Queue) insert(t *Topic, i int) error {
+	if _, err := q.Find(t.Name); err == nil {
+		return discord.NewError("Duplicate Topic", "A topic with that name already exists.")
+	}
+
+	i = clamp(i, len(q.Q))
+	q.Q = append(q.Q[:i], append([]*Topic{t}, q.Q[i:]...)...)
+	q.Modified = time.Now()
+	return nil
+}
+
+// move the named topic to index i
+func (q *Queue) move(name string, i int) error {
+	t, err := q.Find(name)
+	if err != nil {
+		return err
+	}
+
+	if err := q.Remove(name); err != nil {
+		return err
+	}
+	return q.insert(t, i)
+}
+
+// the index where the named topic is, or -1
+func (q *Queue) index(name string) int {
+	for i, t := range q.Q {
+		if t.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// keep i within [0, n]
+func clamp(i, n int) int {
+	if i < 0 {
+		return 0
+	}
+	if i > n {
+		return n
+	}
+	return i
+}
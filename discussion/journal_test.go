@@ -0,0 +1,163 @@
+package discussion
+
+import (
+	gb "github.com/ericebersohl/gobottas"
+	"github.com/ericebersohl/gobottas/journal"
+	"github.com/ericebersohl/gobottas/mock"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+/*
+Test Cases:
+- every change to the queue is journaled and noted for the audit log
+- undoing the changes newest first takes the queue back through each state, to empty
+- redoing them in order brings it back
+*/
+func TestQueue_UndoRedo(t *testing.T) {
+	dir, err := ioutil.TempDir("", "queue_journal")
+	if err != nil {
+		t.Fatalf("temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	j := journal.New(dir)
+	qs := NewQueues(dir, WithJournal(j))
+	q := qs.Get(0)
+	j.Register(JournalName, qs)
+	i := Interceptor(qs)
+
+	// the state of the queue: topic names in order, each topic's sources, and the archive
+	state := func() string {
+		var topics, archived []string
+		for _, t := range q.List() {
+			topics = append(topics, t.Name+strings.Join(append([]string{""}, t.Sources...), "+"))
+		}
+		for _, f := range q.Archive {
+			archived = append(archived, f.Topic.Name)
+		}
+		return strings.Join(topics, ",") + "|" + strings.Join(archived, ",")
+	}
+
+	steps := [][]string{
+		{"add", "a"},
+		{"add", "b"},
+		{"add", "c"},
+		{"bump", "c"},
+		{"skip", "c"},
+		{"attach", "a", "https://x.com"},
+		{"attach", "a", "https://y.com"},
+		{"detach", "a", "0"},
+		{"remove", "b"},
+		{"done"},
+	}
+
+	states := []string{state()}
+	for _, step := range steps {
+		msg := mock.NewMessage(gb.Queue, mock.WithSource(1, 1, "user", ""), mock.WithArgs(step...))
+		if err := i(msg); err != nil || msg.Response.Embed != nil {
+			t.Fatalf("%v: err = %v, response = %+v", step, err, msg.Response)
+		}
+		if msg.Confirm != nil {
+			if err := msg.Confirm.Action(msg); err != nil {
+				t.Fatalf("%v: %v", step, err)
+			}
+		}
+		if len(msg.Changes) != 1 {
+			t.Errorf("%v: noted %d changes for the audit log, want 1", step, len(msg.Changes))
+		}
+		states = append(states, state())
+	}
+
+	if want := "c|a"; states[len(states)-1] != want {
+		t.Fatalf("queue = %q, want %q", states[len(states)-1], want)
+	}
+	if len(j.Entries) != len(steps) {
+		t.Fatalf("journaled %d changes, want %d", len(j.Entries), len(steps))
+	}
+
+	user := &gb.Source{AuthorId: 1}
+	for n := len(steps) - 1; n >= 0; n-- {
+		if _, err := j.Undo(user, 0); err != nil {
+			t.Fatalf("undo %v: %v", steps[n], err)
+		}
+		if got := state(); got != states[n] {
+			t.Errorf("after undoing %v, queue = %q, want %q", steps[n], got, states[n])
+		}
+	}
+
+	for n := range steps {
+		if _, err := j.Redo(user, 0); err != nil {
+			t.Fatalf("redo %v: %v", steps[n], err)
+		}
+		if got := state(); got != states[n+1] {
+			t.Errorf("after redoing %v, queue = %q, want %q", steps[n], got, states[n+1])
+		}
+	}
+
+	if got := j.Recent(0, 1); got[0].Summary != `finished topic "a"` {
+		t.Errorf("last change = %q", got[0].Summary)
+	}
+}
+
+/*
+Test Cases:
+- edits and moves, which only the admin API makes, are undone and redone
+*/
+func TestQueue_UndoRedoEdits(t *testing.T) {
+	dir, err := ioutil.TempDir("", "queue_journal_edits")
+	if err != nil {
+		t.Fatalf("temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	j := journal.New(dir)
+	q := NewQueue()
+	q.Journal = j
+	j.Register(JournalName, q)
+
+	state := func() string {
+		var topics []string
+		for _, t := range q.List() {
+			topics = append(topics, t.Name+":"+t.Description)
+		}
+		return strings.Join(topics, ",")
+	}
+
+	msg := mock.NewMessage(gb.Queue, mock.WithSource(1, 1, "user", ""))
+	_, _ = q.AddTopic(msg, "a", "about a")
+	_, _ = q.AddTopic(msg, "b", "")
+	before := state()
+
+	if err := q.EditTopic(msg, "a", "c", "about c"); err != nil {
+		t.Fatalf("edit: %v", err)
+	}
+	if err := q.MoveTopic(msg, "c", 1); err != nil {
+		t.Fatalf("move: %v", err)
+	}
+	after := state()
+	if after != "b:,c:about c" {
+		t.Fatalf("queue = %q", after)
+	}
+
+	user := &gb.Source{AuthorId: 1}
+	for i := 0; i < 2; i++ {
+		if _, err := j.Undo(user, 0); err != nil {
+			t.Fatalf("undo: %v", err)
+		}
+	}
+	if got := state(); got != before {
+		t.Errorf("after undoing, queue = %q, want %q", got, before)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := j.Redo(user, 0); err != nil {
+			t.Fatalf("redo: %v", err)
+		}
+	}
+	if got := state(); got != after {
+		t.Errorf("after redoing, queue = %q, want %q", got, after)
+	}
+}
@@ -0,0 +1,161 @@
+package discussion
+
+import (
+	"fmt"
+	gb "github.com/ericebersohl/gobottas"
+	"github.com/ericebersohl/gobottas/args"
+	"github.com/ericebersohl/gobottas/discord"
+	"github.com/ericebersohl/gobottas/event"
+	"time"
+)
+
+// Changes to the queue on behalf of a message's author, whether they come from a chat command or the admin API.  Each
+// one checks its input, journals the change, notes it on the message for the audit log and publishes its event.  The
+// caller holds the queue's lock, and the registry saves the queue once the message is executed.
+
+// Add a topic proposed by the author
+func (q *Queue) AddTopic(msg *gb.Message, name, description string) (*Topic, error) {
+	t := Topic{
+		Name:        name,
+		Description: description,
+		Sources:     nil,
+		Modified:    time.Now(),
+		Created:     time.Now(),
+		CreatedBy:   msg.Source.Username,
+	}
+
+	if err := q.Add(&t); err != nil {
+		return nil, err
+	}
+
+	q.record(msg, "add", fmt.Sprintf("added topic %q", t.Name), change{Topic: &t})
+	msg.AddChange("add topic", t.Name, "", t.Description)
+	msg.Publish(&event.TopicAdded{Base: event.From(msg), Topic: t.Name, Description: t.Description})
+	return &t, nil
+}
+
+// Take a topic out of the queue without discussing it
+func (q *Queue) RemoveTopic(msg *gb.Message, name string) error {
+	t, err := q.Find(name)
+	if err != nil {
+		return err
+	}
+
+	i := q.index(name)
+	if err := q.Remove(name); err != nil {
+		return err
+	}
+
+	q.record(msg, "remove", fmt.Sprintf("removed topic %q", name), change{Topic: t, Index: i})
+	msg.AddChange("remove topic", name, position(i), "")
+	msg.Publish(&event.TopicRemoved{Base: event.From(msg), Topic: name})
+	return nil
+}
+
+// Take the topic at the front out of the queue once it has been discussed, and keep it in the archive
+func (q *Queue) FinishTopic(msg *gb.Message) (*Topic, error) {
+	t, err := q.Done()
+	if err != nil {
+		return nil, err
+	}
+
+	f := &Finished{Topic: t, Finished: time.Now(), By: msg.Source.Username}
+	q.archive(f)
+
+	q.record(msg, "done", fmt.Sprintf("finished topic %q", t.Name), change{Topic: t, Finished: f})
+	msg.AddChange("complete topic", t.Name, position(0), "")
+	msg.Publish(&event.TopicCompleted{Base: event.From(msg), Topic: t.Name, Description: t.Description, Sources: t.Sources})
+	return t, nil
+}
+
+// Move a topic to the front of the queue
+func (q *Queue) BumpTopic(msg *gb.Message, name string) error {
+	i := q.index(name)
+	if err := q.Bump(name); err != nil {
+		return err
+	}
+
+	q.record(msg, "bump", fmt.Sprintf("bumped topic %q", name), change{Name: name, Index: i})
+	msg.AddChange("bump topic", name, position(i), position(0))
+	return nil
+}
+
+// Move a topic to the back of the queue
+func (q *Queue) SkipTopic(msg *gb.Message, name string) error {
+	i := q.index(name)
+	if err := q.Skip(name); err != nil {
+		return err
+	}
+
+	q.record(msg, "skip", fmt.Sprintf("skipped topic %q", name), change{Name: name, Index: i})
+	msg.AddChange("skip topic", name, position(i), position(q.Len()-1))
+	return nil
+}
+
+// Move a topic to index to, counting from the front
+func (q *Queue) MoveTopic(msg *gb.Message, name string, to int) error {
+	i := q.index(name)
+	if err := q.Move(name, to); err != nil {
+		return err
+	}
+
+	q.record(msg, "move", fmt.Sprintf("moved topic %q", name), change{Name: name, Index: i, To: to})
+	msg.AddChange("move topic", name, position(i), position(to))
+	return nil
+}
+
+// Rename a topic and change its description
+func (q *Queue) EditTopic(msg *gb.Message, name, newName, description string) error {
+	t, err := q.Find(name)
+	if err != nil {
+		return err
+	}
+	before := *t
+
+	if err := q.Edit(name, newName, description); err != nil {
+		return err
+	}
+
+	q.record(msg, "edit", fmt.Sprintf("edited topic %q", newName), change{Topic: &before, Name: newName, Description: description})
+	if newName != name {
+		msg.AddChange("rename topic", name, name, newName)
+	}
+	if description != before.Description {
+		msg.AddChange("describe topic", newName, before.Description, description)
+	}
+	return nil
+}
+
+// Attach a link to a topic
+func (q *Queue) AttachSource(msg *gb.Message, name, source string) error {
+	u, ok := args.ParseURL(source)
+	if !ok {
+		return discord.NewError("Invalid Source", "Sources must be links starting with http:// or https://.")
+	}
+	source = u.String()
+
+	if err := q.Attach(name, source); err != nil {
+		return err
+	}
+
+	q.record(msg, "attach", fmt.Sprintf("attached %s to topic %q", source, name), change{Name: name, URL: source})
+	msg.AddChange("attach source", name, "", source)
+	return nil
+}
+
+// Detach the link at index i from a topic
+func (q *Queue) DetachSource(msg *gb.Message, name string, i int) error {
+	// remember the source so it can be put back
+	var source string
+	if t, err := q.Find(name); err == nil && i >= 0 && i < len(t.Sources) {
+		source = t.Sources[i]
+	}
+
+	if err := q.Detach(name, i); err != nil {
+		return err
+	}
+
+	q.record(msg, "detach", fmt.Sprintf("detached %s from topic %q", source, name), change{Name: name, Index: i, URL: source})
+	msg.AddChange("detach source", name, source, "")
+	return nil
+}
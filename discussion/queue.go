@@ -3,16 +3,35 @@ package discussion
 import (
 	"encoding/json"
 	"fmt"
+	gb "github.com/ericebersohl/gobottas"
 	"github.com/ericebersohl/gobottas/discord"
+	"github.com/ericebersohl/gobottas/journal"
+	"github.com/ericebersohl/gobottas/metrics"
 	"io/ioutil"
-	"log"
+	"sync"
 	"time"
 )
 
+// Most finished topics the archive keeps; older ones are dropped
+const MaxArchive = 100
+
 // Slice for simplicity, no need to make it a heap-based PQ
 type Queue struct {
-	Q        []*Topic  `json:"q"`        // hide the internal list from the user
-	Modified time.Time `json:"modified"` // time last modified
+	Q        []*Topic    `json:"q"`                 // hide the internal list from the user
+	Modified time.Time   `json:"modified"`          // time last modified
+	Archive  []*Finished `json:"archive,omitempty"` // topics that were discussed, oldest first
+
+	Journal *journal.Journal `json:"-"` // records changes so they can be undone; nil records nothing
+
+	// chat commands and the admin API run on different goroutines
+	mu sync.Mutex
+}
+
+// A topic that was discussed, as the archive keeps it
+type Finished struct {
+	Topic    *Topic    `json:"topic"`
+	Finished time.Time `json:"finished"`
+	By       string    `json:"by"` // username of whoever finished it
 }
 
 // Create a new Queue, initializes the underlying slice and updates Modified
@@ -24,6 +43,15 @@ func NewQueue() *Queue {
 	return &q
 }
 
+// Lock the queue; anything that shares the queue between goroutines must hold the lock while using it
+func (q *Queue) Lock() {
+	q.mu.Lock()
+}
+
+func (q *Queue) Unlock() {
+	q.mu.Unlock()
+}
+
 // Get the number of topics in the queue
 func (q *Queue) Len() int {
 	return len(q.Q)
@@ -99,6 +127,83 @@ func (q *Queue) Remove(s string) error {
 	return nil
 }
 
+// Take the first topic out of the queue, once it has been discussed
+func (q *Queue) Done() (*Topic, error) {
+	if len(q.Q) == 0 {
+		return nil, discord.NewError("Empty Queue", "Cannot finish a topic when the queue is empty.")
+	}
+
+	t := q.Q[0]
+	q.Q = q.Q[1:]
+	q.Modified = time.Now()
+	return t, nil
+}
+
+// Rename a topic and change its description
+func (q *Queue) Edit(name, newName, description string) error {
+	t, err := q.Find(name)
+	if err != nil {
+		return err
+	}
+
+	if newName == "" {
+		return discord.NewError("Empty Topic Name", "Cannot give a topic no name.")
+	}
+
+	if newName != name {
+		if _, err := q.Find(newName); err == nil {
+			return discord.NewError("Duplicate Topic", "A topic with that name already exists.")
+		}
+	}
+
+	t.Name = newName
+	t.Description = description
+	t.Modified = time.Now()
+	q.Modified = time.Now()
+	return nil
+}
+
+// Move the named topic to index i, counting from the front
+func (q *Queue) Move(name string, i int) error {
+	if _, err := q.Find(name); err != nil {
+		return err
+	}
+
+	if i < 0 || i >= len(q.Q) {
+		return discord.NewError("Index Out of Range", "You specified a position that is out of the range of topics.")
+	}
+
+	return q.move(name, i)
+}
+
+// Keep a finished topic in the archive, dropping the oldest past MaxArchive
+func (q *Queue) archive(f *Finished) {
+	q.Archive = append(q.Archive, f)
+	if n := len(q.Archive) - MaxArchive; n > 0 {
+		q.Archive = q.Archive[n:]
+	}
+}
+
+// Take the latest finished topic of that name out of the archive, if it's there
+func (q *Queue) unarchive(name string) {
+	for i := len(q.Archive) - 1; i >= 0; i-- {
+		if q.Archive[i].Topic.Name == name {
+			q.Archive = append(q.Archive[:i], q.Archive[i+1:]...)
+			return
+		}
+	}
+}
+
+// Returns the Topic of the specified name
+func (q *Queue) Find(s string) (*Topic, error) {
+	for _, t := range q.Q {
+		if t.Name == s {
+			return t, nil
+		}
+	}
+	return nil, discord.NewError("Topic Not Found", "Could not find a topic with that name.")
+}
+
 // Moves the Topic of the specified name to the front of the Queue
 func (q *Queue) Bump(s string) error {
 
@@ -174,6 +279,23 @@ func (q *Queue) Attach(n, s string) error {
 	return nil
 }
 
+// Count a user's vote for the named topic, reporting whether it's new; each user votes for a topic once
+func (q *Queue) Vote(n string, user gb.Snowflake) (bool, error) {
+	t, err := q.Find(n)
+	if err != nil {
+		return false, err
+	}
+
+	for _, v := range t.Votes {
+		if v == user {
+			return false, nil
+		}
+	}
+	t.Votes = append(t.Votes, user)
+	q.Modified = time.Now()
+	return true, nil
+}
+
 // remove a source (by index) from the specified topic
 func (q *Queue) Detach(n string, i int) error {
 	found := false
@@ -183,7 +305,6 @@ func (q *Queue) Detach(n string, i int) error {
 			if len(t.Sources) <= i || i < 0 {
 				return discord.NewError("Index Out of Range", "You specified a number that is out of the range of sources.")
 			}
-			fmt.Println(t.Sources)
 			t.Sources = append(t.Sources[:i], t.Sources[i+1:]...)
 			t.Modified = time.Now()
 			found = true
@@ -204,14 +325,14 @@ func (q *Queue) Save(path string) error {
 	// get []byte
 	data, err := json.Marshal(q)
 	if err != nil {
-		log.Printf("Save error: %v", err)
+		metrics.PersistenceErrors.Inc("queue", "save")
 		return err
 	}
 
 	// write to file
 	err = ioutil.WriteFile(fmt.Sprintf("%s/queue.json", path), data, 0644)
 	if err != nil {
-		log.Printf("WriteFile: %v", err)
+		metrics.PersistenceErrors.Inc("queue", "save")
 		return err
 	}
 
@@ -224,14 +345,14 @@ func (q *Queue) Load(path string) error {
 	// get data
 	data, err := ioutil.ReadFile(fmt.Sprintf("%s/queue.json", path))
 	if err != nil {
-		log.Printf("Load: %v", err)
+		metrics.PersistenceErrors.Inc("queue", "load")
 		return err
 	}
 
 	// unmarshal data
 	err = json.Unmarshal(data, q)
 	if err != nil {
-		log.Printf("Load Unmarshal error: %v", err)
+		metrics.PersistenceErrors.Inc("queue", "load")
 		return err
 	}
 
@@ -1,7 +1,9 @@
 package discussion
 
 import (
+	"fmt"
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
 	"os"
 	"testing"
 	"time"
@@ -347,6 +349,12 @@ func TestQueue_SaveLoad(t *testing.T) {
 		t.FailNow()
 	}
 
+	testQ.archive(&Finished{
+		Topic:    &Topic{Name: "oldName", Created: time.Date(1998, 0, 0, 0, 0, 0, 0, time.UTC)},
+		Finished: time.Date(1999, 0, 0, 0, 0, 0, 0, time.UTC),
+		By:       "Tester",
+	})
+
 	err = testQ.Save("./test")
 	if err != nil {
 		t.Errorf("Error on save: %v", err)
@@ -358,8 +366,9 @@ func TestQueue_SaveLoad(t *testing.T) {
 		t.Errorf("Error on load: %v", err)
 	}
 
-	if !cmp.Equal(testQ, newQ) {
-		t.Errorf("testQ != newQ:\n%s", cmp.Diff(testQ, newQ))
+	opt := cmpopts.IgnoreUnexported(Queue{})
+	if !cmp.Equal(testQ, newQ, opt) {
+		t.Errorf("testQ != newQ:\n%s", cmp.Diff(testQ, newQ, opt))
 	}
 
 	// delete dir and all files
@@ -368,3 +377,61 @@ func TestQueue_SaveLoad(t *testing.T) {
 		t.FailNow()
 	}
 }
+
+/*
+Test Cases:
+- archiving past MaxArchive drops the oldest topics
+- unarchiving takes out the latest topic of that name
+- unarchiving a name that isn't there changes nothing
+*/
+func TestQueue_Archive(t *testing.T) {
+	q := NewQueue()
+	for i := 0; i < MaxArchive+2; i++ {
+		q.archive(&Finished{Topic: &Topic{Name: fmt.Sprint(i % 3)}, By: fmt.Sprint(i)})
+	}
+
+	// the archive's By fields, oldest first
+	archived := func() []string {
+		var out []string
+		for _, f := range q.Archive {
+			out = append(out, f.By)
+		}
+		return out
+	}
+
+	got := archived()
+	if len(got) != MaxArchive || got[0] != "2" || got[len(got)-1] != fmt.Sprint(MaxArchive+1) {
+		t.Fatalf("archive runs %v to %v (%d topics), want 2 to %d (%d topics)", got[0], got[len(got)-1], len(got), MaxArchive+1, MaxArchive)
+	}
+
+	tests := []struct {
+		name    string
+		in      string
+		wantLen int
+		wantBy  string // By of the latest topic named in that is left
+	}{
+		{name: "latest", in: "0", wantLen: MaxArchive - 1, wantBy: fmt.Sprint(MaxArchive - 4)},
+		{name: "again", in: "0", wantLen: MaxArchive - 2, wantBy: fmt.Sprint(MaxArchive - 7)},
+		{name: "not-found", in: "x", wantLen: MaxArchive - 2},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			q.unarchive(test.in)
+
+			if len(q.Archive) != test.wantLen {
+				t.Errorf("len = %d, want %d", len(q.Archive), test.wantLen)
+			}
+
+			var by string
+			for _, f := range q.Archive {
+				if f.Topic.Name == test.in {
+					by = f.By
+				}
+			}
+			if by != test.wantBy {
+				t.Errorf("latest %q left was archived by %q, want %q", test.in, by, test.wantBy)
+			}
+		})
+	}
+}
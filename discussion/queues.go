@@ -0,0 +1,146 @@
+package discussion
+
+import (
+	"fmt"
+	gb "github.com/ericebersohl/gobottas"
+	"github.com/ericebersohl/gobottas/journal"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+)
+
+// Every guild's discussion queue.  A guild's queue is loaded the first time it's used and saved in the guild's own
+// directory.  The one queue that older versions shared between guilds, if it was saved, starts off each guild that
+// has no queue of its own.
+type Queues struct {
+	dir     string
+	journal *journal.Journal
+	log     *gb.Logger
+
+	mu     sync.Mutex
+	queues map[gb.Snowflake]*Queue
+}
+
+type QueuesOpt func(*Queues)
+
+// Keep guilds' queues in directories under dir
+func NewQueues(dir string, opts ...QueuesOpt) *Queues {
+	qs := Queues{
+		dir:    dir,
+		queues: make(map[gb.Snowflake]*Queue),
+	}
+
+	for _, o := range opts {
+		o(&qs)
+	}
+
+	return &qs
+}
+
+// record changes to every guild's queue in j
+func WithJournal(j *journal.Journal) QueuesOpt {
+	return func(qs *Queues) {
+		qs.journal = j
+	}
+}
+
+// log problems loading queues with l
+func WithLogger(l *gb.Logger) QueuesOpt {
+	return func(qs *Queues) {
+		qs.log = l
+	}
+}
+
+// The directory a guild's queue is saved in
+func (qs *Queues) Path(guild gb.Snowflake) string {
+	return fmt.Sprintf("%s/guilds/%s", qs.dir, guild)
+}
+
+// The guild's queue, loaded or started the first time it's asked for
+func (qs *Queues) Get(guild gb.Snowflake) *Queue {
+	qs.mu.Lock()
+	defer qs.mu.Unlock()
+
+	if q, ok := qs.queues[guild]; ok {
+		return q
+	}
+
+	q := qs.load(guild)
+	q.Journal = qs.journal
+	if err := os.MkdirAll(qs.Path(guild), 0755); err != nil {
+		qs.log.Warn("failed to make the guild's directory; its queue won't be saved", "guild", guild, "err", err)
+	}
+	qs.queues[guild] = q
+	return q
+}
+
+// read the guild's saved queue, or else the shared one, or start an empty one
+func (qs *Queues) load(guild gb.Snowflake) *Queue {
+	for _, path := range []string{qs.Path(guild), qs.dir} {
+		if _, err := os.Stat(fmt.Sprintf("%s/queue.json", path)); os.IsNotExist(err) {
+			continue
+		}
+
+		q := NewQueue()
+		if err := q.Load(path); err != nil {
+			qs.log.Warn("failed to load the discussion queue; using a new one", "guild", guild, "path", path, "err", err)
+			return NewQueue()
+		}
+		return q
+	}
+	return NewQueue()
+}
+
+// Load the queue of every guild that has one saved
+func (qs *Queues) Load() error {
+	dirs, err := ioutil.ReadDir(fmt.Sprintf("%s/guilds", qs.dir))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, d := range dirs {
+		guild, err := gb.ToSnowflake(d.Name())
+		if err != nil || !d.IsDir() {
+			continue
+		}
+		if _, err := os.Stat(fmt.Sprintf("%s/queue.json", qs.Path(guild))); err == nil {
+			qs.Get(guild)
+		}
+	}
+	return nil
+}
+
+// The guilds that have a queue, in order
+func (qs *Queues) Guilds() []gb.Snowflake {
+	qs.mu.Lock()
+	defer qs.mu.Unlock()
+
+	guilds := make([]gb.Snowflake, 0, len(qs.queues))
+	for g := range qs.queues {
+		guilds = append(guilds, g)
+	}
+	sort.Slice(guilds, func(i, j int) bool { return guilds[i] < guilds[j] })
+	return guilds
+}
+
+// Save the guild's queue
+func (qs *Queues) Save(guild gb.Snowflake) error {
+	q := qs.Get(guild)
+	q.Lock()
+	defer q.Unlock()
+	return q.Save(qs.Path(guild))
+}
+
+// Undo a journaled change to the queue of the guild it was made in; the registry saves it
+func (qs *Queues) Undo(e *journal.Entry) error {
+	return qs.Get(e.GuildId).Undo(e)
+}
+
+// Redo a journaled change to the queue of the guild it was made in; the registry saves it
+func (qs *Queues) Redo(e *journal.Entry) error {
+	return qs.Get(e.GuildId).Redo(e)
+}
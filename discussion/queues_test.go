@@ -0,0 +1,76 @@
+package discussion
+
+import (
+	"encoding/json"
+	gb "github.com/ericebersohl/gobottas"
+	"github.com/ericebersohl/gobottas/journal"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+)
+
+/*
+Test Cases:
+- each guild has its own queue
+- a guild's queue is saved in its own directory and loaded again
+- the queue older versions shared starts off guilds without their own, and is left alone
+- a journaled change is undone in the queue of the guild it was made in
+*/
+func TestQueues(t *testing.T) {
+	dir, err := ioutil.TempDir("", "queues")
+	if err != nil {
+		t.Fatalf("temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	shared := NewQueue()
+	_ = shared.Add(&Topic{Name: "shared"})
+	if err := shared.Save(dir); err != nil {
+		t.Fatalf("save shared: %v", err)
+	}
+
+	qs := NewQueues(dir)
+	if err := qs.Get(1).Add(&Topic{Name: "one"}); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	if err := qs.Save(1); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if _, err := os.Stat(dir + "/guilds/1/queue.json"); err != nil {
+		t.Errorf("not saved in the guild's directory: %v", err)
+	}
+
+	names := func(q *Queue) []string {
+		var out []string
+		for _, t := range q.List() {
+			out = append(out, t.Name)
+		}
+		return out
+	}
+	if got, want := names(qs.Get(2)), []string{"shared"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("guild 2 = %v, want %v", got, want)
+	}
+
+	loaded := NewQueues(dir)
+	if err := loaded.Load(); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if got, want := loaded.Guilds(), []gb.Snowflake{1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("guilds = %v, want %v", got, want)
+	}
+	if got, want := names(loaded.Get(1)), []string{"shared", "one"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("guild 1 = %v, want %v", got, want)
+	}
+
+	data, _ := json.Marshal(change{Topic: &Topic{Name: "shared"}})
+	if err := loaded.Undo(&journal.Entry{GuildId: 2, Op: "add", Data: data}); err != nil {
+		t.Fatalf("undo: %v", err)
+	}
+	if got := names(loaded.Get(2)); len(got) != 0 {
+		t.Errorf("guild 2 after undo = %v, want nothing", got)
+	}
+	if got, want := names(loaded.Get(1)), []string{"shared", "one"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("guild 1 after undo = %v, want %v", got, want)
+	}
+}
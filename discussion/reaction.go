@@ -0,0 +1,87 @@
+package discussion
+
+import (
+	"fmt"
+	"github.com/bwmarrin/discordgo"
+	gb "github.com/ericebersohl/gobottas"
+	"strings"
+)
+
+// Reactions to the bot's messages about the queue
+const (
+	Vote     = "👍"  // on a topic, as &dq next shows it: vote for the topic
+	Previous = "◀️" // on the list: show the page before
+	Next     = "▶️" // on the list: show the page after
+)
+
+// What the bot's messages about the queue start with, so reactions to them can be told apart
+const (
+	listTitle  = "Topics"
+	pageFooter = "Page %d of %d"
+	proposed   = "Proposed by "
+)
+
+// Answer a reaction to one of the bot's messages about the queue: a vote for a topic, or turning the page of the
+// list, which edits the message reacted to.  Reactions to anything else are left alone.
+func react(qs *Queues, msg *gb.Message) error {
+	rx := msg.Reaction
+	if rx == nil || !rx.FromBot || len(rx.Embeds) == 0 || rx.Embeds[0].Footer == nil {
+		return nil
+	}
+	e := rx.Embeds[0]
+	guild := msg.Source.GuildId
+
+	switch {
+	case same(rx.Emoji, Vote) && strings.HasPrefix(e.Footer.Text, proposed):
+		q := qs.Get(guild)
+		q.Lock()
+		ok, err := q.Vote(e.Title, msg.Source.AuthorId)
+		var embed *discordgo.MessageEmbed
+		if t, ferr := q.Find(e.Title); ferr == nil {
+			embed = t.Embed()
+		}
+		q.Unlock()
+
+		// the topic may be gone since the message was sent, and votes after the first change nothing
+		if err != nil || !ok {
+			return nil
+		}
+
+		// votes aren't queue commands, which the registry saves
+		if err := qs.Save(guild); err != nil {
+			return err
+		}
+		msg.Response.ChannelId = msg.Source.ChannelId
+		msg.Response.AddEmbedEdit(rx.MessageId, embed)
+		return nil
+
+	case (same(rx.Emoji, Previous) || same(rx.Emoji, Next)) && e.Title == listTitle:
+		var page, n int
+		if _, err := fmt.Sscanf(e.Footer.Text, pageFooter, &page, &n); err != nil {
+			return nil
+		}
+		if same(rx.Emoji, Previous) {
+			page--
+		} else {
+			page++
+		}
+
+		q := qs.Get(guild)
+		q.Lock()
+		defer q.Unlock()
+
+		// the list may have shrunk since the message was sent
+		if page < 1 || page > pages(q.Len()) {
+			return nil
+		}
+		msg.Response.ChannelId = msg.Source.ChannelId
+		msg.Response.AddEmbedEdit(rx.MessageId, listEmbed(q, page))
+	}
+
+	return nil
+}
+
+// whether two emoji are the same, with or without the variation selector that asks for the emoji style
+func same(a, b string) bool {
+	return strings.TrimSuffix(a, "\ufe0f") == strings.TrimSuffix(b, "\ufe0f")
+}
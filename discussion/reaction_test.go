@@ -0,0 +1,99 @@
+package discussion
+
+import (
+	"fmt"
+	"github.com/bwmarrin/discordgo"
+	gb "github.com/ericebersohl/gobottas"
+	"github.com/ericebersohl/gobottas/mock"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+/*
+Test Cases:
+- a vote for a topic is counted once per user, saved, and shown by editing the topic
+- reactions to other people's messages, other emoji and topics that are gone are left alone
+- the list turns its page forward and back, with or without the emoji's variation selector, but not past its ends
+*/
+func TestInterceptor_Reactions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reactions")
+	if err != nil {
+		t.Fatalf("temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	qs := NewQueues(dir)
+	q := qs.Get(1)
+	for n := 0; n < PageSize+2; n++ {
+		if err := q.Add(&Topic{Name: fmt.Sprintf("t%d", n), CreatedBy: "user"}); err != nil {
+			t.Fatalf("add: %v", err)
+		}
+	}
+
+	topic := q.Q[0].Embed()
+	gone := (&Topic{Name: "gone"}).Embed()
+	first, second := listEmbed(q, 1), listEmbed(q, 2)
+
+	react := func(emoji string, user gb.Snowflake, e *discordgo.MessageEmbed, fromBot bool) *gb.Message {
+		msg := mock.NewMessage(gb.Reaction, mock.WithSource(user, 7, "user", ""), mock.WithGuild(1), mock.WithReaction(emoji, 99, fromBot))
+		msg.Reaction.Embeds = []*discordgo.MessageEmbed{e}
+		return msg
+	}
+
+	tests := []struct {
+		name       string
+		in         *gb.Message
+		wantEdit   bool
+		wantFooter string // something the edited embed's footer says
+		wantFields int    // topics on the edited page
+		wantVotes  int
+	}{
+		{name: "vote", in: react(Vote, 1, topic, true), wantEdit: true, wantFooter: "1 vote ", wantVotes: 1},
+		{name: "vote-again", in: react(Vote, 1, topic, true), wantVotes: 1},
+		{name: "vote-other-user", in: react(Vote, 2, topic, true), wantEdit: true, wantFooter: "2 votes", wantVotes: 2},
+		{name: "vote-not-bot", in: react(Vote, 3, topic, false), wantVotes: 2},
+		{name: "vote-other-emoji", in: react("😂", 3, topic, true), wantVotes: 2},
+		{name: "vote-on-list", in: react(Vote, 3, first, true), wantVotes: 2},
+		{name: "vote-gone", in: react(Vote, 3, gone, true), wantVotes: 2},
+		{name: "next", in: react(Next, 1, first, true), wantEdit: true, wantFooter: "Page 2 of 2", wantFields: 2, wantVotes: 2},
+		{name: "next-last", in: react(Next, 1, second, true), wantVotes: 2},
+		{name: "previous", in: react("◀", 1, second, true), wantEdit: true, wantFooter: "Page 1 of 2", wantFields: PageSize, wantVotes: 2},
+		{name: "previous-first", in: react(Previous, 1, first, true), wantVotes: 2},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := Interceptor(qs)(test.in); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			out := test.in.Response.Outputs
+			if (len(out) == 1) != test.wantEdit {
+				t.Fatalf("outputs = %+v, want edit: %t", out, test.wantEdit)
+			}
+			if test.wantEdit {
+				e := out[0].Embed
+				if out[0].MessageId != 99 || test.in.Response.ChannelId != 7 || e == nil || e.Footer == nil {
+					t.Fatalf("didn't edit the message reacted to: %+v", out[0])
+				}
+				if !strings.Contains(e.Footer.Text, test.wantFooter) {
+					t.Errorf("footer = %q, want it to contain %q", e.Footer.Text, test.wantFooter)
+				}
+				if test.wantFields > 0 && len(e.Fields) != test.wantFields {
+					t.Errorf("page has %d topics, want %d", len(e.Fields), test.wantFields)
+				}
+			}
+
+			if n := len(q.Q[0].Votes); n != test.wantVotes {
+				t.Errorf("votes = %d, want %d", n, test.wantVotes)
+			}
+		})
+	}
+
+	saved := NewQueue()
+	if err := saved.Load(qs.Path(1)); err != nil || len(saved.Q[0].Votes) != 2 {
+		t.Errorf("votes not saved (err = %v)", err)
+	}
+}
@@ -0,0 +1,379 @@
+// Package dispatch sends the bot's responses through a gb.Session while keeping under discord's rate limits.  Sends
+// that fail for a moment, because of rate limiting or a server error, are retried with backoff, and sends that still
+// fail can be written to a dead-letter log.
+//
+// Sends block the goroutine that handles messages, so retries stop once they've waited MaxRetryWait in all; a send
+// discord wants held off for longer is dead-lettered instead of holding up every other guild.  discordgo already
+// waits out most 429s itself, so the retries here are mostly for server and network errors.
+package dispatch
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/bwmarrin/discordgo"
+	gb "github.com/ericebersohl/gobottas"
+	"github.com/ericebersohl/gobottas/metrics"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Defaults, a little under discord's own limits
+const (
+	DefaultGlobalLimit   = 45 // sends per second across all channels
+	DefaultChannelLimit  = 5  // sends per DefaultChannelPeriod in one channel
+	DefaultChannelPeriod = 5 * time.Second
+	DefaultRetries       = 3
+	DefaultBackoff       = 500 * time.Millisecond // doubled after each failed attempt
+	MaxBackoff           = 30 * time.Second
+	MaxRetryWait         = 5 * time.Second // waited between attempts at one send, in all
+)
+
+// A gb.Session that rate limits and retries sends to the session it wraps
+type Dispatcher struct {
+	session gb.Session
+	clock   gb.Clock
+	log     *gb.Logger
+
+	retries int
+	backoff time.Duration
+	maxWait time.Duration // waited between attempts, in all
+
+	// failed responses are written here as JSON lines; nil drops them
+	deadLetter io.Writer
+	dlMu       sync.Mutex
+
+	mu            sync.Mutex
+	global        *limiter
+	channels      map[string]*limiter
+	channelLimit  int
+	channelPeriod time.Duration
+	swept         time.Time // when idle channels were last forgotten
+}
+
+type Opt func(*Dispatcher)
+
+func New(s gb.Session, opts ...Opt) *Dispatcher {
+	d := Dispatcher{
+		session:       s,
+		clock:         gb.SystemClock{},
+		retries:       DefaultRetries,
+		backoff:       DefaultBackoff,
+		maxWait:       MaxRetryWait,
+		global:        newLimiter(DefaultGlobalLimit, time.Second),
+		channels:      make(map[string]*limiter),
+		channelLimit:  DefaultChannelLimit,
+		channelPeriod: DefaultChannelPeriod,
+	}
+
+	for _, o := range opts {
+		o(&d)
+	}
+
+	return &d
+}
+
+func WithClock(c gb.Clock) Opt {
+	return func(d *Dispatcher) {
+		d.clock = c
+	}
+}
+
+func WithLogger(l *gb.Logger) Opt {
+	return func(d *Dispatcher) {
+		d.log = l
+	}
+}
+
+// allow n sends per period across all channels
+func WithGlobalLimit(n int, per time.Duration) Opt {
+	return func(d *Dispatcher) {
+		d.global = newLimiter(n, per)
+	}
+}
+
+// allow n sends per period in each channel
+func WithChannelLimit(n int, per time.Duration) Opt {
+	return func(d *Dispatcher) {
+		d.channelLimit = n
+		d.channelPeriod = per
+	}
+}
+
+// retry failed sends up to n times, waiting backoff before the first retry and twice as long before each one after
+func WithRetries(n int, backoff time.Duration) Opt {
+	return func(d *Dispatcher) {
+		d.retries = n
+		d.backoff = backoff
+	}
+}
+
+// give up on a send once retrying it would wait longer than max in all
+func WithMaxRetryWait(max time.Duration) Opt {
+	return func(d *Dispatcher) {
+		d.maxWait = max
+	}
+}
+
+// write responses that could not be sent to w, one JSON object per line
+func WithDeadLetter(w io.Writer) Opt {
+	return func(d *Dispatcher) {
+		d.deadLetter = w
+	}
+}
+
+func (d *Dispatcher) ChannelMessageSend(channelId string, msg string) (*discordgo.Message, error) {
+	var m *discordgo.Message
+	err := d.send(letter{ChannelId: channelId, Kind: "text", Text: msg}, func() (err error) {
+		m, err = d.session.ChannelMessageSend(channelId, msg)
+		return err
+	})
+	return m, err
+}
+
+func (d *Dispatcher) ChannelMessageSendEmbed(channelId string, embed *discordgo.MessageEmbed) (*discordgo.Message, error) {
+	var m *discordgo.Message
+	err := d.send(letter{ChannelId: channelId, Kind: "embed", Embed: embed}, func() (err error) {
+		m, err = d.session.ChannelMessageSendEmbed(channelId, embed)
+		return err
+	})
+	return m, err
+}
+
+func (d *Dispatcher) ChannelFileSendWithMessage(channelId, content, name string, r io.Reader) (*discordgo.Message, error) {
+	// a retry needs the file again, so read it once up front
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var m *discordgo.Message
+	err = d.send(letter{ChannelId: channelId, Kind: "file", Text: content, File: name}, func() (err error) {
+		m, err = d.session.ChannelFileSendWithMessage(channelId, content, name, bytes.NewReader(data))
+		return err
+	})
+	return m, err
+}
+
+func (d *Dispatcher) ChannelMessageEdit(channelId, messageId, content string) (*discordgo.Message, error) {
+	var m *discordgo.Message
+	err := d.send(letter{ChannelId: channelId, Kind: "edit", MessageId: messageId, Text: content}, func() (err error) {
+		m, err = d.session.ChannelMessageEdit(channelId, messageId, content)
+		return err
+	})
+	return m, err
+}
+
+func (d *Dispatcher) ChannelMessageEditEmbed(channelId, messageId string, embed *discordgo.MessageEmbed) (*discordgo.Message, error) {
+	var m *discordgo.Message
+	err := d.send(letter{ChannelId: channelId, Kind: "edit", MessageId: messageId, Embed: embed}, func() (err error) {
+		m, err = d.session.ChannelMessageEditEmbed(channelId, messageId, embed)
+		return err
+	})
+	return m, err
+}
+
+func (d *Dispatcher) ChannelMessageDelete(channelId, messageId string) error {
+	return d.send(letter{ChannelId: channelId, Kind: "delete", MessageId: messageId}, func() error {
+		return d.session.ChannelMessageDelete(channelId, messageId)
+	})
+}
+
+func (d *Dispatcher) MessageReactionAdd(channelId, messageId, emoji string) error {
+	return d.send(letter{ChannelId: channelId, Kind: "reaction", MessageId: messageId, Text: emoji}, func() error {
+		return d.session.MessageReactionAdd(channelId, messageId, emoji)
+	})
+}
+
+// Opening a direct message channel isn't a send, so it isn't limited, retried or dead-lettered; the message sent
+// there is.
+func (d *Dispatcher) UserChannelCreate(userId string) (*discordgo.Channel, error) {
+	return d.session.UserChannelCreate(userId)
+}
+
+// A response that could not be sent, as written to the dead-letter log
+type letter struct {
+	Time      time.Time               `json:"time"`
+	ChannelId string                  `json:"channel"`
+	Kind      string                  `json:"kind"`
+	MessageId string                  `json:"message,omitempty"` // the message edited, deleted or reacted to
+	Text      string                  `json:"text,omitempty"`
+	Embed     *discordgo.MessageEmbed `json:"embed,omitempty"`
+	File      string                  `json:"file,omitempty"` // name only; the contents aren't kept
+	Attempts  int                     `json:"attempts"`
+	Error     string                  `json:"error"`
+}
+
+// call f within the rate limits until it succeeds, fails for good, or runs out of retries or time to wait for them
+func (d *Dispatcher) send(l letter, f func() error) error {
+	var err error
+	var waited time.Duration
+	attempt := 0
+	for {
+		d.wait(l.ChannelId)
+
+		attempt++
+		if err = f(); err == nil {
+			return nil
+		}
+
+		delay, ok := d.retryDelay(err, attempt)
+		if !ok || attempt > d.retries || waited+delay > d.maxWait {
+			break
+		}
+		waited += delay
+
+		metrics.SendRetries.Inc(l.Kind)
+		d.log.Warn("send failed; retrying", "channel", l.ChannelId, "kind", l.Kind, "attempt", attempt, "delay", delay, "err", err)
+		<-d.clock.After(delay)
+	}
+
+	l.Time = d.clock.Now()
+	l.Attempts = attempt
+	l.Error = err.Error()
+	d.bury(l)
+	return err
+}
+
+// wait until both the channel and the global limit allow another send
+func (d *Dispatcher) wait(channel string) {
+	d.mu.Lock()
+	now := d.clock.Now()
+
+	// a limiter that has refilled is no different from a new one, so channels that have gone quiet are forgotten
+	if now.Sub(d.swept) >= d.channelPeriod {
+		for id, c := range d.channels {
+			if c.full(now) {
+				delete(d.channels, id)
+			}
+		}
+		d.swept = now
+	}
+
+	c, ok := d.channels[channel]
+	if !ok {
+		c = newLimiter(d.channelLimit, d.channelPeriod)
+		d.channels[channel] = c
+	}
+
+	delay := c.reserve(now)
+	if g := d.global.reserve(now); g > delay {
+		delay = g
+	}
+	d.mu.Unlock()
+
+	if delay > 0 {
+		d.log.Debug("rate limited", "channel", channel, "delay", delay)
+		<-d.clock.After(delay)
+	}
+}
+
+// How long to wait before retrying after err, and whether err is worth retrying at all.  Rate limits and server
+// errors pass; anything else, like a missing permission, would fail again.
+func (d *Dispatcher) retryDelay(err error, attempt int) (time.Duration, bool) {
+	delay := d.backoff << uint(attempt-1)
+	if delay > MaxBackoff || delay <= 0 {
+		delay = MaxBackoff
+	}
+
+	switch e := err.(type) {
+	case *discordgo.RESTError:
+		if e.Response == nil {
+			return 0, false
+		}
+
+		switch code := e.Response.StatusCode; {
+		case code == http.StatusTooManyRequests:
+			// discord says how long to wait, in milliseconds
+			var tmr discordgo.TooManyRequests
+			if json.Unmarshal(e.ResponseBody, &tmr) == nil && tmr.RetryAfter*time.Millisecond > delay {
+				delay = tmr.RetryAfter * time.Millisecond
+			}
+			return delay, true
+		case code >= 500:
+			return delay, true
+		}
+		return 0, false
+
+	case net.Error:
+		return delay, true
+	}
+
+	return 0, false
+}
+
+// write a response that could not be sent to the dead-letter log
+func (d *Dispatcher) bury(l letter) {
+	// the registry logs the error along with the message's correlation id
+	metrics.DeadLetters.Inc(l.Kind)
+
+	if d.deadLetter == nil {
+		return
+	}
+
+	data, err := json.Marshal(l)
+	if err != nil {
+		d.log.Error("failed to encode a dead letter", "err", err)
+		return
+	}
+
+	d.dlMu.Lock()
+	defer d.dlMu.Unlock()
+
+	if _, err := d.deadLetter.Write(append(data, '\n')); err != nil {
+		d.log.Error("failed to write a dead letter", "err", err)
+	}
+}
+
+// Token bucket that allows n sends per period, in bursts of up to n
+type limiter struct {
+	n      float64
+	period time.Duration
+	tokens float64
+	last   time.Time
+}
+
+func newLimiter(n int, per time.Duration) *limiter {
+	l := limiter{
+		n:      float64(n),
+		period: per,
+		tokens: float64(n),
+	}
+	return &l
+}
+
+// Report whether the limiter has every token back at now
+func (l *limiter) full(now time.Time) bool {
+	if l.n <= 0 || l.period <= 0 || l.last.IsZero() {
+		return true
+	}
+
+	rate := l.n / l.period.Seconds()
+	return l.tokens+now.Sub(l.last).Seconds()*rate >= l.n
+}
+
+// Take a token and return how long to wait before using it.  Tokens can be taken before they refill, so that
+// waiting callers queue up in order.
+func (l *limiter) reserve(now time.Time) time.Duration {
+	if l.n <= 0 || l.period <= 0 {
+		return 0
+	}
+
+	rate := l.n / l.period.Seconds() // tokens per second
+	if !l.last.IsZero() {
+		l.tokens += now.Sub(l.last).Seconds() * rate
+		if l.tokens > l.n {
+			l.tokens = l.n
+		}
+	}
+	l.last = now
+
+	l.tokens--
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / rate * float64(time.Second))
+}
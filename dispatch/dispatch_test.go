@@ -0,0 +1,185 @@
+package dispatch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/ericebersohl/gobottas/mock"
+	"strings"
+	"testing"
+	"time"
+)
+
+// call f, moving the clock forward in small steps whenever it waits, and return how much time passed
+func run(t *testing.T, clock *mock.Clock, f func()) time.Duration {
+	start := clock.Now()
+	done := make(chan struct{})
+	go func() {
+		f()
+		close(done)
+	}()
+
+	for {
+		select {
+		case <-done:
+			return clock.Now().Sub(start)
+		default:
+		}
+
+		if clock.Waiting() > 0 {
+			clock.Advance(100 * time.Millisecond)
+		}
+
+		if clock.Now().Sub(start) > time.Hour {
+			t.Fatalf("send never finished")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+/*
+Test Cases:
+- sent right away
+- rate limited: retried after discord's retry_after
+- server errors: retried with doubling backoff
+- forbidden: not retried, dead-lettered
+- out of retries: dead-lettered with the number of attempts
+- rate limited for longer than the retries may wait: dead-lettered without waiting
+- file: resent in full
+*/
+func TestDispatcher_Retry(t *testing.T) {
+	tests := []struct {
+		name       string
+		errs       []error
+		file       bool
+		wantErr    bool
+		wantCalls  int
+		wantWait   time.Duration
+		wantBuried bool
+	}{
+		{name: "sent", wantCalls: 1},
+		{name: "rate-limited", errs: []error{mock.TooManyRequests(1500 * time.Millisecond)}, wantCalls: 2, wantWait: 1500 * time.Millisecond},
+		{name: "server-errors", errs: []error{mock.StatusError(502, ""), mock.StatusError(500, "")}, wantCalls: 3, wantWait: 1500 * time.Millisecond},
+		{name: "forbidden", errs: []error{mock.StatusError(403, `{"message": "Missing Permissions"}`)}, wantErr: true, wantCalls: 1, wantBuried: true},
+		{name: "out-of-retries", errs: []error{mock.StatusError(502, ""), mock.StatusError(502, ""), mock.StatusError(502, "")}, wantErr: true, wantCalls: 3, wantWait: 1500 * time.Millisecond, wantBuried: true},
+		{name: "rate-limited-too-long", errs: []error{mock.TooManyRequests(MaxRetryWait + time.Second)}, wantErr: true, wantCalls: 1, wantBuried: true},
+		{name: "file", errs: []error{mock.StatusError(503, "")}, file: true, wantCalls: 2, wantWait: 500 * time.Millisecond},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			clock := mock.NewClock(time.Date(2020, 1, 2, 15, 4, 5, 0, time.UTC))
+			s := mock.NewSession()
+			s.Fail(test.errs...)
+
+			var dead bytes.Buffer
+			d := New(s, WithClock(clock), WithRetries(2, 500*time.Millisecond), WithDeadLetter(&dead))
+
+			var err error
+			waited := run(t, clock, func() {
+				if test.file {
+					_, err = d.ChannelFileSendWithMessage("1", "a meme", "meme.png", strings.NewReader("png data"))
+				} else {
+					_, err = d.ChannelMessageSend("1", "hello")
+				}
+			})
+
+			if (err != nil) != test.wantErr {
+				t.Fatalf("err != wantErr (err = %v, wantErr = %v)", err, test.wantErr)
+			}
+
+			if s.Calls() != test.wantCalls {
+				t.Errorf("calls = %d, want %d", s.Calls(), test.wantCalls)
+			}
+
+			if waited != test.wantWait {
+				t.Errorf("waited %s, want %s", waited, test.wantWait)
+			}
+
+			if test.file && (len(s.Sent()) != 1 || string(s.Sent()[0].Data) != "png data") {
+				t.Errorf("file not resent in full: %+v", s.Sent())
+			}
+
+			if (dead.Len() > 0) != test.wantBuried {
+				t.Fatalf("dead letter written = %t, want %t", dead.Len() > 0, test.wantBuried)
+			}
+
+			if test.wantBuried {
+				var l letter
+				if err := json.Unmarshal(dead.Bytes(), &l); err != nil {
+					t.Fatalf("dead letter: %v", err)
+				}
+				if l.ChannelId != "1" || l.Text != "hello" || l.Attempts != test.wantCalls || l.Error == "" {
+					t.Errorf("dead letter incomplete: %+v", l)
+				}
+			}
+		})
+	}
+}
+
+/*
+Test Cases:
+- a burst up to the channel limit goes right away, the next send waits for a token
+- other channels aren't held up by a busy one
+- the global limit holds up every channel
+- channels are forgotten once their limits have refilled
+*/
+func TestDispatcher_Limit(t *testing.T) {
+	clock := mock.NewClock(time.Date(2020, 1, 2, 15, 4, 5, 0, time.UTC))
+	s := mock.NewSession()
+	d := New(s, WithClock(clock), WithChannelLimit(2, time.Second), WithGlobalLimit(3, time.Second))
+
+	send := func(channel string) time.Duration {
+		return run(t, clock, func() {
+			if _, err := d.ChannelMessageSend(channel, "hi"); err != nil {
+				t.Errorf("send: %v", err)
+			}
+		})
+	}
+
+	tests := []struct {
+		name     string
+		channel  string
+		wantWait time.Duration
+	}{
+		{name: "burst-1", channel: "1"},
+		{name: "burst-2", channel: "1"},
+		{name: "channel-limit", channel: "1", wantWait: 500 * time.Millisecond},
+		{name: "other-channel", channel: "2"},
+		{name: "global-limit", channel: "3", wantWait: 200 * time.Millisecond},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := send(test.channel); got != test.wantWait {
+				t.Errorf("waited %s, want %s", got, test.wantWait)
+			}
+		})
+	}
+
+	if len(s.Sent()) != len(tests) {
+		t.Errorf("sent %d, want %d", len(s.Sent()), len(tests))
+	}
+
+	clock.Advance(time.Second)
+	send("4")
+	if len(d.channels) != 1 {
+		t.Errorf("kept %d channels, want only the last", len(d.channels))
+	}
+}
+
+/*
+Test Cases:
+- errors that aren't from discord aren't retried
+- backoff is capped
+*/
+func TestDispatcher_retryDelay(t *testing.T) {
+	d := New(mock.NewSession())
+	if _, ok := d.retryDelay(errors.New("bad embed"), 1); ok {
+		t.Errorf("plain error retried")
+	}
+
+	if delay, ok := d.retryDelay(mock.StatusError(500, ""), 10); !ok || delay != MaxBackoff {
+		t.Errorf("backoff not capped (delay = %s)", delay)
+	}
+}
@@ -0,0 +1,181 @@
+package event
+
+import (
+	"fmt"
+	gb "github.com/ericebersohl/gobottas"
+	"github.com/ericebersohl/gobottas/metrics"
+	"sync"
+)
+
+// Events an asynchronous subscriber can fall behind by, unless it asks for another buffer
+const DefaultBuffer = 64
+
+// Something to do with an event.  Handlers must not publish events synchronously to the bus they are subscribed to.
+type Handler func(e gb.Event)
+
+// Delivers published events to the subscribers that want them.  Synchronous subscribers are called before Publish
+// returns; asynchronous ones get events on their own goroutine, in order, and miss events while their buffer is full
+// rather than holding up the publisher.
+type Bus struct {
+	mu     sync.RWMutex
+	subs   []*subscriber // in the order they subscribed
+	nextId int
+	closed bool
+	wg     sync.WaitGroup // asynchronous subscribers still delivering
+	log    *gb.Logger
+}
+
+type subscriber struct {
+	id    int
+	name  string          // for logs and metrics
+	kinds map[string]bool // kinds wanted; nil for all
+	h     Handler
+	ch    chan gb.Event // buffer of an asynchronous subscriber; nil for a synchronous one
+}
+
+type Opt func(*Bus)
+
+func New(opts ...Opt) *Bus {
+	var b Bus
+	for _, o := range opts {
+		o(&b)
+	}
+
+	return &b
+}
+
+// log handlers that panic with l
+func WithLogger(l *gb.Logger) Opt {
+	return func(b *Bus) {
+		b.log = l
+	}
+}
+
+type SubOpt func(*subscriber)
+
+// only deliver events of these kinds
+func Kinds(kinds ...string) SubOpt {
+	return func(s *subscriber) {
+		s.kinds = make(map[string]bool)
+		for _, k := range kinds {
+			s.kinds[k] = true
+		}
+	}
+}
+
+// deliver events on the subscriber's own goroutine, keeping up to buffer events it hasn't handled yet
+func Async(buffer int) SubOpt {
+	return func(s *subscriber) {
+		s.ch = make(chan gb.Event, buffer)
+	}
+}
+
+// Call h with published events, all of them unless Kinds says otherwise.  The name identifies the subscriber in logs
+// and metrics.  Returns a function that unsubscribes; an asynchronous subscriber handles the events it has buffered
+// first.
+func (b *Bus) Subscribe(name string, h Handler, opts ...SubOpt) func() {
+	s := &subscriber{name: name, h: h}
+	for _, o := range opts {
+		o(s)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return func() {}
+	}
+
+	s.id = b.nextId
+	b.nextId++
+	b.subs = append(b.subs, s)
+
+	if s.ch != nil {
+		b.wg.Add(1)
+		go b.deliver(s)
+	}
+
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		for i, e := range b.subs {
+			if e.id == s.id {
+				b.subs = append(b.subs[:i], b.subs[i+1:]...)
+				if s.ch != nil {
+					close(s.ch)
+				}
+				return
+			}
+		}
+	}
+}
+
+// Deliver an event to its subscribers.  A nil bus drops every event, so modules can publish without one.
+func (b *Bus) Publish(e gb.Event) {
+	if b == nil || e == nil {
+		return
+	}
+
+	metrics.EventsPublished.Inc(e.Kind())
+
+	// the lock keeps subscribers from closing their buffers mid-send; synchronous handlers run without it, so they
+	// can subscribe and unsubscribe
+	var direct []*subscriber
+	b.mu.RLock()
+	for _, s := range b.subs {
+		if s.kinds != nil && !s.kinds[e.Kind()] {
+			continue
+		}
+
+		if s.ch == nil {
+			direct = append(direct, s)
+			continue
+		}
+
+		select {
+		case s.ch <- e:
+		default:
+			metrics.EventsDropped.Inc(s.name, e.Kind())
+			b.log.Warn("event dropped; subscriber is behind", "subscriber", s.name, "kind", e.Kind())
+		}
+	}
+	b.mu.RUnlock()
+
+	for _, s := range direct {
+		b.handle(s, e)
+	}
+}
+
+// Unsubscribe everyone, and wait for asynchronous subscribers to handle the events they have buffered
+func (b *Bus) Close() {
+	b.mu.Lock()
+	b.closed = true
+	for _, s := range b.subs {
+		if s.ch != nil {
+			close(s.ch)
+		}
+	}
+	b.subs = nil
+	b.mu.Unlock()
+
+	b.wg.Wait()
+}
+
+// handle an asynchronous subscriber's events until it unsubscribes
+func (b *Bus) deliver(s *subscriber) {
+	defer b.wg.Done()
+	for e := range s.ch {
+		b.handle(s, e)
+	}
+}
+
+// call a handler, which mustn't take the bus or the publisher down with it
+func (b *Bus) handle(s *subscriber, e gb.Event) {
+	defer func() {
+		if r := recover(); r != nil {
+			b.log.Error("event handler panicked", "subscriber", s.name, "kind", e.Kind(), "err", fmt.Sprint(r))
+		}
+	}()
+	s.h(e)
+}
@@ -0,0 +1,97 @@
+package event
+
+import (
+	"fmt"
+	gb "github.com/ericebersohl/gobottas"
+	"github.com/ericebersohl/gobottas/metrics"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+/*
+Test Cases:
+- synchronous subscribers get events before Publish returns, in the order they subscribed
+- subscribers only get the kinds they asked for
+- unsubscribed subscribers get nothing more
+- a panicking handler doesn't stop the others
+- a nil bus drops events
+*/
+func TestBus_Sync(t *testing.T) {
+	b := New(WithLogger(nil))
+
+	var got []string
+	b.Subscribe("all", func(e gb.Event) { got = append(got, "all:"+e.Kind()) })
+	b.Subscribe("panics", func(e gb.Event) { panic("boom") })
+	stop := b.Subscribe("topics", func(e gb.Event) { got = append(got, "topics:"+e.Kind()) }, Kinds(KindTopicAdded, KindTopicCompleted))
+
+	b.Publish(&TopicAdded{Topic: "x"})
+	b.Publish(&MemeAdded{Meme: "y"})
+	stop()
+	b.Publish(&TopicCompleted{Topic: "x"})
+
+	want := []string{"all:topic.added", "topics:topic.added", "all:meme.added", "all:topic.completed"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	var none *Bus
+	none.Publish(&TopicAdded{})
+}
+
+/*
+Test Cases:
+- asynchronous subscribers get every event, in order, while they keep up
+- events beyond a full buffer are dropped and counted, without blocking the publisher
+- closing waits for buffered events to be handled
+- subscribing after close does nothing
+*/
+func TestBus_Async(t *testing.T) {
+	b := New()
+
+	var mu sync.Mutex
+	var fast []string
+	b.Subscribe("fast", func(e gb.Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		fast = append(fast, e.(*TopicAdded).Topic)
+	}, Async(100))
+
+	// the slow subscriber is stuck on its first event until released
+	release := make(chan struct{})
+	started := make(chan struct{}, 1)
+	var slow []string
+	b.Subscribe("slow", func(e gb.Event) {
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		<-release
+		slow = append(slow, e.(*TopicAdded).Topic)
+	}, Async(2), Kinds(KindTopicAdded))
+
+	dropped := metrics.EventsDropped.Value("slow", KindTopicAdded)
+
+	b.Publish(&TopicAdded{Topic: "0"})
+	<-started
+	for i := 1; i <= 5; i++ {
+		b.Publish(&TopicAdded{Topic: fmt.Sprint(i)})
+	}
+
+	if d := metrics.EventsDropped.Value("slow", KindTopicAdded) - dropped; d != 3 {
+		t.Errorf("dropped %v events, want 3", d)
+	}
+
+	close(release)
+	b.Close()
+
+	if want := []string{"0", "1", "2", "3", "4", "5"}; !reflect.DeepEqual(fast, want) {
+		t.Errorf("fast got %v, want %v", fast, want)
+	}
+	if want := []string{"0", "1", "2"}; !reflect.DeepEqual(slow, want) {
+		t.Errorf("slow got %v, want %v", slow, want)
+	}
+
+	b.Subscribe("late", func(e gb.Event) { t.Errorf("late subscriber got %s", e.Kind()) })
+	b.Publish(&TopicAdded{})
+}
@@ -0,0 +1,94 @@
+// Package event has the events that modules publish, and the bus that delivers them to subscribers.  Interceptors
+// publish events on the message they handle, and the registry passes them to the bus once the message is done, so
+// modules can react to each other without importing each other.
+package event
+
+import (
+	gb "github.com/ericebersohl/gobottas"
+	"time"
+)
+
+// Kinds of event
+const (
+	KindTopicAdded     = "topic.added"
+	KindTopicRemoved   = "topic.removed"
+	KindTopicCompleted = "topic.completed"
+	KindMemeAdded      = "meme.added"
+	KindCommandFailed  = "command.failed"
+)
+
+// What every event has: when it happened, where, and who did it
+type Base struct {
+	Time      time.Time    `json:"time"`
+	GuildId   gb.Snowflake `json:"guild"`
+	ChannelId gb.Snowflake `json:"channel"`
+	UserId    gb.Snowflake `json:"user"`
+	Username  string       `json:"username"`
+}
+
+// The base of an event caused by msg, happening now
+func From(msg *gb.Message) Base {
+	b := Base{Time: time.Now()}
+	if msg.Source != nil {
+		b.GuildId = msg.Source.GuildId
+		b.ChannelId = msg.Source.ChannelId
+		b.UserId = msg.Source.AuthorId
+		b.Username = msg.Source.Username
+	}
+	return b
+}
+
+// A topic was added to the discussion queue
+type TopicAdded struct {
+	Base
+	Topic       string `json:"topic"`
+	Description string `json:"description"`
+}
+
+func (e *TopicAdded) Kind() string {
+	return KindTopicAdded
+}
+
+// A topic was taken out of the discussion queue without being discussed
+type TopicRemoved struct {
+	Base
+	Topic string `json:"topic"`
+}
+
+func (e *TopicRemoved) Kind() string {
+	return KindTopicRemoved
+}
+
+// The topic at the front of the discussion queue was discussed, and left the queue
+type TopicCompleted struct {
+	Base
+	Topic       string   `json:"topic"`
+	Description string   `json:"description"`
+	Sources     []string `json:"sources"`
+}
+
+func (e *TopicCompleted) Kind() string {
+	return KindTopicCompleted
+}
+
+// A meme went into the stash, whether added directly, approved or imported
+type MemeAdded struct {
+	Base
+	Meme    string `json:"meme"`
+	AddedBy string `json:"added_by"`
+}
+
+func (e *MemeAdded) Kind() string {
+	return KindMemeAdded
+}
+
+// A command couldn't be carried out, or its response couldn't be sent
+type CommandFailed struct {
+	Base
+	Command string `json:"command"`
+	Err     string `json:"error"`
+}
+
+func (e *CommandFailed) Kind() string {
+	return KindCommandFailed
+}
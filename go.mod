@@ -1,9 +1,16 @@
 module github.com/ericebersohl/gobottas
 
-go 1.12
+go 1.18
 
 require (
+	github.com/BurntSushi/toml v0.4.1
 	github.com/bwmarrin/discordgo v0.19.0
 	github.com/google/go-cmp v0.3.1
 	github.com/joho/godotenv v1.3.0
+	gopkg.in/yaml.v2 v2.4.0
+)
+
+require (
+	github.com/gorilla/websocket v1.4.0 // indirect
+	golang.org/x/crypto v0.0.0-20181030102418-4d3f4d9ffa16 // indirect
 )
@@ -0,0 +1,257 @@
+package guild
+
+import (
+	"errors"
+	"fmt"
+	"github.com/bwmarrin/discordgo"
+	gb "github.com/ericebersohl/gobottas"
+	"github.com/ericebersohl/gobottas/args"
+	"github.com/ericebersohl/gobottas/discord"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Limits on custom shortcuts
+const (
+	MaxAliases     = 50 // per guild
+	MaxAliasLength = 32 // characters in an alias name
+	MaxAliasDepth  = 8  // aliases that can expand into each other before the chain is cut off
+)
+
+// Get what an alias in a guild stands for.  Names are case-insensitive.
+func (s *Store) Alias(guild gb.Snowflake, name string) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	g, ok := s.Guilds[guild]
+	if !ok {
+		return "", false
+	}
+
+	exp, ok := g.Aliases[strings.ToLower(name)]
+	return exp, ok
+}
+
+// Define or replace an alias in a guild.  The expansion must start with a command or another alias, and must not
+// lead back to the alias being defined.
+func (s *Store) SetAlias(guild gb.Snowflake, name, expansion string) error {
+	name = strings.ToLower(name)
+
+	if name == "" || len([]rune(name)) > MaxAliasLength || strings.ContainsAny(name, " \t\n\"'`\\") {
+		return discord.NewError("Invalid Alias", fmt.Sprintf("An alias must be a single word of at most %d characters.", MaxAliasLength))
+	}
+
+	// built-in commands can't be shadowed, or an alias could lock admins out of alias and config
+	if gb.StrToCommand(name) != gb.Unrecognized {
+		return discord.NewError("Invalid Alias", fmt.Sprintf("`%s` is already a command.", name))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	g := s.settings(guild)
+
+	// follow the chain of aliases to make sure it ends at a command, reading each one the way it will be expanded
+	seen := map[string]bool{name: true}
+	next := expansion
+	for depth := 0; ; depth++ {
+		words, err := args.Tokenize(next)
+		if err != nil {
+			return discord.NewError("Invalid Alias", fmt.Sprintf("`%s` could not be read: %v", next, err))
+		}
+		if len(words) == 0 {
+			return discord.NewError("Invalid Alias", "An alias must stand for a command, like `\"dq list\"`.")
+		}
+
+		first := strings.ToLower(words[0])
+		if seen[first] {
+			return discord.NewError("Alias Loop", fmt.Sprintf("`%s` would expand into itself.", name))
+		}
+		if depth >= MaxAliasDepth {
+			return discord.NewError("Alias Loop", fmt.Sprintf("`%s` goes through more than %d aliases.", name, MaxAliasDepth))
+		}
+		seen[first] = true
+
+		if gb.StrToCommand(first) != gb.Unrecognized {
+			break
+		}
+
+		exp, ok := g.Aliases[first]
+		if !ok {
+			return discord.NewError("Invalid Alias", fmt.Sprintf("`%s` is not a command or an alias.", words[0]))
+		}
+		next = exp
+	}
+
+	if _, ok := g.Aliases[name]; !ok && len(g.Aliases) >= MaxAliases {
+		return discord.NewError("Too Many Aliases", fmt.Sprintf("A server can have at most %d aliases.", MaxAliases))
+	}
+
+	if g.Aliases == nil {
+		g.Aliases = make(map[string]string)
+	}
+	g.Aliases[name] = expansion
+	g.Modified = time.Now()
+	return nil
+}
+
+// Delete an alias from a guild
+func (s *Store) RemoveAlias(guild gb.Snowflake, name string) error {
+	name = strings.ToLower(name)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	g, ok := s.Guilds[guild]
+	if !ok {
+		return discord.NewError("Alias Not Found", fmt.Sprintf("There is no alias `%s`.", name))
+	}
+
+	if _, ok := g.Aliases[name]; !ok {
+		return discord.NewError("Alias Not Found", fmt.Sprintf("There is no alias `%s`.", name))
+	}
+
+	delete(g.Aliases, name)
+	g.Modified = time.Now()
+	return nil
+}
+
+// Build the embed listing a guild's aliases
+func (s *Store) AliasEmbed(guild gb.Snowflake) *discordgo.MessageEmbed {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	e := discord.NewEmbed().
+		EmbedColor(gb.ConfigCol).
+		EmbedTitle("Aliases").
+		EmbedTimestamp(time.Now())
+
+	var names []string
+	if g, ok := s.Guilds[guild]; ok {
+		for n := range g.Aliases {
+			names = append(names, n)
+		}
+	}
+	sort.Strings(names)
+
+	var lines []string
+	for _, n := range names {
+		lines = append(lines, fmt.Sprintf("`%s` → `%s`", n, s.Guilds[guild].Aliases[n]))
+	}
+
+	if len(lines) == 0 {
+		return e.EmbedDescription("This server has no aliases.").MessageEmbed
+	}
+	return e.EmbedDescription(strings.Join(lines, "\n")).MessageEmbed
+}
+
+// Enum for alias commands
+type AliasCommand int
+
+const (
+	AError AliasCommand = iota
+	AAdd
+	ARemove
+	AList
+)
+
+func (c AliasCommand) String() string {
+	return [...]string{"Error", "Add", "Remove", "List"}[c]
+}
+
+// parse a string arg into an AliasCommand
+func ArgToAliasCommand(arg string) AliasCommand {
+	switch strings.ToLower(arg) {
+	case "add":
+		return AAdd
+	case "remove", "rm":
+		return ARemove
+	case "", "list":
+		return AList
+	default:
+		return AError
+	}
+}
+
+// Returns an interceptor that handles the alias command
+func AliasInterceptor(s *Store) gb.Interceptor {
+	return func(msg *gb.Message) error {
+
+		// skip if not an alias message
+		if msg.Command != gb.Alias {
+			return nil
+		}
+
+		// error if registry doesn't have a store
+		if s == nil {
+			return errors.New("cannot intercept with nil guild store")
+		}
+
+		msg.Response.ChannelId = msg.Source.ChannelId
+
+		guild := msg.Source.GuildId
+		if guild == 0 {
+			msg.Response.Embed = discord.NewError("Not in a Guild", "Aliases can only be used in a guild channel.").Embed()
+			return nil
+		}
+
+		var arg string
+		if len(msg.Args) > 0 {
+			arg = msg.Args[0]
+		}
+
+		cmd := ArgToAliasCommand(arg)
+		if (cmd == AAdd || cmd == ARemove) && !msg.Source.Admin {
+			msg.Response.Embed = discord.NewError("Not Allowed", "Only server admins can change aliases.").Embed()
+			return nil
+		}
+
+		switch cmd {
+		case AAdd:
+			var a struct {
+				Name    string `arg:"name"`
+				Command string `arg:"command"`
+			}
+			if err := args.Parse("&alias add", msg.Args[1:], &a); err != nil {
+				return embedError(msg, err)
+			}
+
+			before, _ := s.Alias(guild, a.Name)
+			if err := s.SetAlias(guild, a.Name, a.Command); err != nil {
+				return embedError(msg, err)
+			}
+			msg.AddChange("set alias", strings.ToLower(a.Name), before, a.Command)
+
+			msg.Response.Text = fmt.Sprintf("`%s` now runs `%s`.", strings.ToLower(a.Name), a.Command)
+			return save(s, msg)
+
+		case ARemove:
+			var a struct {
+				Name string `arg:"name"`
+			}
+			if err := args.Parse("&alias remove", msg.Args[1:], &a); err != nil {
+				return embedError(msg, err)
+			}
+
+			before, _ := s.Alias(guild, a.Name)
+			if err := s.RemoveAlias(guild, a.Name); err != nil {
+				return embedError(msg, err)
+			}
+			msg.AddChange("remove alias", strings.ToLower(a.Name), before, "")
+
+			msg.Response.Text = fmt.Sprintf("Removed alias `%s`.", strings.ToLower(a.Name))
+			return save(s, msg)
+
+		case AList:
+			msg.Response.Embed = s.AliasEmbed(guild)
+			return nil
+
+		case AError:
+			msg.Response.Embed = discord.NewError("Unrecognized Command", "Gobottas did not recognize your command.").Embed()
+			return nil
+		}
+
+		return errors.New("reached end of interceptor without returning from the switch")
+	}
+}
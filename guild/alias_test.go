@@ -0,0 +1,126 @@
+package guild
+
+import (
+	gb "github.com/ericebersohl/gobottas"
+	"github.com/ericebersohl/gobottas/discord"
+	"github.com/ericebersohl/gobottas/mock"
+	"os"
+	"testing"
+)
+
+/*
+Test Cases:
+- command, case-insensitive name, alias of an alias, replace, quoted command
+- shadows a command, built-in alias, spaces in name, empty expansion, unknown command, loop to itself, longer loop
+- an expansion that can't be tokenized
+*/
+func TestStore_SetAlias(t *testing.T) {
+	s := NewStore("")
+
+	tests := []struct {
+		name      string
+		alias     string
+		expansion string
+		wantErr   string // Name of the discord.Error, or empty for none
+	}{
+		{name: "command", alias: "topics", expansion: "dq list"},
+		{name: "case", alias: "Next", expansion: "DQ next"},
+		{name: "alias-of-alias", alias: "t", expansion: "topics"},
+		{name: "replace", alias: "topics", expansion: "q list"},
+		{name: "quoted", alias: "add", expansion: `"dq" add "a topic"`},
+
+		{name: "shadow", alias: "meme", expansion: "dq list", wantErr: "Invalid Alias"},
+		{name: "shadow-builtin-alias", alias: "Q", expansion: "dq list", wantErr: "Invalid Alias"},
+		{name: "space", alias: "my topics", expansion: "dq list", wantErr: "Invalid Alias"},
+		{name: "empty", alias: "nothing", expansion: " ", wantErr: "Invalid Alias"},
+		{name: "unknown", alias: "x", expansion: "foo bar", wantErr: "Invalid Alias"},
+		{name: "self", alias: "self", expansion: "self list", wantErr: "Alias Loop"},
+		{name: "loop", alias: "topics", expansion: "t", wantErr: "Alias Loop"},
+		{name: "unterminated", alias: "broken", expansion: `dq add "a topic`, wantErr: "Invalid Alias"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := s.SetAlias(1, test.alias, test.expansion)
+			if err != nil {
+				e, ok := err.(discord.Error)
+				if !ok || e.Name != test.wantErr {
+					t.Errorf("err = %v, want %q", err, test.wantErr)
+				}
+				return
+			}
+
+			if test.wantErr != "" {
+				t.Fatalf("no error, want %q", test.wantErr)
+			}
+
+			if exp, ok := s.Alias(1, test.alias); !ok || exp != test.expansion {
+				t.Errorf("alias = %q (ok = %t), want %q", exp, ok, test.expansion)
+			}
+		})
+	}
+
+	if _, ok := s.Alias(2, "topics"); ok {
+		t.Errorf("alias leaked into another guild")
+	}
+}
+
+/*
+Test Cases:
+- not alias msg, nil store, direct message, bad command
+- List: empty
+- Add: not admin, too few args, unquoted command, invalid, normal
+- Remove: not found, normal
+*/
+func TestAliasInterceptor(t *testing.T) {
+	_ = os.Mkdir("guild_test", 0755)
+	defer os.RemoveAll("guild_test")
+
+	s := NewStore("guild_test")
+
+	tests := []struct {
+		name      string
+		store     *Store
+		in        *gb.Message
+		wantErr   bool
+		wantEmbed bool
+		wantText  bool
+	}{
+		// General errors
+		{name: "not-alias", store: s, in: mock.NewMessage(gb.Config)},
+		{name: "nil-store", store: nil, in: mock.NewMessage(gb.Alias), wantErr: true},
+		{name: "direct", store: s, in: mock.NewMessage(gb.Alias, mock.WithArgs("list")), wantEmbed: true},
+		{name: "bad-command", store: s, in: mock.NewMessage(gb.Alias, mock.WithArgs("bad"), mock.WithGuild(1)), wantEmbed: true},
+
+		// List
+		{name: "list-empty", store: s, in: mock.NewMessage(gb.Alias, mock.WithGuild(1)), wantEmbed: true},
+
+		// Add
+		{name: "add-not-admin", store: s, in: mock.NewMessage(gb.Alias, mock.WithArgs("add", "topics", "dq list"), mock.WithGuild(1)), wantEmbed: true},
+		{name: "add-too-few", store: s, in: mock.NewMessage(gb.Alias, mock.WithArgs("add", "topics"), mock.WithGuild(1), mock.WithAdmin()), wantEmbed: true},
+		{name: "add-unquoted", store: s, in: mock.NewMessage(gb.Alias, mock.WithArgs("add", "topics", "dq", "list"), mock.WithGuild(1), mock.WithAdmin()), wantEmbed: true},
+		{name: "add-invalid", store: s, in: mock.NewMessage(gb.Alias, mock.WithArgs("add", "dq", "meme"), mock.WithGuild(1), mock.WithAdmin()), wantEmbed: true},
+		{name: "add", store: s, in: mock.NewMessage(gb.Alias, mock.WithArgs("ADD", "topics", "dq list"), mock.WithGuild(1), mock.WithAdmin()), wantText: true},
+
+		// Remove
+		{name: "rm-not-found", store: s, in: mock.NewMessage(gb.Alias, mock.WithArgs("rm", "nope"), mock.WithGuild(1), mock.WithAdmin()), wantEmbed: true},
+		{name: "rm", store: s, in: mock.NewMessage(gb.Alias, mock.WithArgs("rm", "topics"), mock.WithGuild(1), mock.WithAdmin()), wantText: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := AliasInterceptor(test.store)(test.in)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("err != wantErr (err = %v, wantErr = %v)", err, test.wantErr)
+			}
+
+			if (test.in.Response.Embed != nil) != test.wantEmbed {
+				t.Errorf("embed != wantEmbed (embed == nil: %t)", test.in.Response.Embed == nil)
+			}
+
+			if (test.in.Response.Text != "") != test.wantText {
+				t.Errorf("text != wantText (text = %q)", test.in.Response.Text)
+			}
+		})
+	}
+}
@@ -0,0 +1,398 @@
+package guild
+
+import (
+	"errors"
+	"fmt"
+	"github.com/bwmarrin/discordgo"
+	gb "github.com/ericebersohl/gobottas"
+	"github.com/ericebersohl/gobottas/args"
+	"github.com/ericebersohl/gobottas/discord"
+	"sort"
+	"strings"
+	"time"
+)
+
+// What the config command needs to know about the bot's own configuration; core.Registry implements it
+type Bot interface {
+	DefaultPrefix() string                                   // the prefix of guilds that haven't set one
+	Enabled(c gb.Command, src *gb.Source) bool               // whether a module's command works where a message was sent
+	ChannelGuild(channel gb.Snowflake) (gb.Snowflake, error) // the guild a channel is in
+}
+
+// Enum for config commands
+type Command int
+
+const (
+	CError Command = iota
+	CShow
+	CPrefix
+	CEnable
+	CDisable
+	CReset
+	COutput
+	CSet
+	CAudit
+)
+
+func (c Command) String() string {
+	return [...]string{"Error", "Show", "Prefix", "Enable", "Disable", "Reset", "Output", "Set", "Audit"}[c]
+}
+
+// parse a string arg into a config Command
+func ArgToCommand(arg string) Command {
+	switch strings.ToLower(arg) {
+	case "", "show":
+		return CShow
+	case "prefix":
+		return CPrefix
+	case "enable", "on":
+		return CEnable
+	case "disable", "off":
+		return CDisable
+	case "reset":
+		return CReset
+	case "output":
+		return COutput
+	case "set":
+		return CSet
+	case "audit":
+		return CAudit
+	default:
+		return CError
+	}
+}
+
+// Returns an interceptor that handles the config command.  Only guild admins can change settings; anyone can see them.
+func Interceptor(s *Store, bot Bot) gb.Interceptor {
+	return func(msg *gb.Message) error {
+
+		// skip if not a config message
+		if msg.Command != gb.Config {
+			return nil
+		}
+
+		// error if registry doesn't have a store
+		if s == nil {
+			return errors.New("cannot intercept with nil guild store")
+		}
+
+		// Config commands are sent back on the channel in which they are received
+		msg.Response.ChannelId = msg.Source.ChannelId
+
+		// settings belong to a guild, so there is nothing to configure in direct messages
+		guild := msg.Source.GuildId
+		if guild == 0 {
+			msg.Response.Embed = discord.NewError("Not in a Guild", "Settings can only be changed in a guild channel.").Embed()
+			return nil
+		}
+
+		var arg string
+		if len(msg.Args) > 0 {
+			arg = msg.Args[0]
+		}
+
+		cmd := ArgToCommand(arg)
+
+		// showing a setting is done with the same commands that change it, so they check for admins themselves
+		if cmd != CShow && cmd != CPrefix && cmd != CSet && cmd != CError && !msg.Source.Admin {
+			return notAllowed(msg)
+		}
+
+		switch cmd {
+		case CShow:
+			msg.Response.Embed = s.Embed(bot, msg.Source)
+			return nil
+
+		case CPrefix:
+			var a struct {
+				Prefix string `arg:"prefix,optional"`
+			}
+			if err := args.Parse("&config prefix", msg.Args[1:], &a); err != nil {
+				return embedError(msg, err)
+			}
+
+			// anyone can see the prefix
+			if a.Prefix == "" {
+				msg.Response.Text = fmt.Sprintf("Commands in this server start with `%s`.", prefixOf(s, bot, guild))
+				return nil
+			}
+
+			if !msg.Source.Admin {
+				return notAllowed(msg)
+			}
+
+			// reset goes back to the default
+			if a.Prefix == "reset" {
+				a.Prefix = ""
+			}
+
+			before := prefixOf(s, bot, guild)
+			if err := s.SetPrefix(guild, a.Prefix); err != nil {
+				return embedError(msg, err)
+			}
+
+			p := prefixOf(s, bot, guild)
+			msg.AddChange("set prefix", "prefix", before, p)
+			msg.Response.Text = fmt.Sprintf("Commands in this server now start with `%s`, e.g. `%sdq list`.", p, p)
+			return save(s, msg)
+
+		case CEnable, CDisable, CReset:
+			// without a channel the change applies to the whole guild
+			var a struct {
+				Module  string       `arg:"module"`
+				Channel gb.Snowflake `arg:"channel,optional"`
+			}
+			if err := args.Parse("&config "+strings.ToLower(cmd.String()), msg.Args[1:], &a); err != nil {
+				return embedError(msg, err)
+			}
+
+			var on *bool
+			if cmd != CReset {
+				b := cmd == CEnable
+				on = &b
+			}
+
+			before := s.moduleState(guild, a.Channel, a.Module)
+			if err := s.SetModule(guild, a.Channel, a.Module, on); err != nil {
+				return embedError(msg, err)
+			}
+
+			where := "this server"
+			if a.Channel != 0 {
+				where = fmt.Sprintf("<#%s>", a.Channel)
+			}
+			msg.AddChange(strings.ToLower(cmd.String())+" module", fmt.Sprintf("%s in %s", strings.ToLower(a.Module), where), before, s.moduleState(guild, a.Channel, a.Module))
+
+			state := map[Command]string{CEnable: "enabled", CDisable: "disabled", CReset: "back to the default"}[cmd]
+			msg.Response.Text = fmt.Sprintf("The %s module is %s in %s.", strings.ToLower(a.Module), state, where)
+			return save(s, msg)
+
+		case COutput:
+			// without a channel the module replies where it is used
+			var a struct {
+				Module  string       `arg:"module"`
+				Channel gb.Snowflake `arg:"channel,optional"`
+			}
+			if err := args.Parse("&config output", msg.Args[1:], &a); err != nil {
+				return embedError(msg, err)
+			}
+
+			if err := ownChannel(bot, msg, a.Channel); err != nil {
+				return embedError(msg, err)
+			}
+
+			before, _ := s.Output(guild, gb.Modules[strings.ToLower(a.Module)])
+			if err := s.SetOutput(guild, a.Module, a.Channel); err != nil {
+				return embedError(msg, err)
+			}
+			msg.AddChange("set output", strings.ToLower(a.Module), channelName(before), channelName(a.Channel))
+
+			if a.Channel == 0 {
+				msg.Response.Text = fmt.Sprintf("The %s module replies where it is used.", strings.ToLower(a.Module))
+			} else {
+				msg.Response.Text = fmt.Sprintf("The %s module replies in <#%s>.", strings.ToLower(a.Module), a.Channel)
+			}
+			return save(s, msg)
+
+		case CSet:
+			var a struct {
+				Option string `arg:"option"`
+				Value  string `arg:"value,optional"`
+			}
+			if err := args.Parse("&config set", msg.Args[1:], &a); err != nil {
+				return embedError(msg, err)
+			}
+
+			o, err := s.Option(a.Option)
+			if err != nil {
+				return embedError(msg, err)
+			}
+
+			// anyone can see an option
+			if a.Value == "" {
+				msg.Response.Text = fmt.Sprintf("`%s` is `%s` (%s).", o.Name, o.Get(guild), o.Usage)
+				return nil
+			}
+
+			if !msg.Source.Admin {
+				return notAllowed(msg)
+			}
+
+			// the module saves its own settings
+			before := o.Get(guild)
+			if err := o.Set(guild, a.Value); err != nil {
+				return embedError(msg, err)
+			}
+			msg.AddChange("set option", o.Name, before, o.Get(guild))
+
+			msg.Response.Text = fmt.Sprintf("`%s` is now `%s`.", o.Name, o.Get(guild))
+			return nil
+
+		case CAudit:
+			// without a channel changes are only kept in the audit file
+			var a struct {
+				Channel gb.Snowflake `arg:"channel,optional"`
+			}
+			if err := args.Parse("&config audit", msg.Args[1:], &a); err != nil {
+				return embedError(msg, err)
+			}
+
+			if err := ownChannel(bot, msg, a.Channel); err != nil {
+				return embedError(msg, err)
+			}
+
+			before, _ := s.AuditChannel(guild)
+			s.SetAuditChannel(guild, a.Channel)
+
+			if a.Channel == 0 {
+				msg.Response.Text = "Changes are no longer posted in an audit channel."
+			} else {
+				msg.Response.Text = fmt.Sprintf("Changes are posted in <#%s>.", a.Channel)
+			}
+			msg.AddChange("set audit channel", "audit channel", channelName(before), channelName(a.Channel))
+			return save(s, msg)
+
+		case CError:
+			msg.Response.Embed = discord.NewError("Unrecognized Command", "Gobottas did not recognize your command.").Embed()
+			return nil
+		}
+
+		return errors.New("reached end of interceptor without returning from the switch")
+	}
+}
+
+// Refuse a channel that isn't in the guild the message came from, so one guild's admins can't send the bot's messages
+// into another guild.  0, for no channel, is fine.
+func ownChannel(bot Bot, msg *gb.Message, channel gb.Snowflake) error {
+	if channel == 0 {
+		return nil
+	}
+
+	g, err := bot.ChannelGuild(channel)
+	if err != nil {
+		msg.Log.Warn("failed to look up a channel", "channel", channel, "err", err)
+	}
+	if err != nil || g != msg.Source.GuildId {
+		return discord.NewError("Unknown Channel", "That channel isn't in this server.")
+	}
+	return nil
+}
+
+// how a guild has set a module, in one channel if channel isn't 0, for the audit log
+func (s *Store) moduleState(guild, channel gb.Snowflake, module string) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	g, ok := s.Guilds[guild]
+	if !ok {
+		return "default"
+	}
+
+	var on bool
+	module = strings.ToLower(module)
+	if channel == 0 {
+		on, ok = g.Modules[module]
+	} else {
+		on, ok = g.Channels[channel][module]
+	}
+
+	switch {
+	case !ok:
+		return "default"
+	case on:
+		return "on"
+	}
+	return "off"
+}
+
+// a channel as discord shows it, or "" for none
+func channelName(ch gb.Snowflake) string {
+	if ch == 0 {
+		return ""
+	}
+	return fmt.Sprintf("<#%s>", ch)
+}
+
+// the prefix that commands in a guild start with
+func prefixOf(s *Store, bot Bot, guild gb.Snowflake) string {
+	if p := s.Prefix(guild); p != "" {
+		return p
+	}
+	return bot.DefaultPrefix()
+}
+
+// Build the embed that shows the settings of the guild and channel a message was sent in
+func (s *Store) Embed(bot Bot, src *gb.Source) *discordgo.MessageEmbed {
+	e := discord.NewEmbed().
+		EmbedColor(gb.ConfigCol).
+		EmbedTitle("Settings").
+		EmbedTimestamp(time.Now())
+
+	prefix := fmt.Sprintf("`%s`", prefixOf(s, bot, src.GuildId))
+	if s.Prefix(src.GuildId) == "" {
+		prefix += " (default)"
+	}
+	e = e.AddField("Prefix", prefix, false)
+
+	var names []string
+	for n := range gb.Modules {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	// modules as they apply in this channel
+	var modules []string
+	for _, n := range names {
+		state := "off"
+		if bot.Enabled(gb.Modules[n], src) {
+			state = "on"
+		}
+
+		line := fmt.Sprintf("%s: %s", n, state)
+		if ch, ok := s.Output(src.GuildId, gb.Modules[n]); ok {
+			line += fmt.Sprintf(", replies in <#%s>", ch)
+		}
+		modules = append(modules, line)
+	}
+	e = e.AddField("Modules in this channel", strings.Join(modules, "\n"), false)
+
+	if ch, ok := s.AuditChannel(src.GuildId); ok {
+		e = e.AddField("Audit channel", channelName(ch), false)
+	}
+
+	var options []string
+	for _, o := range s.Options() {
+		options = append(options, fmt.Sprintf("`%s`: %s (%s)", o.Name, o.Get(src.GuildId), o.Usage))
+	}
+	if len(options) > 0 {
+		e = e.AddField("Options", strings.Join(options, "\n"), false)
+	}
+
+	return e.MessageEmbed
+}
+
+func notAllowed(msg *gb.Message) error {
+	msg.Response.Embed = discord.NewError("Not Allowed", "Only server admins can change settings.").Embed()
+	return nil
+}
+
+// show discord errors to the user, pass anything else up
+func embedError(msg *gb.Message, err error) error {
+	if e, ok := err.(discord.Error); ok {
+		msg.Response.SetError(e.Embed())
+		return nil
+	}
+	return err
+}
+
+// persist the store after a change
+func save(s *Store, msg *gb.Message) error {
+	if err := s.Save(s.LocalPath); err != nil {
+		msg.Log.Error("failed to save guild settings", "path", s.LocalPath, "err", err)
+		msg.Response.SetError(discord.Error{
+			Name: "Config Save Error",
+			Desc: err.Error(),
+		}.Embed())
+	}
+	return nil
+}
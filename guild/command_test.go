@@ -0,0 +1,173 @@
+package guild
+
+import (
+	"errors"
+	gb "github.com/ericebersohl/gobottas"
+	"github.com/ericebersohl/gobottas/discord"
+	"github.com/ericebersohl/gobottas/mock"
+	"os"
+	"testing"
+)
+
+// a bot with every module on unless the guild says otherwise, that knows channels 5 to 8 are in guild 1 and 9 is in
+// guild 2
+type fakeBot struct {
+	s *Store
+}
+
+func (b fakeBot) ChannelGuild(channel gb.Snowflake) (gb.Snowflake, error) {
+	switch {
+	case channel >= 5 && channel <= 8:
+		return 1, nil
+	case channel == 9:
+		return 2, nil
+	default:
+		return 0, errors.New("unknown channel")
+	}
+}
+
+func (b fakeBot) DefaultPrefix() string {
+	return "&"
+}
+
+func (b fakeBot) Enabled(c gb.Command, src *gb.Source) bool {
+	if on, ok := b.s.ModuleEnabled(src.GuildId, src.ChannelId, c); ok {
+		return on
+	}
+	return true
+}
+
+/*
+Test Cases:
+- not config msg, nil store, direct message, bad command, not admin
+- Show: no args, show
+- Prefix: show, not admin, invalid, set, reset
+- Enable, Disable, Reset: unknown module, guild, channel, reset channel
+- Output: bad channel, another guild's channel, unknown channel, set, reset
+- Set: unknown option, show, not admin, bad value, normal
+- Audit: not admin, bad channel, another guild's channel, set, off
+- every change is noted for the audit log, with its before and after values
+*/
+func TestInterceptor(t *testing.T) {
+	_ = os.Mkdir("guild_test", 0755)
+	defer os.RemoveAll("guild_test")
+
+	s := NewStore("guild_test")
+	bot := fakeBot{s: s}
+
+	moderated := "off"
+	s.AddOption(&Option{
+		Name:  "meme.moderation",
+		Usage: "on or off",
+		Get:   func(gb.Snowflake) string { return moderated },
+		Set: func(_ gb.Snowflake, v string) error {
+			if v != "on" && v != "off" {
+				return discord.NewError("Invalid Value", "on or off")
+			}
+			moderated = v
+			return nil
+		},
+	})
+
+	in := func(admin bool, a ...string) *gb.Message {
+		m := mock.NewMessage(gb.Config, mock.WithArgs(a...), mock.WithGuild(1))
+		m.Source.ChannelId = 5
+		m.Source.Admin = admin
+		return m
+	}
+
+	tests := []struct {
+		name      string
+		store     *Store
+		in        *gb.Message
+		wantErr   bool
+		wantEmbed bool
+		wantText  bool
+		check     func() bool // state after the command
+		want      *gb.Change  // the change noted for the audit log
+	}{
+		// General errors
+		{name: "not-config", store: s, in: mock.NewMessage(gb.Meme)},
+		{name: "nil-store", store: nil, in: mock.NewMessage(gb.Config), wantErr: true},
+		{name: "direct", store: s, in: mock.NewMessage(gb.Config, mock.WithArgs("prefix", "!"), mock.WithAdmin()), wantEmbed: true},
+		{name: "bad-command", store: s, in: in(true, "bad"), wantEmbed: true},
+		{name: "not-admin", store: s, in: in(false, "disable", "meme"), wantEmbed: true, check: func() bool { return bot.Enabled(gb.Meme, &gb.Source{GuildId: 1}) }},
+
+		// Show
+		{name: "no-args", store: s, in: in(false), wantEmbed: true},
+		{name: "show", store: s, in: in(false, "show"), wantEmbed: true},
+
+		// Prefix
+		{name: "prefix-show", store: s, in: in(false, "prefix"), wantText: true},
+		{name: "prefix-not-admin", store: s, in: in(false, "prefix", "!"), wantEmbed: true},
+		{name: "prefix-invalid", store: s, in: in(true, "prefix", "a b"), wantEmbed: true},
+		{name: "prefix-set", store: s, in: in(true, "prefix", "gb!"), wantText: true, check: func() bool { return s.Prefix(1) == "gb!" }, want: &gb.Change{Action: "set prefix", Target: "prefix", Before: "&", After: "gb!"}},
+		{name: "prefix-reset", store: s, in: in(true, "prefix", "reset"), wantText: true, check: func() bool { return s.Prefix(1) == "" }, want: &gb.Change{Action: "set prefix", Target: "prefix", Before: "gb!", After: "&"}},
+
+		// Modules
+		{name: "enable-unknown", store: s, in: in(true, "enable", "music"), wantEmbed: true},
+		{name: "disable-guild", store: s, in: in(true, "disable", "Meme"), wantText: true, check: func() bool { return !bot.Enabled(gb.Meme, &gb.Source{GuildId: 1, ChannelId: 6}) }, want: &gb.Change{Action: "disable module", Target: "meme in this server", Before: "default", After: "off"}},
+		{name: "enable-channel", store: s, in: in(true, "enable", "meme", "<#5>"), wantText: true, check: func() bool {
+			return bot.Enabled(gb.Meme, &gb.Source{GuildId: 1, ChannelId: 5}) && !bot.Enabled(gb.Meme, &gb.Source{GuildId: 1, ChannelId: 6})
+		}, want: &gb.Change{Action: "enable module", Target: "meme in <#5>", Before: "default", After: "on"}},
+		{name: "reset-channel", store: s, in: in(true, "reset", "meme", "5"), wantText: true, check: func() bool { return !bot.Enabled(gb.Meme, &gb.Source{GuildId: 1, ChannelId: 5}) }, want: &gb.Change{Action: "reset module", Target: "meme in <#5>", Before: "on", After: "default"}},
+
+		// Output
+		{name: "output-bad", store: s, in: in(true, "output", "queue", "#general"), wantEmbed: true},
+		{name: "output-other-guild", store: s, in: in(true, "output", "queue", "<#9>"), wantEmbed: true, check: func() bool { _, ok := s.Output(1, gb.Queue); return !ok }},
+		{name: "output-unknown", store: s, in: in(true, "output", "queue", "<#10>"), wantEmbed: true, check: func() bool { _, ok := s.Output(1, gb.Queue); return !ok }},
+		{name: "output-set", store: s, in: in(true, "output", "queue", "<#7>"), wantText: true, check: func() bool { ch, ok := s.Output(1, gb.Queue); return ok && ch == 7 }, want: &gb.Change{Action: "set output", Target: "queue", After: "<#7>"}},
+		{name: "output-reset", store: s, in: in(true, "output", "queue"), wantText: true, check: func() bool { _, ok := s.Output(1, gb.Queue); return !ok }, want: &gb.Change{Action: "set output", Target: "queue", Before: "<#7>"}},
+
+		// Options
+		{name: "set-unknown", store: s, in: in(true, "set", "meme.volume", "11"), wantEmbed: true},
+		{name: "set-show", store: s, in: in(false, "set", "meme.moderation"), wantText: true},
+		{name: "set-not-admin", store: s, in: in(false, "set", "meme.moderation", "on"), wantEmbed: true, check: func() bool { return moderated == "off" }},
+		{name: "set-bad", store: s, in: in(true, "set", "meme.moderation", "maybe"), wantEmbed: true, check: func() bool { return moderated == "off" }},
+		{name: "set", store: s, in: in(true, "set", "meme.moderation", "on"), wantText: true, check: func() bool { return moderated == "on" }, want: &gb.Change{Action: "set option", Target: "meme.moderation", Before: "off", After: "on"}},
+
+		// Audit
+		{name: "audit-not-admin", store: s, in: in(false, "audit", "<#8>"), wantEmbed: true, check: func() bool { _, ok := s.AuditChannel(1); return !ok }},
+		{name: "audit-bad", store: s, in: in(true, "audit", "#mods"), wantEmbed: true},
+		{name: "audit-other-guild", store: s, in: in(true, "audit", "<#9>"), wantEmbed: true, check: func() bool { _, ok := s.AuditChannel(1); return !ok }},
+		{name: "audit-set", store: s, in: in(true, "audit", "<#8>"), wantText: true, check: func() bool { ch, ok := s.AuditChannel(1); return ok && ch == 8 }, want: &gb.Change{Action: "set audit channel", Target: "audit channel", After: "<#8>"}},
+		{name: "audit-off", store: s, in: in(true, "audit"), wantText: true, check: func() bool { _, ok := s.AuditChannel(1); return !ok }, want: &gb.Change{Action: "set audit channel", Target: "audit channel", Before: "<#8>"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := Interceptor(test.store, bot)(test.in)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("err != wantErr (err = %v, wantErr = %v)", err, test.wantErr)
+			}
+
+			if (test.in.Response.Embed != nil) != test.wantEmbed {
+				t.Errorf("embed != wantEmbed (embed == nil: %t)", test.in.Response.Embed == nil)
+			}
+
+			if (test.in.Response.Text != "") != test.wantText {
+				t.Errorf("text != wantText (text = %q)", test.in.Response.Text)
+			}
+
+			if test.check != nil && !test.check() {
+				t.Errorf("settings not changed as expected")
+			}
+
+			if test.want != nil && (len(test.in.Changes) != 1 || test.in.Changes[0] != *test.want) {
+				t.Errorf("changes = %+v, want %+v", test.in.Changes, *test.want)
+			}
+			if test.want == nil && len(test.in.Changes) != 0 {
+				t.Errorf("unexpected changes %+v", test.in.Changes)
+			}
+		})
+	}
+
+	// changes are saved
+	l := NewStore("")
+	if err := l.Load("guild_test"); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if on, ok := l.ModuleEnabled(1, 6, gb.Meme); !ok || on {
+		t.Errorf("module setting not saved")
+	}
+}
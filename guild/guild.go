@@ -0,0 +1,175 @@
+package guild
+
+import (
+	"encoding/json"
+	"fmt"
+	gb "github.com/ericebersohl/gobottas"
+	"github.com/ericebersohl/gobottas/discord"
+	"github.com/ericebersohl/gobottas/metrics"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// The longest prefix a guild can set, in characters
+const MaxPrefixLength = 16
+
+// Settings that guild admins can change for their guild
+type Settings struct {
+	Prefix   string                           `json:"prefix,omitempty"`   // command prefix; empty for the bot's default
+	Aliases  map[string]string                `json:"aliases,omitempty"`  // custom shortcuts, from lower-case name to what they run
+	Modules  map[string]bool                  `json:"modules,omitempty"`  // modules turned on or off in the whole guild
+	Channels map[gb.Snowflake]map[string]bool `json:"channels,omitempty"` // modules turned on or off in single channels
+	Output   map[string]gb.Snowflake          `json:"output,omitempty"`   // channels that modules reply in
+	Audit    gb.Snowflake                     `json:"audit,omitempty"`    // channel that changes are posted in; 0 for none
+	Modified time.Time                        `json:"modified"`
+}
+
+// The settings of every guild, persisted in guild.json
+type Store struct {
+	Guilds    map[gb.Snowflake]*Settings `json:"guilds"`
+	LocalPath string                     `json:"path"` // path to local backup
+
+	// module options that the config command can change, by name; registered at startup, not saved
+	options map[string]*Option
+
+	// every incoming message reads the store, while the config command writes it
+	mu sync.RWMutex
+}
+
+func NewStore(localPath string) *Store {
+	s := Store{
+		Guilds:    make(map[gb.Snowflake]*Settings),
+		LocalPath: localPath,
+	}
+	return &s
+}
+
+// Get a guild's prefix, or "" if it uses the default
+func (s *Store) Prefix(guild gb.Snowflake) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if g, ok := s.Guilds[guild]; ok {
+		return g.Prefix
+	}
+	return ""
+}
+
+// Set a guild's prefix; "" goes back to the default
+func (s *Store) SetPrefix(guild gb.Snowflake, prefix string) error {
+	if err := ValidatePrefix(prefix); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	g := s.settings(guild)
+	g.Prefix = prefix
+	g.Modified = time.Now()
+	return nil
+}
+
+// Get the channel a guild's changes are posted in, if it has chosen one
+func (s *Store) AuditChannel(guild gb.Snowflake) (gb.Snowflake, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if g, ok := s.Guilds[guild]; ok && g.Audit != 0 {
+		return g.Audit, true
+	}
+	return 0, false
+}
+
+// Post a guild's changes in a channel; 0 stops posting them
+func (s *Store) SetAuditChannel(guild, channel gb.Snowflake) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	g := s.settings(guild)
+	g.Audit = channel
+	g.Modified = time.Now()
+}
+
+// get a guild's settings, creating them if needed; the caller must hold the write lock
+func (s *Store) settings(guild gb.Snowflake) *Settings {
+	if s.Guilds == nil {
+		s.Guilds = make(map[gb.Snowflake]*Settings)
+	}
+
+	g, ok := s.Guilds[guild]
+	if !ok {
+		g = &Settings{}
+		s.Guilds[guild] = g
+	}
+	return g
+}
+
+// Check that a prefix can be told apart from normal chat and typed on any keyboard
+func ValidatePrefix(p string) error {
+	if p == "" {
+		return nil
+	}
+
+	if utf8.RuneCountInString(p) > MaxPrefixLength {
+		return discord.NewError("Invalid Prefix", fmt.Sprintf("A prefix can be at most %d characters.", MaxPrefixLength))
+	}
+
+	if strings.IndexFunc(p, unicode.IsSpace) >= 0 {
+		return discord.NewError("Invalid Prefix", "A prefix can't contain spaces.")
+	}
+
+	// mentions always work, so a prefix that looks like one would be confusing
+	if strings.HasPrefix(p, "<@") {
+		return discord.NewError("Invalid Prefix", "A prefix can't be a mention.")
+	}
+
+	return nil
+}
+
+// Save the store to the guild.json file in the specified path
+func (s *Store) Save(path string) error {
+	if s == nil {
+		return fmt.Errorf("cannot save a nil guild store")
+	}
+
+	s.mu.RLock()
+	data, err := json.Marshal(s)
+	s.mu.RUnlock()
+	if err != nil {
+		metrics.PersistenceErrors.Inc("guild", "save")
+		return err
+	}
+
+	err = ioutil.WriteFile(fmt.Sprintf("%s/guild.json", path), data, 0644)
+	if err != nil {
+		metrics.PersistenceErrors.Inc("guild", "save")
+		return err
+	}
+
+	return nil
+}
+
+// Load a store from the guild.json file in the specified path
+func (s *Store) Load(path string) error {
+	data, err := ioutil.ReadFile(fmt.Sprintf("%s/guild.json", path))
+	if err != nil {
+		metrics.PersistenceErrors.Inc("guild", "load")
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err = json.Unmarshal(data, s)
+	if err != nil {
+		metrics.PersistenceErrors.Inc("guild", "load")
+		return err
+	}
+
+	return nil
+}
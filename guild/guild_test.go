@@ -0,0 +1,74 @@
+package guild
+
+import (
+	"os"
+	"testing"
+)
+
+/*
+Test Cases:
+- default, multi-character, unicode, reset
+- too long, spaces, mention
+*/
+func TestStore_SetPrefix(t *testing.T) {
+	s := NewStore("guild_test")
+
+	tests := []struct {
+		name    string
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{name: "default", in: "", want: ""},
+		{name: "multi", in: "gb!", want: "gb!"},
+		{name: "unicode", in: "→", want: "→"},
+		{name: "reset", in: "", want: ""},
+		{name: "too-long", in: "abcdefghijklmnopq", want: "", wantErr: true},
+		{name: "space", in: "g b", want: "", wantErr: true},
+		{name: "mention", in: "<@1>", want: "", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := s.SetPrefix(1, test.in)
+			if (err != nil) != test.wantErr {
+				t.Errorf("err != wantErr (err = %v, wantErr = %v)", err, test.wantErr)
+			}
+
+			if got := s.Prefix(1); got != test.want {
+				t.Errorf("prefix = %q, want %q", got, test.want)
+			}
+		})
+	}
+
+	if got := s.Prefix(2); got != "" {
+		t.Errorf("unset guild has prefix %q", got)
+	}
+}
+
+/*
+Test Cases:
+- saved prefixes load back
+*/
+func TestStore_SaveLoad(t *testing.T) {
+	_ = os.Mkdir("guild_test", 0755)
+	defer os.RemoveAll("guild_test")
+
+	s := NewStore("guild_test")
+	if err := s.SetPrefix(1, "!"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := s.Save("guild_test"); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	l := NewStore("")
+	if err := l.Load("guild_test"); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	if got := l.Prefix(1); got != "!" {
+		t.Errorf("prefix = %q, want !", got)
+	}
+}
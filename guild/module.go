@@ -0,0 +1,193 @@
+package guild
+
+import (
+	"fmt"
+	gb "github.com/ericebersohl/gobottas"
+	"github.com/ericebersohl/gobottas/discord"
+	"sort"
+	"strings"
+	"time"
+)
+
+// A module setting that guild admins can change with `&config set`.  The module keeps the value, so options are
+// registered at startup rather than saved with the store.
+type Option struct {
+	Name  string                                       // module.option, e.g. meme.moderation
+	Usage string                                       // what values mean, e.g. "on or off"
+	Get   func(guild gb.Snowflake) string              // the current value in a guild
+	Set   func(guild gb.Snowflake, value string) error // change the value in a guild, returning a discord.Error for bad values
+}
+
+// Make an option available to the config command
+func (s *Store) AddOption(o *Option) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.options == nil {
+		s.options = make(map[string]*Option)
+	}
+	s.options[o.Name] = o
+}
+
+// Look up an option by name
+func (s *Store) Option(name string) (*Option, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if o, ok := s.options[strings.ToLower(name)]; ok {
+		return o, nil
+	}
+	return nil, discord.NewError("Unknown Option", fmt.Sprintf("There is no option `%s`; see `&config show` for the options.", name))
+}
+
+// All the options, sorted by name
+func (s *Store) Options() []*Option {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var opts []*Option
+	for _, o := range s.options {
+		opts = append(opts, o)
+	}
+	sort.Slice(opts, func(i, j int) bool { return opts[i].Name < opts[j].Name })
+	return opts
+}
+
+// Get the name of a module from user input
+func ModuleName(name string) (string, error) {
+	name = strings.ToLower(name)
+	if _, ok := gb.Modules[name]; ok {
+		return name, nil
+	}
+
+	var names []string
+	for n := range gb.Modules {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return "", discord.NewError("Unknown Module", fmt.Sprintf("There is no module `%s`; choose from %s.", name, strings.Join(names, ", ")))
+}
+
+// the name of a module's command, or "" for commands outside modules
+func moduleOf(c gb.Command) string {
+	for n, m := range gb.Modules {
+		if m == c {
+			return n
+		}
+	}
+	return ""
+}
+
+// Turn a module on or off in a guild, or in one channel if channel isn't 0.  A nil on removes the setting, so the
+// channel follows the guild, and the guild follows the bot's config.
+func (s *Store) SetModule(guild, channel gb.Snowflake, module string, on *bool) error {
+	module, err := ModuleName(module)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	g := s.settings(guild)
+	g.Modified = time.Now()
+
+	if channel == 0 {
+		if on == nil {
+			delete(g.Modules, module)
+			return nil
+		}
+
+		if g.Modules == nil {
+			g.Modules = make(map[string]bool)
+		}
+		g.Modules[module] = *on
+		return nil
+	}
+
+	if on == nil {
+		delete(g.Channels[channel], module)
+		if len(g.Channels[channel]) == 0 {
+			delete(g.Channels, channel)
+		}
+		return nil
+	}
+
+	if g.Channels == nil {
+		g.Channels = make(map[gb.Snowflake]map[string]bool)
+	}
+	if g.Channels[channel] == nil {
+		g.Channels[channel] = make(map[string]bool)
+	}
+	g.Channels[channel][module] = *on
+	return nil
+}
+
+// Report whether a guild has turned a module's command on or off in a channel.  ok is false if the guild hasn't
+// chosen, and the bot's config decides.
+func (s *Store) ModuleEnabled(guild, channel gb.Snowflake, c gb.Command) (on, ok bool) {
+	module := moduleOf(c)
+	if module == "" {
+		return false, false
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	g, found := s.Guilds[guild]
+	if !found {
+		return false, false
+	}
+
+	// a channel's setting beats the guild's
+	if on, ok := g.Channels[channel][module]; ok {
+		return on, true
+	}
+
+	on, ok = g.Modules[module]
+	return on, ok
+}
+
+// Send a module's replies to a channel in the guild; 0 sends them back where the command came from
+func (s *Store) SetOutput(guild gb.Snowflake, module string, channel gb.Snowflake) error {
+	module, err := ModuleName(module)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	g := s.settings(guild)
+	g.Modified = time.Now()
+
+	if channel == 0 {
+		delete(g.Output, module)
+		return nil
+	}
+
+	if g.Output == nil {
+		g.Output = make(map[string]gb.Snowflake)
+	}
+	g.Output[module] = channel
+	return nil
+}
+
+// Get the channel a module's command replies in, if the guild has chosen one
+func (s *Store) Output(guild gb.Snowflake, c gb.Command) (gb.Snowflake, bool) {
+	module := moduleOf(c)
+	if module == "" {
+		return 0, false
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	g, ok := s.Guilds[guild]
+	if !ok {
+		return 0, false
+	}
+
+	ch, ok := g.Output[module]
+	return ch, ok
+}
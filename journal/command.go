@@ -0,0 +1,102 @@
+package journal
+
+import (
+	"errors"
+	"fmt"
+	"github.com/bwmarrin/discordgo"
+	gb "github.com/ericebersohl/gobottas"
+	"github.com/ericebersohl/gobottas/args"
+	"github.com/ericebersohl/gobottas/discord"
+	"time"
+)
+
+// Entries shown by the journal command
+const listed = 10
+
+// Returns the interceptor for the undo, redo and journal commands
+func Interceptor(j *Journal) gb.Interceptor {
+	return func(msg *gb.Message) error {
+		if msg.Command != gb.Undo && msg.Command != gb.Redo && msg.Command != gb.Journal {
+			return nil
+		}
+
+		if j == nil {
+			return errors.New("cannot intercept without a journal")
+		}
+
+		msg.Response.ChannelId = msg.Source.ChannelId
+
+		if msg.Command == gb.Journal {
+			if err := args.Parse("&journal", msg.Args, &struct{}{}); err != nil {
+				return embedError(msg, err)
+			}
+
+			msg.Response.Embed = j.embed(msg.Source.GuildId)
+			return nil
+		}
+
+		// without an id, the author's own last change
+		var a struct {
+			Id int `arg:"id,optional,min=1"`
+		}
+		if msg.Command == gb.Undo {
+			if err := args.Parse("&undo", msg.Args, &a); err != nil {
+				return embedError(msg, err)
+			}
+
+			e, err := j.Undo(msg.Source, a.Id)
+			if err != nil {
+				return embedError(msg, err)
+			}
+
+			msg.Response.Text = fmt.Sprintf("Undid change %d: %s.", e.Id, e.Summary)
+			msg.AddChange("undo", fmt.Sprintf("change %d", e.Id), e.Summary, "undone")
+			return nil
+		}
+
+		if err := args.Parse("&redo", msg.Args, &a); err != nil {
+			return embedError(msg, err)
+		}
+
+		e, err := j.Redo(msg.Source, a.Id)
+		if err != nil {
+			return embedError(msg, err)
+		}
+
+		msg.Response.Text = fmt.Sprintf("Redid change %d: %s.", e.Id, e.Summary)
+		msg.AddChange("redo", fmt.Sprintf("change %d", e.Id), "undone", e.Summary)
+		return nil
+	}
+}
+
+// list the guild's recent changes, newest first
+func (j *Journal) embed(guild gb.Snowflake) *discordgo.MessageEmbed {
+	e := discord.NewEmbed().
+		EmbedColor(gb.ConfigCol).
+		EmbedTitle("Recent Changes").
+		EmbedTimestamp(time.Now())
+
+	recent := j.Recent(guild, listed)
+	for _, entry := range recent {
+		name := fmt.Sprintf("%d: %s", entry.Id, entry.Summary)
+		if entry.Undone {
+			name += " (undone)"
+		}
+		e = e.AddField(name, fmt.Sprintf("by %s, %s", entry.Username, entry.Time.Format("Jan 2 15:04")), false)
+	}
+
+	if len(recent) == 0 {
+		e = e.EmbedDescription("Nothing has changed yet.")
+	}
+
+	return e.MessageEmbed
+}
+
+// show discord errors to the user, pass anything else up
+func embedError(msg *gb.Message, err error) error {
+	if e, ok := err.(discord.Error); ok {
+		msg.Response.SetError(e.Embed())
+		return nil
+	}
+	return err
+}
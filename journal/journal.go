@@ -0,0 +1,259 @@
+// Package journal records changes to the bot's stores, such as the discussion queue and the meme stash, so that they
+// can be undone and redone.  Each store records its own changes, with whatever it needs to reverse them, and undoes
+// and redoes them itself when asked.
+package journal
+
+import (
+	"encoding/json"
+	"fmt"
+	gb "github.com/ericebersohl/gobottas"
+	"github.com/ericebersohl/gobottas/discord"
+	"github.com/ericebersohl/gobottas/metrics"
+	"io/ioutil"
+	"sync"
+	"time"
+)
+
+// Entries kept before the oldest are dropped, unless the journal is given another limit
+const DefaultMaxEntries = 500
+
+// One change to a store
+type Entry struct {
+	Id       int             `json:"id"`
+	Time     time.Time       `json:"time"`
+	GuildId  gb.Snowflake    `json:"guild"` // where the change was made; it's only listed and undone there
+	User     gb.Snowflake    `json:"user"`
+	Username string          `json:"username"`
+	Store    string          `json:"store"`   // name the store was registered with, e.g. "queue"
+	Op       string          `json:"op"`      // e.g. "add" or "bump"
+	Summary  string          `json:"summary"` // what changed, for people, e.g. `bumped topic "x"`
+	Data     json.RawMessage `json:"data"`    // what the store needs to undo and redo the change
+	Undone   bool            `json:"undone"`
+}
+
+// Something whose changes are journaled.  Undo and Redo persist the store if it persists itself.
+type Store interface {
+	Undo(e *Entry) error
+	Redo(e *Entry) error
+}
+
+// The changes made to the stores, oldest first
+type Journal struct {
+	mu      sync.Mutex
+	Entries []*Entry `json:"entries"`
+	NextId  int      `json:"next_id"`
+
+	LocalPath string           `json:"-"` // directory the journal is saved in
+	max       int              // entries kept
+	stores    map[string]Store // by name
+}
+
+type Opt func(*Journal)
+
+func New(localPath string, opts ...Opt) *Journal {
+	j := Journal{
+		NextId:    1,
+		LocalPath: localPath,
+		max:       DefaultMaxEntries,
+		stores:    make(map[string]Store),
+	}
+
+	for _, o := range opts {
+		o(&j)
+	}
+
+	return &j
+}
+
+// keep at most n entries
+func WithMaxEntries(n int) Opt {
+	return func(j *Journal) {
+		j.max = n
+	}
+}
+
+// Journal changes to s under the name; s records them with the journal itself
+func (j *Journal) Register(name string, s Store) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.stores[name] = s
+}
+
+// Record a change made by the author of src and save the journal.  data is encoded as JSON and given back to the
+// store to undo or redo the change.  A nil journal records nothing, so stores can be used without one.
+func (j *Journal) Record(src *gb.Source, store, op, summary string, data interface{}) error {
+	if j == nil {
+		return nil
+	}
+
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	e := Entry{
+		Time:    time.Now(),
+		Store:   store,
+		Op:      op,
+		Summary: summary,
+		Data:    raw,
+	}
+	if src != nil {
+		e.GuildId = src.GuildId
+		e.User = src.AuthorId
+		e.Username = src.Username
+	}
+
+	j.mu.Lock()
+	e.Id = j.NextId
+	j.NextId++
+	j.Entries = append(j.Entries, &e)
+	if len(j.Entries) > j.max {
+		j.Entries = append([]*Entry(nil), j.Entries[len(j.Entries)-j.max:]...)
+	}
+	j.mu.Unlock()
+
+	return j.Save(j.LocalPath)
+}
+
+// Undo a change made in the source's guild: the entry with the id, or the last change by the user that hasn't been
+// undone if id is 0.  Only moderators can undo other people's changes.
+func (j *Journal) Undo(src *gb.Source, id int) (*Entry, error) {
+	return j.apply(src, id, false)
+}
+
+// Redo a change that was undone: the entry with the id, or the oldest of the user's undone changes if id is 0
+func (j *Journal) Redo(src *gb.Source, id int) (*Entry, error) {
+	return j.apply(src, id, true)
+}
+
+func (j *Journal) apply(src *gb.Source, id int, redo bool) (*Entry, error) {
+	j.mu.Lock()
+	e, err := j.find(src, id, redo)
+	if err != nil {
+		j.mu.Unlock()
+		return nil, err
+	}
+
+	s := j.stores[e.Store]
+	if s == nil {
+		j.mu.Unlock()
+		return nil, fmt.Errorf("journal: no store named %q", e.Store)
+	}
+
+	// mark the entry first, so that it isn't undone twice at once
+	e.Undone = !redo
+	j.mu.Unlock()
+
+	// the journal isn't held while the store changes, since stores hold their own locks when they record changes
+	if redo {
+		err = s.Redo(e)
+	} else {
+		err = s.Undo(e)
+	}
+	if err != nil {
+		j.mu.Lock()
+		e.Undone = redo
+		j.mu.Unlock()
+		return nil, err
+	}
+
+	return e, j.Save(j.LocalPath)
+}
+
+// find the entry to undo or redo; the caller holds the lock
+func (j *Journal) find(src *gb.Source, id int, redo bool) (*Entry, error) {
+	verb, nothing := "undo", "Nothing to Undo"
+	if redo {
+		verb, nothing = "redo", "Nothing to Redo"
+	}
+
+	// undo takes back the user's changes newest first, and redo puts them back in the order they were made
+	if id == 0 {
+		for n := range j.Entries {
+			i := len(j.Entries) - 1 - n
+			if redo {
+				i = n
+			}
+
+			e := j.Entries[i]
+			if e.GuildId == src.GuildId && e.User == src.AuthorId && e.Undone == redo {
+				return e, nil
+			}
+		}
+		return nil, discord.NewError(nothing, fmt.Sprintf("You have no recent changes to %s.", verb))
+	}
+
+	// another guild's changes aren't listed there, so they're treated as if they weren't in the journal
+	for _, e := range j.Entries {
+		if e.Id != id || e.GuildId != src.GuildId {
+			continue
+		}
+
+		if e.User != src.AuthorId && !src.Moderator {
+			return nil, discord.NewError("Not Allowed", fmt.Sprintf("Only moderators can %s other people's changes.", verb))
+		}
+		if e.Undone != redo {
+			if redo {
+				return nil, discord.NewError("Not Undone", fmt.Sprintf("Change %d hasn't been undone.", id))
+			}
+			return nil, discord.NewError("Already Undone", fmt.Sprintf("Change %d has already been undone.", id))
+		}
+		return e, nil
+	}
+
+	return nil, discord.NewError("Change Not Found", fmt.Sprintf("There is no change %d in the journal.", id))
+}
+
+// The last n entries made in the guild, newest first
+func (j *Journal) Recent(guild gb.Snowflake, n int) []Entry {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	var recent []Entry
+	for i := len(j.Entries) - 1; i >= 0 && len(recent) < n; i-- {
+		if j.Entries[i].GuildId == guild {
+			recent = append(recent, *j.Entries[i])
+		}
+	}
+	return recent
+}
+
+// Save the journal to the journal.json file in the specified path
+func (j *Journal) Save(path string) error {
+	j.mu.Lock()
+	data, err := json.Marshal(j)
+	j.mu.Unlock()
+	if err != nil {
+		metrics.PersistenceErrors.Inc("journal", "save")
+		return err
+	}
+
+	err = ioutil.WriteFile(fmt.Sprintf("%s/journal.json", path), data, 0644)
+	if err != nil {
+		metrics.PersistenceErrors.Inc("journal", "save")
+		return err
+	}
+
+	return nil
+}
+
+// Load the journal from the journal.json file in the specified path
+func (j *Journal) Load(path string) error {
+	data, err := ioutil.ReadFile(fmt.Sprintf("%s/journal.json", path))
+	if err != nil {
+		metrics.PersistenceErrors.Inc("journal", "load")
+		return err
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	err = json.Unmarshal(data, j)
+	if err != nil {
+		metrics.PersistenceErrors.Inc("journal", "load")
+		return err
+	}
+
+	return nil
+}
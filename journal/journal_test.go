@@ -0,0 +1,238 @@
+package journal
+
+import (
+	"encoding/json"
+	gb "github.com/ericebersohl/gobottas"
+	"github.com/ericebersohl/gobottas/discord"
+	"github.com/ericebersohl/gobottas/mock"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+// a store of one number, which changes add to
+type counter struct {
+	n int
+}
+
+func (c *counter) Undo(e *Entry) error {
+	var d int
+	if err := json.Unmarshal(e.Data, &d); err != nil {
+		return err
+	}
+	c.n -= d
+	return nil
+}
+
+func (c *counter) Redo(e *Entry) error {
+	var d int
+	if err := json.Unmarshal(e.Data, &d); err != nil {
+		return err
+	}
+	c.n += d
+	return nil
+}
+
+/*
+Test Cases:
+- undo without an id undoes the author's last change, then the one before
+- nothing left to undo
+- redo without an id redoes the author's last undone change
+- other users' changes can't be undone, except by moderators
+- an undone change can't be undone again, a change that wasn't undone can't be redone
+- unknown ids
+- changes made in another guild can't be undone or redone there, even by its moderators
+*/
+func TestJournal_Undo(t *testing.T) {
+	dir, err := ioutil.TempDir("", "journal_undo")
+	if err != nil {
+		t.Fatalf("temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	c := &counter{}
+	j := New(dir)
+	j.Register("counter", c)
+
+	alice := &gb.Source{GuildId: 1, AuthorId: 1, Username: "alice"}
+	bob := &gb.Source{GuildId: 1, AuthorId: 2, Username: "bob"}
+	mod := &gb.Source{GuildId: 1, AuthorId: 3, Username: "mod", Moderator: true}
+	aliceElsewhere := &gb.Source{GuildId: 2, AuthorId: 1, Username: "alice"}
+	otherMod := &gb.Source{GuildId: 2, AuthorId: 4, Username: "other", Moderator: true}
+
+	for i, src := range []*gb.Source{alice, alice, bob} {
+		d := []int{1, 10, 100}[i]
+		c.n += d
+		if err := j.Record(src, "counter", "add", "added", d); err != nil {
+			t.Fatalf("record: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		redo    bool
+		src     *gb.Source
+		id      int
+		wantId  int
+		wantErr string
+		wantN   int
+	}{
+		{name: "other-guild-last", src: aliceElsewhere, wantErr: "Nothing to Undo", wantN: 111},
+		{name: "other-guild-moderator", src: otherMod, id: 3, wantErr: "Change Not Found", wantN: 111},
+		{name: "last", src: alice, wantId: 2, wantN: 101},
+		{name: "before-last", src: alice, wantId: 1, wantN: 100},
+		{name: "nothing", src: alice, wantErr: "Nothing to Undo", wantN: 100},
+		{name: "redo-last", redo: true, src: alice, wantId: 1, wantN: 101},
+		{name: "other-user", src: alice, id: 3, wantErr: "Not Allowed", wantN: 101},
+		{name: "moderator", src: mod, id: 3, wantId: 3, wantN: 1},
+		{name: "already-undone", src: mod, id: 3, wantErr: "Already Undone", wantN: 1},
+		{name: "not-undone", redo: true, src: alice, id: 1, wantErr: "Not Undone", wantN: 1},
+		{name: "unknown", src: alice, id: 9, wantErr: "Change Not Found", wantN: 1},
+		{name: "redo-other-user", redo: true, src: alice, id: 3, wantErr: "Not Allowed", wantN: 1},
+		{name: "redo-other-guild", redo: true, src: otherMod, id: 3, wantErr: "Change Not Found", wantN: 1},
+		{name: "redo-own", redo: true, src: bob, wantId: 3, wantN: 101},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var e *Entry
+			var err error
+			if test.redo {
+				e, err = j.Redo(test.src, test.id)
+			} else {
+				e, err = j.Undo(test.src, test.id)
+			}
+
+			dErr, _ := err.(discord.Error)
+			switch {
+			case test.wantErr == "" && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case test.wantErr != "" && dErr.Name != test.wantErr:
+				t.Errorf("err = %v, want %s", err, test.wantErr)
+			case test.wantErr == "" && e.Id != test.wantId:
+				t.Errorf("changed entry %d, want %d", e.Id, test.wantId)
+			}
+
+			if c.n != test.wantN {
+				t.Errorf("n = %d, want %d", c.n, test.wantN)
+			}
+		})
+	}
+
+	// the journal survives a restart
+	loaded := New(dir)
+	if err := loaded.Load(dir); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if len(loaded.Entries) != 3 || loaded.NextId != 4 || !loaded.Entries[1].Undone || loaded.Entries[0].Username != "alice" || loaded.Entries[0].GuildId != 1 {
+		t.Errorf("loaded %+v", loaded)
+	}
+}
+
+/*
+Test Cases:
+- old entries are dropped past the limit, and ids keep counting
+- recent entries are newest first, and only the guild's
+- a nil journal records nothing
+*/
+func TestJournal_Record(t *testing.T) {
+	dir, err := ioutil.TempDir("", "journal_record")
+	if err != nil {
+		t.Fatalf("temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	j := New(dir, WithMaxEntries(3))
+	for i := 0; i < 5; i++ {
+		guild := gb.Snowflake(1 + i%2)
+		if err := j.Record(&gb.Source{GuildId: guild, AuthorId: 1}, "counter", "add", "added", i); err != nil {
+			t.Fatalf("record: %v", err)
+		}
+	}
+
+	if len(j.Entries) != 3 || j.Entries[0].Id != 3 || j.NextId != 6 {
+		t.Errorf("kept %d entries from %d, next id %d", len(j.Entries), j.Entries[0].Id, j.NextId)
+	}
+
+	recent := j.Recent(1, 2)
+	if len(recent) != 2 || recent[0].Id != 5 || recent[1].Id != 3 {
+		t.Errorf("recent = %+v", recent)
+	}
+	if recent := j.Recent(2, 5); len(recent) != 1 || recent[0].Id != 4 {
+		t.Errorf("other guild's recent = %+v", recent)
+	}
+
+	var none *Journal
+	if err := none.Record(&gb.Source{}, "counter", "add", "added", 1); err != nil {
+		t.Errorf("nil journal: %v", err)
+	}
+}
+
+/*
+Test Cases:
+- undo and redo reply with the change
+- errors are shown to the user
+- the journal lists recent changes, only in the guild they were made in
+- other commands are ignored
+*/
+func TestInterceptor(t *testing.T) {
+	dir, err := ioutil.TempDir("", "journal_interceptor")
+	if err != nil {
+		t.Fatalf("temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	c := &counter{n: 5}
+	j := New(dir)
+	j.Register("counter", c)
+	if err := j.Record(&gb.Source{AuthorId: 1, Username: "user"}, "counter", "add", "added 5", 5); err != nil {
+		t.Fatalf("record: %v", err)
+	}
+
+	i := Interceptor(j)
+	tests := []struct {
+		name      string
+		in        *gb.Message
+		wantText  string
+		wantTitle string
+		wantLen   int // fields in the embed
+		wantN     int
+	}{
+		{name: "other-command", in: mock.NewMessage(gb.Queue, mock.WithSource(1, 1, "user", "")), wantN: 5},
+		{name: "undo", in: mock.NewMessage(gb.Undo, mock.WithSource(1, 1, "user", "")), wantText: "Undid change 1: added 5.", wantN: 0},
+		{name: "undo-again", in: mock.NewMessage(gb.Undo, mock.WithSource(1, 1, "user", "")), wantTitle: "Nothing to Undo", wantN: 0},
+		{name: "bad-id", in: mock.NewMessage(gb.Undo, mock.WithSource(1, 1, "user", ""), mock.WithArgs("x")), wantTitle: "Invalid Argument", wantN: 0},
+		{name: "redo", in: mock.NewMessage(gb.Redo, mock.WithSource(1, 1, "user", ""), mock.WithArgs("1")), wantText: "Redid change 1: added 5.", wantN: 5},
+		{name: "journal", in: mock.NewMessage(gb.Journal, mock.WithSource(1, 1, "user", "")), wantTitle: "Recent Changes", wantLen: 1, wantN: 5},
+		{name: "journal-other-guild", in: mock.NewMessage(gb.Journal, mock.WithSource(1, 1, "user", ""), mock.WithGuild(4)), wantTitle: "Recent Changes", wantN: 5},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := i(test.in); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if test.in.Response.Text != test.wantText {
+				t.Errorf("text = %q, want %q", test.in.Response.Text, test.wantText)
+			}
+
+			var title string
+			var fields int
+			if test.in.Response.Embed != nil {
+				title = test.in.Response.Embed.Title
+				fields = len(test.in.Response.Embed.Fields)
+			}
+			if title != test.wantTitle {
+				t.Errorf("embed = %q, want %q", title, test.wantTitle)
+			}
+			if fields != test.wantLen {
+				t.Errorf("got %d fields, want %d", fields, test.wantLen)
+			}
+
+			if c.n != test.wantN {
+				t.Errorf("n = %d, want %d", c.n, test.wantN)
+			}
+		})
+	}
+}
@@ -0,0 +1,272 @@
+package gobottas
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// How important a log entry is; entries below the logger's level are dropped
+type Level int32
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelWarn:
+		return "warn"
+	case LevelError:
+		return "error"
+	default:
+		return fmt.Sprintf("level(%d)", int32(l))
+	}
+}
+
+// Parse a level name such as "debug" or "WARN"
+func ParseLevel(s string) (Level, error) {
+	switch strings.ToLower(s) {
+	case "debug":
+		return LevelDebug, nil
+	case "info", "":
+		return LevelInfo, nil
+	case "warn", "warning":
+		return LevelWarn, nil
+	case "error":
+		return LevelError, nil
+	default:
+		return LevelInfo, fmt.Errorf("unknown log level %q; choose from debug, info, warn, error", s)
+	}
+}
+
+// Leveled logger that writes one line per entry, either as text or as JSON for log shipping.  Entries carry key-value
+// fields, e.g. log.Info("meme added", "guild", id).  A nil *Logger discards everything, so it is safe to leave unset.
+type Logger struct {
+	out    *logOutput    // shared with every logger made by With
+	fields []interface{} // key-value pairs added to every entry
+}
+
+// where entries go; the level can change while the bot runs
+type logOutput struct {
+	mu    sync.Mutex
+	w     io.Writer
+	json  bool
+	level int32
+	clock Clock
+}
+
+type LoggerOpt func(*logOutput)
+
+// Write entries as JSON objects instead of text
+func LogJSON() LoggerOpt {
+	return func(o *logOutput) {
+		o.json = true
+	}
+}
+
+// Drop entries below the level; the default is LevelInfo
+func LogLevel(l Level) LoggerOpt {
+	return func(o *logOutput) {
+		o.level = int32(l)
+	}
+}
+
+// Timestamp entries with the clock instead of the system time
+func LogClock(c Clock) LoggerOpt {
+	return func(o *logOutput) {
+		o.clock = c
+	}
+}
+
+func NewLogger(w io.Writer, opts ...LoggerOpt) *Logger {
+	o := logOutput{
+		w:     w,
+		level: int32(LevelInfo),
+		clock: SystemClock{},
+	}
+
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return &Logger{out: &o}
+}
+
+// Make a logger that adds the key-value pairs to every entry
+func (l *Logger) With(kv ...interface{}) *Logger {
+	if l == nil {
+		return nil
+	}
+
+	fields := make([]interface{}, 0, len(l.fields)+len(kv))
+	fields = append(fields, l.fields...)
+	fields = append(fields, kv...)
+	return &Logger{out: l.out, fields: fields}
+}
+
+// Change the level of the logger and every logger made from it
+func (l *Logger) SetLevel(level Level) {
+	if l == nil {
+		return
+	}
+	atomic.StoreInt32(&l.out.level, int32(level))
+}
+
+func (l *Logger) Level() Level {
+	if l == nil {
+		return LevelError
+	}
+	return Level(atomic.LoadInt32(&l.out.level))
+}
+
+// Report whether entries at the level are written, to skip building expensive fields
+func (l *Logger) Enabled(level Level) bool {
+	return l != nil && level >= l.Level()
+}
+
+func (l *Logger) Debug(msg string, kv ...interface{}) {
+	l.log(LevelDebug, msg, kv)
+}
+
+func (l *Logger) Info(msg string, kv ...interface{}) {
+	l.log(LevelInfo, msg, kv)
+}
+
+func (l *Logger) Warn(msg string, kv ...interface{}) {
+	l.log(LevelWarn, msg, kv)
+}
+
+func (l *Logger) Error(msg string, kv ...interface{}) {
+	l.log(LevelError, msg, kv)
+}
+
+// An io.Writer that logs each write as an entry at the level, so that the standard log package can be pointed at
+// the logger with log.SetOutput
+func (l *Logger) Writer(level Level) io.Writer {
+	return logWriter{l: l, level: level}
+}
+
+type logWriter struct {
+	l     *Logger
+	level Level
+}
+
+func (w logWriter) Write(p []byte) (int, error) {
+	w.l.log(w.level, strings.TrimRight(string(p), "\n"), nil)
+	return len(p), nil
+}
+
+func (l *Logger) log(level Level, msg string, kv []interface{}) {
+	if !l.Enabled(level) {
+		return
+	}
+
+	fields := append(append([]interface{}{}, l.fields...), kv...)
+
+	var b bytes.Buffer
+	if l.out.json {
+		writeJSON(&b, l.out.clock.Now(), level, msg, fields)
+	} else {
+		writeText(&b, l.out.clock.Now(), level, msg, fields)
+	}
+	b.WriteByte('\n')
+
+	l.out.mu.Lock()
+	defer l.out.mu.Unlock()
+	_, _ = l.out.w.Write(b.Bytes())
+}
+
+// e.g. 2020-01-02T15:04:05Z INFO message sent cid=3fa2c1d09e4b7a65 channel=123
+func writeText(b *bytes.Buffer, t time.Time, level Level, msg string, fields []interface{}) {
+	b.WriteString(t.UTC().Format(time.RFC3339))
+	b.WriteByte(' ')
+	b.WriteString(strings.ToUpper(level.String()))
+	b.WriteByte(' ')
+	b.WriteString(msg)
+
+	for i := 0; i < len(fields); i += 2 {
+		k, v := pair(fields, i)
+		b.WriteByte(' ')
+		b.WriteString(k)
+		b.WriteByte('=')
+
+		s := fmt.Sprint(value(v))
+		if s == "" || strings.ContainsAny(s, " \t\n\"=") {
+			s = strconv.Quote(s)
+		}
+		b.WriteString(s)
+	}
+}
+
+// e.g. {"time":"2020-01-02T15:04:05Z","level":"info","msg":"message sent","channel":"123"}
+func writeJSON(b *bytes.Buffer, t time.Time, level Level, msg string, fields []interface{}) {
+	b.WriteString(`{"time":`)
+	writeJSONValue(b, t.UTC().Format(time.RFC3339Nano))
+	b.WriteString(`,"level":`)
+	writeJSONValue(b, level.String())
+	b.WriteString(`,"msg":`)
+	writeJSONValue(b, msg)
+
+	for i := 0; i < len(fields); i += 2 {
+		k, v := pair(fields, i)
+		b.WriteByte(',')
+		writeJSONValue(b, k)
+		b.WriteByte(':')
+		writeJSONValue(b, value(v))
+	}
+	b.WriteByte('}')
+}
+
+func writeJSONValue(b *bytes.Buffer, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		data, _ = json.Marshal(fmt.Sprint(v))
+	}
+	b.Write(data)
+}
+
+// the key and value at i; a missing value is reported rather than dropped
+func pair(fields []interface{}, i int) (string, interface{}) {
+	k := fmt.Sprint(fields[i])
+	if i+1 >= len(fields) {
+		return k, "(missing)"
+	}
+	return k, fields[i+1]
+}
+
+// errors and ids are written as the strings people know them by
+func value(v interface{}) interface{} {
+	switch v := v.(type) {
+	case error:
+		return v.Error()
+	case fmt.Stringer:
+		return v.String()
+	default:
+		return v
+	}
+}
+
+// Make a random id that ties together the log entries for one message
+func NewCorrelationId() string {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return hex.EncodeToString(b[:])
+}
@@ -0,0 +1,114 @@
+package gobottas
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log"
+	"testing"
+	"time"
+)
+
+// a clock stopped at one time
+type fixedClock time.Time
+
+func (c fixedClock) Now() time.Time {
+	return time.Time(c)
+}
+
+func (c fixedClock) After(d time.Duration) <-chan time.Time {
+	return nil
+}
+
+/*
+Test Cases:
+- text: fields, quoting, errors and ids, missing value
+- json: same entry
+- below the level, level changed at runtime and shared with children
+- nil logger, standard log package through Writer
+*/
+func TestLogger(t *testing.T) {
+	at := fixedClock(time.Date(2020, 1, 2, 15, 4, 5, 0, time.UTC))
+
+	var b bytes.Buffer
+	l := NewLogger(&b, LogClock(at)).With("cid", "3fa2")
+	l.Info("message sent", "channel", Snowflake(123), "text", "two words", "err", errors.New("boom"), "odd")
+
+	want := `2020-01-02T15:04:05Z INFO message sent cid=3fa2 channel=123 text="two words" err=boom odd=(missing)` + "\n"
+	if b.String() != want {
+		t.Errorf("text:\n got %q\nwant %q", b.String(), want)
+	}
+
+	b.Reset()
+	j := NewLogger(&b, LogClock(at), LogJSON()).With("cid", "3fa2")
+	j.Warn("message sent", "channel", Snowflake(123), "args", 2)
+
+	var got map[string]interface{}
+	if err := json.Unmarshal(b.Bytes(), &got); err != nil {
+		t.Fatalf("json: %v (%s)", err, b.String())
+	}
+	for k, v := range map[string]interface{}{"time": "2020-01-02T15:04:05Z", "level": "warn", "msg": "message sent", "channel": "123", "args": 2.0} {
+		if got[k] != v {
+			t.Errorf("json %s = %v, want %v", k, got[k], v)
+		}
+	}
+
+	b.Reset()
+	l.Debug("hidden")
+	if b.Len() != 0 {
+		t.Errorf("debug entry written at info: %q", b.String())
+	}
+
+	// children share the level with their parent
+	root := NewLogger(&b, LogClock(at))
+	child := root.With("cid", "1")
+	root.SetLevel(LevelDebug)
+	child.Debug("shown")
+	if b.Len() == 0 {
+		t.Errorf("debug entry not written after the level changed")
+	}
+
+	var none *Logger
+	none.With("k", "v").Error("dropped")
+	if none.Enabled(LevelError) {
+		t.Errorf("nil logger enabled")
+	}
+
+	b.Reset()
+	std := log.New(root.Writer(LevelWarn), "", 0)
+	std.Printf("Save error: %v", "disk full")
+	if want := "2020-01-02T15:04:05Z WARN Save error: disk full\n"; b.String() != want {
+		t.Errorf("writer:\n got %q\nwant %q", b.String(), want)
+	}
+}
+
+/*
+Test Cases:
+- every level, any case, empty, unknown
+*/
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Level
+		wantErr bool
+	}{
+		{in: "debug", want: LevelDebug},
+		{in: "INFO", want: LevelInfo},
+		{in: "", want: LevelInfo},
+		{in: "warning", want: LevelWarn},
+		{in: "error", want: LevelError},
+		{in: "loud", want: LevelInfo, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.in, func(t *testing.T) {
+			got, err := ParseLevel(test.in)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("err != wantErr (err = %v, wantErr = %v)", err, test.wantErr)
+			}
+			if got != test.want {
+				t.Errorf("got %s, want %s", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,273 @@
+package meme
+
+import (
+	"fmt"
+	"github.com/bwmarrin/discordgo"
+	gb "github.com/ericebersohl/gobottas"
+	"github.com/ericebersohl/gobottas/args"
+	"github.com/ericebersohl/gobottas/discord"
+	"strconv"
+	"time"
+)
+
+// Defaults for meme of the day schedules
+const (
+	DefaultWindow = 7           // days before a meme may be posted again
+	CheckInterval = time.Minute // how often the scheduler looks for due posts
+)
+
+// A meme of the day schedule for one channel
+type Daily struct {
+	ChannelId gb.Snowflake `json:"channel-id"`
+	GuildId   gb.Snowflake `json:"guild-id"`
+	Hour      int          `json:"hour"`
+	Minute    int          `json:"minute"`
+	Zone      string       `json:"zone"`   // IANA time zone name that Hour and Minute are in
+	Window    int          `json:"window"` // days before a meme may be posted again
+	Last      time.Time    `json:"last"`   // when the schedule last posted, or was set up
+	History   []*Post      `json:"history"`
+}
+
+// A meme posted by a schedule
+type Post struct {
+	Meme   string    `json:"meme"`
+	Posted time.Time `json:"posted"`
+}
+
+// The schedule in short, e.g. "09:30 Europe/London, no repeats within 7 days"
+func (d *Daily) String() string {
+	if d == nil {
+		return ""
+	}
+	return fmt.Sprintf("%02d:%02d %s, no repeats within %d days", d.Hour, d.Minute, d.Zone, d.Window)
+}
+
+// Parse the arguments of `&meme daily [time] [window?] [zone?]` into a schedule for the channel.  The window and the
+// zone may come in either order, since a zone is never a number.
+func NewDaily(src *gb.Source, in []string, now time.Time) (*Daily, error) {
+	var a struct {
+		Time  string   `arg:"time"`
+		Extra []string `arg:"window-or-zone,optional"`
+	}
+	if err := args.Parse("&meme daily", in, &a); err != nil {
+		return nil, err
+	}
+
+	d := Daily{
+		ChannelId: src.ChannelId,
+		GuildId:   src.GuildId,
+		Zone:      "UTC",
+		Window:    DefaultWindow,
+		Last:      now,
+	}
+
+	var window, zone string
+	for _, e := range a.Extra {
+		p := &zone
+		if _, err := strconv.Atoi(e); err == nil {
+			p = &window
+		}
+		if *p != "" {
+			return nil, discord.NewError("Too Many Args", fmt.Sprintf("Unexpected %q:\n`&meme daily [HH:MM] [window-days?] [zone?]`", e))
+		}
+		*p = e
+	}
+
+	if window != "" {
+		n, _ := strconv.Atoi(window)
+		if n < 0 {
+			return nil, discord.NewError("Invalid Window", "The window must be a number of days, at least 0.")
+		}
+		d.Window = n
+	}
+
+	t, err := time.Parse("15:04", a.Time)
+	if err != nil {
+		return nil, discord.NewError("Invalid Time", "The time must be in 24 hour HH:MM format, like 09:30.")
+	}
+	d.Hour, d.Minute = t.Hour(), t.Minute()
+
+	if zone != "" {
+		if _, err := time.LoadLocation(zone); err != nil {
+			return nil, discord.NewError("Invalid Time Zone", fmt.Sprintf("Unknown time zone %q; use a name like Europe/London.", zone))
+		}
+		d.Zone = zone
+	}
+
+	return &d, nil
+}
+
+// The most recent time at or before now that the schedule should have posted
+func (d *Daily) slot(now time.Time) time.Time {
+	loc, err := time.LoadLocation(d.Zone)
+	if err != nil {
+		loc = time.UTC
+	}
+
+	now = now.In(loc)
+	t := time.Date(now.Year(), now.Month(), now.Day(), d.Hour, d.Minute, 0, 0, loc)
+	if t.After(now) {
+		t = t.AddDate(0, 0, -1)
+	}
+	return t
+}
+
+// Report whether the schedule has a post due at now
+func (d *Daily) Due(now time.Time) bool {
+	return d.Last.Before(d.slot(now))
+}
+
+// Choose the meme to post at now: a random meme that hasn't been posted within the window, or nil if every meme has
+// been, so that nothing repeats.  The pick is added to the history and the history is trimmed to the window.
+func (d *Daily) pick(s *Stash, now time.Time) *Meme {
+	cutoff := now.AddDate(0, 0, -d.Window)
+
+	var history []*Post
+	recent := make(map[string]time.Time)
+	for _, p := range d.History {
+		if p.Posted.After(cutoff) {
+			history = append(history, p)
+			recent[p.Meme] = p.Posted
+		}
+	}
+
+	m := s.Random(func(m *Meme) bool {
+		_, ok := recent[m.Meme]
+		return ok
+	})
+
+	if m != nil {
+		history = append(history, &Post{Meme: m.Meme, Posted: now})
+	}
+	d.History = history
+	d.Last = now
+
+	return m
+}
+
+// Describe the schedule for the daily command
+func (d *Daily) Embed() *discordgo.MessageEmbed {
+	e := discord.NewEmbed().
+		EmbedColor(gb.MemeCol).
+		EmbedTitle("Meme of the Day").
+		EmbedDescription(fmt.Sprintf("Posting at %02d:%02d %s; memes aren't repeated within %d days.", d.Hour, d.Minute, d.Zone, d.Window)).
+		EmbedTimestamp(d.Last)
+	return e.MessageEmbed
+}
+
+// Set up or replace the schedule for a channel
+func (s *Stash) SetDaily(d *Daily) {
+	if s.Daily == nil {
+		s.Daily = make(map[gb.Snowflake]*Daily)
+	}
+	s.Daily[d.ChannelId] = d
+}
+
+// Stop posting in a channel
+func (s *Stash) StopDaily(channel gb.Snowflake) error {
+	if _, ok := s.Daily[channel]; !ok {
+		return discord.NewError("No Schedule", "There is no meme of the day in this channel.")
+	}
+	delete(s.Daily, channel)
+	return nil
+}
+
+// Posts memes of the day in every guild when their schedules come due, logging to its stashes' logger
+type Scheduler struct {
+	stashes *Stashes
+	clock   gb.Clock
+	enabled func(c gb.Command, src *gb.Source) bool // whether memes are on where a schedule posts
+}
+
+type SchedulerOpt func(*Scheduler)
+
+func NewScheduler(ss *Stashes, c gb.Clock, opts ...SchedulerOpt) *Scheduler {
+	sc := Scheduler{
+		stashes: ss,
+		clock:   c,
+		enabled: func(gb.Command, *gb.Source) bool { return true },
+	}
+
+	for _, o := range opts {
+		o(&sc)
+	}
+
+	return &sc
+}
+
+// only post where f reports the meme module is enabled, such as the registry's Enabled
+func WithEnabled(f func(c gb.Command, src *gb.Source) bool) SchedulerOpt {
+	return func(sc *Scheduler) {
+		sc.enabled = f
+	}
+}
+
+// Build the messages for every schedule in s due at now and record what was posted, reporting whether any schedule
+// changed.  Schedules where memes are disabled, or with nothing left to post in their window, skip to their next slot.
+// The caller must hold the stash lock.
+func (sc *Scheduler) Due(s *Stash, now time.Time) ([]*gb.Message, bool) {
+	var msgs []*gb.Message
+	changed := false
+
+	for _, d := range s.Daily {
+		if !d.Due(now) {
+			continue
+		}
+		changed = true
+
+		src := &gb.Source{
+			ChannelId: d.ChannelId,
+			GuildId:   d.GuildId,
+		}
+		if !sc.enabled(gb.Meme, src) {
+			d.Last = now
+			continue
+		}
+
+		m := d.pick(s, now)
+		if m == nil {
+			sc.stashes.log.Info("every meme was posted within the window; skipping the meme of the day",
+				"guild", d.GuildId, "channel", d.ChannelId, "window", d.Window)
+			continue
+		}
+
+		msgs = append(msgs, &gb.Message{
+			Command: gb.Meme,
+			Source:  src,
+			Response: &gb.Response{
+				ChannelId: d.ChannelId,
+				Embed:     m.Embed(),
+			},
+		})
+	}
+
+	return msgs, changed
+}
+
+// Check for due posts every CheckInterval until stop is closed, handing each post to send
+func (sc *Scheduler) Run(stop <-chan struct{}, send func(*gb.Message)) {
+	for {
+		now := sc.clock.Now()
+		for _, g := range sc.stashes.Guilds() {
+			s := sc.stashes.Get(g)
+			s.Lock()
+			msgs, changed := sc.Due(s, now)
+			if changed {
+				if err := s.Save(s.LocalPath); err != nil {
+					sc.stashes.log.Error("failed to save the meme stash", "guild", g, "path", s.LocalPath, "err", err)
+				}
+			}
+			s.Unlock()
+
+			for _, msg := range msgs {
+				send(msg)
+			}
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-sc.clock.After(CheckInterval):
+		}
+	}
+}
@@ -0,0 +1,257 @@
+package meme
+
+import (
+	gb "github.com/ericebersohl/gobottas"
+	"github.com/ericebersohl/gobottas/mock"
+	"os"
+	"testing"
+	"time"
+)
+
+/*
+Test Cases:
+- time only, window, zone, bad time, bad window, bad zone
+- the zone before the window, or without one
+- two windows or two zones
+*/
+func TestNewDaily(t *testing.T) {
+	src := &gb.Source{ChannelId: 5, GuildId: 1}
+	now := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		in         []string
+		wantHour   int
+		wantMinute int
+		wantWindow int
+		wantZone   string
+		wantErr    bool
+	}{
+		{name: "time", in: []string{"09:30"}, wantHour: 9, wantMinute: 30, wantWindow: DefaultWindow, wantZone: "UTC"},
+		{name: "window", in: []string{"21:05", "3"}, wantHour: 21, wantMinute: 5, wantWindow: 3, wantZone: "UTC"},
+		{name: "zone", in: []string{"08:00", "10", "Europe/London"}, wantHour: 8, wantWindow: 10, wantZone: "Europe/London"},
+		{name: "bad-time", in: []string{"9am"}, wantErr: true},
+		{name: "bad-window", in: []string{"09:00", "-1"}, wantErr: true},
+		{name: "bad-zone", in: []string{"09:00", "1", "Mars/Olympus"}, wantErr: true},
+		{name: "zone-first", in: []string{"09:30", "Europe/London", "3"}, wantHour: 9, wantMinute: 30, wantWindow: 3, wantZone: "Europe/London"},
+		{name: "zone-only", in: []string{"09:30", "Europe/London"}, wantHour: 9, wantMinute: 30, wantWindow: DefaultWindow, wantZone: "Europe/London"},
+		{name: "two-windows", in: []string{"09:00", "3", "4"}, wantErr: true},
+		{name: "two-zones", in: []string{"09:00", "UTC", "Europe/London"}, wantErr: true},
+		{name: "too-many", in: []string{"09:00", "3", "UTC", "x"}, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			d, err := NewDaily(src, test.in, now)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("err != wantErr (err = %v, wantErr = %v)", err, test.wantErr)
+			}
+
+			if err == nil {
+				if d.Hour != test.wantHour || d.Minute != test.wantMinute || d.Window != test.wantWindow || d.Zone != test.wantZone {
+					t.Errorf("got %+v", d)
+				}
+
+				if d.ChannelId != 5 || d.GuildId != 1 || !d.Last.Equal(now) {
+					t.Errorf("source not recorded: %+v", d)
+				}
+			}
+		})
+	}
+}
+
+/*
+Test Cases:
+- set up before the slot: due at the slot, not after posting, due again the next day
+- set up after the slot: not due until the next day
+- zone: slot is in local time
+*/
+func TestDaily_Due(t *testing.T) {
+	day := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	d := Daily{Hour: 9, Zone: "UTC", Last: day.Add(8 * time.Hour)}
+
+	if d.Due(day.Add(8*time.Hour + 59*time.Minute)) {
+		t.Errorf("due before the slot")
+	}
+	if !d.Due(day.Add(9 * time.Hour)) {
+		t.Errorf("not due at the slot")
+	}
+
+	d.Last = day.Add(9 * time.Hour)
+	if d.Due(day.Add(20 * time.Hour)) {
+		t.Errorf("due again the same day")
+	}
+	if !d.Due(day.Add(33 * time.Hour)) {
+		t.Errorf("not due the next day")
+	}
+
+	late := Daily{Hour: 9, Zone: "UTC", Last: day.Add(10 * time.Hour)}
+	if late.Due(day.Add(23 * time.Hour)) {
+		t.Errorf("schedule set up after the slot is due the same day")
+	}
+
+	// 09:00 in New York is 14:00 UTC in January
+	ny := Daily{Hour: 9, Zone: "America/New_York", Last: day}
+	if ny.Due(day.Add(13*time.Hour)) || !ny.Due(day.Add(14*time.Hour)) {
+		t.Errorf("zone not applied")
+	}
+}
+
+/*
+Test Cases:
+- every meme is posted once before any repeats
+- with everything recent, nothing is posted
+- posts older than the window are forgotten
+*/
+func TestDaily_Pick(t *testing.T) {
+	s := DefaultStash("meme_test")
+	d := Daily{Window: 7}
+	now := time.Date(2020, 1, 1, 9, 0, 0, 0, time.UTC)
+
+	seen := make(map[string]bool)
+	for i := 0; i < len(s.Memes); i++ {
+		m := d.pick(s, now.AddDate(0, 0, i))
+		if seen[m.Meme] {
+			t.Fatalf("meme repeated within the window: %s", m.Meme)
+		}
+		seen[m.Meme] = true
+	}
+
+	if m := d.pick(s, now.AddDate(0, 0, len(s.Memes))); m != nil {
+		t.Errorf("repeated %q within the window", m.Meme)
+	}
+
+	if m := d.pick(s, now.AddDate(0, 0, 30)); m == nil || len(d.History) != 1 {
+		t.Errorf("history not trimmed to the window: %d posts", len(d.History))
+	}
+}
+
+/*
+Test Cases:
+- Run posts in every guild when the clock reaches the slot, once, and saves each guild's history
+*/
+func TestScheduler_Run(t *testing.T) {
+	_ = os.Mkdir("meme_test", 0755)
+	defer os.RemoveAll("meme_test")
+
+	clock := mock.NewClock(time.Date(2020, 1, 1, 8, 58, 0, 0, time.UTC))
+	ss := NewStashes("meme_test")
+	ss.Get(1).SetDaily(&Daily{ChannelId: 5, GuildId: 1, Hour: 9, Zone: "UTC", Window: 7, Last: clock.Now()})
+	ss.Get(2).SetDaily(&Daily{ChannelId: 6, GuildId: 2, Hour: 9, Zone: "UTC", Window: 7, Last: clock.Now()})
+
+	sent := make(chan *gb.Message, 10)
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		NewScheduler(ss, clock).Run(stop, func(msg *gb.Message) { sent <- msg })
+		close(done)
+	}()
+
+	// step the clock a minute at a time once the scheduler is waiting on it
+	for i := 0; i < 5; i++ {
+		for clock.Waiting() == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		clock.Advance(time.Minute)
+	}
+	for clock.Waiting() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	close(stop)
+	<-done
+
+	if len(sent) != 2 {
+		t.Fatalf("sent %d posts, want 2", len(sent))
+	}
+
+	for _, ch := range []gb.Snowflake{5, 6} {
+		msg := <-sent
+		if msg.Response.ChannelId != ch || msg.Response.Embed == nil {
+			t.Errorf("bad post: %+v", msg.Response)
+		}
+
+		guild := ch - 4
+		l := Stash{}
+		if err := l.Load(ss.Path(guild)); err != nil || len(l.Daily[ch].History) != 1 {
+			t.Errorf("guild %s: history not saved (err = %v)", guild, err)
+		}
+	}
+}
+
+/*
+Test Cases:
+- schedules where memes are disabled don't post, and wait for their next slot
+- schedules with every meme posted within the window skip the day
+*/
+func TestScheduler_Due(t *testing.T) {
+	now := time.Date(2020, 1, 1, 9, 0, 0, 0, time.UTC)
+	s := DefaultStash("meme_test")
+	s.SetDaily(&Daily{ChannelId: 5, GuildId: 1, Hour: 9, Zone: "UTC", Window: 7, Last: now.Add(-time.Hour)})
+	s.SetDaily(&Daily{ChannelId: 6, GuildId: 2, Hour: 9, Zone: "UTC", Window: 7, Last: now.Add(-time.Hour)})
+
+	var history []*Post
+	for _, m := range s.Memes {
+		history = append(history, &Post{Meme: m.Meme, Posted: now.AddDate(0, 0, -1)})
+	}
+	s.Daily[6].History = history
+
+	off := func(c gb.Command, src *gb.Source) bool {
+		return src.GuildId != 1
+	}
+	msgs, changed := NewScheduler(NewStashes("meme_test"), mock.NewClock(now), WithEnabled(off)).Due(s, now)
+	if len(msgs) != 0 || !changed {
+		t.Fatalf("got %d posts (changed = %t), want none", len(msgs), changed)
+	}
+
+	for _, ch := range []gb.Snowflake{5, 6} {
+		if d := s.Daily[ch]; d.Due(now) {
+			t.Errorf("channel %s is still due", ch)
+		}
+	}
+	if len(s.Daily[5].History) != 0 {
+		t.Errorf("disabled schedule recorded a post")
+	}
+}
+
+/*
+Test Cases:
+- show: none, not moderator, bad time, set, show, off, off again
+*/
+func TestInterceptor_Daily(t *testing.T) {
+	_ = os.Mkdir("meme_test", 0755)
+	defer os.RemoveAll("meme_test")
+
+	ss := NewStashes("meme_test")
+	s := ss.Get(0)
+
+	tests := []struct {
+		name      string
+		in        *gb.Message
+		wantEmbed bool
+		wantSched bool
+	}{
+		{name: "show-none", in: mock.NewMessage(gb.Meme, mock.WithArgs("daily")), wantEmbed: true, wantSched: false},
+		{name: "not-mod", in: mock.NewMessage(gb.Meme, mock.WithArgs("daily", "09:00")), wantEmbed: true, wantSched: false},
+		{name: "bad-time", in: mock.NewMessage(gb.Meme, mock.WithArgs("daily", "nine"), mock.WithModerator()), wantEmbed: true, wantSched: false},
+		{name: "set", in: mock.NewMessage(gb.Meme, mock.WithArgs("daily", "09:00", "3"), mock.WithModerator()), wantEmbed: true, wantSched: true},
+		{name: "show", in: mock.NewMessage(gb.Meme, mock.WithArgs("daily")), wantEmbed: true, wantSched: true},
+		{name: "off", in: mock.NewMessage(gb.Meme, mock.WithArgs("daily", "off"), mock.WithModerator()), wantEmbed: false, wantSched: false},
+		{name: "off-again", in: mock.NewMessage(gb.Meme, mock.WithArgs("daily", "off"), mock.WithModerator()), wantEmbed: true, wantSched: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := Interceptor(ss)(test.in); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if (test.in.Response.Embed != nil) != test.wantEmbed {
+				t.Errorf("embed != wantEmbed (embed == nil: %t)", test.in.Response.Embed == nil)
+			}
+
+			if _, ok := s.Daily[0]; ok != test.wantSched {
+				t.Errorf("scheduled = %t, want %t", ok, test.wantSched)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package meme
+
+import (
+	"encoding/json"
+	"fmt"
+	gb "github.com/ericebersohl/gobottas"
+	"github.com/ericebersohl/gobottas/discord"
+	"github.com/ericebersohl/gobottas/journal"
+)
+
+// Name the stash is registered with in the journal
+const JournalName = "stash"
+
+// What the stash needs to undo and redo a change.  Memes are matched by their text, since the ones in the stash
+// aren't the ones decoded from the journal.
+type change struct {
+	Memes      []*Meme     `json:"memes"`                // the memes added or removed
+	Index      int         `json:"index"`                // where a removed meme was
+	Submission *Submission `json:"submission,omitempty"` // the submission approved or rejected
+}
+
+// journal a change made by the author of msg; the change stands even if the journal can't be saved
+func (s *Stash) record(msg *gb.Message, op, summary string, c change) {
+	if err := s.Journal.Record(msg.Source, JournalName, op, summary, c); err != nil {
+		msg.Log.Warn("failed to journal the change", "op", op, "err", err)
+	}
+}
+
+// Undo a journaled change to the stash and save it
+func (s *Stash) Undo(e *journal.Entry) error {
+	var c change
+	if err := json.Unmarshal(e.Data, &c); err != nil {
+		return err
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	switch e.Op {
+	case "add", "import":
+		if err := s.take(c.Memes); err != nil {
+			return err
+		}
+	case "remove":
+		s.put(c.Memes[0], c.Index)
+	case "approve":
+		if err := s.take(c.Memes); err != nil {
+			return err
+		}
+		s.unpend(c.Submission)
+	case "reject":
+		s.unpend(c.Submission)
+	default:
+		return fmt.Errorf("stash: cannot undo %q", e.Op)
+	}
+
+	return s.Save(s.LocalPath)
+}
+
+// Redo a journaled change to the stash that was undone and save it
+func (s *Stash) Redo(e *journal.Entry) error {
+	var c change
+	if err := json.Unmarshal(e.Data, &c); err != nil {
+		return err
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	switch e.Op {
+	case "add", "import":
+		// the memes were accepted once, so they aren't checked for duplicates again
+		s.Memes = append(s.Memes, c.Memes...)
+	case "remove":
+		if err := s.take(c.Memes); err != nil {
+			return err
+		}
+	case "approve":
+		if _, err := s.Approve(c.Submission.Id, c.Submission.GuildId); err != nil {
+			return err
+		}
+	case "reject":
+		if _, err := s.Reject(c.Submission.Id, c.Submission.GuildId); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("stash: cannot redo %q", e.Op)
+	}
+
+	return s.Save(s.LocalPath)
+}
+
+// take memes out of the stash by their text; none are taken unless all are there.  The caller holds the lock.
+func (s *Stash) take(memes []*Meme) error {
+	var found []*Meme
+	for _, m := range memes {
+		i := s.find(m.Meme)
+		if i < 0 {
+			return discord.NewError("Meme Not Found", fmt.Sprintf("%s is no longer in the stash.", m.Meme))
+		}
+		found = append(found, s.Memes[i])
+	}
+
+	for _, m := range found {
+		if err := s.Remove(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// put a meme back at index i, or at the end if the stash has shrunk since; the caller holds the lock
+func (s *Stash) put(m *Meme, i int) {
+	if i < 0 || i > len(s.Memes) {
+		i = len(s.Memes)
+	}
+	s.Memes = append(s.Memes[:i], append([]*Meme{m}, s.Memes[i:]...)...)
+}
+
+// put a submission back in the pending list, in order of id; the caller holds the lock
+func (s *Stash) unpend(sub *Submission) {
+	i := len(s.Pending)
+	for i > 0 && s.Pending[i-1].Id > sub.Id {
+		i--
+	}
+	s.Pending = append(s.Pending[:i], append([]*Submission{sub}, s.Pending[i:]...)...)
+}
+
+// the index of the meme with the text, or -1
+func (s *Stash) find(text string) int {
+	for i, m := range s.Memes {
+		if m.Meme == text {
+			return i
+		}
+	}
+	return -1
+}
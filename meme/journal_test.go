@@ -0,0 +1,117 @@
+package meme
+
+import (
+	gb "github.com/ericebersohl/gobottas"
+	"github.com/ericebersohl/gobottas/journal"
+	"github.com/ericebersohl/gobottas/mock"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+/*
+Test Cases:
+- adds, removals, approvals and rejections are journaled and noted for the audit log
+- undoing them newest first takes the stash back through each state
+- redoing them in order brings it back
+- undoing saves the stash
+*/
+func TestStash_UndoRedo(t *testing.T) {
+	dir, err := ioutil.TempDir("", "stash_journal")
+	if err != nil {
+		t.Fatalf("temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	j := journal.New(dir)
+	ss := NewStashes(dir, WithJournal(j))
+	s := ss.Get(1)
+	s.Memes = []*Meme{NewMeme("Lights out", "user")}
+	j.Register(JournalName, ss)
+	i := Interceptor(ss)
+
+	// the state of the stash: memes in order, then pending submissions
+	state := func() string {
+		s.Lock()
+		defer s.Unlock()
+
+		var memes, pending []string
+		for _, m := range s.Memes {
+			memes = append(memes, m.Meme)
+		}
+		for _, p := range s.Pending {
+			pending = append(pending, p.Meme.Meme)
+		}
+		return strings.Join(memes, ",") + "|" + strings.Join(pending, ",")
+	}
+
+	mod := func(args ...string) *gb.Message {
+		return mock.NewMessage(gb.Meme, mock.WithSource(9, 8, "mod", ""), mock.WithGuild(1), mock.WithModerator(), mock.WithArgs(args...))
+	}
+	user := func(args ...string) *gb.Message {
+		return mock.NewMessage(gb.Meme, mock.WithSource(42, 7, "user", ""), mock.WithGuild(1), mock.WithArgs(args...))
+	}
+
+	// submissions by the user and moderation changes aren't journaled
+	for _, msg := range []*gb.Message{mod("moderation", "on"), user("add", "Box box"), user("add", "Hold position")} {
+		if err := i(msg); err != nil {
+			t.Fatalf("setup: %v", err)
+		}
+	}
+
+	steps := []*gb.Message{
+		mod("add", "Bwoah"),
+		mod("add", "Get in there"),
+		mod("remove", "1"),
+		mod("approve", "0"),
+		mod("reject", "1"),
+	}
+
+	states := []string{state()}
+	for _, msg := range steps {
+		if err := i(msg); err != nil {
+			t.Fatalf("%v: %v", msg.Args, err)
+		}
+		if msg.Confirm != nil {
+			if err := msg.Confirm.Action(msg); err != nil {
+				t.Fatalf("%v: %v", msg.Args, err)
+			}
+		}
+		if len(msg.Changes) != 1 {
+			t.Errorf("%v: noted %d changes for the audit log, want 1", msg.Args, len(msg.Changes))
+		}
+		states = append(states, state())
+	}
+
+	if want := "Lights out,Get in there,Box box|"; states[len(states)-1] != want {
+		t.Fatalf("stash = %q, want %q", states[len(states)-1], want)
+	}
+	if len(j.Entries) != len(steps) {
+		t.Fatalf("journaled %d changes, want %d", len(j.Entries), len(steps))
+	}
+
+	src := &gb.Source{GuildId: 1, AuthorId: 9}
+	for n := len(steps) - 1; n >= 0; n-- {
+		if _, err := j.Undo(src, 0); err != nil {
+			t.Fatalf("undo %v: %v", steps[n].Args, err)
+		}
+		if got := state(); got != states[n] {
+			t.Errorf("after undoing %v, stash = %q, want %q", steps[n].Args, got, states[n])
+		}
+	}
+
+	saved := &Stash{}
+	if err := saved.Load(ss.Path(1)); err != nil || len(saved.Memes) != 1 || len(saved.Pending) != 2 {
+		t.Errorf("undo wasn't saved (err = %v, memes = %d, pending = %d)", err, len(saved.Memes), len(saved.Pending))
+	}
+
+	for n := range steps {
+		if _, err := j.Redo(src, 0); err != nil {
+			t.Fatalf("redo %v: %v", steps[n].Args, err)
+		}
+		if got := state(); got != states[n+1] {
+			t.Errorf("after redoing %v, stash = %q, want %q", steps[n].Args, got, states[n+1])
+		}
+	}
+}
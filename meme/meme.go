@@ -1,24 +1,33 @@
 package meme
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/bwmarrin/discordgo"
 	gb "github.com/ericebersohl/gobottas"
+	"github.com/ericebersohl/gobottas/args"
+	"github.com/ericebersohl/gobottas/confirm"
 	"github.com/ericebersohl/gobottas/discord"
+	"github.com/ericebersohl/gobottas/event"
+	"github.com/ericebersohl/gobottas/journal"
+	"github.com/ericebersohl/gobottas/metrics"
 	"io/ioutil"
-	"log"
 	"math/rand"
-	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
+// source of random memes
+var rng = rand.New(rand.NewSource(time.Now().UnixNano()))
+
 type Meme struct {
 	Meme    string    `json:"meme"`
 	Added   time.Time `json:"added"`
 	AddedBy string    `json:"added-by"`
+	Hash    uint64    `json:"hash,omitempty"` // perceptual hash of image memes
 }
 
 // create a msg.MessageEmbed to be sent to the discord channel
@@ -45,10 +54,24 @@ func NewMeme(meme, user string) *Meme {
 type Stash struct {
 	Memes     []*Meme `json:"memes"` // the memes in the stash
 	LocalPath string  `json:"path"`  // path to local backup
+
+	// approval workflow
+	Pending   []*Submission         `json:"pending"`   // memes waiting for a moderator
+	Moderated map[gb.Snowflake]bool `json:"moderated"` // guilds in which memes need approval
+	NextId    int                   `json:"next-id"`   // id of the next submission
+
+	// meme of the day schedules, by channel
+	Daily map[gb.Snowflake]*Daily `json:"daily"`
+
+	// records changes so they can be undone; nil records nothing
+	Journal *journal.Journal `json:"-"`
+
+	// the interceptor and the daily scheduler run on different goroutines
+	mu sync.Mutex
 }
 
 // The default stash
-func DefaultStash(localPath string) Stash {
+func DefaultStash(localPath string) *Stash {
 	s := Stash{
 		Memes: []*Meme{
 			{Meme: "When did I do dangerous driving?", AddedBy: "Default Meme", Added: time.Now()},
@@ -58,7 +81,51 @@ func DefaultStash(localPath string) Stash {
 		LocalPath: localPath,
 	}
 
-	return s
+	return &s
+}
+
+// Lock the stash; anything that shares the stash between goroutines must hold the lock while using it
+func (s *Stash) Lock() {
+	s.mu.Lock()
+}
+
+func (s *Stash) Unlock() {
+	s.mu.Unlock()
+}
+
+// Pick a meme at random, skipping those that exclude returns true for.  Returns nil if every meme is excluded.
+func (s *Stash) Random(exclude func(*Meme) bool) *Meme {
+	var memes []*Meme
+	for _, m := range s.Memes {
+		if exclude == nil || !exclude(m) {
+			memes = append(memes, m)
+		}
+	}
+
+	if len(memes) == 0 {
+		return nil
+	}
+
+	return memes[rng.Intn(len(memes))]
+}
+
+// The meme with the given text, or nil if it isn't in the stash
+func (s *Stash) ByText(text string) *Meme {
+	if i := s.find(text); i >= 0 {
+		return s.Memes[i]
+	}
+	return nil
+}
+
+// Take a meme out of the stash; it may have gone since it was picked, e.g. while its removal was being confirmed
+func (s *Stash) Remove(m *Meme) error {
+	for i, e := range s.Memes {
+		if e == m {
+			s.Memes = append(s.Memes[:i], s.Memes[i+1:]...)
+			return nil
+		}
+	}
+	return discord.NewError("Meme Not Found", "That meme is no longer in the stash.")
 }
 
 // Save the stash to a local folder
@@ -73,14 +140,14 @@ func (s *Stash) Save(path string) error {
 	// get bytes
 	data, err := json.Marshal(s)
 	if err != nil {
-		log.Printf("Stash save error: %v", err)
+		metrics.PersistenceErrors.Inc("meme", "save")
 		return err
 	}
 
 	// write to file
 	err = ioutil.WriteFile(fmt.Sprintf("%s/meme.json", path), data, 0644)
 	if err != nil {
-		log.Printf("WriteFile error: %v", err)
+		metrics.PersistenceErrors.Inc("meme", "save")
 		return err
 	}
 
@@ -92,13 +159,13 @@ func (s *Stash) Load(path string) error {
 	// get data
 	data, err := ioutil.ReadFile(fmt.Sprintf("%s/meme.json", path))
 	if err != nil {
-		log.Printf("Load error: %v", err)
+		metrics.PersistenceErrors.Inc("meme", "load")
 		return err
 	}
 
 	err = json.Unmarshal(data, s)
 	if err != nil {
-		log.Printf("Unmarshal err: %v", err)
+		metrics.PersistenceErrors.Inc("meme", "load")
 		return err
 	}
 
@@ -113,45 +180,74 @@ const (
 	MAdd
 	MRemove
 	MList
+	MExport
+	MImport
+	MPending
+	MApprove
+	MReject
+	MModeration
+	MDaily
 	MError
 )
 
 func (c Command) String() string {
-	return [...]string{"Meme", "Add", "Remove", "List"}[c]
+	return [...]string{"Meme", "Add", "Remove", "List", "Export", "Import", "Pending", "Approve", "Reject", "Moderation", "Daily", "Error"}[c]
 }
 
 func ArgToCommand(arg string) Command {
-	switch arg {
+	switch strings.ToLower(arg) {
 	case "":
 		return M
 	case "add":
 		return MAdd
-	case "remove":
+	case "remove", "rm":
 		return MRemove
 	case "list":
 		return MList
+	case "export":
+		return MExport
+	case "import":
+		return MImport
+	case "pending":
+		return MPending
+	case "approve":
+		return MApprove
+	case "reject":
+		return MReject
+	case "moderation":
+		return MModeration
+	case "daily":
+		return MDaily
 	default:
 		return MError
 
 	}
 }
 
-func Interceptor(s *Stash) gb.Interceptor {
+// Arguments of &meme add
+type addArgs struct {
+	Meme  string `arg:"meme"`
+	Force bool   `arg:"force,flag"`
+}
+
+// Returns an interceptor for the meme command, which uses the stash of the guild it's sent in.  Moderators can also
+// review submissions by reacting to the bot's notice of them.
+func Interceptor(ss *Stashes) gb.Interceptor {
 	return func(msg *gb.Message) error {
 		// skip if not a meme message
-		if msg.Command != gb.Meme {
+		if msg.Command != gb.Meme && msg.Command != gb.Reaction {
 			return nil
 		}
 
 		// error if there isn't a stash
-		if s == nil {
+		if ss == nil {
 			return errors.New("cannot intercept without a stash")
 		}
 
-		// error if the meme stash is empty
-		if len(s.Memes) == 0 {
-			return errors.New("meme stash is empty")
+		if msg.Command == gb.Reaction {
+			return react(ss, msg)
 		}
+		s := ss.Get(msg.Source.GuildId)
 
 		// This command is returned to the same channel
 		msg.Response.ChannelId = msg.Source.ChannelId
@@ -163,33 +259,67 @@ func Interceptor(s *Stash) gb.Interceptor {
 		}
 
 		cmd := ArgToCommand(arg)
+
+		// imports download a file, and the images in it, before locking the stash
+		if cmd == MImport {
+			return importMemes(s, msg)
+		}
+
+		// a new meme is read, and downloaded if it's an image, before anyone waiting on the stash is held up
+		var add addArgs
+		var meme *Meme
+		if cmd == MAdd {
+			if err := args.Parse("&meme add", msg.Args[1:], &add); err != nil {
+				return embedError(msg, err)
+			}
+			meme = NewMeme(add.Meme, msg.Source.Username)
+			meme.Fingerprint(msg.Log)
+		}
+
+		s.Lock()
+		defer s.Unlock()
+
 		switch cmd {
 		case M:
+			// error if the meme stash is empty
+			if len(s.Memes) == 0 {
+				return errors.New("meme stash is empty")
+			}
+
 			// select a meme at random
-			rand.Seed(time.Now().UnixNano())
-			fmt.Print("==", len(s.Memes), "==")
-			meme := s.Memes[rand.Intn(len(s.Memes))]
+			meme := s.Random(nil)
 
 			// set the embed, return nil
 			msg.Response.Embed = meme.Embed()
 			return nil
 
 		case MAdd:
-			// check args
-			if len(msg.Args) < 2 {
-				msg.Response.Embed = discord.NewError("Too Few Args", "add requires a meme string\n").Embed()
+			if add.Force && !msg.Source.Moderator {
+				msg.Response.Embed = discord.NewError("Not Allowed", "Only moderators can force a meme into the stash.").Embed()
 				return nil
 			}
 
-			// create the meme
-			meme := NewMeme(msg.Args[1], msg.Source.Username)
+			// submissions from regular users wait for approval in moderated guilds
+			if s.IsModerated(msg.Source.GuildId) && !msg.Source.Moderator {
+				sub, err := s.Submit(meme, msg.Source)
+				if err != nil {
+					return embedError(msg, err)
+				}
+
+				msg.Response.Text = fmt.Sprintf(submitted+" Moderators: react %s to approve it or %s to reject it.", sub.Id, confirm.Yes, confirm.No)
+				msg.AddChange("submit meme", meme.Meme, "", fmt.Sprintf("submission %d", sub.Id))
+				return save(s, msg)
+			}
 
-			// add it to the list
-			s.Memes = append(s.Memes, meme)
+			// create the meme and add it to the list
+			if err := s.AddMeme(msg, meme, add.Force); err != nil {
+				return embedError(msg, err)
+			}
 
 			// save the list
 			err := s.Save(s.LocalPath)
 			if err != nil {
+				msg.Log.Error("failed to save the meme stash", "path", s.LocalPath, "err", err)
 				msg.Response.Embed = discord.Error{
 					Name: "Meme Save Error",
 					Desc: err.Error(),
@@ -198,33 +328,32 @@ func Interceptor(s *Stash) gb.Interceptor {
 			return nil
 
 		case MRemove:
-			// check args
-			if len(msg.Args) < 2 {
-				msg.Response.Embed = discord.NewError("Too Few Args", "Remove requires a position argument of type int\n").Embed()
-				return nil
+			var a struct {
+				Index int `arg:"index,min=0"`
+			}
+			if err := args.Parse("&meme remove", msg.Args[1:], &a); err != nil {
+				return embedError(msg, err)
 			}
 
-			// attempt to convert to index
-			if idx, err := strconv.Atoi(msg.Args[1]); err == nil {
-				if idx >= len(s.Memes) || idx < 0 {
-					msg.Response.Embed = discord.NewError("Out of Bounds", "The provided index does not correspond to a meme\n").Embed()
-					return nil
-				}
-
-				s.Memes = append(s.Memes[:idx], s.Memes[idx+1:]...)
-			} else {
-				msg.Response.Embed = discord.NewError("Invalid Index", "The provided meme index could not be converted to an integer\n").Embed()
+			if a.Index >= len(s.Memes) {
+				msg.Response.Embed = discord.NewError("Out of Bounds", "The provided index does not correspond to a meme\n").Embed()
 				return nil
 			}
 
-			// save the list
-			err := s.Save(s.LocalPath)
+			m := s.Memes[a.Index]
+			msg.Confirm = &gb.Confirmation{
+				Prompt: fmt.Sprintf("Remove meme %d, %s?", a.Index, m.Meme),
+				Action: func(reply *gb.Message) error {
+					s.Lock()
+					defer s.Unlock()
 
-			if err != nil {
-				msg.Response.Embed = discord.Error{
-					Name: "Meme Save Error",
-					Desc: err.Error(),
-				}.Embed()
+					if err := s.RemoveMeme(reply, m); err != nil {
+						return embedError(reply, err)
+					}
+
+					reply.Response.Text = fmt.Sprintf("Removed meme %s.", m.Meme)
+					return save(s, reply)
+				},
 			}
 			return nil
 
@@ -247,6 +376,43 @@ func Interceptor(s *Stash) gb.Interceptor {
 			msg.Response.Embed = e.MessageEmbed
 			return nil
 
+		case MExport:
+			var a struct {
+				Format string `arg:"format,optional"`
+			}
+			if err := args.Parse("&meme export", msg.Args[1:], &a); err != nil {
+				return embedError(msg, err)
+			}
+
+			// default to json, the same format as meme.json
+			format := FormatJSON
+			if a.Format != "" {
+				format = strings.ToLower(a.Format)
+			}
+
+			var buf bytes.Buffer
+			if err := s.Export(&buf, format); err != nil {
+				return embedError(msg, err)
+			}
+
+			msg.Response.Text = fmt.Sprintf("Exported %d memes.", len(s.Memes))
+			msg.Response.File = &discordgo.File{
+				Name:        "memes." + format,
+				ContentType: contentTypes[format],
+				Reader:      &buf,
+			}
+			return nil
+
+		case MPending, MApprove, MReject, MModeration:
+			if !msg.Source.Moderator {
+				msg.Response.Embed = discord.NewError("Not Allowed", "Only moderators can review memes.").Embed()
+				return nil
+			}
+			return moderate(s, cmd, msg)
+
+		case MDaily:
+			return daily(s, msg)
+
 		case MError:
 			msg.Response.Embed = discord.NewError("Unrecognized Command", "Gobottas did not recognize your command.").Embed()
 			return nil
@@ -255,3 +421,128 @@ func Interceptor(s *Stash) gb.Interceptor {
 		return errors.New("reached end of interceptor without returning from the switch")
 	}
 }
+
+// Import the memes in the file attached to msg.  The file is downloaded before the stash is locked.
+func importMemes(s *Stash, msg *gb.Message) error {
+	// bulk changes are left to moderators
+	if !msg.Source.Moderator {
+		msg.Response.Embed = discord.NewError("Not Allowed", "Only moderators can import memes.").Embed()
+		return nil
+	}
+
+	if err := args.Parse("&meme import", msg.Args[1:], &struct{}{}); err != nil {
+		return embedError(msg, err)
+	}
+
+	if len(msg.Source.Attachments) < 1 {
+		msg.Response.Embed = discord.NewError("No File", "Upload a .json or .csv file with the import command.").Embed()
+		return nil
+	}
+
+	memes, err := Download(msg.Source.Attachments[0], msg.Log)
+	if err != nil {
+		msg.Response.Embed = discord.NewError("Import Failed", err.Error()).Embed()
+		return nil
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	added, skipped := s.Import(memes, msg.Source.Username)
+	msg.Response.Embed = importReport(added, skipped)
+
+	// nothing to save if every meme was a duplicate
+	if len(added) == 0 {
+		return nil
+	}
+
+	s.record(msg, "import", fmt.Sprintf("imported %d memes", len(added)), change{Memes: added})
+	msg.AddChange("import memes", msg.Source.Attachments[0], fmt.Sprintf("%d memes", len(s.Memes)-len(added)), fmt.Sprintf("%d memes", len(s.Memes)))
+	for _, m := range added {
+		msg.Publish(&event.MemeAdded{Base: event.From(msg), Meme: m.Meme, AddedBy: m.AddedBy})
+	}
+
+	return save(s, msg)
+}
+
+// Show, set up, or stop the meme of the day in the channel
+func daily(s *Stash, msg *gb.Message) error {
+	ch := msg.Source.ChannelId
+
+	// anyone can see the schedule
+	if len(msg.Args) < 2 {
+		if d, ok := s.Daily[ch]; ok {
+			msg.Response.Embed = d.Embed()
+		} else {
+			msg.Response.Embed = discord.NewError("No Schedule", "There is no meme of the day in this channel:\n`&meme daily [HH:MM] [window-days?] [zone?]`").Embed()
+		}
+		return nil
+	}
+
+	if !msg.Source.Moderator {
+		msg.Response.Embed = discord.NewError("Not Allowed", "Only moderators can schedule the meme of the day.").Embed()
+		return nil
+	}
+
+	before := s.Daily[ch].String()
+	if msg.Args[1] == "off" {
+		if err := s.StopDaily(ch); err != nil {
+			return embedError(msg, err)
+		}
+		msg.AddChange("stop meme of the day", fmt.Sprintf("<#%s>", ch), before, "")
+		msg.Response.Text = "Stopped the meme of the day."
+		return save(s, msg)
+	}
+
+	d, err := NewDaily(msg.Source, msg.Args[1:], time.Now())
+	if err != nil {
+		return embedError(msg, err)
+	}
+
+	s.SetDaily(d)
+	msg.AddChange("schedule meme of the day", fmt.Sprintf("<#%s>", ch), before, d.String())
+	msg.Response.Embed = d.Embed()
+	return save(s, msg)
+}
+
+// show discord errors to the user, pass anything else up
+func embedError(msg *gb.Message, err error) error {
+	if e, ok := err.(discord.Error); ok {
+		msg.Response.SetError(e.Embed())
+		return nil
+	}
+	return err
+}
+
+// persist the stash, showing the user any failure
+func save(s *Stash, msg *gb.Message) error {
+	err := s.Save(s.LocalPath)
+	if err != nil {
+		msg.Log.Error("failed to save the meme stash", "path", s.LocalPath, "err", err)
+		msg.Response.SetError(discord.Error{
+			Name: "Meme Save Error",
+			Desc: err.Error(),
+		}.Embed())
+	}
+	return nil
+}
+
+// Build the embed that tells the user what an import added
+func importReport(added []*Meme, skipped int) *discordgo.MessageEmbed {
+	var memes []string
+	for _, m := range added {
+		memes = append(memes, m.Meme)
+	}
+
+	e := discord.NewEmbed().
+		EmbedColor(gb.MemeCol).
+		EmbedTitle(fmt.Sprintf("Imported %d Memes", len(added))).
+		EmbedFooter(fmt.Sprintf("Skipped %d duplicates", skipped), "", "").
+		EmbedTimestamp(time.Now())
+
+	if len(memes) > 0 {
+		e = e.EmbedDescription(strings.Join(memes, "\n"))
+	}
+
+	return e.MessageEmbed
+}
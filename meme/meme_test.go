@@ -5,6 +5,8 @@ import (
 	"github.com/ericebersohl/gobottas/discord"
 	"github.com/ericebersohl/gobottas/mock"
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"io/ioutil"
 	"os"
 	"testing"
 )
@@ -20,36 +22,37 @@ Test Cases:
 - List: normal
 */
 func TestInterceptor(t *testing.T) {
-	var s Stash
-	var ds = DefaultStash("meme_stash")
+	ds := NewStashes("meme_stash")
+	ds.Get(1).Memes = nil
 
 	tests := []struct {
 		name        string
-		stash       *Stash
+		stash       *Stashes
 		in          *gb.Message
 		wantErr     bool
 		wantDiscErr bool
 		wantEmbed   bool
 	}{
 		// General errors
-		{name: "not-meme", stash: &ds, in: mock.NewMessage(gb.None), wantErr: false, wantDiscErr: false, wantEmbed: false},
-		{name: "nil-stash", stash: &s, in: mock.NewMessage(gb.Meme), wantErr: true, wantDiscErr: false, wantEmbed: false},
-		{name: "bad-arg", stash: &ds, in: mock.NewMessage(gb.Meme, mock.WithArgs("not", "valid", "args")), wantErr: true, wantDiscErr: false, wantEmbed: true}, // todo(ee): this test passes whatever wantErr val is
+		{name: "not-meme", stash: ds, in: mock.NewMessage(gb.None), wantErr: false, wantDiscErr: false, wantEmbed: false},
+		{name: "nil-stash", stash: nil, in: mock.NewMessage(gb.Meme), wantErr: true, wantDiscErr: false, wantEmbed: false},
+		{name: "empty-stash", stash: ds, in: mock.NewMessage(gb.Meme, mock.WithGuild(1)), wantErr: true, wantDiscErr: false, wantEmbed: false},
+		{name: "bad-arg", stash: ds, in: mock.NewMessage(gb.Meme, mock.WithArgs("not", "valid", "args")), wantErr: true, wantDiscErr: false, wantEmbed: true}, // todo(ee): this test passes whatever wantErr val is
 
 		// Meme
-		{name: "normal", stash: &ds, in: mock.NewMessage(gb.Meme), wantErr: false, wantDiscErr: false, wantEmbed: true},
+		{name: "normal", stash: ds, in: mock.NewMessage(gb.Meme), wantErr: false, wantDiscErr: false, wantEmbed: true},
 
 		// Add
-		{name: "too-few-args", stash: &ds, in: mock.NewMessage(gb.Meme, mock.WithArgs("add")), wantErr: true, wantDiscErr: false, wantEmbed: true},
-		{name: "", stash: &ds, in: mock.NewMessage(gb.None), wantErr: false, wantDiscErr: false, wantEmbed: false},
+		{name: "too-few-args", stash: ds, in: mock.NewMessage(gb.Meme, mock.WithArgs("add")), wantErr: true, wantDiscErr: false, wantEmbed: true},
+		{name: "", stash: ds, in: mock.NewMessage(gb.None), wantErr: false, wantDiscErr: false, wantEmbed: false},
 
 		// Remove
-		{name: "", stash: &ds, in: mock.NewMessage(gb.None), wantErr: false, wantDiscErr: false, wantEmbed: false},
-		{name: "", stash: &ds, in: mock.NewMessage(gb.None), wantErr: false, wantDiscErr: false, wantEmbed: false},
-		{name: "", stash: &ds, in: mock.NewMessage(gb.None), wantErr: false, wantDiscErr: false, wantEmbed: false},
+		{name: "", stash: ds, in: mock.NewMessage(gb.None), wantErr: false, wantDiscErr: false, wantEmbed: false},
+		{name: "", stash: ds, in: mock.NewMessage(gb.None), wantErr: false, wantDiscErr: false, wantEmbed: false},
+		{name: "", stash: ds, in: mock.NewMessage(gb.None), wantErr: false, wantDiscErr: false, wantEmbed: false},
 
 		// List
-		{name: "", stash: &ds, in: mock.NewMessage(gb.None), wantErr: false, wantDiscErr: false, wantEmbed: false},
+		{name: "", stash: ds, in: mock.NewMessage(gb.None), wantErr: false, wantDiscErr: false, wantEmbed: false},
 	}
 
 	for _, test := range tests {
@@ -91,8 +94,8 @@ func TestSave(t *testing.T) {
 		stash   *Stash
 		wantErr bool
 	}{
-		{name: "normal", path: "meme_test", stash: &s, wantErr: false},
-		{name: "bad-path", path: "not-a-dir", stash: &s, wantErr: true},
+		{name: "normal", path: "meme_test", stash: s, wantErr: false},
+		{name: "bad-path", path: "not-a-dir", stash: s, wantErr: true},
 		{name: "nil-stash", path: "meme_test", stash: nil, wantErr: true},
 	}
 
@@ -123,7 +126,7 @@ func TestLoad(t *testing.T) {
 		wantStash *Stash
 		wantErr   bool
 	}{
-		{name: "normal", path: "meme_test", stash: &Stash{}, wantStash: &s, wantErr: false},
+		{name: "normal", path: "meme_test", stash: &Stash{}, wantStash: s, wantErr: false},
 		{name: "bad-path", path: "not-a-dir", stash: &Stash{}, wantStash: nil, wantErr: true},
 		{name: "nil-stash", path: "meme_test", stash: nil, wantStash: nil, wantErr: true},
 	}
@@ -144,10 +147,49 @@ func TestLoad(t *testing.T) {
 			}
 
 			if err == nil && !test.wantErr {
-				if !cmp.Equal(*test.stash, *test.wantStash) {
-					t.Errorf("incorrect stash after load:\n%s", cmp.Diff(*test.stash, *test.wantStash))
+				// the lock isn't part of the saved state
+				opt := cmpopts.IgnoreUnexported(Stash{})
+				if !cmp.Equal(test.stash, test.wantStash, opt) {
+					t.Errorf("incorrect stash after load:\n%s", cmp.Diff(test.stash, test.wantStash, opt))
 				}
 			}
 		})
 	}
 }
+
+/*
+Test Cases:
+- remove asks before removing
+- confirming removes the meme that was asked about, even if others moved
+- confirming twice finds the meme gone
+*/
+func TestInterceptor_Remove(t *testing.T) {
+	dir, err := ioutil.TempDir("", "meme_remove")
+	if err != nil {
+		t.Fatalf("temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	ss := NewStashes(dir)
+	s := ss.Get(0)
+	s.Memes = []*Meme{NewMeme("https://a.png", "user"), NewMeme("https://b.png", "user")}
+
+	msg := mock.NewMessage(gb.Meme, mock.WithArgs("remove", "1"))
+	if err := Interceptor(ss)(msg); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	if msg.Confirm == nil || msg.Confirm.Prompt != "Remove meme 1, https://b.png?" || len(s.Memes) != 2 {
+		t.Fatalf("didn't ask first (confirm = %+v, memes = %d)", msg.Confirm, len(s.Memes))
+	}
+
+	s.Memes = s.Memes[1:]
+	reply := mock.NewMessage(gb.None)
+	if err := msg.Confirm.Action(reply); err != nil || reply.Response.Text != "Removed meme https://b.png." || len(s.Memes) != 0 {
+		t.Errorf("not removed (err = %v, response = %+v, memes = %d)", err, reply.Response, len(s.Memes))
+	}
+
+	again := mock.NewMessage(gb.None)
+	if err := msg.Confirm.Action(again); err != nil || again.Response.Embed == nil || again.Response.Embed.Title != "Meme Not Found" {
+		t.Errorf("removed twice (err = %v, response = %+v)", err, again.Response)
+	}
+}
@@ -0,0 +1,179 @@
+package meme
+
+import (
+	"fmt"
+	"github.com/bwmarrin/discordgo"
+	gb "github.com/ericebersohl/gobottas"
+	"github.com/ericebersohl/gobottas/args"
+	"github.com/ericebersohl/gobottas/discord"
+	"strings"
+	"time"
+)
+
+// A meme waiting for a moderator to approve it
+type Submission struct {
+	Id          int          `json:"id"`
+	Meme        *Meme        `json:"meme"`
+	SubmitterId gb.Snowflake `json:"submitter-id"`
+	ChannelId   gb.Snowflake `json:"channel-id"` // where the submitter is told the outcome
+	GuildId     gb.Snowflake `json:"guild-id"`
+}
+
+// Report whether memes added in the guild need a moderator's approval
+func (s *Stash) IsModerated(guild gb.Snowflake) bool {
+	return s.Moderated[guild]
+}
+
+// Turn the approval workflow on or off for a guild
+func (s *Stash) SetModerated(guild gb.Snowflake, on bool) {
+	if s.Moderated == nil {
+		s.Moderated = make(map[gb.Snowflake]bool)
+	}
+
+	if on {
+		s.Moderated[guild] = true
+	} else {
+		delete(s.Moderated, guild)
+	}
+}
+
+// Put a meme in the pending list.  Memes that duplicate the stash or another submission are rejected straight away.
+func (s *Stash) Submit(m *Meme, src *gb.Source) (*Submission, error) {
+	if i := s.FindDuplicate(m); i >= 0 {
+		return nil, discord.NewError("Possible Duplicate", fmt.Sprintf("That looks like meme %d:\n%s", i, s.Memes[i].Meme))
+	}
+
+	norm := Normalize(m.Meme)
+	for _, p := range s.Pending {
+		if Normalize(p.Meme.Meme) == norm {
+			return nil, discord.NewError("Already Submitted", fmt.Sprintf("That meme is already waiting for approval as submission %d.", p.Id))
+		}
+	}
+
+	sub := Submission{
+		Id:          s.NextId,
+		Meme:        m,
+		SubmitterId: src.AuthorId,
+		ChannelId:   src.ChannelId,
+		GuildId:     src.GuildId,
+	}
+	s.NextId++
+	s.Pending = append(s.Pending, &sub)
+
+	return &sub, nil
+}
+
+// remove a guild's submission from the pending list
+func (s *Stash) takePending(id int, guild gb.Snowflake) (*Submission, error) {
+	for i, p := range s.Pending {
+		if p.Id == id && p.GuildId == guild {
+			s.Pending = append(s.Pending[:i], s.Pending[i+1:]...)
+			return p, nil
+		}
+	}
+	return nil, discord.NewError("Submission Not Found", "Could not find a pending meme with that id.")
+}
+
+// Move a guild's submission into the stash
+func (s *Stash) Approve(id int, guild gb.Snowflake) (*Submission, error) {
+	sub, err := s.takePending(id, guild)
+	if err != nil {
+		return nil, err
+	}
+
+	// the moderator has looked at it, so skip the duplicate check
+	if err := s.Add(sub.Meme, true); err != nil {
+		return nil, err
+	}
+
+	return sub, nil
+}
+
+// Drop a guild's submission
+func (s *Stash) Reject(id int, guild gb.Snowflake) (*Submission, error) {
+	return s.takePending(id, guild)
+}
+
+// Build the embed listing the submissions waiting for approval in a guild
+func (s *Stash) PendingEmbed(guild gb.Snowflake) *discordgo.MessageEmbed {
+	e := discord.NewEmbed().
+		EmbedColor(gb.MemeCol).
+		EmbedTitle("Pending Memes").
+		EmbedTimestamp(time.Now())
+
+	n := 0
+	for _, p := range s.Pending {
+		if p.GuildId != guild {
+			continue
+		}
+		e = e.AddField(fmt.Sprintf("%d: %s", p.Id, p.Meme.AddedBy), p.Meme.Meme, false)
+		n++
+	}
+
+	if n == 0 {
+		e = e.EmbedDescription("No memes are waiting for approval.")
+	}
+
+	return e.MessageEmbed
+}
+
+// Handle the commands of the approval workflow; the caller has checked the sender is a moderator
+func moderate(s *Stash, cmd Command, msg *gb.Message) error {
+	guild := msg.Source.GuildId
+
+	switch cmd {
+	case MPending:
+		msg.Response.Embed = s.PendingEmbed(guild)
+		return nil
+
+	case MModeration:
+		var a struct {
+			Mode string `arg:"on|off"`
+		}
+		if err := args.Parse("&meme moderation", msg.Args[1:], &a); err != nil {
+			return embedError(msg, err)
+		}
+
+		if a.Mode != "on" && a.Mode != "off" {
+			msg.Response.Embed = discord.NewError("Invalid Argument", "moderation must be on or off:\n`&meme moderation [on|off]`").Embed()
+			return nil
+		}
+
+		before := "off"
+		if s.IsModerated(guild) {
+			before = "on"
+		}
+		s.SetModerated(guild, a.Mode == "on")
+		msg.AddChange("set meme moderation", "meme.moderation", before, a.Mode)
+		msg.Response.Text = fmt.Sprintf("Meme approval is %s.", a.Mode)
+		return save(s, msg)
+	}
+
+	var err error
+	if cmd == MApprove {
+		var a struct {
+			Id int `arg:"id"`
+		}
+		if err := args.Parse("&meme approve", msg.Args[1:], &a); err != nil {
+			return embedError(msg, err)
+		}
+
+		_, err = s.ApproveSubmission(msg, a.Id)
+	} else {
+		// a rejection may give a reason
+		var a struct {
+			Id     int      `arg:"id"`
+			Reason []string `arg:"reason,optional"`
+		}
+		if err := args.Parse("&meme reject", msg.Args[1:], &a); err != nil {
+			return embedError(msg, err)
+		}
+
+		_, err = s.RejectSubmission(msg, a.Id, strings.Join(a.Reason, " "))
+	}
+
+	if err != nil {
+		return embedError(msg, err)
+	}
+	return save(s, msg)
+}
@@ -0,0 +1,92 @@
+package meme
+
+import (
+	gb "github.com/ericebersohl/gobottas"
+	"github.com/ericebersohl/gobottas/mock"
+	"os"
+	"strings"
+	"testing"
+)
+
+/*
+Test Cases:
+- moderation: not moderator, bad arg, on
+- add: regular user is submitted, duplicate of stash, duplicate of submission, moderator goes straight in
+- pending: lists submissions
+- approve: bad atoi, other guild, normal (submitter notified)
+- reject: not found, normal with reason
+- off: regular users add directly again
+*/
+func TestInterceptor_Moderation(t *testing.T) {
+	_ = os.Mkdir("meme_test", 0755)
+	defer os.RemoveAll("meme_test")
+
+	ss := NewStashes("meme_test")
+	s := ss.Get(1)
+
+	user := func(args ...string) *gb.Message {
+		return mock.NewMessage(gb.Meme, mock.WithSource(42, 7, "user", ""), mock.WithGuild(1), mock.WithArgs(args...))
+	}
+	mod := func(args ...string) *gb.Message {
+		return mock.NewMessage(gb.Meme, mock.WithSource(9, 8, "mod", ""), mock.WithGuild(1), mock.WithModerator(), mock.WithArgs(args...))
+	}
+
+	tests := []struct {
+		name        string
+		in          *gb.Message
+		wantEmbed   bool
+		wantText    string
+		wantChannel gb.Snowflake
+		wantLen     int
+		wantPending int
+	}{
+		{name: "mod-not-allowed", in: user("moderation", "on"), wantEmbed: true, wantChannel: 7, wantLen: 3},
+		{name: "mod-bad-arg", in: mod("moderation", "maybe"), wantEmbed: true, wantChannel: 8, wantLen: 3},
+		{name: "mod-on", in: mod("moderation", "on"), wantText: "approval is on", wantChannel: 8, wantLen: 3},
+		{name: "submit", in: user("add", "Box box"), wantText: "Submitted meme 0", wantChannel: 7, wantLen: 3, wantPending: 1},
+		{name: "submit-second", in: user("add", "Hold position"), wantText: "Submitted meme 1", wantChannel: 7, wantLen: 3, wantPending: 2},
+		{name: "submit-dup-stash", in: user("add", "is his career over"), wantEmbed: true, wantChannel: 7, wantLen: 3, wantPending: 2},
+		{name: "submit-dup-pending", in: user("add", "box, box!"), wantEmbed: true, wantChannel: 7, wantLen: 3, wantPending: 2},
+		{name: "mod-add", in: mod("add", "Leave me alone"), wantChannel: 8, wantLen: 4, wantPending: 2},
+		{name: "pending", in: mod("pending"), wantEmbed: true, wantChannel: 8, wantLen: 4, wantPending: 2},
+		{name: "approve-bad-atoi", in: mod("approve", "zero"), wantEmbed: true, wantChannel: 8, wantLen: 4, wantPending: 2},
+		{name: "approve-other-guild", in: mock.NewMessage(gb.Meme, mock.WithGuild(2), mock.WithModerator(), mock.WithArgs("approve", "0")), wantEmbed: true, wantLen: 4, wantPending: 2},
+		{name: "approve", in: mod("approve", "0"), wantText: "<@42> your meme \"Box box\" was approved by mod", wantChannel: 7, wantLen: 5, wantPending: 1},
+		{name: "reject-not-found", in: mod("reject", "0"), wantEmbed: true, wantChannel: 8, wantLen: 5, wantPending: 1},
+		{name: "reject", in: mod("reject", "1", "not", "funny"), wantText: "rejected (not funny) by mod", wantChannel: 7, wantLen: 5, wantPending: 0},
+		{name: "mod-off", in: mod("moderation", "off"), wantText: "approval is off", wantChannel: 8, wantLen: 5},
+		{name: "add-unmoderated", in: user("add", "Multi 21"), wantChannel: 7, wantLen: 6},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := Interceptor(ss)(test.in); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			r := test.in.Response
+			if (r.Embed != nil) != test.wantEmbed {
+				t.Errorf("embed != wantEmbed (embed == nil: %t)", r.Embed == nil)
+			}
+
+			if !strings.Contains(r.Text, test.wantText) {
+				t.Errorf("text = %q, want it to contain %q", r.Text, test.wantText)
+			}
+
+			if r.ChannelId != test.wantChannel {
+				t.Errorf("channel = %d, want %d", r.ChannelId, test.wantChannel)
+			}
+
+			if len(s.Memes) != test.wantLen || len(s.Pending) != test.wantPending {
+				t.Errorf("len = %d, pending = %d, want %d, %d", len(s.Memes), len(s.Pending), test.wantLen, test.wantPending)
+			}
+		})
+	}
+
+	// the workflow state survives a save and load
+	_ = s.Save(s.LocalPath)
+	l := Stash{}
+	if err := l.Load(s.LocalPath); err != nil || l.NextId != 2 {
+		t.Errorf("load: err = %v, next id = %d", err, l.NextId)
+	}
+}
@@ -0,0 +1,74 @@
+package meme
+
+import (
+	"fmt"
+	gb "github.com/ericebersohl/gobottas"
+	"github.com/ericebersohl/gobottas/event"
+)
+
+// Changes to the stash on behalf of a message's author, whether they come from a chat command or the admin API.  Each
+// one checks its input, journals the change, notes it on the message for the audit log and publishes its event.  The
+// caller holds the stash's lock and saves the stash.
+
+// Put a meme in the stash.  Unless force is set, memes that look like one already there are refused.  The meme is
+// fingerprinted before the stash is locked.
+func (s *Stash) AddMeme(msg *gb.Message, meme *Meme, force bool) error {
+	if err := s.Add(meme, force); err != nil {
+		return err
+	}
+
+	s.record(msg, "add", fmt.Sprintf("added meme %s", meme.Meme), change{Memes: []*Meme{meme}})
+	msg.AddChange("add meme", meme.Meme, "", fmt.Sprintf("meme %d", len(s.Memes)-1))
+	msg.Publish(&event.MemeAdded{Base: event.From(msg), Meme: meme.Meme, AddedBy: meme.AddedBy})
+	return nil
+}
+
+// Take a meme out of the stash
+func (s *Stash) RemoveMeme(msg *gb.Message, m *Meme) error {
+	i := s.find(m.Meme)
+	if err := s.Remove(m); err != nil {
+		return err
+	}
+
+	s.record(msg, "remove", fmt.Sprintf("removed meme %s", m.Meme), change{Memes: []*Meme{m}, Index: i})
+	msg.AddChange("remove meme", m.Meme, fmt.Sprintf("meme %d", i), "")
+	return nil
+}
+
+// Move a submission from the author's guild into the stash, and tell the submitter where they submitted
+func (s *Stash) ApproveSubmission(msg *gb.Message, id int) (*Submission, error) {
+	sub, err := s.Approve(id, msg.Source.GuildId)
+	if err != nil {
+		return nil, err
+	}
+
+	s.record(msg, "approve", fmt.Sprintf("approved meme %s", sub.Meme.Meme), change{Memes: []*Meme{sub.Meme}, Submission: sub})
+	msg.Publish(&event.MemeAdded{Base: event.From(msg), Meme: sub.Meme.Meme, AddedBy: sub.Meme.AddedBy})
+	reviewed(msg, sub, "approve", "approved")
+	return sub, nil
+}
+
+// Drop a submission from the author's guild, and tell the submitter why if there's a reason
+func (s *Stash) RejectSubmission(msg *gb.Message, id int, reason string) (*Submission, error) {
+	sub, err := s.Reject(id, msg.Source.GuildId)
+	if err != nil {
+		return nil, err
+	}
+
+	outcome := "rejected"
+	if reason != "" {
+		outcome = fmt.Sprintf("rejected (%s)", reason)
+	}
+
+	s.record(msg, "reject", fmt.Sprintf("rejected meme %s", sub.Meme.Meme), change{Submission: sub})
+	reviewed(msg, sub, "reject", outcome)
+	return sub, nil
+}
+
+// note a review for the audit log, and tell the submitter the outcome where they submitted
+func reviewed(msg *gb.Message, sub *Submission, action, outcome string) {
+	msg.AddChange(action+" meme", sub.Meme.Meme, fmt.Sprintf("submission %d", sub.Id), outcome)
+
+	msg.Response.ChannelId = sub.ChannelId
+	msg.Response.Text = fmt.Sprintf("<@%s> your meme %q was %s by %s.", sub.SubmitterId, sub.Meme.Meme, outcome, msg.Source.Username)
+}
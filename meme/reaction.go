@@ -0,0 +1,40 @@
+package meme
+
+import (
+	"fmt"
+	gb "github.com/ericebersohl/gobottas"
+	"github.com/ericebersohl/gobottas/confirm"
+)
+
+// What the bot says when a meme is submitted; moderators review the submission by reacting to it
+const submitted = "Submitted meme %d; a moderator will review it."
+
+// Answer a moderator's reaction to the bot's notice of a submission: approve it with ✅ or reject it with ❌, as if
+// they'd used &meme approve or &meme reject.  Reactions to anything else, and other people's, are left alone.
+func react(ss *Stashes, msg *gb.Message) error {
+	rx := msg.Reaction
+	if rx == nil || !rx.FromBot || !msg.Source.Moderator || (rx.Emoji != confirm.Yes && rx.Emoji != confirm.No) {
+		return nil
+	}
+
+	var id int
+	if _, err := fmt.Sscanf(rx.Content, submitted, &id); err != nil {
+		return nil
+	}
+
+	s := ss.Get(msg.Source.GuildId)
+	s.Lock()
+	defer s.Unlock()
+
+	msg.Response.ChannelId = msg.Source.ChannelId
+	var err error
+	if rx.Emoji == confirm.Yes {
+		_, err = s.ApproveSubmission(msg, id)
+	} else {
+		_, err = s.RejectSubmission(msg, id, "")
+	}
+	if err != nil {
+		return embedError(msg, err)
+	}
+	return save(s, msg)
+}
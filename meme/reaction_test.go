@@ -0,0 +1,91 @@
+package meme
+
+import (
+	gb "github.com/ericebersohl/gobottas"
+	"github.com/ericebersohl/gobottas/confirm"
+	"github.com/ericebersohl/gobottas/mock"
+	"os"
+	"strings"
+	"testing"
+)
+
+/*
+Test Cases:
+- a moderator's ✅ on the bot's notice of a submission approves it, telling the submitter, and ❌ rejects it
+- reactions by regular users, to other messages or with other emoji change nothing
+- a reaction to a submission already reviewed says it's gone
+- the review is saved
+*/
+func TestInterceptor_Reactions(t *testing.T) {
+	_ = os.Mkdir("meme_test", 0755)
+	defer os.RemoveAll("meme_test")
+
+	ss := NewStashes("meme_test")
+	s := ss.Get(1)
+	s.SetModerated(1, true)
+
+	// two submissions, and the notices the bot sent for them
+	var notices []string
+	for _, text := range []string{"Box box", "Hold position"} {
+		msg := mock.NewMessage(gb.Meme, mock.WithSource(42, 7, "user", ""), mock.WithGuild(1), mock.WithArgs("add", text))
+		if err := Interceptor(ss)(msg); err != nil {
+			t.Fatalf("submit: %v", err)
+		}
+		notices = append(notices, msg.Response.Text)
+	}
+
+	react := func(emoji, notice string, mod, fromBot bool) *gb.Message {
+		opts := []mock.MessageOpt{mock.WithSource(9, 8, "mod", ""), mock.WithGuild(1), mock.WithReaction(emoji, 99, fromBot)}
+		if mod {
+			opts = append(opts, mock.WithModerator())
+		}
+		msg := mock.NewMessage(gb.Reaction, opts...)
+		msg.Reaction.Content = notice
+		return msg
+	}
+
+	tests := []struct {
+		name        string
+		in          *gb.Message
+		wantText    string
+		wantEmbed   bool
+		wantChannel gb.Snowflake
+		wantLen     int
+		wantPending int
+	}{
+		{name: "not-moderator", in: react(confirm.Yes, notices[0], false, true), wantLen: 3, wantPending: 2},
+		{name: "not-bot", in: react(confirm.Yes, notices[0], true, false), wantLen: 3, wantPending: 2},
+		{name: "other-message", in: react(confirm.Yes, "Meme approval is on.", true, true), wantLen: 3, wantPending: 2},
+		{name: "other-emoji", in: react("👍", notices[0], true, true), wantLen: 3, wantPending: 2},
+		{name: "approve", in: react(confirm.Yes, notices[0], true, true), wantText: "<@42> your meme \"Box box\" was approved by mod", wantChannel: 7, wantLen: 4, wantPending: 1},
+		{name: "approve-again", in: react(confirm.Yes, notices[0], true, true), wantEmbed: true, wantChannel: 8, wantLen: 4, wantPending: 1},
+		{name: "reject", in: react(confirm.No, notices[1], true, true), wantText: "<@42> your meme \"Hold position\" was rejected by mod", wantChannel: 7, wantLen: 4, wantPending: 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := Interceptor(ss)(test.in); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			r := test.in.Response
+			if !strings.Contains(r.Text, test.wantText) || (test.wantText == "") != (r.Text == "") {
+				t.Errorf("text = %q, want %q", r.Text, test.wantText)
+			}
+			if (r.Embed != nil) != test.wantEmbed {
+				t.Errorf("embed != wantEmbed (embed == nil: %t)", r.Embed == nil)
+			}
+			if r.ChannelId != test.wantChannel {
+				t.Errorf("channel = %d, want %d", r.ChannelId, test.wantChannel)
+			}
+			if len(s.Memes) != test.wantLen || len(s.Pending) != test.wantPending {
+				t.Errorf("len = %d, pending = %d, want %d, %d", len(s.Memes), len(s.Pending), test.wantLen, test.wantPending)
+			}
+		})
+	}
+
+	saved := Stash{}
+	if err := saved.Load(s.LocalPath); err != nil || len(saved.Memes) != 4 || len(saved.Pending) != 0 {
+		t.Errorf("reviews not saved (err = %v, memes = %d, pending = %d)", err, len(saved.Memes), len(saved.Pending))
+	}
+}
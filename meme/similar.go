@@ -0,0 +1,240 @@
+package meme
+
+import (
+	"bytes"
+	"fmt"
+	gb "github.com/ericebersohl/gobottas"
+	"github.com/ericebersohl/gobottas/discord"
+	"image"
+	_ "image/gif"  // register gif for image.Decode
+	_ "image/jpeg" // register jpeg for image.Decode
+	_ "image/png"  // register png for image.Decode
+	"math/bits"
+	"net/url"
+	"path"
+	"strings"
+	"unicode"
+)
+
+// Thresholds for treating two memes as the same
+const (
+	TextSimilarity = 0.8 // minimum share of words two text memes have in common
+	HashDistance   = 10  // maximum number of differing bits between two image hashes
+)
+
+// Limits on the images downloaded to be hashed: the size of the file, and the pixels it may decode to so that a small
+// file can't expand into a huge image
+const (
+	MaxImageSize   = 10 << 20
+	MaxImagePixels = 1 << 24
+)
+
+// Hosts image memes are downloaded from to be hashed.  Links anywhere else are compared by text, so that chat can't
+// make the bot request arbitrary addresses.
+var ImageHosts = []string{"cdn.discordapp.com", "media.discordapp.net"}
+
+// Report whether an image can be downloaded from a link: it must be https on one of the ImageHosts
+func imageHost(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme != "https" {
+		return false
+	}
+
+	for _, h := range ImageHosts {
+		if strings.EqualFold(u.Hostname(), h) {
+			return true
+		}
+	}
+	return false
+}
+
+// Lower-case a meme and strip punctuation and extra whitespace, so "Is his career over!?" and "is his career over"
+// compare equal
+func Normalize(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(s) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(r)
+		case unicode.IsSpace(r):
+			b.WriteRune(' ')
+		}
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// Token-set (Jaccard) similarity of two memes: the number of distinct words they share over the number of distinct
+// words in either.  Memes with no words, like a lone emoji, aren't similar to anything.
+func Similarity(a, b string) float64 {
+	ta, tb := tokenSet(a), tokenSet(b)
+	if len(ta) == 0 || len(tb) == 0 {
+		return 0
+	}
+
+	shared := 0
+	for t := range ta {
+		if tb[t] {
+			shared++
+		}
+	}
+
+	return float64(shared) / float64(len(ta)+len(tb)-shared)
+}
+
+func tokenSet(s string) map[string]bool {
+	set := make(map[string]bool)
+	for _, t := range strings.Fields(Normalize(s)) {
+		set[t] = true
+	}
+	return set
+}
+
+// Report whether a meme is a link to an image, which is compared by perceptual hash rather than text
+func IsImage(s string) bool {
+	if !strings.HasPrefix(s, "http://") && !strings.HasPrefix(s, "https://") || strings.ContainsAny(s, " \n") {
+		return false
+	}
+
+	if i := strings.IndexAny(s, "?#"); i >= 0 {
+		s = s[:i]
+	}
+
+	switch strings.ToLower(path.Ext(s)) {
+	case ".png", ".jpg", ".jpeg", ".gif":
+		return true
+	default:
+		return false
+	}
+}
+
+// Download an image and compute its difference hash: the image is shrunk to 9x8 grey pixels and each bit records
+// whether a pixel is brighter than its right neighbour.  Resized or recompressed copies of an image hash to values
+// only a few bits apart.  Only images on the ImageHosts and within MaxImageSize and MaxImagePixels are hashed.
+func HashImage(url string) (uint64, error) {
+	if !imageHost(url) {
+		return 0, fmt.Errorf("hash %s: images are only downloaded from %s", url, strings.Join(ImageHosts, ", "))
+	}
+
+	data, err := fetch(url, MaxImageSize)
+	if err != nil {
+		return 0, err
+	}
+
+	// check the dimensions in the header before decoding the pixels
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return 0, err
+	}
+	if px := int64(cfg.Width) * int64(cfg.Height); px > MaxImagePixels {
+		return 0, fmt.Errorf("hash %s: %dx%d image is over the %d pixel limit", url, cfg.Width, cfg.Height, MaxImagePixels)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return 0, err
+	}
+
+	return dHash(img), nil
+}
+
+func dHash(img image.Image) uint64 {
+	const w, h = 9, 8
+
+	// average the brightness of the block of source pixels behind each target pixel
+	var grey [h][w]float64
+	b := img.Bounds()
+	for y := 0; y < h; y++ {
+		y0 := b.Min.Y + y*b.Dy()/h
+		y1 := b.Min.Y + (y+1)*b.Dy()/h
+		if y1 == y0 {
+			y1++
+		}
+
+		for x := 0; x < w; x++ {
+			x0 := b.Min.X + x*b.Dx()/w
+			x1 := b.Min.X + (x+1)*b.Dx()/w
+			if x1 == x0 {
+				x1++
+			}
+
+			var sum float64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					r, g, bl, _ := img.At(sx, sy).RGBA()
+					sum += 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(bl)
+				}
+			}
+			grey[y][x] = sum / float64((y1-y0)*(x1-x0))
+		}
+	}
+
+	var hash uint64
+	for y := 0; y < h; y++ {
+		for x := 0; x < w-1; x++ {
+			hash <<= 1
+			if grey[y][x] > grey[y][x+1] {
+				hash |= 1
+			}
+		}
+	}
+
+	return hash
+}
+
+// Find a meme in the stash that m duplicates.  Text memes match when their normalized words are similar enough,
+// image memes when they are the same link or their hashes are close.  Returns -1 if there is no duplicate.
+func (s *Stash) FindDuplicate(m *Meme) int {
+	norm := Normalize(m.Meme)
+
+	for i, e := range s.Memes {
+		if e.Meme == m.Meme || norm != "" && Normalize(e.Meme) == norm {
+			return i
+		}
+
+		if m.Hash != 0 && e.Hash != 0 {
+			if bits.OnesCount64(m.Hash^e.Hash) <= HashDistance {
+				return i
+			}
+			continue
+		}
+
+		if !IsImage(m.Meme) && !IsImage(e.Meme) && Similarity(m.Meme, e.Meme) >= TextSimilarity {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// Hash an image meme from one of the ImageHosts, so it can be compared by what it looks like.  This downloads the
+// image, so it is done before taking the stash's lock.  Memes that can't be hashed are compared by text, and the
+// failure is logged to l.
+func (m *Meme) Fingerprint(l *gb.Logger) {
+	if m.Hash != 0 || !IsImage(m.Meme) || !imageHost(m.Meme) {
+		return
+	}
+
+	h, err := HashImage(m.Meme)
+	if err != nil {
+		l.Warn("failed to hash an image meme", "meme", m.Meme, "err", err)
+	}
+	m.Hash = h
+}
+
+// Add a meme to the stash.  Unless force is set, memes that look like one already in the stash are rejected with a
+// discord.Error that names the existing meme.  Image memes should be fingerprinted first.
+func (s *Stash) Add(m *Meme, force bool) error {
+	if m == nil || strings.TrimSpace(m.Meme) == "" {
+		return discord.NewError("Empty Meme", "Cannot add an empty meme.")
+	}
+
+	if !force {
+		if i := s.FindDuplicate(m); i >= 0 {
+			return discord.NewError("Possible Duplicate", fmt.Sprintf("That looks like meme %d:\n%s\n"+
+				"Moderators can add it anyway with `&meme add --force [meme]`.", i, s.Memes[i].Meme))
+		}
+	}
+
+	s.Memes = append(s.Memes, m)
+	return nil
+}
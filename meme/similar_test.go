@@ -0,0 +1,265 @@
+package meme
+
+import (
+	"bytes"
+	"encoding/binary"
+	gb "github.com/ericebersohl/gobottas"
+	"github.com/ericebersohl/gobottas/mock"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"os"
+	"reflect"
+	"testing"
+)
+
+/*
+Test Cases:
+- case and punctuation, whitespace, unicode letters
+*/
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{in: "Is his career over!?", want: "is his career over"},
+		{in: "  Stay   out.\n\tIN!  ", want: "stay out in"},
+		{in: "Ça va, Zoë?", want: "ça va zoë"},
+	}
+
+	for _, test := range tests {
+		if got := Normalize(test.in); got != test.want {
+			t.Errorf("Normalize(%q) = %q, want %q", test.in, got, test.want)
+		}
+	}
+}
+
+/*
+Test Cases:
+- identical, reordered, one word different, unrelated
+- emoji only, punctuation only, empty
+*/
+func TestSimilarity(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want float64
+	}{
+		{a: "When did I do dangerous driving?", b: "when did i do DANGEROUS driving", want: 1},
+		{a: "in in stay out", b: "Stay out. IN!", want: 1},
+		{a: "one two three four", b: "one two three five", want: 0.6},
+		{a: "apples", b: "oranges", want: 0},
+		{a: "🔥", b: "😂😂😂", want: 0},
+		{a: "???", b: "!!!", want: 0},
+		{a: "", b: "apples", want: 0},
+	}
+
+	for _, test := range tests {
+		if got := Similarity(test.a, test.b); got != test.want {
+			t.Errorf("Similarity(%q, %q) = %v, want %v", test.a, test.b, got, test.want)
+		}
+	}
+}
+
+/*
+Test Cases:
+- image link, query string, text, non-image link, text mentioning an image
+*/
+func TestIsImage(t *testing.T) {
+	tests := []struct {
+		in   string
+		want bool
+	}{
+		{in: "https://i.imgur.com/abc.PNG", want: true},
+		{in: "https://cdn.discordapp.com/a/b/meme.jpg?width=300", want: true},
+		{in: "just text.png", want: false},
+		{in: "https://example.com/page.html", want: false},
+		{in: "look at https://i.imgur.com/abc.png", want: false},
+	}
+
+	for _, test := range tests {
+		if got := IsImage(test.in); got != test.want {
+			t.Errorf("IsImage(%q) = %t, want %t", test.in, got, test.want)
+		}
+	}
+}
+
+/*
+Test Cases:
+- HashImage: image, image with more pixels than allowed, file that isn't an image, link off discord's CDN
+*/
+func TestHashImage(t *testing.T) {
+	const cdn = "https://cdn.discordapp.com/attachments/1/2/"
+	images := map[string][]byte{
+		cdn + "a.png":    pattern(90, 80, false),
+		cdn + "bomb.png": header(100000, 100000),
+		cdn + "text.png": []byte("not an image"),
+	}
+	fetch = func(url string, _ int64) ([]byte, error) {
+		return images[url], nil
+	}
+
+	tests := []struct {
+		name    string
+		url     string
+		wantErr bool
+	}{
+		{name: "image", url: cdn + "a.png", wantErr: false},
+		{name: "too-many-pixels", url: cdn + "bomb.png", wantErr: true},
+		{name: "not-image", url: cdn + "text.png", wantErr: true},
+		{name: "off-cdn", url: "https://example.com/a.png", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h, err := HashImage(test.url)
+			if (err != nil) != test.wantErr {
+				t.Errorf("err != wantErr (err = %v, wantErr = %v)", err, test.wantErr)
+			}
+			if !test.wantErr && h == 0 {
+				t.Errorf("hash = 0")
+			}
+		})
+	}
+}
+
+// the start of a png that claims to be w by h pixels, with no pixels after it
+func header(w, h uint32) []byte {
+	ihdr := make([]byte, 17)
+	copy(ihdr, "IHDR")
+	binary.BigEndian.PutUint32(ihdr[4:], w)
+	binary.BigEndian.PutUint32(ihdr[8:], h)
+	ihdr[12] = 8 // 8 bit greyscale
+
+	var buf bytes.Buffer
+	buf.WriteString("\x89PNG\r\n\x1a\n")
+	_ = binary.Write(&buf, binary.BigEndian, uint32(13))
+	buf.Write(ihdr)
+	_ = binary.Write(&buf, binary.BigEndian, crc32.ChecksumIEEE(ihdr))
+	return buf.Bytes()
+}
+
+// draw a png with a smooth wave pattern, or noise when noisy is set
+func pattern(w, h int, noisy bool) []byte {
+	img := image.NewGray(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			fx, fy := float64(x)/float64(w), float64(y)/float64(h)
+			v := 128 + 127*math.Sin(2*math.Pi*fx)*math.Cos(math.Pi*fy)
+			if noisy {
+				v = float64((x*7 + y*13) % 256)
+			}
+			img.SetGray(x, y, color.Gray{Y: uint8(v)})
+		}
+	}
+
+	var buf bytes.Buffer
+	_ = png.Encode(&buf, img)
+	return buf.Bytes()
+}
+
+/*
+Test Cases:
+- Add: empty, new text, near duplicate text, forced duplicate
+- Add: emoji and punctuation memes only duplicate the same emoji or punctuation
+- Add: image, resized copy of the image, different image
+- Add: images off discord's CDN, or over http, aren't downloaded and are compared by link
+*/
+func TestStash_Add(t *testing.T) {
+	const cdn = "https://cdn.discordapp.com/attachments/1/2/"
+	images := map[string][]byte{
+		cdn + "a.png":       pattern(90, 80, false),
+		cdn + "a-small.png": pattern(45, 40, false),
+		cdn + "b.png":       pattern(90, 80, true),
+	}
+	var fetched []string
+	fetch = func(url string, _ int64) ([]byte, error) {
+		fetched = append(fetched, url)
+		return images[url], nil
+	}
+
+	s := DefaultStash("meme_test")
+
+	tests := []struct {
+		name    string
+		in      string
+		force   bool
+		wantErr bool
+	}{
+		{name: "empty", in: "  ", wantErr: true},
+		{name: "new-text", in: "Box box", wantErr: false},
+		{name: "near-dup-text", in: "is his CAREER over", wantErr: true},
+		{name: "forced-dup", in: "is his CAREER over", force: true, wantErr: false},
+		{name: "emoji", in: "🔥", wantErr: false},
+		{name: "other-emoji", in: "😂😂😂", wantErr: false},
+		{name: "same-emoji", in: "🔥", wantErr: true},
+		{name: "punctuation", in: "???", wantErr: false},
+		{name: "other-punctuation", in: "!!!", wantErr: false},
+		{name: "image", in: cdn + "a.png", wantErr: false},
+		{name: "resized-image", in: cdn + "a-small.png", wantErr: true},
+		{name: "other-image", in: cdn + "b.png", wantErr: false},
+		{name: "internal-image", in: "https://169.254.169.254/a.png", wantErr: false},
+		{name: "http-image", in: "http://cdn.discordapp.com/attachments/1/2/a.png", wantErr: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			l := len(s.Memes)
+			m := NewMeme(test.in, "user")
+			m.Fingerprint(nil)
+			err := s.Add(m, test.force)
+			if (err != nil) != test.wantErr {
+				t.Errorf("err != wantErr (err = %v, wantErr = %v)", err, test.wantErr)
+			}
+
+			if want := l + 1; !test.wantErr && len(s.Memes) != want {
+				t.Errorf("len = %d, want %d", len(s.Memes), want)
+			}
+		})
+	}
+
+	if want := []string{cdn + "a.png", cdn + "a-small.png", cdn + "b.png"}; !reflect.DeepEqual(fetched, want) {
+		t.Errorf("fetched %v, want %v", fetched, want)
+	}
+}
+
+/*
+Test Cases:
+- add: duplicate, force without moderator, force as moderator, force flag only
+*/
+func TestInterceptor_Duplicates(t *testing.T) {
+	_ = os.Mkdir("meme_test", 0755)
+	defer os.RemoveAll("meme_test")
+
+	ss := NewStashes("meme_test")
+	s := ss.Get(0)
+
+	tests := []struct {
+		name      string
+		in        *gb.Message
+		wantEmbed bool
+		wantLen   int
+	}{
+		{name: "dup", in: mock.NewMessage(gb.Meme, mock.WithArgs("add", "Is his career over?")), wantEmbed: true, wantLen: 3},
+		{name: "force-not-mod", in: mock.NewMessage(gb.Meme, mock.WithArgs("add", "--force", "Is his career over?")), wantEmbed: true, wantLen: 3},
+		{name: "force-mod", in: mock.NewMessage(gb.Meme, mock.WithArgs("add", "Is his career over?", "-f"), mock.WithModerator()), wantEmbed: false, wantLen: 4},
+		{name: "force-only", in: mock.NewMessage(gb.Meme, mock.WithArgs("add", "--force"), mock.WithModerator()), wantEmbed: true, wantLen: 4},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := Interceptor(ss)(test.in); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if (test.in.Response.Embed != nil) != test.wantEmbed {
+				t.Errorf("embed != wantEmbed (embed == nil: %t)", test.in.Response.Embed == nil)
+			}
+
+			if len(s.Memes) != test.wantLen {
+				t.Errorf("len = %d, want %d", len(s.Memes), test.wantLen)
+			}
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package meme
+
+import (
+	"fmt"
+	gb "github.com/ericebersohl/gobottas"
+	"github.com/ericebersohl/gobottas/journal"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+)
+
+// Every guild's meme stash.  A guild's stash is loaded the first time it's used and saved in the guild's own
+// directory.  The one stash that older versions shared between guilds, if it was saved, starts off each guild that
+// has no stash of its own with its memes, and the submissions, schedules and moderation setting of that guild.
+type Stashes struct {
+	dir     string
+	journal *journal.Journal
+	log     *gb.Logger
+
+	mu      sync.Mutex
+	stashes map[gb.Snowflake]*Stash
+}
+
+type StashesOpt func(*Stashes)
+
+// Keep guilds' stashes in directories under dir
+func NewStashes(dir string, opts ...StashesOpt) *Stashes {
+	ss := Stashes{
+		dir:     dir,
+		stashes: make(map[gb.Snowflake]*Stash),
+	}
+
+	for _, o := range opts {
+		o(&ss)
+	}
+
+	return &ss
+}
+
+// record changes to every guild's stash in j
+func WithJournal(j *journal.Journal) StashesOpt {
+	return func(ss *Stashes) {
+		ss.journal = j
+	}
+}
+
+// log problems loading stashes with l
+func WithLogger(l *gb.Logger) StashesOpt {
+	return func(ss *Stashes) {
+		ss.log = l
+	}
+}
+
+// The directory a guild's stash is saved in
+func (ss *Stashes) Path(guild gb.Snowflake) string {
+	return fmt.Sprintf("%s/guilds/%s", ss.dir, guild)
+}
+
+// The guild's stash, loaded or started the first time it's asked for
+func (ss *Stashes) Get(guild gb.Snowflake) *Stash {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	if s, ok := ss.stashes[guild]; ok {
+		return s
+	}
+
+	s := ss.load(guild)
+	s.LocalPath = ss.Path(guild)
+	s.Journal = ss.journal
+	if err := os.MkdirAll(s.LocalPath, 0755); err != nil {
+		ss.log.Warn("failed to make the guild's directory; its stash won't be saved", "guild", guild, "err", err)
+	}
+	ss.stashes[guild] = s
+	return s
+}
+
+// read the guild's saved stash, or else its part of the shared one, or start with the default memes
+func (ss *Stashes) load(guild gb.Snowflake) *Stash {
+	path := ss.Path(guild)
+	if _, err := os.Stat(fmt.Sprintf("%s/meme.json", path)); !os.IsNotExist(err) {
+		s := DefaultStash(path)
+		if err := s.Load(path); err != nil {
+			ss.log.Warn("failed to load the meme stash; using the default memes", "guild", guild, "err", err)
+			return DefaultStash(path)
+		}
+		return s
+	}
+
+	s, ok := ss.shared()
+	if !ok {
+		return DefaultStash(path)
+	}
+
+	// only the guild's own part of what was shared comes along
+	pending := s.Pending
+	s.Pending = nil
+	for _, p := range pending {
+		if p.GuildId == guild {
+			s.Pending = append(s.Pending, p)
+		}
+	}
+	for ch, d := range s.Daily {
+		if d.GuildId != guild {
+			delete(s.Daily, ch)
+		}
+	}
+	s.Moderated = map[gb.Snowflake]bool{guild: s.Moderated[guild]}
+
+	return s
+}
+
+// the stash older versions shared between guilds, if it was saved and can be read
+func (ss *Stashes) shared() (*Stash, bool) {
+	if _, err := os.Stat(fmt.Sprintf("%s/meme.json", ss.dir)); os.IsNotExist(err) {
+		return nil, false
+	}
+
+	s := DefaultStash(ss.dir)
+	if err := s.Load(ss.dir); err != nil {
+		ss.log.Warn("failed to load the shared meme stash", "err", err)
+		return nil, false
+	}
+	return s, true
+}
+
+// Load the stash of every guild that has one saved, and of every guild with submissions or schedules in the shared
+// stash, so that their memes of the day are posted
+func (ss *Stashes) Load() error {
+	dirs, err := ioutil.ReadDir(fmt.Sprintf("%s/guilds", ss.dir))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	for _, d := range dirs {
+		guild, err := gb.ToSnowflake(d.Name())
+		if err != nil || !d.IsDir() {
+			continue
+		}
+		if _, err := os.Stat(fmt.Sprintf("%s/meme.json", ss.Path(guild))); err == nil {
+			ss.Get(guild)
+		}
+	}
+
+	if s, ok := ss.shared(); ok {
+		for _, p := range s.Pending {
+			ss.Get(p.GuildId)
+		}
+		for _, d := range s.Daily {
+			ss.Get(d.GuildId)
+		}
+	}
+	return nil
+}
+
+// The guilds that have a stash, in order
+func (ss *Stashes) Guilds() []gb.Snowflake {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	guilds := make([]gb.Snowflake, 0, len(ss.stashes))
+	for g := range ss.stashes {
+		guilds = append(guilds, g)
+	}
+	sort.Slice(guilds, func(i, j int) bool { return guilds[i] < guilds[j] })
+	return guilds
+}
+
+// Undo a journaled change to the stash of the guild it was made in and save it
+func (ss *Stashes) Undo(e *journal.Entry) error {
+	return ss.Get(e.GuildId).Undo(e)
+}
+
+// Redo a journaled change to the stash of the guild it was made in and save it
+func (ss *Stashes) Redo(e *journal.Entry) error {
+	return ss.Get(e.GuildId).Redo(e)
+}
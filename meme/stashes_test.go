@@ -0,0 +1,80 @@
+package meme
+
+import (
+	"encoding/json"
+	gb "github.com/ericebersohl/gobottas"
+	"github.com/ericebersohl/gobottas/journal"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+)
+
+/*
+Test Cases:
+- each guild has its own stash, saved in its own directory
+- the stash older versions shared starts off guilds without their own, with only their part of it, and is left alone
+- loading starts the stashes of guilds with submissions or schedules in the shared stash
+- a journaled change is undone in the stash of the guild it was made in
+*/
+func TestStashes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "stashes")
+	if err != nil {
+		t.Fatalf("temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	shared := &Stash{
+		Memes:     []*Meme{NewMeme("shared", "user")},
+		Pending:   []*Submission{{Id: 0, Meme: NewMeme("from 1", "user"), GuildId: 1}, {Id: 1, Meme: NewMeme("from 2", "user"), GuildId: 2}},
+		Daily:     map[gb.Snowflake]*Daily{10: {ChannelId: 10, GuildId: 1}, 20: {ChannelId: 20, GuildId: 2}},
+		Moderated: map[gb.Snowflake]bool{1: true},
+		NextId:    2,
+	}
+	if err := shared.Save(dir); err != nil {
+		t.Fatalf("save shared: %v", err)
+	}
+
+	ss := NewStashes(dir)
+	one := ss.Get(1)
+	if one.LocalPath != ss.Path(1) || len(one.Memes) != 1 || len(one.Pending) != 1 || one.Pending[0].GuildId != 1 ||
+		len(one.Daily) != 1 || one.Daily[10] == nil || !one.IsModerated(1) {
+		t.Errorf("guild 1 started with %+v", one)
+	}
+	if two := ss.Get(2); len(two.Pending) != 1 || two.Pending[0].GuildId != 2 || two.Daily[20] == nil || two.IsModerated(2) {
+		t.Errorf("guild 2 started with %+v", two)
+	}
+
+	one.Memes = append(one.Memes, NewMeme("one", "user"))
+	if err := one.Save(one.LocalPath); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if _, err := os.Stat(dir + "/guilds/1/meme.json"); err != nil {
+		t.Errorf("not saved in the guild's directory: %v", err)
+	}
+	if left := (&Stash{}); left.Load(dir) != nil || len(left.Memes) != 1 || len(left.Pending) != 2 {
+		t.Errorf("shared stash changed: %+v", left)
+	}
+
+	loaded := NewStashes(dir)
+	if err := loaded.Load(); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if got, want := loaded.Guilds(), []gb.Snowflake{1, 2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("guilds = %v, want %v", got, want)
+	}
+	if got := loaded.Get(1).Memes; len(got) != 2 || got[1].Meme != "one" {
+		t.Errorf("guild 1 = %+v", got)
+	}
+
+	data, _ := json.Marshal(change{Memes: []*Meme{NewMeme("shared", "user")}})
+	if err := loaded.Undo(&journal.Entry{GuildId: 2, Op: "add", Data: data}); err != nil {
+		t.Fatalf("undo: %v", err)
+	}
+	if got := loaded.Get(2).Memes; len(got) != 0 {
+		t.Errorf("guild 2 after undo = %+v, want nothing", got)
+	}
+	if got := loaded.Get(1).Memes; len(got) != 2 {
+		t.Errorf("guild 1 after undo = %+v, want 2 memes", got)
+	}
+}
@@ -0,0 +1,253 @@
+package meme
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	gb "github.com/ericebersohl/gobottas"
+	"github.com/ericebersohl/gobottas/discord"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+// Formats supported by import and export
+const (
+	FormatJSON = "json"
+	FormatCSV  = "csv"
+)
+
+// mime types of the formats, for uploads
+var contentTypes = map[string]string{
+	FormatJSON: "application/json",
+	FormatCSV:  "text/csv",
+}
+
+// Largest file that import will read
+const MaxImportSize = 1 << 20
+
+// Most image memes an import downloads to hash; the rest are compared by link
+const MaxImportImages = 50
+
+// column names of the csv format
+var csvHeader = []string{"meme", "added", "added-by"}
+
+// Get the transfer format from a file name or url, based on its extension
+func FormatFromName(name string) (string, error) {
+	// discord attachment urls can carry a query string
+	if i := strings.IndexAny(name, "?#"); i >= 0 {
+		name = name[:i]
+	}
+
+	switch strings.ToLower(path.Ext(name)) {
+	case ".json":
+		return FormatJSON, nil
+	case ".csv":
+		return FormatCSV, nil
+	default:
+		return "", discord.NewError("Unknown Format", "Memes can only be imported from .json or .csv files.")
+	}
+}
+
+// Write every meme in the stash to w in the given format
+func (s *Stash) Export(w io.Writer, format string) error {
+	switch format {
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(s.Memes)
+
+	case FormatCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(csvHeader); err != nil {
+			return err
+		}
+
+		for _, m := range s.Memes {
+			if err := cw.Write([]string{m.Meme, m.Added.Format(time.RFC3339), m.AddedBy}); err != nil {
+				return err
+			}
+		}
+
+		cw.Flush()
+		return cw.Error()
+
+	default:
+		return discord.NewError("Unknown Format", fmt.Sprintf("Cannot export memes as %q; use json or csv.", format))
+	}
+}
+
+// Read memes from r in the given format
+func Decode(r io.Reader, format string) ([]*Meme, error) {
+	switch format {
+	case FormatJSON:
+		return decodeJSON(r)
+	case FormatCSV:
+		return decodeCSV(r)
+	default:
+		return nil, discord.NewError("Unknown Format", fmt.Sprintf("Cannot import memes from %q; use json or csv.", format))
+	}
+}
+
+// Add the memes that don't duplicate a meme already in the stash.  Memes without an author or date are credited to
+// user at the current time.  Returns the added memes and the number of duplicates skipped.
+func (s *Stash) Import(in []*Meme, user string) (added []*Meme, skipped int) {
+	// memes added earlier in the import are in the stash, so duplicates within the file are caught too
+	for _, m := range in {
+		if m == nil || strings.TrimSpace(m.Meme) == "" {
+			continue
+		}
+
+		m.Meme = strings.TrimSpace(m.Meme)
+		if s.FindDuplicate(m) >= 0 {
+			skipped++
+			continue
+		}
+
+		if m.AddedBy == "" {
+			m.AddedBy = user
+		}
+		if m.Added.IsZero() {
+			m.Added = time.Now()
+		}
+
+		s.Memes = append(s.Memes, m)
+		added = append(added, m)
+	}
+
+	return added, skipped
+}
+
+// decode either a list of memes, as written by export, or a whole stash, as saved in meme.json
+func decodeJSON(r io.Reader) ([]*Meme, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var memes []*Meme
+	if err := json.Unmarshal(data, &memes); err == nil {
+		return memes, nil
+	}
+
+	var st Stash
+	if err := json.Unmarshal(data, &st); err != nil {
+		return nil, discord.NewError("Invalid JSON", fmt.Sprintf("Could not read memes from the file: %v", err))
+	}
+
+	return st.Memes, nil
+}
+
+// decode csv written by export; only the meme column is required
+func decodeCSV(r io.Reader) ([]*Meme, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+
+	rows, err := cr.ReadAll()
+	if err != nil {
+		return nil, discord.NewError("Invalid CSV", fmt.Sprintf("Could not read memes from the file: %v", err))
+	}
+
+	// skip the header if there is one
+	if len(rows) > 0 && len(rows[0]) > 0 && rows[0][0] == csvHeader[0] {
+		rows = rows[1:]
+	}
+
+	var memes []*Meme
+	for i, row := range rows {
+		if len(row) == 0 {
+			continue
+		}
+
+		m := Meme{Meme: row[0]}
+		if len(row) > 1 && row[1] != "" {
+			m.Added, err = time.Parse(time.RFC3339, row[1])
+			if err != nil {
+				return nil, discord.NewError("Invalid CSV", fmt.Sprintf("Row %d has an invalid date: %v", i+1, err))
+			}
+		}
+		if len(row) > 2 {
+			m.AddedBy = row[2]
+		}
+
+		memes = append(memes, &m)
+	}
+
+	return memes, nil
+}
+
+// Download a file of at most max bytes uploaded to discord; replaced in tests
+var fetch = func(url string, max int64) ([]byte, error) {
+	c := http.Client{
+		Timeout: 10 * time.Second,
+
+		// discord's CDN doesn't redirect off itself, so nothing else should either
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if !imageHost(req.URL.String()) {
+				return fmt.Errorf("redirected off the allowed hosts to %s", req.URL.Host)
+			}
+			return nil
+		},
+	}
+
+	resp, err := c.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch %s: %s", url, resp.Status)
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, max+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(data)) > max {
+		return nil, discord.NewError("File Too Large", fmt.Sprintf("Files are limited to %d bytes.", max))
+	}
+
+	return data, nil
+}
+
+// Download and read the memes in a file uploaded to discord, fingerprinting up to MaxImportImages image memes.  This
+// is done before the stash is locked to import them.  Images that can't be hashed are logged to l.
+func Download(url string, l *gb.Logger) ([]*Meme, error) {
+	format, err := FormatFromName(url)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := fetch(url, MaxImportSize)
+	if err != nil {
+		return nil, err
+	}
+
+	memes, err := Decode(bytes.NewReader(data), format)
+	if err != nil {
+		return nil, err
+	}
+
+	FingerprintImages(memes, l)
+	return memes, nil
+}
+
+// Fingerprint up to MaxImportImages image memes that aren't hashed yet, so an import catches copies of images
+func FingerprintImages(memes []*Meme, l *gb.Logger) {
+	images := 0
+	for _, m := range memes {
+		if m == nil || m.Hash != 0 || !IsImage(m.Meme) {
+			continue
+		}
+		if images++; images > MaxImportImages {
+			return
+		}
+		m.Fingerprint(l)
+	}
+}
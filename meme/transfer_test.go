@@ -0,0 +1,207 @@
+package meme
+
+import (
+	"bytes"
+	gb "github.com/ericebersohl/gobottas"
+	"github.com/ericebersohl/gobottas/mock"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+/*
+Test Cases:
+- json, csv, query string, upper case, unknown
+*/
+func TestFormatFromName(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{name: "json", in: "memes.json", want: FormatJSON},
+		{name: "csv", in: "memes.csv", want: FormatCSV},
+		{name: "query", in: "https://cdn.discordapp.com/attachments/1/2/memes.csv?ex=abc", want: FormatCSV},
+		{name: "upper", in: "MEMES.JSON", want: FormatJSON},
+		{name: "unknown", in: "memes.txt", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := FormatFromName(test.in)
+			if (err != nil) != test.wantErr {
+				t.Errorf("err != wantErr (err = %v, wantErr = %v)", err, test.wantErr)
+			}
+
+			if got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+// decode memes from r and import them as "importer"
+func importFrom(s *Stash, r io.Reader, format string) ([]*Meme, int, error) {
+	memes, err := Decode(r, format)
+	if err != nil {
+		return nil, 0, err
+	}
+	added, skipped := s.Import(memes, "importer")
+	return added, skipped, nil
+}
+
+/*
+Test Cases:
+- export then import into an empty stash (json, csv)
+- import into the same stash only skips
+- bad format
+*/
+func TestExportImport(t *testing.T) {
+	for _, format := range []string{FormatJSON, FormatCSV} {
+		t.Run(format, func(t *testing.T) {
+			s := DefaultStash("meme_test")
+
+			var buf bytes.Buffer
+			if err := s.Export(&buf, format); err != nil {
+				t.Fatalf("export: %v", err)
+			}
+			data := buf.Bytes()
+
+			e := Stash{}
+			added, skipped, err := importFrom(&e, bytes.NewReader(data), format)
+			if err != nil || len(added) != len(s.Memes) || skipped != 0 {
+				t.Fatalf("import into empty stash: added = %d, skipped = %d, err = %v", len(added), skipped, err)
+			}
+
+			// csv dates only keep seconds
+			want := s.Memes[0].Added
+			if format == FormatCSV {
+				want = want.Truncate(time.Second)
+			}
+
+			if e.Memes[0].AddedBy != "Default Meme" || !e.Memes[0].Added.Equal(want) {
+				t.Errorf("metadata lost: %+v", e.Memes[0])
+			}
+
+			added, skipped, err = importFrom(s, bytes.NewReader(data), format)
+			if err != nil || len(added) != 0 || skipped != len(s.Memes) {
+				t.Errorf("import into same stash: added = %d, skipped = %d, err = %v", len(added), skipped, err)
+			}
+		})
+	}
+
+	s := DefaultStash("meme_test")
+	if err := s.Export(&bytes.Buffer{}, "xml"); err == nil {
+		t.Errorf("no error exporting xml")
+	}
+}
+
+/*
+Test Cases:
+- csv without header or metadata, duplicates within the file, blank rows
+- json stash object
+*/
+func TestImport_Inputs(t *testing.T) {
+	s := Stash{}
+
+	added, skipped, err := importFrom(&s, strings.NewReader("one\n  two  \none\n\"\"\n"), FormatCSV)
+	if err != nil || len(added) != 2 || skipped != 1 {
+		t.Fatalf("csv: added = %d, skipped = %d, err = %v", len(added), skipped, err)
+	}
+
+	if added[1].Meme != "two" || added[1].AddedBy != "importer" || added[1].Added.IsZero() {
+		t.Errorf("csv meme not normalized: %+v", added[1])
+	}
+
+	added, _, err = importFrom(&s, strings.NewReader(`{"memes": [{"meme": "three"}], "path": "x"}`), FormatJSON)
+	if err != nil || len(added) != 1 || s.LocalPath != "" {
+		t.Errorf("json stash: added = %d, err = %v, path = %q", len(added), err, s.LocalPath)
+	}
+
+	if _, _, err := importFrom(&s, strings.NewReader(`not json`), FormatJSON); err == nil {
+		t.Errorf("no error importing bad json")
+	}
+}
+
+/*
+Test Cases:
+- export: default, csv, bad format
+- import: not moderator, no file, bad format, normal
+- import: images in the file are hashed, so a resized copy is skipped
+- import: the stash isn't locked while downloading
+*/
+func TestInterceptor_Transfer(t *testing.T) {
+	_ = os.Mkdir("meme_test", 0755)
+	defer os.RemoveAll("meme_test")
+
+	ss := NewStashes("meme_test")
+	s := ss.Get(0)
+
+	const cdn = "https://cdn.discordapp.com/attachments/1/2/"
+	files := map[string][]byte{
+		"a.csv":             []byte("meme\nnew meme\nIs his career over!?\n" + cdn + "a.png\n" + cdn + "a-small.png\n"),
+		cdn + "a.png":       pattern(90, 80, false),
+		cdn + "a-small.png": pattern(45, 40, false),
+	}
+	locked := false
+	fetch = func(url string, _ int64) ([]byte, error) {
+		free := make(chan struct{})
+		go func() {
+			s.Lock()
+			s.Unlock()
+			close(free)
+		}()
+		select {
+		case <-free:
+		case <-time.After(time.Second):
+			locked = true
+		}
+		return files[url], nil
+	}
+
+	tests := []struct {
+		name      string
+		in        *gb.Message
+		wantFile  bool
+		wantEmbed bool
+		wantLen   int
+	}{
+		{name: "export", in: mock.NewMessage(gb.Meme, mock.WithArgs("export")), wantFile: true, wantLen: 3},
+		{name: "export-csv", in: mock.NewMessage(gb.Meme, mock.WithArgs("export", "CSV")), wantFile: true, wantLen: 3},
+		{name: "export-bad", in: mock.NewMessage(gb.Meme, mock.WithArgs("export", "xml")), wantEmbed: true, wantLen: 3},
+		{name: "import-not-mod", in: mock.NewMessage(gb.Meme, mock.WithArgs("import"), mock.WithAttachments("a.csv")), wantEmbed: true, wantLen: 3},
+		{name: "import-no-file", in: mock.NewMessage(gb.Meme, mock.WithArgs("import"), mock.WithModerator()), wantEmbed: true, wantLen: 3},
+		{name: "import-bad-format", in: mock.NewMessage(gb.Meme, mock.WithArgs("import"), mock.WithModerator(), mock.WithAttachments("a.txt")), wantEmbed: true, wantLen: 3},
+		{name: "import", in: mock.NewMessage(gb.Meme, mock.WithArgs("import"), mock.WithModerator(), mock.WithAttachments("a.csv")), wantEmbed: true, wantLen: 5},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := Interceptor(ss)(test.in)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if (test.in.Response.File != nil) != test.wantFile {
+				t.Errorf("file != wantFile (file == nil: %t)", test.in.Response.File == nil)
+			}
+
+			if (test.in.Response.Embed != nil) != test.wantEmbed {
+				t.Errorf("embed != wantEmbed (embed == nil: %t)", test.in.Response.Embed == nil)
+			}
+
+			if len(s.Memes) != test.wantLen {
+				t.Errorf("len = %d, want %d", len(s.Memes), test.wantLen)
+			}
+		})
+	}
+	if locked {
+		t.Errorf("the stash was locked while downloading")
+	}
+	if h := s.Memes[len(s.Memes)-1].Hash; h == 0 {
+		t.Errorf("imported image wasn't hashed")
+	}
+}
@@ -0,0 +1,40 @@
+package metrics
+
+// The bot's metrics
+var (
+	MessagesParsed = Default.NewCounter("gobottas_messages_parsed_total",
+		"Messages parsed, by result: command, chat, reaction or error.", "result")
+
+	Commands = Default.NewCounter("gobottas_commands_total",
+		"Commands received, by command and subcommand.", "command", "subcommand")
+
+	InterceptorSeconds = Default.NewHistogram("gobottas_interceptor_duration_seconds",
+		"Time each interceptor spent on a message.", DefaultBuckets, "interceptor")
+
+	Throttled = Default.NewCounter("gobottas_commands_throttled_total",
+		"Commands dropped because the user, channel or guild was on cooldown, by command.", "command")
+
+	SendFailures = Default.NewCounter("gobottas_send_failures_total",
+		"Responses that could not be sent to discord, by kind: text, embed, file, reaction, delete or audit.", "kind")
+
+	SendRetries = Default.NewCounter("gobottas_send_retries_total",
+		"Sends retried after a rate limit or server error, by kind.", "kind")
+
+	DeadLetters = Default.NewCounter("gobottas_dead_letters_total",
+		"Responses given up on after every retry failed, by kind.", "kind")
+
+	PersistenceErrors = Default.NewCounter("gobottas_persistence_errors_total",
+		"Failures to save or load a data file, by store and operation.", "store", "op")
+
+	EventsPublished = Default.NewCounter("gobottas_events_published_total",
+		"Events published to the event bus, by kind.", "kind")
+
+	EventsDropped = Default.NewCounter("gobottas_events_dropped_total",
+		"Events an asynchronous subscriber missed because its buffer was full, by subscriber and kind.", "subscriber", "kind")
+
+	WebhookDeliveries = Default.NewCounter("gobottas_webhook_deliveries_total",
+		"Events posted to webhooks, by hook and result: delivered or failed.", "hook", "result")
+
+	WebhookRetries = Default.NewCounter("gobottas_webhook_retries_total",
+		"Webhook deliveries retried after a network, rate limit or server error, by hook.", "hook")
+)
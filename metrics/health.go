@@ -0,0 +1,97 @@
+package metrics
+
+import (
+	"fmt"
+	gb "github.com/ericebersohl/gobottas"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// How long the session may be disconnected, or take to connect at startup, before the bot is unhealthy.  discordgo
+// reconnects on its own, so short outages are only reported by /readyz.
+const DefaultGrace = 2 * time.Minute
+
+// Tracks the state of the discord session for health checks
+type Health struct {
+	mu        sync.Mutex
+	clock     gb.Clock
+	grace     time.Duration
+	connected bool
+	since     time.Time // when connected last changed, or the start
+}
+
+func NewHealth(clock gb.Clock, grace time.Duration) *Health {
+	h := Health{
+		clock: clock,
+		grace: grace,
+		since: clock.Now(),
+	}
+	return &h
+}
+
+// Record that the session is connected and ready for events
+func (h *Health) Connected() {
+	h.set(true)
+}
+
+// Record that the session lost its connection
+func (h *Health) Disconnected() {
+	h.set(false)
+}
+
+func (h *Health) set(connected bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.connected != connected {
+		h.connected = connected
+		h.since = h.clock.Now()
+	}
+}
+
+// Report whether the session is connected, i.e. the bot can answer messages
+func (h *Health) Ready() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.connected
+}
+
+// Report whether the bot is working or can be expected to recover: it is connected, or has been disconnected for
+// less than the grace period
+func (h *Health) Healthy() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.connected || h.clock.Now().Sub(h.since) < h.grace
+}
+
+func (h *Health) state() string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s := "disconnected"
+	if h.connected {
+		s = "connected"
+	}
+	return fmt.Sprintf("%s for %s", s, h.clock.Now().Sub(h.since).Round(time.Second))
+}
+
+// Serve the metrics at /metrics and the health checks at /healthz and /readyz.  The checks answer 200 or 503 with the
+// session state as text.
+func Handler(r *Registry, h *Health) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", r)
+	mux.HandleFunc("/healthz", probe(h, h.Healthy))
+	mux.HandleFunc("/readyz", probe(h, h.Ready))
+	return mux
+}
+
+func probe(h *Health, ok func() bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if !ok() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		fmt.Fprintln(w, h.state())
+	}
+}
@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"github.com/ericebersohl/gobottas/mock"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+/*
+Test Cases:
+- starting: healthy but not ready, unhealthy once the grace period passes
+- connected: healthy and ready
+- disconnected: not ready, healthy until the grace period passes
+- metrics served
+*/
+func TestHandler(t *testing.T) {
+	clock := mock.NewClock(time.Date(2020, 1, 2, 15, 4, 5, 0, time.UTC))
+	h := NewHealth(clock, time.Minute)
+
+	r := NewRegistry()
+	r.NewCounter("test_total", "A test.").Inc()
+	srv := httptest.NewServer(Handler(r, h))
+	defer srv.Close()
+
+	status := func(path string) int {
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatalf("get %s: %v", path, err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	tests := []struct {
+		name        string
+		step        func()
+		wantHealthy int
+		wantReady   int
+	}{
+		{name: "starting", step: func() {}, wantHealthy: 200, wantReady: 503},
+		{name: "slow-start", step: func() { clock.Advance(2 * time.Minute) }, wantHealthy: 503, wantReady: 503},
+		{name: "connected", step: h.Connected, wantHealthy: 200, wantReady: 200},
+		{name: "disconnected", step: h.Disconnected, wantHealthy: 200, wantReady: 503},
+		{name: "stays-down", step: func() { clock.Advance(time.Minute) }, wantHealthy: 503, wantReady: 503},
+		{name: "reconnected", step: h.Connected, wantHealthy: 200, wantReady: 200},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.step()
+
+			if got := status("/healthz"); got != test.wantHealthy {
+				t.Errorf("/healthz = %d, want %d", got, test.wantHealthy)
+			}
+			if got := status("/readyz"); got != test.wantReady {
+				t.Errorf("/readyz = %d, want %d", got, test.wantReady)
+			}
+		})
+	}
+
+	if got := status("/metrics"); got != 200 {
+		t.Errorf("/metrics = %d", got)
+	}
+}
@@ -0,0 +1,289 @@
+// Package metrics counts what the bot does and serves the numbers in the Prometheus text format, along with health
+// checks, on an optional HTTP listener.  The bot's own metrics are package variables registered with Default, so that
+// any package can record them without having a registry passed in.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// A set of metrics that are written out together
+type Registry struct {
+	mu      sync.Mutex
+	metrics []metric
+	names   map[string]bool
+}
+
+// Registry for the bot's metrics
+var Default = NewRegistry()
+
+type metric interface {
+	write(w *bufio.Writer)
+}
+
+func NewRegistry() *Registry {
+	r := Registry{
+		names: make(map[string]bool),
+	}
+	return &r
+}
+
+// add a metric; two metrics with the same name are a programming error
+func (r *Registry) register(name string, m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.names[name] {
+		panic(fmt.Sprintf("metrics: %s registered twice", name))
+	}
+	r.names[name] = true
+	r.metrics = append(r.metrics, m)
+}
+
+// Write every metric in the Prometheus text format
+func (r *Registry) Write(w io.Writer) error {
+	r.mu.Lock()
+	metrics := append([]metric(nil), r.metrics...)
+	r.mu.Unlock()
+
+	b := bufio.NewWriter(w)
+	for _, m := range metrics {
+		m.write(b)
+	}
+	return b.Flush()
+}
+
+// Serve the metrics to a Prometheus scraper
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_ = r.Write(w)
+}
+
+// what every metric has: a name, a description and the names of its labels
+type desc struct {
+	name   string
+	help   string
+	typ    string
+	labels []string
+}
+
+func (d desc) header(b *bufio.Writer) {
+	fmt.Fprintf(b, "# HELP %s %s\n", d.name, strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(d.help))
+	fmt.Fprintf(b, "# TYPE %s %s\n", d.name, d.typ)
+}
+
+// the key of a set of label values; panics if there are too many or too few, like a bad format string would
+func (d desc) key(values []string) string {
+	if len(values) != len(d.labels) {
+		panic(fmt.Sprintf("metrics: %s has labels %v, got values %v", d.name, d.labels, values))
+	}
+	return strings.Join(values, "\xff")
+}
+
+// e.g. {command="Queue",subcommand="add"}, with extra pairs such as le appended
+func (d desc) labelString(values []string, extra ...string) string {
+	var pairs []string
+	for i, l := range d.labels {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, l, escape(values[i])))
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, extra[i], escape(extra[i+1])))
+	}
+
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func escape(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// the values of a metric by label key, written in a stable order
+type series struct {
+	mu     sync.Mutex
+	values map[string][]string // label values by key
+}
+
+func (s *series) sortedKeys() []string {
+	keys := make([]string, 0, len(s.values))
+	for k := range s.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// A value that only goes up, such as the number of messages parsed
+type Counter struct {
+	desc
+	series
+	counts map[string]float64
+}
+
+func (r *Registry) NewCounter(name, help string, labels ...string) *Counter {
+	c := Counter{
+		desc:   desc{name: name, help: help, typ: "counter", labels: labels},
+		series: series{values: make(map[string][]string)},
+		counts: make(map[string]float64),
+	}
+	r.register(name, &c)
+	return &c
+}
+
+// Add one to the counter for the label values
+func (c *Counter) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// Add v, which must not be negative, to the counter for the label values
+func (c *Counter) Add(v float64, values ...string) {
+	if v < 0 {
+		panic(fmt.Sprintf("metrics: counter %s cannot go down", c.name))
+	}
+
+	k := c.key(values)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.values[k] = values
+	c.counts[k] += v
+}
+
+// Get the count for the label values
+func (c *Counter) Value(values ...string) float64 {
+	k := c.key(values)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.counts[k]
+}
+
+func (c *Counter) write(b *bufio.Writer) {
+	c.header(b)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, k := range c.sortedKeys() {
+		fmt.Fprintf(b, "%s%s %s\n", c.name, c.labelString(c.values[k]), formatFloat(c.counts[k]))
+	}
+}
+
+// A value that is read when the metrics are written, such as the length of a channel
+type GaugeFunc struct {
+	desc
+	f func() float64
+}
+
+func (r *Registry) NewGaugeFunc(name, help string, f func() float64) *GaugeFunc {
+	g := GaugeFunc{
+		desc: desc{name: name, help: help, typ: "gauge"},
+		f:    f,
+	}
+	r.register(name, &g)
+	return &g
+}
+
+func (g *GaugeFunc) write(b *bufio.Writer) {
+	g.header(b)
+	fmt.Fprintf(b, "%s %s\n", g.name, formatFloat(g.f()))
+}
+
+// Buckets for durations in seconds, from a millisecond to ten seconds
+var DefaultBuckets = []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Counts of observations in buckets, such as how long interceptors take
+type Histogram struct {
+	desc
+	series
+	buckets []float64 // upper bounds, in increasing order
+	counts  map[string][]uint64
+	sums    map[string]float64
+	totals  map[string]uint64
+}
+
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	b := append([]float64(nil), buckets...)
+	sort.Float64s(b)
+
+	h := Histogram{
+		desc:    desc{name: name, help: help, typ: "histogram", labels: labels},
+		series:  series{values: make(map[string][]string)},
+		buckets: b,
+		counts:  make(map[string][]uint64),
+		sums:    make(map[string]float64),
+		totals:  make(map[string]uint64),
+	}
+	r.register(name, &h)
+	return &h
+}
+
+// Record v for the label values
+func (h *Histogram) Observe(v float64, values ...string) {
+	k := h.key(values)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	counts, ok := h.counts[k]
+	if !ok {
+		counts = make([]uint64, len(h.buckets))
+		h.counts[k] = counts
+		h.values[k] = values
+	}
+
+	for i, upper := range h.buckets {
+		if v <= upper {
+			counts[i]++
+		}
+	}
+	h.sums[k] += v
+	h.totals[k]++
+}
+
+// Get the number of observations for the label values
+func (h *Histogram) Count(values ...string) uint64 {
+	k := h.key(values)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.totals[k]
+}
+
+func (h *Histogram) write(b *bufio.Writer) {
+	h.header(b)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, k := range h.sortedKeys() {
+		values := h.values[k]
+		for i, upper := range h.buckets {
+			fmt.Fprintf(b, "%s_bucket%s %d\n", h.name, h.labelString(values, "le", formatFloat(upper)), h.counts[k][i])
+		}
+		fmt.Fprintf(b, "%s_bucket%s %d\n", h.name, h.labelString(values, "le", "+Inf"), h.totals[k])
+		fmt.Fprintf(b, "%s_sum%s %s\n", h.name, h.labelString(values), formatFloat(h.sums[k]))
+		fmt.Fprintf(b, "%s_count%s %d\n", h.name, h.labelString(values), h.totals[k])
+	}
+}
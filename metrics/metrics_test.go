@@ -0,0 +1,78 @@
+package metrics
+
+import (
+	"bytes"
+	"testing"
+)
+
+/*
+Test Cases:
+- counter: labels in a stable order, escaped values, no entries yet
+- gauge func: read when written
+- histogram: cumulative buckets, sum and count
+- wrong number of labels, duplicate name, counter going down
+*/
+func TestRegistry_Write(t *testing.T) {
+	r := NewRegistry()
+
+	c := r.NewCounter("test_commands_total", "Commands received.", "command", "subcommand")
+	c.Inc("Queue", "list")
+	c.Add(2, "Meme", `say "hi"`)
+	c.Inc("Queue", "list")
+	r.NewCounter("test_unused_total", "Never counted.")
+
+	n := 0.0
+	r.NewGaugeFunc("test_buffer_length", "Messages waiting.", func() float64 { return n })
+	n = 3
+
+	h := r.NewHistogram("test_duration_seconds", "Time spent.", []float64{1, 0.1}, "interceptor")
+	h.Observe(0.05, "Meme")
+	h.Observe(0.5, "Meme")
+	h.Observe(5, "Meme")
+
+	var b bytes.Buffer
+	if err := r.Write(&b); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	want := `# HELP test_commands_total Commands received.
+# TYPE test_commands_total counter
+test_commands_total{command="Meme",subcommand="say \"hi\""} 2
+test_commands_total{command="Queue",subcommand="list"} 2
+# HELP test_unused_total Never counted.
+# TYPE test_unused_total counter
+# HELP test_buffer_length Messages waiting.
+# TYPE test_buffer_length gauge
+test_buffer_length 3
+# HELP test_duration_seconds Time spent.
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{interceptor="Meme",le="0.1"} 1
+test_duration_seconds_bucket{interceptor="Meme",le="1"} 2
+test_duration_seconds_bucket{interceptor="Meme",le="+Inf"} 3
+test_duration_seconds_sum{interceptor="Meme"} 5.55
+test_duration_seconds_count{interceptor="Meme"} 3
+`
+	if b.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", b.String(), want)
+	}
+
+	if c.Value("Queue", "list") != 2 || h.Count("Meme") != 3 {
+		t.Errorf("values not kept (count = %v, observations = %d)", c.Value("Queue", "list"), h.Count("Meme"))
+	}
+
+	panics := map[string]func(){
+		"labels":    func() { c.Inc("Queue") },
+		"duplicate": func() { r.NewCounter("test_commands_total", "Again.") },
+		"negative":  func() { c.Add(-1, "Queue", "list") },
+	}
+	for name, f := range panics {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("no panic")
+				}
+			}()
+			f()
+		})
+	}
+}
@@ -71,3 +71,42 @@ func WithResponse(cid gb.Snowflake, text string, embed *discord.Embed) MessageOp
 		msg.Response = &r
 	}
 }
+
+func WithModerator() MessageOpt {
+	return func(msg *gb.Message) {
+		msg.Source.Moderator = true
+	}
+}
+
+func WithAttachments(urls ...string) MessageOpt {
+	return func(msg *gb.Message) {
+		msg.Source.Attachments = urls
+	}
+}
+
+func WithGuild(gid gb.Snowflake) MessageOpt {
+	return func(msg *gb.Message) {
+		msg.Source.GuildId = gid
+	}
+}
+
+func WithAdmin() MessageOpt {
+	return func(msg *gb.Message) {
+		msg.Source.Admin = true
+	}
+}
+
+// Make the message a reaction with the emoji to the message with the id, which the bot sent if fromBot
+func WithReaction(emoji string, mid gb.Snowflake, fromBot bool) MessageOpt {
+	return func(msg *gb.Message) {
+		r := gb.ReactionSource{
+			Emoji:     emoji,
+			MessageId: mid,
+			FromBot:   fromBot,
+		}
+
+		msg.Command = gb.Reaction
+		msg.Source.MessageId = mid
+		msg.Reaction = &r
+	}
+}
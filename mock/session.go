@@ -0,0 +1,157 @@
+package mock
+
+import (
+	"fmt"
+	"github.com/bwmarrin/discordgo"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// A response sent through a Session
+type Sent struct {
+	ChannelId string
+	Text      string
+	Embed     *discordgo.MessageEmbed
+	File      string // name of the file
+	Data      []byte // contents of the file
+	MessageId string // the message sent, edited, deleted or reacted to
+	Reaction  string
+	Edited    bool
+	Deleted   bool
+}
+
+// A gb.Session that records what is sent instead of talking to discord.  Errors queued with Fail are returned by the
+// next calls, one per call, before sends start succeeding again.  Editing or deleting a message the session didn't
+// send fails with discord's 404.
+type Session struct {
+	mu       sync.Mutex
+	sent     []Sent
+	errs     []error
+	calls    int
+	ids      int             // messages sent, which numbers them
+	messages map[string]bool // channel and id of the messages that haven't been deleted
+}
+
+func NewSession() *Session {
+	s := Session{
+		messages: make(map[string]bool),
+	}
+	return &s
+}
+
+// Make the next calls fail with errs, in order
+func (s *Session) Fail(errs ...error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.errs = append(s.errs, errs...)
+}
+
+// The responses sent successfully so far
+func (s *Session) Sent() []Sent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Sent(nil), s.sent...)
+}
+
+// The number of calls so far, including failed ones
+func (s *Session) Calls() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls
+}
+
+func (s *Session) record(sent Sent) (*discordgo.Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.calls++
+	if len(s.errs) > 0 {
+		err := s.errs[0]
+		s.errs = s.errs[1:]
+		return nil, err
+	}
+
+	switch {
+	case sent.Edited || sent.Deleted:
+		if !s.messages[sent.ChannelId+"/"+sent.MessageId] {
+			return nil, StatusError(http.StatusNotFound, `{"message": "Unknown Message", "code": 10008}`)
+		}
+		if sent.Deleted {
+			delete(s.messages, sent.ChannelId+"/"+sent.MessageId)
+		}
+	case sent.Reaction == "":
+		s.ids++
+		sent.MessageId = fmt.Sprint(s.ids)
+		s.messages[sent.ChannelId+"/"+sent.MessageId] = true
+	}
+
+	s.sent = append(s.sent, sent)
+	m := discordgo.Message{
+		ID:        sent.MessageId,
+		ChannelID: sent.ChannelId,
+		Content:   sent.Text,
+	}
+	if sent.Embed != nil {
+		m.Embeds = []*discordgo.MessageEmbed{sent.Embed}
+	}
+	return &m, nil
+}
+
+func (s *Session) ChannelMessageSend(channelId string, msg string) (*discordgo.Message, error) {
+	return s.record(Sent{ChannelId: channelId, Text: msg})
+}
+
+func (s *Session) ChannelMessageSendEmbed(channelId string, embed *discordgo.MessageEmbed) (*discordgo.Message, error) {
+	return s.record(Sent{ChannelId: channelId, Embed: embed})
+}
+
+func (s *Session) ChannelFileSendWithMessage(channelId, content, name string, r io.Reader) (*discordgo.Message, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return s.record(Sent{ChannelId: channelId, Text: content, File: name, Data: data})
+}
+
+func (s *Session) ChannelMessageEdit(channelId, messageId, content string) (*discordgo.Message, error) {
+	return s.record(Sent{ChannelId: channelId, MessageId: messageId, Text: content, Edited: true})
+}
+
+func (s *Session) ChannelMessageEditEmbed(channelId, messageId string, embed *discordgo.MessageEmbed) (*discordgo.Message, error) {
+	return s.record(Sent{ChannelId: channelId, MessageId: messageId, Embed: embed, Edited: true})
+}
+
+func (s *Session) ChannelMessageDelete(channelId, messageId string) error {
+	_, err := s.record(Sent{ChannelId: channelId, MessageId: messageId, Deleted: true})
+	return err
+}
+
+func (s *Session) MessageReactionAdd(channelId, messageId, emoji string) error {
+	_, err := s.record(Sent{ChannelId: channelId, MessageId: messageId, Reaction: emoji})
+	return err
+}
+
+// Direct message channels have the id "dm-" and the user's id
+func (s *Session) UserChannelCreate(userId string) (*discordgo.Channel, error) {
+	c := discordgo.Channel{
+		ID:   "dm-" + userId,
+		Type: discordgo.ChannelTypeDM,
+	}
+	return &c, nil
+}
+
+// An error like the one discord returns when the bot is rate limited
+func TooManyRequests(retryAfter time.Duration) error {
+	return StatusError(http.StatusTooManyRequests, fmt.Sprintf(`{"message": "You are being rate limited.", "retry_after": %d}`, retryAfter/time.Millisecond))
+}
+
+// An error like the one discord returns with an HTTP status, e.g. 502 or 403
+func StatusError(status int, body string) error {
+	return &discordgo.RESTError{
+		Response:     &http.Response{StatusCode: status, Status: fmt.Sprintf("%d %s", status, http.StatusText(status))},
+		ResponseBody: []byte(body),
+	}
+}
@@ -1,16 +1,18 @@
 package gobottas
 
 import (
-	"fmt"
 	"github.com/bwmarrin/discordgo"
+	"io"
 	"strconv"
+	"strings"
 )
 
 // colors
 const (
-	ErrorCol = 13632027
-	DiscCol  = 4289797
-	MemeCol  = 16251392
+	ErrorCol  = 13632027
+	DiscCol   = 4289797
+	MemeCol   = 16251392
+	ConfigCol = 7506394
 )
 
 // Enumeration of the basic types of commands that Gobottas supports
@@ -23,27 +25,66 @@ const (
 	Help
 	Meme
 	Queue
+	Trigger
+	Config
+	Alias
+	Reaction // a reaction added to a message, rather than a message
+	Undo
+	Redo
+	Journal
+	Audit
 )
 
 // Get the string value associated with a command type
 func (c Command) String() string {
-	return [...]string{"None", "Error", "Unrecognized", "Help", "Meme", "Queue"}[c]
+	return [...]string{"None", "Error", "Unrecognized", "Help", "Meme", "Queue", "Trigger", "Config", "Alias", "Reaction", "Undo", "Redo", "Journal", "Audit"}[c]
 }
 
-// Parse select strings into commands; note that there are several Commands that no string will parse into
+// Parse select strings into commands, ignoring case; note that there are several Commands that no string will parse into
 func StrToCommand(s string) Command {
-	switch s {
+	switch strings.ToLower(s) {
 	case "help":
 		return Help
 	case "meme":
 		return Meme
-	case "dq":
+	case "dq", "q":
 		return Queue
+	case "trigger":
+		return Trigger
+	case "config":
+		return Config
+	case "alias":
+		return Alias
+	case "undo":
+		return Undo
+	case "redo":
+		return Redo
+	case "journal":
+		return Journal
+	case "audit":
+		return Audit
 	default:
 		return Unrecognized
 	}
 }
 
+// Commands that make up optional features, by the name used to turn them on and off
+var Modules = map[string]Command{
+	"queue":   Queue,
+	"meme":    Meme,
+	"trigger": Trigger,
+}
+
+// Report whether a command belongs to an optional feature
+func IsModule(c Command) bool {
+	for _, m := range Modules {
+		if m == c {
+			return true
+		}
+	}
+	return false
+}
+
 // functions for Discord's unique id system
 type Snowflake uint64
 
@@ -54,7 +95,6 @@ func (s Snowflake) String() string {
 func ToSnowflake(s string) (Snowflake, error) {
 	sf, err := strconv.ParseUint(s, 10, 64)
 	if err != nil {
-		fmt.Printf("ToSnowflake: %v\n", err)
 		return 0, err
 	}
 
@@ -71,30 +111,196 @@ type Message struct {
 
 	// Initialized by Parser, Modified by Interceptors
 	Response *Response
+
+	// Set by an interceptor to ask the author before doing something that can't be taken back
+	Confirm *Confirmation
+
+	// Set for the Reaction command; the Source is the user who reacted, in the channel of the message they reacted to
+	Reaction *ReactionSource
+
+	// Set by interceptors for each change they make, for the audit log
+	Changes []Change
+
+	// Set by interceptors for other modules to hear about; the registry publishes them once the message is handled
+	Events []Event
+
+	// Set by the Parser so that every log entry about the message can be found together
+	Id  string  // correlation id
+	Log *Logger // logs with the correlation id; nil discards
 }
 
 // Data parsed from the original discord message
 type Source struct {
-	AuthorId  Snowflake // Unique id of sender
-	Username  string    // Username (not including the number) of the sender
-	ChannelId Snowflake // Unique id of channel
-	Content   string    // Original content of the message
+	MessageId Snowflake   // Unique id of the message (0 for messages the bot makes up, like scheduled ones)
+	AuthorId  Snowflake   // Unique id of sender
+	Username  string      // Username (not including the number) of the sender
+	ChannelId Snowflake   // Unique id of channel
+	GuildId   Snowflake   // Unique id of the guild (0 for direct messages)
+	Content   string      // Original content of the message
+	Moderator bool        // Whether the sender may manage messages in the channel
+	Admin     bool        // Whether the sender may manage the guild
+	Roles     []Snowflake // Roles the sender has in the guild
+
+	Attachments []string // URLs of files uploaded with the message
 }
 
+// An action that waits for the author to confirm it
+type Confirmation struct {
+	Prompt string               // what is being confirmed, e.g. "Remove topic X?"
+	Action func(*Message) error // carries out the action, setting the response of the confirming message
+}
+
+// A change a command made, as the audit log shows it
+type Change struct {
+	Action string // what was done, e.g. "remove topic"
+	Target string // what it was done to, e.g. the topic's name
+	Before string // the value before the change; "" if there was none
+	After  string // the value after the change; "" if there is none
+}
+
+// Note a change for the audit log
+func (m *Message) AddChange(action, target, before, after string) {
+	m.Changes = append(m.Changes, Change{Action: action, Target: target, Before: before, After: after})
+}
+
+// Something that happened, which modules can subscribe to without knowing which module it came from.  The event
+// package has the events themselves.
+type Event interface {
+	Kind() string // e.g. "topic.added"
+}
+
+// Publish an event once the message is handled
+func (m *Message) Publish(e Event) {
+	m.Events = append(m.Events, e)
+}
+
+// Data parsed from a reaction added to a message, and the message itself
+type ReactionSource struct {
+	Emoji     string    // the emoji itself, or name:id for a custom one, as MessageReactionAdd takes it
+	MessageId Snowflake // the message reacted to
+	AuthorId  Snowflake // who sent the message reacted to
+	FromBot   bool      // whether the message reacted to is the bot's own
+	Content   string    // text of the message reacted to
+	Embeds    []*discordgo.MessageEmbed
+}
+
+// What the bot sends back.  Text, Embed and File are shorthand for a single reply and are sent first, text before the
+// embed (or as the file's message); Outputs are sent after them, in order.
 type Response struct {
 	ChannelId Snowflake
 	Text      string
 	Embed     *discordgo.MessageEmbed
+	File      *discordgo.File // uploaded as an attachment, with Text as the message
+
+	Outputs []Output
+}
+
+// Kinds of output
+type OutputKind int
+
+const (
+	TextOutput     OutputKind = iota
+	EmbedOutput               // Embed
+	FileOutput                // File, with Text as the message
+	ReactionOutput            // Text is the emoji, added to the source message
+	DeleteOutput              // deletes MessageId, or the source message if it is 0
+)
+
+func (k OutputKind) String() string {
+	switch k {
+	case TextOutput:
+		return "text"
+	case EmbedOutput:
+		return "embed"
+	case FileOutput:
+		return "file"
+	case ReactionOutput:
+		return "reaction"
+	case DeleteOutput:
+		return "delete"
+	}
+	return "unknown"
+}
+
+// One thing sent in reply to a message
+type Output struct {
+	Kind  OutputKind
+	Text  string
+	Embed *discordgo.MessageEmbed
+	File  *discordgo.File
+
+	// Sent only to the author, in a direct message; discord has no ephemeral messages outside of interactions
+	Ephemeral bool
+
+	// Text that mentions the author, so it reads as a reply to them
+	Reply bool
+
+	// Edit this message, in the response channel, instead of sending a new one.  Edits must fit in one message.
+	MessageId Snowflake
+
+	// Edit the last message sent with this key in the response channel, or send one if there isn't one, so that
+	// something like a list updates in place instead of being posted again
+	Update string
+}
+
+func (r *Response) AddText(text string) {
+	r.Outputs = append(r.Outputs, Output{Kind: TextOutput, Text: text})
+}
+
+func (r *Response) AddEmbed(e *discordgo.MessageEmbed) {
+	r.Outputs = append(r.Outputs, Output{Kind: EmbedOutput, Embed: e})
+}
+
+func (r *Response) AddFile(f *discordgo.File, text string) {
+	r.Outputs = append(r.Outputs, Output{Kind: FileOutput, File: f, Text: text})
+}
+
+// Change the embed of a message the bot sent
+func (r *Response) AddEmbedEdit(id Snowflake, e *discordgo.MessageEmbed) {
+	r.Outputs = append(r.Outputs, Output{Kind: EmbedOutput, Embed: e, MessageId: id})
+}
+
+// Send an embed, or edit the one sent last time with the same key in the channel
+func (r *Response) AddEmbedUpdate(key string, e *discordgo.MessageEmbed) {
+	r.Outputs = append(r.Outputs, Output{Kind: EmbedOutput, Embed: e, Update: key})
+}
+
+// Replace anything set so far with an error, so that a failure isn't sent alongside the success it undoes
+func (r *Response) SetError(e *discordgo.MessageEmbed) {
+	r.Text, r.File, r.Outputs = "", nil, nil
+	r.Embed = e
+}
+
+// Every output in the order it is sent, starting with the shorthand fields
+func (r *Response) List() []Output {
+	var out []Output
+	switch {
+	case r.File != nil:
+		out = append(out, Output{Kind: FileOutput, File: r.File, Text: r.Text})
+	case r.Text != "":
+		out = append(out, Output{Kind: TextOutput, Text: r.Text})
+	}
+	if r.Embed != nil {
+		out = append(out, Output{Kind: EmbedOutput, Embed: r.Embed})
+	}
+	return append(out, r.Outputs...)
 }
 
 // Session interfaces with the discordgo Session struct using only the relevant functions for Gobottas
 type Session interface {
 	ChannelMessageSend(channelId string, msg string) (*discordgo.Message, error)
 	ChannelMessageSendEmbed(channelId string, embed *discordgo.MessageEmbed) (*discordgo.Message, error)
+	ChannelFileSendWithMessage(channelId, content, name string, r io.Reader) (*discordgo.Message, error)
+	ChannelMessageEdit(channelId, messageId, content string) (*discordgo.Message, error)
+	ChannelMessageEditEmbed(channelId, messageId string, embed *discordgo.MessageEmbed) (*discordgo.Message, error)
+	ChannelMessageDelete(channelId, messageId string) error
+	MessageReactionAdd(channelId, messageId, emoji string) error
+	UserChannelCreate(userId string) (*discordgo.Channel, error)
 }
 
 type Registry interface {
 	Parse(*discordgo.Message) (*Message, error)
+	ParseReaction(*discordgo.MessageReaction, *discordgo.Message) (*Message, error)
 	Intercept(*Message) error
 	Execute(*Message, Session) error
 }
@@ -0,0 +1,244 @@
+package trigger
+
+import (
+	"errors"
+	"fmt"
+	gb "github.com/ericebersohl/gobottas"
+	"github.com/ericebersohl/gobottas/args"
+	"github.com/ericebersohl/gobottas/discord"
+	"github.com/ericebersohl/gobottas/meme"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Enum for trigger commands
+type Command int
+
+const (
+	TError Command = iota
+	TAdd
+	TRemove
+	TList
+	TOn
+	TOff
+	TCooldown
+)
+
+func (c Command) String() string {
+	return [...]string{"Error", "Add", "Remove", "List", "On", "Off", "Cooldown"}[c]
+}
+
+// parse a string arg into a trigger Command
+func ArgToCommand(arg string) Command {
+	switch strings.ToLower(arg) {
+	case "add":
+		return TAdd
+	case "remove", "rm":
+		return TRemove
+	case "list":
+		return TList
+	case "on":
+		return TOn
+	case "off":
+		return TOff
+	case "cooldown":
+		return TCooldown
+	default:
+		return TError
+	}
+}
+
+// Returns an interceptor that handles the trigger command and replies to normal chat that matches a trigger.
+// The guild's stash is used for triggers that reply with a meme; stashes may be nil.
+func Interceptor(s *Set, stashes *meme.Stashes) gb.Interceptor {
+	return func(msg *gb.Message) error {
+
+		// only trigger commands and normal chat are of interest
+		if msg.Command != gb.Trigger && msg.Command != gb.None {
+			return nil
+		}
+
+		// error if registry doesn't have a trigger set
+		if s == nil {
+			return errors.New("cannot intercept with nil trigger set")
+		}
+
+		if msg.Source == nil {
+			return nil
+		}
+
+		// replies go back to the channel the message came from
+		msg.Response.ChannelId = msg.Source.ChannelId
+
+		if msg.Command == gb.None {
+			reply(s, stashes, msg)
+			return nil
+		}
+
+		// error if Trigger msg without at least one arg
+		if len(msg.Args) < 1 {
+			msg.Response.Embed = discord.NewError("Too Few Args", "You must supply a trigger command (add, remove, list, on, off, or cooldown)").Embed()
+			return nil
+		}
+
+		cmd := ArgToCommand(msg.Args[0])
+
+		// everything but list changes how the bot behaves in chat
+		if cmd != TList && cmd != TError && !msg.Source.Moderator {
+			msg.Response.Embed = discord.NewError("Not Allowed", "Only moderators can change triggers.").Embed()
+			return nil
+		}
+
+		switch cmd {
+		case TAdd:
+			var a struct {
+				Pattern string `arg:"pattern"`
+				Reply   string `arg:"meme-id|text"`
+			}
+			if err := args.Parse("&trigger add", msg.Args[1:], &a); err != nil {
+				return embedError(msg, err)
+			}
+
+			t, err := NewTrigger(msg.Source.GuildId, a.Pattern, msg.Source.Username)
+			if err != nil {
+				return embedError(msg, err)
+			}
+
+			// a number that indexes the stash is a meme reply, anything else is text.  The meme is kept by its
+			// text, since indices change as memes are removed.
+			if idx, err := strconv.Atoi(a.Reply); err == nil {
+				t.Meme = memeAt(stashes, msg.Source.GuildId, idx)
+			}
+			if t.Meme == "" {
+				t.Text = a.Reply
+			}
+
+			if err := s.Add(t); err != nil {
+				return embedError(msg, err)
+			}
+
+			msg.Response.Text = fmt.Sprintf("Added trigger %d.", t.Id)
+			msg.AddChange("add trigger", fmt.Sprintf("trigger %d", t.Id), "", fmt.Sprintf("%s → %s", t, a.Reply))
+			return save(s, msg)
+
+		case TRemove:
+			var a struct {
+				Id int `arg:"id"`
+			}
+			if err := args.Parse("&trigger remove", msg.Args[1:], &a); err != nil {
+				return embedError(msg, err)
+			}
+
+			var before string
+			if t := s.Find(msg.Source.GuildId, a.Id); t != nil {
+				before = t.String()
+			}
+
+			if err := s.Remove(msg.Source.GuildId, a.Id); err != nil {
+				return embedError(msg, err)
+			}
+			msg.AddChange("remove trigger", fmt.Sprintf("trigger %d", a.Id), before, "")
+
+			return save(s, msg)
+
+		case TList:
+			msg.Response.Embed = s.Embed(msg.Source.GuildId, msg.Source.ChannelId)
+			return nil
+
+		case TOn, TOff:
+			before := "on"
+			if s.Disabled[msg.Source.ChannelId] {
+				before = "off"
+			}
+			s.Enable(msg.Source.ChannelId, cmd == TOn)
+			msg.AddChange("switch triggers", fmt.Sprintf("<#%s>", msg.Source.ChannelId), before, strings.ToLower(cmd.String()))
+			return save(s, msg)
+
+		case TCooldown:
+			// a bare number is seconds
+			var a struct {
+				Cooldown time.Duration `arg:"duration,min=0"`
+			}
+			if err := args.Parse("&trigger cooldown", msg.Args[1:], &a); err != nil {
+				return embedError(msg, err)
+			}
+
+			msg.AddChange("set trigger cooldown", "cooldown", s.CooldownIn(msg.Source.GuildId).String(), a.Cooldown.String())
+			s.SetCooldown(msg.Source.GuildId, a.Cooldown)
+			return save(s, msg)
+
+		case TError:
+			msg.Response.Embed = discord.NewError("Unrecognized Command", "Gobottas did not recognize your command.").Embed()
+			return nil
+		}
+
+		return errors.New("reached end of interceptor without returning from the switch")
+	}
+}
+
+// set the response for normal chat that matches a trigger
+func reply(s *Set, stashes *meme.Stashes, msg *gb.Message) {
+	t := s.Match(msg.Source.GuildId, msg.Source.ChannelId, msg.Source.Content)
+	if t == nil {
+		return
+	}
+
+	if t.Meme == "" {
+		msg.Response.Text = t.Text
+		return
+	}
+
+	if stashes == nil {
+		return
+	}
+
+	stash := stashes.Get(msg.Source.GuildId)
+	stash.Lock()
+	defer stash.Unlock()
+
+	// the meme may have been removed since the trigger was added
+	m := stash.ByText(t.Meme)
+	if m == nil {
+		msg.Log.Warn("trigger references a missing meme", "trigger", t.Id, "meme", t.Meme)
+		return
+	}
+
+	msg.Response.Embed = m.Embed()
+}
+
+// The text of the meme at the index in the guild's stash, or "" if there isn't one
+func memeAt(stashes *meme.Stashes, guild gb.Snowflake, idx int) string {
+	if stashes == nil {
+		return ""
+	}
+
+	stash := stashes.Get(guild)
+	stash.Lock()
+	defer stash.Unlock()
+	if idx < 0 || idx >= len(stash.Memes) {
+		return ""
+	}
+	return stash.Memes[idx].Meme
+}
+
+// show discord errors to the user, pass anything else up
+func embedError(msg *gb.Message, err error) error {
+	if e, ok := err.(discord.Error); ok {
+		msg.Response.SetError(e.Embed())
+		return nil
+	}
+	return err
+}
+
+// persist the set after a change
+func save(s *Set, msg *gb.Message) error {
+	if err := s.Save(s.LocalPath); err != nil {
+		msg.Log.Error("failed to save triggers", "path", s.LocalPath, "err", err)
+		msg.Response.SetError(discord.Error{
+			Name: "Trigger Save Error",
+			Desc: err.Error(),
+		}.Embed())
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+package trigger
+
+import (
+	gb "github.com/ericebersohl/gobottas"
+	"github.com/ericebersohl/gobottas/meme"
+	"github.com/ericebersohl/gobottas/mock"
+	"os"
+	"testing"
+	"time"
+)
+
+/*
+Test Cases:
+- not trigger msg, nil set
+- no args, bad command, not moderator
+- Add: too few args, bad regex, text, meme
+- Remove: too few args, bad atoi, not found, normal
+- List, Off, On, Cooldown: bad value, normal
+- chat: text reply, meme reply, no match
+*/
+func TestInterceptor(t *testing.T) {
+	_ = os.Mkdir("trigger_test", 0755)
+	defer os.RemoveAll("trigger_test")
+
+	s := NewSet("trigger_test")
+	s.Cooldown = 0
+	ds := meme.NewStashes("trigger_test")
+
+	tests := []struct {
+		name      string
+		set       *Set
+		in        *gb.Message
+		wantErr   bool
+		wantEmbed bool
+		wantText  bool
+	}{
+		// General errors
+		{name: "not-trigger", set: s, in: mock.NewMessage(gb.Meme), wantErr: false, wantEmbed: false},
+		{name: "nil-set", set: nil, in: mock.NewMessage(gb.Trigger), wantErr: true, wantEmbed: false},
+		{name: "no-args", set: s, in: mock.NewMessage(gb.Trigger, mock.WithModerator()), wantErr: false, wantEmbed: true},
+		{name: "bad-command", set: s, in: mock.NewMessage(gb.Trigger, mock.WithArgs("bad"), mock.WithModerator()), wantErr: false, wantEmbed: true},
+		{name: "not-moderator", set: s, in: mock.NewMessage(gb.Trigger, mock.WithArgs("add", "hi", "hello")), wantErr: false, wantEmbed: true},
+
+		// Add
+		{name: "add-too-few", set: s, in: mock.NewMessage(gb.Trigger, mock.WithArgs("add", "hi"), mock.WithModerator()), wantErr: false, wantEmbed: true},
+		{name: "add-bad-regex", set: s, in: mock.NewMessage(gb.Trigger, mock.WithArgs("add", "/(/", "hello"), mock.WithModerator()), wantErr: false, wantEmbed: true},
+		{name: "add-text", set: s, in: mock.NewMessage(gb.Trigger, mock.WithArgs("add", "hi", "hello"), mock.WithModerator()), wantErr: false, wantText: true},
+		{name: "add-meme", set: s, in: mock.NewMessage(gb.Trigger, mock.WithArgs("add", "/^drive/", "0"), mock.WithModerator()), wantErr: false, wantText: true},
+
+		// Remove
+		{name: "rem-too-few", set: s, in: mock.NewMessage(gb.Trigger, mock.WithArgs("remove"), mock.WithModerator()), wantErr: false, wantEmbed: true},
+		{name: "rem-bad-atoi", set: s, in: mock.NewMessage(gb.Trigger, mock.WithArgs("remove", "one"), mock.WithModerator()), wantErr: false, wantEmbed: true},
+		{name: "rem-not-found", set: s, in: mock.NewMessage(gb.Trigger, mock.WithArgs("remove", "9"), mock.WithModerator()), wantErr: false, wantEmbed: true},
+
+		// List, switches
+		{name: "list", set: s, in: mock.NewMessage(gb.Trigger, mock.WithArgs("list")), wantErr: false, wantEmbed: true},
+		{name: "cooldown-bad", set: s, in: mock.NewMessage(gb.Trigger, mock.WithArgs("cooldown", "-1"), mock.WithModerator()), wantErr: false, wantEmbed: true},
+		{name: "cooldown", set: s, in: mock.NewMessage(gb.Trigger, mock.WithArgs("cooldown", "0"), mock.WithModerator()), wantErr: false, wantEmbed: false},
+		{name: "off", set: s, in: mock.NewMessage(gb.Trigger, mock.WithArgs("off"), mock.WithModerator()), wantErr: false, wantEmbed: false},
+		{name: "chat-off", set: s, in: mock.NewMessage(gb.None, mock.WithSource(1, 0, "user", "hi all")), wantErr: false, wantText: false},
+		{name: "on", set: s, in: mock.NewMessage(gb.Trigger, mock.WithArgs("on"), mock.WithModerator()), wantErr: false, wantEmbed: false},
+
+		// Chat
+		{name: "chat-text", set: s, in: mock.NewMessage(gb.None, mock.WithSource(1, 0, "user", "hi all")), wantErr: false, wantText: true},
+		{name: "chat-meme", set: s, in: mock.NewMessage(gb.None, mock.WithSource(1, 0, "user", "drive safe")), wantErr: false, wantEmbed: true},
+		{name: "chat-no-match", set: s, in: mock.NewMessage(gb.None, mock.WithSource(1, 0, "user", "nothing")), wantErr: false},
+		{name: "rem-normal", set: s, in: mock.NewMessage(gb.Trigger, mock.WithArgs("remove", "0"), mock.WithModerator()), wantErr: false, wantEmbed: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			i := Interceptor(test.set, ds)
+			err := i(test.in)
+			if (err != nil) != test.wantErr {
+				t.Errorf("err != wantErr (err = %v, wantErr = %v)", err, test.wantErr)
+			}
+
+			if err == nil {
+				if (test.in.Response.Embed != nil) != test.wantEmbed {
+					t.Errorf("embed != wantEmbed (embed == nil: %t)", test.in.Response.Embed == nil)
+				}
+
+				if (test.in.Response.Text != "") != test.wantText {
+					t.Errorf("text != wantText (text = %q)", test.in.Response.Text)
+				}
+			}
+		})
+	}
+}
+
+/*
+Test Cases:
+- a meme trigger keeps replying with its meme after an earlier meme is removed from the stash
+*/
+func TestInterceptor_MemeReplies(t *testing.T) {
+	_ = os.Mkdir("trigger_test", 0755)
+	defer os.RemoveAll("trigger_test")
+
+	s := NewSet("trigger_test")
+	s.Cooldown = 0
+	ss := meme.NewStashes("trigger_test")
+	ds := ss.Get(0)
+	i := Interceptor(s, ss)
+
+	want := ds.Memes[1].Meme
+	if err := i(mock.NewMessage(gb.Trigger, mock.WithArgs("add", "box", "1"), mock.WithModerator())); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+
+	// removing meme 0 shifts the others down
+	if err := ds.Remove(ds.Memes[0]); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+
+	msg := mock.NewMessage(gb.None, mock.WithSource(1, 0, "user", "box box"))
+	if err := i(msg); err != nil {
+		t.Fatalf("reply: %v", err)
+	}
+	if msg.Response.Embed == nil || msg.Response.Embed.Title != want {
+		t.Errorf("replied with %+v, want %q", msg.Response.Embed, want)
+	}
+}
+
+/*
+Test Cases:
+- a guild's triggers don't fire, list or get removed in another guild
+- a guild's cooldown doesn't change another's
+*/
+func TestInterceptor_Guilds(t *testing.T) {
+	_ = os.Mkdir("trigger_test", 0755)
+	defer os.RemoveAll("trigger_test")
+
+	s := NewSet("trigger_test")
+	s.Cooldown = 0
+	i := Interceptor(s, nil)
+
+	run := func(c gb.Command, guild gb.Snowflake, content string, args ...string) *gb.Message {
+		msg := mock.NewMessage(c, mock.WithSource(1, guild*10, "user", content), mock.WithArgs(args...), mock.WithGuild(guild), mock.WithModerator())
+		if err := i(msg); err != nil {
+			t.Fatalf("%v %v: %v", c, args, err)
+		}
+		return msg
+	}
+
+	run(gb.Trigger, 1, "", "add", "hi", "hello")
+	run(gb.Trigger, 1, "", "cooldown", "1h")
+
+	if msg := run(gb.None, 2, "hi all", ""); msg.Response.Text != "" {
+		t.Errorf("guild 1's trigger fired in guild 2: %q", msg.Response.Text)
+	}
+	if msg := run(gb.None, 1, "hi all", ""); msg.Response.Text != "hello" {
+		t.Errorf("guild 1's trigger didn't fire in guild 1: %q", msg.Response.Text)
+	}
+	if msg := run(gb.Trigger, 2, "", "list"); len(msg.Response.Embed.Fields) != 0 {
+		t.Errorf("guild 2 lists guild 1's triggers: %+v", msg.Response.Embed.Fields)
+	}
+	if msg := run(gb.Trigger, 2, "", "remove", "0"); msg.Response.Embed == nil || len(s.Triggers) != 1 {
+		t.Errorf("guild 2 removed guild 1's trigger")
+	}
+	if s.CooldownIn(2) != 0 || s.CooldownIn(1) != time.Hour {
+		t.Errorf("cooldowns: guild 1 %v, guild 2 %v", s.CooldownIn(1), s.CooldownIn(2))
+	}
+}
@@ -0,0 +1,317 @@
+package trigger
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/bwmarrin/discordgo"
+	gb "github.com/ericebersohl/gobottas"
+	"github.com/ericebersohl/gobottas/discord"
+	"github.com/ericebersohl/gobottas/metrics"
+	"io/ioutil"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// The default time that must pass in a channel before another trigger can fire there
+const DefaultCooldown = 60 * time.Second
+
+// A pattern that Gobottas watches for in normal chat, along with the reply it sends
+type Trigger struct {
+	Id      int          `json:"id"`
+	GuildId gb.Snowflake `json:"guild"`          // the guild the trigger fires in
+	Pattern string       `json:"pattern"`        // keyword, or regular expression if Regex is set
+	Regex   bool         `json:"regex"`          // whether Pattern is a regular expression
+	Meme    string       `json:"meme,omitempty"` // text of the meme in the stash to reply with
+	Text    string       `json:"text,omitempty"` // reply text, used when Meme is empty
+	Added   time.Time    `json:"added"`
+	AddedBy string       `json:"added-by"`
+
+	re *regexp.Regexp // compiled Pattern, only set for regex triggers
+}
+
+// Create a new trigger in a guild from user input.  Patterns wrapped in slashes (/like this/) are treated as regular
+// expressions, anything else is a case-insensitive keyword that must appear as whole words
+func NewTrigger(guild gb.Snowflake, pattern, user string) (*Trigger, error) {
+	t := Trigger{
+		GuildId: guild,
+		Pattern: pattern,
+		Added:   time.Now(),
+		AddedBy: user,
+	}
+
+	if len(pattern) > 2 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+		t.Pattern = pattern[1 : len(pattern)-1]
+		t.Regex = true
+	} else {
+		t.Pattern = strings.ToLower(pattern)
+	}
+
+	if err := t.compile(); err != nil {
+		return nil, err
+	}
+
+	return &t, nil
+}
+
+// compile the regular expression of a regex trigger; keyword triggers need no preparation
+func (t *Trigger) compile() error {
+	if !t.Regex {
+		return nil
+	}
+
+	re, err := regexp.Compile(t.Pattern)
+	if err != nil {
+		return discord.NewError("Invalid Pattern", fmt.Sprintf("Could not compile the regular expression: %v", err))
+	}
+
+	t.re = re
+	return nil
+}
+
+// Report whether the trigger matches the message content; lower is the lower-cased content, computed once per message
+func (t *Trigger) Matches(content, lower string) bool {
+	if t.Regex {
+		return t.re != nil && t.re.MatchString(content)
+	}
+	return containsWord(lower, t.Pattern)
+}
+
+// Report whether the keyword appears in s without being part of a longer word, so "hi" doesn't match "nothing"
+func containsWord(s, keyword string) bool {
+	for i := 0; i <= len(s)-len(keyword); {
+		j := strings.Index(s[i:], keyword)
+		if j < 0 {
+			return false
+		}
+
+		start, end := i+j, i+j+len(keyword)
+		before, _ := utf8.DecodeLastRuneInString(s[:start])
+		after, _ := utf8.DecodeRuneInString(s[end:])
+		if !isWordRune(before) && !isWordRune(after) {
+			return true
+		}
+
+		// keep looking after the start of this occurrence
+		_, size := utf8.DecodeRuneInString(s[start:])
+		i = start + size
+	}
+	return false
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// Human readable form of the pattern, as shown by the list command
+func (t *Trigger) String() string {
+	if t.Regex {
+		return fmt.Sprintf("/%s/", t.Pattern)
+	}
+	return fmt.Sprintf("%q", t.Pattern)
+}
+
+// All triggers known to Gobottas along with the per-channel switches and cooldowns.  Each guild has its own triggers
+// and cooldown.
+type Set struct {
+	Triggers  []*Trigger                     `json:"triggers"`
+	Cooldown  time.Duration                  `json:"cooldown"`            // default minimum time between replies in a channel
+	Cooldowns map[gb.Snowflake]time.Duration `json:"cooldowns,omitempty"` // guilds' own cooldowns
+	Disabled  map[gb.Snowflake]bool          `json:"disabled"`            // channels in which triggers have been switched off
+	NextId    int                            `json:"next-id"`
+	LocalPath string                         `json:"path"` // path to local backup
+
+	fired map[gb.Snowflake]time.Time // last time a trigger fired in each channel
+	now   func() time.Time
+}
+
+// Create an empty set of triggers
+func NewSet(localPath string) *Set {
+	s := Set{
+		Triggers:  make([]*Trigger, 0),
+		Cooldown:  DefaultCooldown,
+		Disabled:  make(map[gb.Snowflake]bool),
+		LocalPath: localPath,
+		fired:     make(map[gb.Snowflake]time.Time),
+		now:       time.Now,
+	}
+	return &s
+}
+
+// Add a trigger to the set, assigning it an id.  Patterns only need to be unique within the trigger's guild.
+func (s *Set) Add(t *Trigger) error {
+	if t == nil {
+		return discord.NewError("Nil Trigger", "Cannot add a nil trigger.")
+	}
+
+	if t.Pattern == "" {
+		return discord.NewError("Empty Pattern", "Cannot add a trigger with an empty pattern.")
+	}
+
+	for _, e := range s.Triggers {
+		if e.GuildId == t.GuildId && e.Pattern == t.Pattern && e.Regex == t.Regex {
+			return discord.NewError("Duplicate Trigger", fmt.Sprintf("Trigger %d already uses that pattern.", e.Id))
+		}
+	}
+
+	t.Id = s.NextId
+	s.NextId++
+	s.Triggers = append(s.Triggers, t)
+	return nil
+}
+
+// The guild's trigger with the given id, or nil
+func (s *Set) Find(guild gb.Snowflake, id int) *Trigger {
+	for _, t := range s.Triggers {
+		if t.Id == id && t.GuildId == guild {
+			return t
+		}
+	}
+	return nil
+}
+
+// Remove the guild's trigger with the given id
+func (s *Set) Remove(guild gb.Snowflake, id int) error {
+	for i, t := range s.Triggers {
+		if t.Id == id && t.GuildId == guild {
+			s.Triggers = append(s.Triggers[:i], s.Triggers[i+1:]...)
+			return nil
+		}
+	}
+	return discord.NewError("Trigger Not Found", "Could not find a trigger with that id.")
+}
+
+// The minimum time between replies in a guild's channels
+func (s *Set) CooldownIn(guild gb.Snowflake) time.Duration {
+	if d, ok := s.Cooldowns[guild]; ok {
+		return d
+	}
+	return s.Cooldown
+}
+
+// Set the minimum time between replies in a guild's channels
+func (s *Set) SetCooldown(guild gb.Snowflake, d time.Duration) {
+	if s.Cooldowns == nil {
+		s.Cooldowns = make(map[gb.Snowflake]time.Duration)
+	}
+	s.Cooldowns[guild] = d
+}
+
+// Switch triggers on or off for a channel
+func (s *Set) Enable(channel gb.Snowflake, on bool) {
+	if s.Disabled == nil {
+		s.Disabled = make(map[gb.Snowflake]bool)
+	}
+
+	if on {
+		delete(s.Disabled, channel)
+	} else {
+		s.Disabled[channel] = true
+	}
+}
+
+// Find the first of a guild's triggers matching the content of a message in the channel.  Returns nil if triggers are
+// off in the channel, the channel is cooling down, or nothing matches.  A match starts the channel's cooldown.
+func (s *Set) Match(guild, channel gb.Snowflake, content string) *Trigger {
+	if len(s.Triggers) == 0 || s.Disabled[channel] {
+		return nil
+	}
+
+	if s.fired == nil {
+		s.fired = make(map[gb.Snowflake]time.Time)
+	}
+	if s.now == nil {
+		s.now = time.Now
+	}
+
+	now := s.now()
+	if last, ok := s.fired[channel]; ok && now.Sub(last) < s.CooldownIn(guild) {
+		return nil
+	}
+
+	lower := strings.ToLower(content)
+	for _, t := range s.Triggers {
+		if t.GuildId == guild && t.Matches(content, lower) {
+			s.fired[channel] = now
+			return t
+		}
+	}
+
+	return nil
+}
+
+// Save the triggers to trigger.json in the specified path
+func (s *Set) Save(path string) error {
+	if s == nil {
+		return fmt.Errorf("cannot save a nil trigger set")
+	}
+
+	data, err := json.Marshal(s)
+	if err != nil {
+		metrics.PersistenceErrors.Inc("trigger", "save")
+		return err
+	}
+
+	err = ioutil.WriteFile(fmt.Sprintf("%s/trigger.json", path), data, 0644)
+	if err != nil {
+		metrics.PersistenceErrors.Inc("trigger", "save")
+		return err
+	}
+
+	return nil
+}
+
+// Load triggers from the trigger.json file in the specified path
+func (s *Set) Load(path string) error {
+	data, err := ioutil.ReadFile(fmt.Sprintf("%s/trigger.json", path))
+	if err != nil {
+		metrics.PersistenceErrors.Inc("trigger", "load")
+		return err
+	}
+
+	err = json.Unmarshal(data, s)
+	if err != nil {
+		metrics.PersistenceErrors.Inc("trigger", "load")
+		return err
+	}
+
+	// regular expressions aren't stored, so compile them again
+	for _, t := range s.Triggers {
+		if err := t.compile(); err != nil {
+			metrics.PersistenceErrors.Inc("trigger", "load")
+			return fmt.Errorf("trigger %d: %w", t.Id, err)
+		}
+	}
+
+	return nil
+}
+
+// Build the list embed of a guild's triggers for the trigger command
+func (s *Set) Embed(guild, channel gb.Snowflake) *discordgo.MessageEmbed {
+	state := "on"
+	if s.Disabled[channel] {
+		state = "off"
+	}
+
+	e := discord.NewEmbed().
+		EmbedColor(gb.MemeCol).
+		EmbedTitle("Triggers").
+		EmbedDescription(fmt.Sprintf("Triggers are %s in this channel (cooldown %v).", state, s.CooldownIn(guild))).
+		EmbedTimestamp(time.Now())
+
+	for _, t := range s.Triggers {
+		if t.GuildId != guild {
+			continue
+		}
+
+		reply := t.Text
+		if t.Meme != "" {
+			reply = "meme: " + t.Meme
+		}
+		e = e.AddField(fmt.Sprintf("%d: %s", t.Id, t.String()), reply, false)
+	}
+
+	return e.MessageEmbed
+}
@@ -0,0 +1,169 @@
+package trigger
+
+import (
+	gb "github.com/ericebersohl/gobottas"
+	"os"
+	"testing"
+	"time"
+)
+
+/*
+Test Cases:
+- keyword, regex, bad regex, slash only
+*/
+func TestNewTrigger(t *testing.T) {
+	tests := []struct {
+		name      string
+		in        string
+		wantRegex bool
+		wantPat   string
+		wantErr   bool
+	}{
+		{name: "keyword", in: "Hello There", wantRegex: false, wantPat: "hello there", wantErr: false},
+		{name: "regex", in: `/gen(eral)? kenobi/`, wantRegex: true, wantPat: `gen(eral)? kenobi`, wantErr: false},
+		{name: "bad-regex", in: `/(unclosed/`, wantErr: true},
+		{name: "slashes-only", in: "//", wantRegex: false, wantPat: "//", wantErr: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := NewTrigger(1, test.in, "user")
+			if (err != nil) != test.wantErr {
+				t.Fatalf("err != wantErr (err = %v, wantErr = %v)", err, test.wantErr)
+			}
+
+			if err == nil {
+				if got.Regex != test.wantRegex || got.Pattern != test.wantPat {
+					t.Errorf("got (%t, %q), want (%t, %q)", got.Regex, got.Pattern, test.wantRegex, test.wantPat)
+				}
+			}
+		})
+	}
+}
+
+/*
+Test Cases:
+- nil, empty pattern, normal, duplicate, same pattern in another guild
+- remove: not found, another guild's, normal
+*/
+func TestSet_AddRemove(t *testing.T) {
+	s := NewSet("trigger_test")
+
+	if err := s.Add(nil); err == nil {
+		t.Errorf("no error adding nil trigger")
+	}
+
+	if err := s.Add(&Trigger{}); err == nil {
+		t.Errorf("no error adding empty pattern")
+	}
+
+	a, _ := NewTrigger(1, "hello", "user")
+	if err := s.Add(a); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	b, _ := NewTrigger(1, "HELLO", "user")
+	if err := s.Add(b); err == nil {
+		t.Errorf("no error adding duplicate pattern")
+	}
+
+	c, _ := NewTrigger(2, "hello", "user")
+	if err := s.Add(c); err != nil {
+		t.Errorf("error adding the same pattern in another guild: %v", err)
+	}
+
+	if err := s.Remove(1, 5); err == nil {
+		t.Errorf("no error removing missing trigger")
+	}
+
+	if err := s.Remove(2, a.Id); err == nil {
+		t.Errorf("no error removing another guild's trigger")
+	}
+
+	if err := s.Remove(1, a.Id); err != nil || len(s.Triggers) != 1 {
+		t.Errorf("remove failed (err = %v, len = %d)", err, len(s.Triggers))
+	}
+}
+
+/*
+Test Cases:
+- no match, keyword match, cooldown, after cooldown, regex match, disabled channel, other channel
+- another guild's trigger, a guild's own cooldown
+*/
+func TestSet_Match(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	s := NewSet("trigger_test")
+	s.Cooldown = time.Minute
+	s.now = func() time.Time { return now }
+
+	kw, _ := NewTrigger(1, "hello there", "user")
+	re, _ := NewTrigger(1, `/^\d{3}$/`, "user")
+	hi, _ := NewTrigger(3, "hi", "user")
+	_ = s.Add(kw)
+	_ = s.Add(re)
+	_ = s.Add(hi)
+	s.SetCooldown(3, time.Hour)
+
+	tests := []struct {
+		name    string
+		advance time.Duration
+		guild   gb.Snowflake
+		channel gb.Snowflake
+		content string
+		want    *Trigger
+	}{
+		{name: "no-match", guild: 1, channel: 1, content: "nothing to see", want: nil},
+		{name: "keyword", guild: 1, channel: 1, content: "Well HELLO THERE!", want: kw},
+		{name: "cooldown", advance: 30 * time.Second, guild: 1, channel: 1, content: "hello there", want: nil},
+		{name: "other-channel", guild: 1, channel: 2, content: "hello there", want: kw},
+		{name: "after-cooldown", advance: 31 * time.Second, guild: 1, channel: 1, content: "123", want: re},
+		{name: "disabled", advance: time.Hour, guild: 1, channel: 3, content: "hello there", want: nil},
+		{name: "other-guild", guild: 2, channel: 4, content: "hello there", want: nil},
+		{name: "guild-cooldown", guild: 3, channel: 5, content: "hi", want: hi},
+		{name: "guild-cooldown-again", advance: 2 * time.Minute, guild: 3, channel: 5, content: "hi", want: nil},
+	}
+
+	s.Enable(3, false)
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			now = now.Add(test.advance)
+			got := s.Match(test.guild, test.channel, test.content)
+			if got != test.want {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+/*
+Test Cases:
+- save then load keeps triggers and compiles regexes
+*/
+func TestSet_SaveLoad(t *testing.T) {
+	_ = os.Mkdir("trigger_test", 0755)
+	defer os.RemoveAll("trigger_test")
+
+	s := NewSet("trigger_test")
+	re, _ := NewTrigger(1, `/^ping$/`, "user")
+	_ = s.Add(re)
+	s.Enable(7, false)
+
+	if err := s.Save("trigger_test"); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	l := NewSet("")
+	if err := l.Load("trigger_test"); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	if len(l.Triggers) != 1 || !l.Disabled[7] || l.NextId != 1 {
+		t.Fatalf("loaded set differs: %+v", l)
+	}
+
+	if l.Match(1, 1, "ping") == nil {
+		t.Errorf("loaded regex trigger did not match")
+	}
+}
@@ -0,0 +1,228 @@
+// Package webhook posts queue and meme events to other services, like a wiki or a calendar, so that they don't have to
+// poll.  Each hook gets the events it asks for as signed JSON, on its own goroutine, and deliveries that fail for a
+// moment are retried with backoff.
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	gb "github.com/ericebersohl/gobottas"
+	"github.com/ericebersohl/gobottas/event"
+	"github.com/ericebersohl/gobottas/metrics"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Defaults for deliveries
+const (
+	DefaultRetries = 5
+	DefaultBackoff = time.Second // doubled after each failed attempt
+	MaxBackoff     = 5 * time.Minute
+	DefaultTimeout = 10 * time.Second // for each attempt
+)
+
+// Headers sent with every delivery
+const (
+	HeaderEvent     = "X-Gobottas-Event"     // the kind of event
+	HeaderDelivery  = "X-Gobottas-Delivery"  // the id of the delivery, the same for each attempt
+	HeaderSignature = "X-Gobottas-Signature" // "sha256=" and the hex HMAC of the body, keyed with the hook's secret
+)
+
+// The kinds of event hooks can ask for
+var Kinds = []string{event.KindTopicAdded, event.KindTopicCompleted, event.KindTopicRemoved, event.KindMemeAdded}
+
+// Where to post events, and which ones
+type Hook struct {
+	Name   string   // for logs and metrics
+	URL    string   // http or https
+	Secret string   // key for signing payloads
+	Kinds  []string // events to post; empty for every one in Kinds
+}
+
+// What a hook is posted
+type Payload struct {
+	Id    string      `json:"id"` // the delivery id, also in HeaderDelivery
+	Kind  string      `json:"kind"`
+	Time  time.Time   `json:"time"` // when the delivery was first attempted
+	Event interface{} `json:"event"`
+}
+
+// Posts events to hooks
+type Sender struct {
+	hooks  []Hook
+	client *http.Client
+	clock  gb.Clock
+	log    *gb.Logger
+
+	retries int
+	backoff time.Duration
+}
+
+type Opt func(*Sender)
+
+func New(hooks []Hook, opts ...Opt) *Sender {
+	s := Sender{
+		hooks:   hooks,
+		client:  &http.Client{Timeout: DefaultTimeout},
+		clock:   gb.SystemClock{},
+		retries: DefaultRetries,
+		backoff: DefaultBackoff,
+	}
+
+	for _, o := range opts {
+		o(&s)
+	}
+
+	return &s
+}
+
+func WithClient(c *http.Client) Opt {
+	return func(s *Sender) {
+		s.client = c
+	}
+}
+
+func WithClock(c gb.Clock) Opt {
+	return func(s *Sender) {
+		s.clock = c
+	}
+}
+
+func WithLogger(l *gb.Logger) Opt {
+	return func(s *Sender) {
+		s.log = l
+	}
+}
+
+// retry failed deliveries up to n times, waiting backoff before the first retry and twice as long before each one after
+func WithRetries(n int, backoff time.Duration) Opt {
+	return func(s *Sender) {
+		s.retries = n
+		s.backoff = backoff
+	}
+}
+
+// Subscribe every hook to the events it wants from b.  Each hook has its own buffer, so one that is slow or down
+// doesn't hold up the others.  Returns a function that unsubscribes them all.
+func (s *Sender) Subscribe(b *event.Bus) func() {
+	var stops []func()
+	for _, h := range s.hooks {
+		h := h
+
+		kinds := h.Kinds
+		if len(kinds) == 0 {
+			kinds = Kinds
+		}
+
+		stop := b.Subscribe("webhook:"+h.Name, func(e gb.Event) {
+			// failures are logged and counted
+			_ = s.Deliver(h, e)
+		}, event.Kinds(kinds...), event.Async(event.DefaultBuffer))
+		stops = append(stops, stop)
+	}
+
+	return func() {
+		for _, stop := range stops {
+			stop()
+		}
+	}
+}
+
+// Post an event to a hook until it is accepted, refused, or out of retries
+func (s *Sender) Deliver(h Hook, e gb.Event) error {
+	p := Payload{Id: gb.NewCorrelationId(), Kind: e.Kind(), Time: s.clock.Now(), Event: e}
+	body, err := json.Marshal(p)
+	if err != nil {
+		s.log.Error("failed to encode a webhook payload", "hook", h.Name, "kind", p.Kind, "err", err)
+		metrics.WebhookDeliveries.Inc(h.Name, "failed")
+		return err
+	}
+
+	attempt := 0
+	for {
+		attempt++
+		var delay time.Duration
+		var retry bool
+		if delay, retry, err = s.post(h, p, body); err == nil {
+			metrics.WebhookDeliveries.Inc(h.Name, "delivered")
+			s.log.Debug("webhook delivered", "hook", h.Name, "kind", p.Kind, "delivery", p.Id, "attempt", attempt)
+			return nil
+		}
+
+		if !retry || attempt > s.retries {
+			break
+		}
+
+		if d := s.backoff << uint(attempt-1); d > delay {
+			delay = d
+		}
+		if delay > MaxBackoff || delay <= 0 {
+			delay = MaxBackoff
+		}
+
+		metrics.WebhookRetries.Inc(h.Name)
+		s.log.Warn("webhook failed; retrying", "hook", h.Name, "kind", p.Kind, "delivery", p.Id, "attempt", attempt, "delay", delay, "err", err)
+		<-s.clock.After(delay)
+	}
+
+	metrics.WebhookDeliveries.Inc(h.Name, "failed")
+	s.log.Error("webhook failed", "hook", h.Name, "kind", p.Kind, "delivery", p.Id, "attempts", attempt, "err", err)
+	return err
+}
+
+// Make one attempt at a delivery.  Returns whether a failure is worth retrying, and how long the hook asked to wait
+// first, if it did.  Network errors, rate limits and server errors are retried; anything else would fail again.
+func (s *Sender) post(h Hook, p Payload, body []byte) (time.Duration, bool, error) {
+	req, err := http.NewRequest(http.MethodPost, h.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, false, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "gobottas-webhook")
+	req.Header.Set(HeaderEvent, p.Kind)
+	req.Header.Set(HeaderDelivery, p.Id)
+	req.Header.Set(HeaderSignature, Sign(h.Secret, body))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, true, err
+	}
+
+	// drain the body so the connection can be reused
+	_, _ = io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 64<<10))
+	_ = resp.Body.Close()
+
+	switch code := resp.StatusCode; {
+	case code >= 200 && code < 300:
+		return 0, false, nil
+	case code == http.StatusTooManyRequests:
+		var wait time.Duration
+		if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+			wait = time.Duration(secs) * time.Second
+		}
+		return wait, true, fmt.Errorf("webhook: %s", resp.Status)
+	case code >= 500:
+		return 0, true, fmt.Errorf("webhook: %s", resp.Status)
+	}
+	return 0, false, fmt.Errorf("webhook: %s", resp.Status)
+}
+
+// The signature of a body, as sent in HeaderSignature
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	_, _ = mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Whether signature is the one Sign gives for the body; for services receiving deliveries
+func Verify(secret string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, body)), []byte(signature))
+}
@@ -0,0 +1,203 @@
+package webhook
+
+import (
+	"encoding/json"
+	"github.com/ericebersohl/gobottas/event"
+	"github.com/ericebersohl/gobottas/metrics"
+	"github.com/ericebersohl/gobottas/mock"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// A receiving service that answers each delivery with the next status, then 200s, and keeps what it was sent
+type receiver struct {
+	mu       sync.Mutex
+	statuses []int
+	retry    string // Retry-After sent with a 429
+	got      []*http.Request
+	bodies   [][]byte
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := ioutil.ReadAll(req.Body)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.got = append(r.got, req)
+	r.bodies = append(r.bodies, body)
+
+	status := http.StatusOK
+	if len(r.statuses) > 0 {
+		status, r.statuses = r.statuses[0], r.statuses[1:]
+	}
+	if status == http.StatusTooManyRequests {
+		w.Header().Set("Retry-After", r.retry)
+	}
+	w.WriteHeader(status)
+}
+
+func (r *receiver) calls() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.got)
+}
+
+// call f, moving the clock forward in small steps whenever it waits, and return how much time passed
+func run(t *testing.T, clock *mock.Clock, f func()) time.Duration {
+	start := clock.Now()
+	done := make(chan struct{})
+	go func() {
+		f()
+		close(done)
+	}()
+
+	for {
+		select {
+		case <-done:
+			return clock.Now().Sub(start)
+		default:
+		}
+
+		if clock.Waiting() > 0 {
+			clock.Advance(100 * time.Millisecond)
+		}
+
+		if clock.Now().Sub(start) > time.Hour {
+			t.Fatalf("delivery never finished")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+/*
+Test Cases:
+- delivered: posted once, signed, with the event in the payload
+- server errors: retried with doubling backoff, under the same delivery id
+- rate limited: retried after the hook's Retry-After
+- refused: not retried
+- out of retries: given up after every attempt failed
+*/
+func TestSender_Deliver(t *testing.T) {
+	tests := []struct {
+		name      string
+		statuses  []int
+		wantErr   bool
+		wantCalls int
+		wantWait  time.Duration
+	}{
+		{name: "delivered", wantCalls: 1},
+		{name: "server-errors", statuses: []int{500, 503}, wantCalls: 3, wantWait: 3 * time.Second},
+		{name: "rate-limited", statuses: []int{429}, wantCalls: 2, wantWait: 5 * time.Second},
+		{name: "refused", statuses: []int{404}, wantErr: true, wantCalls: 1},
+		{name: "out-of-retries", statuses: []int{500, 500, 500, 500}, wantErr: true, wantCalls: 3, wantWait: 3 * time.Second},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := &receiver{statuses: test.statuses, retry: "5"}
+			srv := httptest.NewServer(r)
+			defer srv.Close()
+
+			clock := mock.NewClock(time.Date(2020, 1, 2, 15, 4, 5, 0, time.UTC))
+			h := Hook{Name: test.name, URL: srv.URL, Secret: "shh"}
+			s := New([]Hook{h}, WithClient(srv.Client()), WithClock(clock), WithRetries(2, time.Second))
+
+			failed := metrics.WebhookDeliveries.Value(test.name, "failed")
+			e := &event.TopicAdded{Base: event.Base{GuildId: 1, UserId: 2, Username: "user"}, Topic: "Racing Lines"}
+
+			var err error
+			wait := run(t, clock, func() { err = s.Deliver(h, e) })
+
+			if (err != nil) != test.wantErr {
+				t.Errorf("err != wantErr (err = %v, wantErr = %v)", err, test.wantErr)
+			}
+			if r.calls() != test.wantCalls {
+				t.Errorf("posted %d times, want %d", r.calls(), test.wantCalls)
+			}
+			if wait != test.wantWait {
+				t.Errorf("waited %s, want %s", wait, test.wantWait)
+			}
+			if test.wantErr && metrics.WebhookDeliveries.Value(test.name, "failed") != failed+1 {
+				t.Errorf("failure not counted")
+			}
+
+			id := r.got[0].Header.Get(HeaderDelivery)
+			for i, req := range r.got {
+				if req.Header.Get(HeaderDelivery) != id {
+					t.Errorf("attempt %d has delivery id %q, want %q", i+1, req.Header.Get(HeaderDelivery), id)
+				}
+				if !Verify("shh", r.bodies[i], req.Header.Get(HeaderSignature)) {
+					t.Errorf("attempt %d not signed", i+1)
+				}
+			}
+
+			var p struct {
+				Id    string
+				Kind  string
+				Event event.TopicAdded
+			}
+			if err := json.Unmarshal(r.bodies[0], &p); err != nil {
+				t.Fatalf("payload: %v", err)
+			}
+			if p.Id != id || p.Kind != event.KindTopicAdded || r.got[0].Header.Get(HeaderEvent) != p.Kind || p.Event.Topic != "Racing Lines" || p.Event.GuildId != 1 || p.Event.Username != "user" {
+				t.Errorf("payload = %s", r.bodies[0])
+			}
+		})
+	}
+}
+
+/*
+Test Cases:
+- hooks get the kinds they ask for, or every kind they can have
+- events hooks can't have aren't posted
+*/
+func TestSender_Subscribe(t *testing.T) {
+	all := &receiver{}
+	allSrv := httptest.NewServer(all)
+	defer allSrv.Close()
+
+	topics := &receiver{}
+	topicsSrv := httptest.NewServer(topics)
+	defer topicsSrv.Close()
+
+	b := event.New()
+	s := New([]Hook{
+		{Name: "all", URL: allSrv.URL, Secret: "a"},
+		{Name: "topics", URL: topicsSrv.URL, Secret: "b", Kinds: []string{event.KindTopicCompleted}},
+	})
+	s.Subscribe(b)
+
+	b.Publish(&event.TopicAdded{Topic: "x"})
+	b.Publish(&event.TopicCompleted{Topic: "x"})
+	b.Publish(&event.MemeAdded{Meme: "y"})
+	b.Publish(&event.CommandFailed{Command: "Queue"})
+	b.Close()
+
+	if all.calls() != 3 {
+		t.Errorf("all got %d events, want 3", all.calls())
+	}
+	if topics.calls() != 1 || topics.got[0].Header.Get(HeaderEvent) != event.KindTopicCompleted {
+		t.Errorf("topics got %d events, want the completed topic", topics.calls())
+	}
+}
+
+/*
+Test Cases:
+- a signature checks out with its secret and body only
+*/
+func TestVerify(t *testing.T) {
+	body := []byte(`{"kind":"topic.added"}`)
+	sig := Sign("shh", body)
+
+	if !Verify("shh", body, sig) {
+		t.Errorf("signature not verified")
+	}
+	if Verify("other", body, sig) || Verify("shh", []byte(`{"kind":"meme.added"}`), sig) {
+		t.Errorf("signature verified with the wrong secret or body")
+	}
+}